@@ -0,0 +1,158 @@
+package backtest
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	. "tinyquant/src/logger"
+	"tinyquant/src/mod"
+	"tinyquant/src/quant/paper"
+	"tinyquant/src/strategy"
+	"tinyquant/src/util"
+)
+
+// 用历史K线回放插针策略
+// 回测期间会关闭 util.PlaceTest 和 util.MsgEnable,同一进程内不能同时运行实盘或多个回测
+type Backtest struct {
+	Symbol   string
+	Balance  float64          // 初始资金
+	Param    *util.QuantParam // 策略参数
+	Exchange *paper.Exchange
+	Strategy *strategy.Strategy
+}
+
+type Report struct {
+	Start       time.Time
+	End         time.Time
+	Bars        int     // 回放K线数量,不含预热
+	InitBalance float64 // 初始资金
+	Equity      float64 // 结束时权益
+	PnL         float64 // 总盈亏,含未实现盈亏和手续费
+	Realized    float64 // 已实现盈亏,不含手续费
+	Fees        float64 // 手续费
+	MaxDrawdown float64 // 最大回撤比例
+	Fills       int     // 成交次数
+	Trades      int     // 平仓次数
+	Wins        int     // 盈利的平仓次数
+	WinRate     float64 // 胜率
+}
+
+func New(symbol string, balance float64) *Backtest {
+	return &Backtest{
+		Symbol:   symbol,
+		Balance:  balance,
+		Param:    util.LoadQuantParam(symbol),
+		Exchange: paper.New(nil, balance),
+	}
+}
+
+func (b *Backtest) Run(klines []*mod.Kline) (*Report, error) {
+	b.Strategy = &strategy.Strategy{RWMutex: &sync.RWMutex{}, Exchange: b.Exchange, Param: b.Param, Sync: true}
+	b.Strategy.InitState(b.Symbol)
+	if err := b.Strategy.InitSignals(); err != nil {
+		return nil, err
+	}
+
+	queue := b.Strategy.KlineManager.MinuteKlineList
+	if len(klines) <= queue.Capacity {
+		return nil, fmt.Errorf("need more than %d klines, got %d", queue.Capacity, len(klines))
+	}
+
+	msgEnable, placeTest := util.MsgEnable, util.PlaceTest
+	util.MsgEnable, util.PlaceTest = false, false
+	defer func() {
+		util.MsgEnable, util.PlaceTest = msgEnable, placeTest
+	}()
+
+	b.Strategy.PlaceOrderManager.Clock = b.Exchange.Now
+	b.Strategy.PlaceOrderManager.Account = &strategy.BinanceFutureAsset{
+		RWMutex:          &sync.RWMutex{},
+		Symbol:           b.Symbol,
+		Exchange:         b.Exchange,
+		Balance:          b.Balance,
+		AvailableBalance: b.Balance,
+	}
+	b.Strategy.LoadPosition()
+
+	//用前面的K线预热均值
+	warmup := klines[:queue.Capacity]
+	for _, k := range warmup {
+		b.Exchange.Match(b.Symbol, k)
+		queue.EnQqueu(&strategy.Kline{
+			Open:      k.Open,
+			Close:     k.Close,
+			High:      k.High,
+			Low:       k.Low,
+			Volume:    k.Volume,
+			CloseTime: k.CloseTime,
+			BuyVolume: k.BuyVolume,
+		})
+	}
+	queue.UpdateUpDownLink(true)
+
+	report := &Report{
+		Start:       klines[queue.Capacity].StartTime,
+		End:         klines[len(klines)-1].CloseTime,
+		InitBalance: b.Balance,
+	}
+	peak := b.Balance
+	for _, k := range klines[queue.Capacity:] {
+		//先撮合上一根K线结束时的挂单,再把这根K线推给策略
+		b.Exchange.Match(b.Symbol, k)
+		b.dispatch()
+		b.Strategy.OnKline(k)
+		b.dispatch()
+
+		equity := b.Exchange.Equity()
+		if equity > peak {
+			peak = equity
+		}
+		if peak > 0 && (peak-equity)/peak > report.MaxDrawdown {
+			report.MaxDrawdown = (peak - equity) / peak
+		}
+		report.Bars++
+	}
+
+	report.Equity = b.Exchange.Equity()
+	report.PnL = report.Equity - b.Balance
+	report.Realized = b.Exchange.Realized
+	report.Fees = b.Exchange.Fees
+	report.Fills = b.Exchange.Fills
+	report.Trades = b.Exchange.Trades
+	report.Wins = b.Exchange.Wins
+	if report.Trades > 0 {
+		report.WinRate = float64(report.Wins) / float64(report.Trades)
+	}
+	Logger.Sugar().Infof("回测结束 %+v", report)
+	return report, nil
+}
+
+// 把撮合产生的账户事件依次交给策略,策略处理时新下的单也会产生事件
+func (b *Backtest) dispatch() {
+	for {
+		events := b.Exchange.PopEvents()
+		if len(events) == 0 {
+			return
+		}
+		for _, ev := range events {
+			b.Strategy.HandleAccountEvent(ev)
+		}
+	}
+}
+
+func (r *Report) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "回测区间 : %s ~ %s (%d 根K线)\n", r.Start.Format("2006-01-02 15:04"), r.End.Format("2006-01-02 15:04"), r.Bars)
+	fmt.Fprintf(&sb, "初始资金 : %.4f\n", r.InitBalance)
+	fmt.Fprintf(&sb, "结束权益 : %.4f\n", r.Equity)
+	fmt.Fprintf(&sb, "总盈亏   : %.4f (%.2f%%)\n", r.PnL, r.PnL/r.InitBalance*100)
+	fmt.Fprintf(&sb, "已实现   : %.4f\n", r.Realized)
+	fmt.Fprintf(&sb, "手续费   : %.4f\n", r.Fees)
+	fmt.Fprintf(&sb, "最大回撤 : %.2f%%\n", r.MaxDrawdown*100)
+	fmt.Fprintf(&sb, "成交次数 : %d\n", r.Fills)
+	fmt.Fprintf(&sb, "平仓次数 : %d\n", r.Trades)
+	fmt.Fprintf(&sb, "胜率     : %.2f%%\n", r.WinRate*100)
+	return sb.String()
+}
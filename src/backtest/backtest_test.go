@@ -0,0 +1,268 @@
+package backtest_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"tinyquant/src/backtest"
+	"tinyquant/src/logger"
+	"tinyquant/src/mod"
+	"tinyquant/src/strategy"
+	"tinyquant/src/util"
+
+	"go.uber.org/zap"
+)
+
+func init() {
+	logger.Logger = zap.NewNop()
+	util.Quantity = 0.01
+	util.Profits = 0.0125
+	util.VolumeIncrease = 5
+	util.VolumeIncreaseForClose = 4
+	util.SpringPrice = 0.0025
+	util.TerracedPrice = []float64{0.002, 0.004, 0.006, 0.008, 0.01}
+	util.IncreaseQuantityLevel = 0.04
+	util.CancelCloseOrderLevel = 0.03
+	util.ContinuousOrderValidityTime = 10
+	util.SupportLevel = 1000
+	util.PressureLevel = 2000
+}
+
+var start = time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func bar(i int, open, high, low, close, volume float64) *mod.Kline {
+	t := start.Add(time.Duration(i) * time.Minute)
+	return &mod.Kline{
+		StartTime: t,
+		CloseTime: t.Add(time.Minute - time.Millisecond),
+		Open:      open,
+		High:      high,
+		Low:       low,
+		Close:     close,
+		Volume:    volume,
+		Final:     true,
+	}
+}
+
+// 60根平稳K线预热,然后一根放量向下插针,随后回落成交开仓单,再反弹成交平仓单
+func pinKlines() []*mod.Kline {
+	var klines []*mod.Kline
+	for i := 0; i < 70; i++ {
+		klines = append(klines, bar(i, 1500, 1501, 1499, 1500, 10))
+	}
+	klines = append(klines,
+		bar(70, 1500, 1500, 1489, 1490, 100),
+		bar(71, 1490, 1491, 1480, 1485, 10),
+		bar(72, 1485, 1495, 1484, 1494, 10),
+		bar(73, 1494, 1510, 1493, 1505, 10),
+		bar(74, 1505, 1506, 1504, 1505, 10),
+	)
+	return klines
+}
+
+func Test_BacktestPin(t *testing.T) {
+	bt := backtest.New(util.ETHUSDT, 1000)
+	report, err := bt.Run(pinKlines())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Bars != 15 {
+		t.Errorf("bars = %d, want 15", report.Bars)
+	}
+	if report.Trades != 1 || report.Wins != 1 || report.WinRate != 1 {
+		t.Fatalf("trades = %d wins = %d winrate = %v, want one winning trade", report.Trades, report.Wins, report.WinRate)
+	}
+	// 开仓价 1490*(1-0.0025) 平仓价 开仓价+开仓价*0.0125
+	entry := util.Round(1490-1490*0.0025, 2)
+	exit := util.Round(entry+entry*0.0125, 2)
+	if want := (exit - entry) * 0.01; math.Abs(report.Realized-want) > 1e-9 {
+		t.Errorf("realized = %v, want %v", report.Realized, want)
+	}
+	if want := (entry + exit) * 0.01 * 0.0002; math.Abs(report.Fees-want) > 1e-9 {
+		t.Errorf("fees = %v, want %v", report.Fees, want)
+	}
+	if math.Abs(report.PnL-(report.Realized-report.Fees)) > 1e-9 {
+		t.Errorf("pnl = %v, want realized - fees", report.PnL)
+	}
+	if report.MaxDrawdown <= 0 {
+		t.Errorf("max drawdown = %v, want > 0", report.MaxDrawdown)
+	}
+}
+
+// 没有配置回调比例时,开仓成交后在支撑位挂固定止损单
+func Test_BacktestFixedStop(t *testing.T) {
+	bt := backtest.New(util.ETHUSDT, 1000)
+	if _, err := bt.Run(pinKlines()[:72]); err != nil {
+		t.Fatal(err)
+	}
+	orders, _ := bt.Exchange.QueryOpenFutureOrders(util.ETHUSDT)
+	var stops []*mod.FutureOrder
+	for _, o := range orders {
+		if o.Type.IsConditional() {
+			stops = append(stops, o)
+		}
+	}
+	if len(stops) != 1 {
+		t.Fatalf("stops = %d, want 1", len(stops))
+	}
+	if o := stops[0]; o.Type != mod.TypeStop || o.PositionSide != mod.LONG || o.Side != mod.SideSell ||
+		o.StopPrice != util.SupportLevel || o.Price != util.SupportLevel-10 {
+		t.Errorf("stop %+v", o)
+	}
+}
+
+// 只统计收到的事件,不下单
+type countSignal struct {
+	strategy.BaseSignal
+	klines, orders int
+}
+
+func (c *countSignal) Name() string { return "count" }
+
+func (c *countSignal) OnKline(interval mod.Interval, k *mod.Kline) { c.klines++ }
+
+func (c *countSignal) OnOrderUpdate(order *mod.OrderUpdate, futureOrder *strategy.MyFutureOrder) {
+	c.orders++
+}
+
+func Test_BacktestSignal(t *testing.T) {
+	if _, err := strategy.NewSignal("unknown"); err == nil {
+		t.Error("expected error for unknown signal")
+	}
+
+	counter := &countSignal{}
+	strategy.RegisterSignal(counter.Name(), func() strategy.Signal { return counter })
+	bt := backtest.New(util.ETHUSDT, 1000)
+	bt.Param.Signals = []string{"pin", counter.Name()}
+	report, err := bt.Run(pinKlines())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bt.Strategy.Signals) != 2 || bt.Strategy.Signals[0].Name() != strategy.PinSignalName {
+		t.Fatalf("signals = %v", bt.Strategy.Signals)
+	}
+	if counter.klines != report.Bars {
+		t.Errorf("klines = %d, want %d", counter.klines, report.Bars)
+	}
+	// 开仓单和平仓单各有新挂单和成交两次推送
+	if counter.orders < 4 {
+		t.Errorf("orders = %d, want >= 4", counter.orders)
+	}
+
+	bt = backtest.New(util.ETHUSDT, 1000)
+	bt.Param.Signals = []string{counter.Name()}
+	if report, err = bt.Run(pinKlines()); err != nil {
+		t.Fatal(err)
+	}
+	if report.Fills != 0 {
+		t.Errorf("fills = %d without pin signal, want 0", report.Fills)
+	}
+}
+
+// 网格 1480~1520 分4格,价格先下探1490再回到1500,然后上探1510再回到1500
+func Test_BacktestGrid(t *testing.T) {
+	var klines []*mod.Kline
+	for i := 0; i < 70; i++ {
+		klines = append(klines, bar(i, 1500, 1501, 1499, 1500, 10))
+	}
+	klines = append(klines,
+		bar(70, 1500, 1500, 1488, 1492, 10),
+		bar(71, 1492, 1503, 1491, 1502, 10),
+		bar(72, 1502, 1512, 1501, 1508, 10),
+		bar(73, 1508, 1509, 1498, 1503, 10),
+	)
+
+	bt := backtest.New(util.ETHUSDT, 1000)
+	bt.Param.Signals = []string{strategy.GridSignalName}
+	bt.Param.Grid = util.GridParam{Lower: 1480, Upper: 1520, Count: 4, Quantity: 0.01}
+	report, err := bt.Run(klines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	grid := bt.Strategy.Signals[0].(*strategy.GridSignal)
+	if report.Trades != 2 || report.Wins != 2 {
+		t.Fatalf("trades = %d wins = %d, want 2 winning trades", report.Trades, report.Wins)
+	}
+	if math.Abs(grid.Profit()-report.Realized) > 1e-9 || math.Abs(grid.Profit()-0.2) > 1e-9 {
+		t.Errorf("grid profit = %v realized = %v, want 0.2", grid.Profit(), report.Realized)
+	}
+	if grid.Levels[1].Trades != 1 || grid.Levels[2].Trades != 1 || grid.Levels[0].Trades != 0 {
+		t.Errorf("level trades = %d %d %d", grid.Levels[0].Trades, grid.Levels[1].Trades, grid.Levels[2].Trades)
+	}
+	// 平仓后重新挂回开仓单,4档开仓单都在
+	if n := len(bt.Strategy.GridFutureOrder); n != 4 {
+		t.Errorf("open grid orders = %d, want 4", n)
+	}
+
+	geo, err := strategy.GridPrices(util.GridParam{Lower: 100, Upper: 400, Count: 2, Geometric: true})
+	if err != nil || math.Abs(geo[1]-200) > 1e-9 {
+		t.Errorf("geometric prices = %v %v", geo, err)
+	}
+	if _, err := strategy.GridPrices(util.GridParam{Lower: 10, Upper: 5, Count: 2}); err == nil {
+		t.Error("expected error for upper below lower")
+	}
+}
+
+// 插针和网格同时启用,网格单成交后插针不能给网格的仓位挂止损单
+func Test_BacktestPinAndGrid(t *testing.T) {
+	var klines []*mod.Kline
+	for i := 0; i < 70; i++ {
+		klines = append(klines, bar(i, 1500, 1501, 1499, 1500, 10))
+	}
+	klines = append(klines,
+		bar(70, 1500, 1500, 1488, 1492, 10),
+		bar(71, 1492, 1503, 1491, 1502, 10),
+	)
+
+	bt := backtest.New(util.ETHUSDT, 1000)
+	bt.Param.Signals = []string{strategy.PinSignalName, strategy.GridSignalName}
+	bt.Param.Grid = util.GridParam{Lower: 1480, Upper: 1520, Count: 4, Quantity: 0.01}
+	report, err := bt.Run(klines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Trades != 1 {
+		t.Fatalf("trades = %d, want 1 grid trade", report.Trades)
+	}
+	if n := len(bt.Strategy.FutureOrder); n != 0 {
+		t.Errorf("non-grid orders = %d, want 0", n)
+	}
+	orders, _ := bt.Exchange.QueryOpenFutureOrders(util.ETHUSDT)
+	for _, o := range orders {
+		if o.Price < 1480 || o.Price > 1520 {
+			t.Errorf("order outside grid %+v", o)
+		}
+	}
+}
+
+func Test_BacktestNeedWarmup(t *testing.T) {
+	if _, err := backtest.New(util.ETHUSDT, 1000).Run(pinKlines()[:60]); err == nil {
+		t.Error("expected error for too few klines")
+	}
+}
+
+func Test_ReadCSV(t *testing.T) {
+	data := `open_time,open,high,low,close,volume,close_time,quote_volume,count,taker_buy_volume,taker_buy_quote_volume,ignore
+1654041600000,1941.5,1945.0,1940.1,1944.2,1200.5,1654041659999,2332000.1,3100,700.5,1360000.2,0
+1654041660000,1944.2,1946.0,1943.0,1945.0,800,1654041719999,1556000,2100,300,583000,0
+`
+	klines, err := backtest.ReadCSV(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(klines) != 2 {
+		t.Fatalf("len = %d, want 2", len(klines))
+	}
+	k := klines[0]
+	if k.Open != 1941.5 || k.High != 1945 || k.Low != 1940.1 || k.Close != 1944.2 || k.TradeNumber != 3100 {
+		t.Errorf("unexpected kline %+v", k)
+	}
+	if k.SellVolume != 500 || !k.Final {
+		t.Errorf("sell volume = %v final = %v", k.SellVolume, k.Final)
+	}
+	if !k.CloseTime.Equal(time.Unix(0, 1654041659999*int64(time.Millisecond))) {
+		t.Errorf("close time = %v", k.CloseTime)
+	}
+}
@@ -0,0 +1,109 @@
+package backtest
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"tinyquant/src/db"
+	"tinyquant/src/mod"
+)
+
+// 读取币安历史K线 csv
+// 列顺序 : open_time,open,high,low,close,volume,close_time,quote_volume,count,taker_buy_volume,taker_buy_quote_volume,ignore
+// 时间为毫秒时间戳,带表头的文件会跳过表头
+func LoadCSV(path string) ([]*mod.Kline, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadCSV(f)
+}
+
+func ReadCSV(r io.Reader) ([]*mod.Kline, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	var klines []*mod.Kline
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) < 6 {
+			return nil, fmt.Errorf("line %d : need at least 6 columns, got %d", line, len(record))
+		}
+		openTime, err := strconv.ParseInt(record[0], 10, 64)
+		if err != nil {
+			if line == 1 {
+				continue // 表头
+			}
+			return nil, fmt.Errorf("line %d : %v", line, err)
+		}
+
+		var vals [11]float64
+		for i := 1; i < len(record) && i < len(vals); i++ {
+			if vals[i], err = strconv.ParseFloat(record[i], 64); err != nil {
+				return nil, fmt.Errorf("line %d column %d : %v", line, i+1, err)
+			}
+		}
+
+		k := &mod.Kline{
+			StartTime:   time.Unix(0, openTime*int64(time.Millisecond)),
+			Open:        vals[1],
+			High:        vals[2],
+			Low:         vals[3],
+			Close:       vals[4],
+			Volume:      vals[5],
+			Quote:       vals[7],
+			TradeNumber: int(vals[8]),
+			BuyVolume:   vals[9],
+			BuyQuote:    vals[10],
+			Final:       true,
+		}
+		if vals[6] != 0 {
+			k.CloseTime = time.Unix(0, int64(vals[6])*int64(time.Millisecond))
+		} else {
+			k.CloseTime = k.StartTime.Add(time.Minute - time.Millisecond)
+		}
+		k.SellVolume = k.Volume - k.BuyVolume
+		k.SellQuote = k.Quote - k.BuyQuote
+		klines = append(klines, k)
+	}
+	return klines, nil
+}
+
+// 从 mysql kline 表读取K线,需要先初始化数据库
+func LoadMysql(start, end time.Time) ([]*mod.Kline, error) {
+	list, err := db.GetKlines(start, end)
+	if err != nil {
+		return nil, err
+	}
+	klines := make([]*mod.Kline, 0, len(list))
+	for _, v := range list {
+		klines = append(klines, &mod.Kline{
+			StartTime:   v.OpenTime,
+			CloseTime:   v.CloseTime,
+			Open:        v.Open,
+			Close:       v.Close,
+			High:        v.High,
+			Low:         v.Low,
+			Volume:      v.Volume,
+			BuyVolume:   v.BuyVolume,
+			SellVolume:  v.SellVolume,
+			Quote:       v.Quote,
+			BuyQuote:    v.BuyQuote,
+			SellQuote:   v.SellQuote,
+			TradeNumber: v.TradeNumber,
+			Final:       true,
+		})
+	}
+	return klines, nil
+}
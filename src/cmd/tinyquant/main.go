@@ -0,0 +1,351 @@
+package main
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"tinyquant/src/backtest"
+	"tinyquant/src/db"
+	"tinyquant/src/logger"
+	"tinyquant/src/mod"
+	"tinyquant/src/quant"
+	"tinyquant/src/strategy"
+	"tinyquant/src/util"
+
+	"github.com/urfave/cli/v2"
+	"go.uber.org/zap"
+)
+
+var commonFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:    "config",
+		Aliases: []string{"c"},
+		Usage:   "配置文件路径,默认读取当前目录下的 config",
+	},
+	&cli.StringFlag{
+		Name:  "api",
+		Usage: "跟单账户配置路径,默认读取当前目录下的 api.json",
+	},
+	&cli.StringFlag{
+		Name:    "symbol",
+		Aliases: []string{"s"},
+		Value:   util.ETHUSDT,
+		Usage:   "交易对,run 可以用逗号分隔多个交易对",
+	},
+	&cli.StringFlag{
+		Name:    "futures",
+		Value:   "usdt",
+		Usage:   "合约类型 usdt : u本位 coin : 币本位 huobi : 火币现货",
+		EnvVars: []string{"futures"},
+	},
+}
+
+var metricsFlag = &cli.StringFlag{
+	Name:  "metrics",
+	Usage: "监控地址,例如 127.0.0.1:6060,在 /debug/vars 查看接口限频用量",
+}
+
+// 启动 expvar 监控
+func serveMetrics(c *cli.Context) {
+	addr := c.String("metrics")
+	if addr == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			logger.Logger.Error("metrics server stopped", zap.Error(err))
+		}
+	}()
+}
+
+func main() {
+	app := &cli.App{
+		Name:  "tinyquant",
+		Usage: "币安合约量化交易",
+		Commands: []*cli.Command{
+			{
+				Name:  "run",
+				Usage: "启动交易策略",
+				Flags: append([]cli.Flag{
+					&cli.BoolFlag{
+						Name:  "paper",
+						Usage: "模拟盘,使用实盘行情在本地撮合订单",
+					},
+					metricsFlag,
+				}, commonFlags...),
+				Action: runStrategy,
+			},
+			{
+				Name:   "copy",
+				Usage:  "启动跟单",
+				Flags:  append([]cli.Flag{metricsFlag}, commonFlags...),
+				Action: runCopy,
+			},
+			{
+				Name:   "positions",
+				Usage:  "查看当前持仓",
+				Flags:  commonFlags,
+				Action: showPositions,
+			},
+			{
+				Name:   "orders",
+				Usage:  "查看当前挂单",
+				Flags:  commonFlags,
+				Action: showOrders,
+			},
+			{
+				Name:   "cancel-all",
+				Usage:  "撤销所有挂单",
+				Flags:  commonFlags,
+				Action: cancelAll,
+			},
+			{
+				Name:  "klines",
+				Usage: "查看K线",
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{
+						Name:    "interval",
+						Aliases: []string{"i"},
+						Value:   string(mod.Minute),
+						Usage:   "K线周期",
+					},
+					&cli.IntFlag{
+						Name:    "limit",
+						Aliases: []string{"n"},
+						Value:   30,
+						Usage:   "K线数量",
+					},
+				}, commonFlags...),
+				Action: showKlines,
+			},
+			{
+				Name:  "backtest",
+				Usage: "用历史K线回测插针策略",
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{
+						Name:  "csv",
+						Usage: "币安格式的K线 csv 文件",
+					},
+					&cli.TimestampFlag{
+						Name:   "start",
+						Layout: "2006-01-02",
+						Usage:  "从 mysql kline 表读取的开始日期",
+					},
+					&cli.TimestampFlag{
+						Name:   "end",
+						Layout: "2006-01-02",
+						Usage:  "从 mysql kline 表读取的结束日期",
+					},
+					&cli.Float64Flag{
+						Name:  "balance",
+						Value: 1000,
+						Usage: "初始资金",
+					},
+					&cli.Float64Flag{Name: "maker-fee", Value: 0.0002, Usage: "挂单手续费率"},
+					&cli.Float64Flag{Name: "taker-fee", Value: 0.0004, Usage: "吃单手续费率"},
+					&cli.Float64Flag{Name: "volume-increase", Usage: "覆盖配置 quant.VolumeIncrease"},
+					&cli.Float64Flag{Name: "spring-price", Usage: "覆盖配置 quant.SpringPrice"},
+					&cli.Float64Flag{Name: "profits", Usage: "覆盖配置 quant.Profits"},
+					&cli.StringFlag{Name: "terraced-price", Usage: "覆盖配置 quant.TerracedPrice0~4,逗号分隔"},
+				}, commonFlags...),
+				Action: runBacktest,
+			},
+		},
+	}
+
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// 读取命令行参数并初始化配置和日志
+func setup(c *cli.Context, follow bool) {
+	util.ConfigFile = c.String("config")
+	util.ApiFile = c.String("api")
+	util.Futures = c.String("futures")
+	util.InitParam(follow)
+	logger.InitLogger()
+}
+
+// 币本位没有指定交易对时使用 ETHUSD_PERP
+func symbolFlag(c *cli.Context) string {
+	if !c.IsSet("symbol") && util.Futures == "coin" {
+		return util.COIN_ETHUSD
+	}
+	return c.String("symbol")
+}
+
+// 策略运行的交易对,优先使用命令行参数,其次是配置 quant.Symbols
+func symbolsFlag(c *cli.Context) []string {
+	if !c.IsSet("symbol") && len(util.Symbols) > 0 {
+		return util.Symbols
+	}
+	var symbols []string
+	for _, v := range strings.Split(symbolFlag(c), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			symbols = append(symbols, strings.ToUpper(v))
+		}
+	}
+	return symbols
+}
+
+// 创建只用于查询和撤单的客户端,不修改杠杆和持仓模式
+func newClient(c *cli.Context) (quant.Exchange, error) {
+	setup(c, false)
+	b, err := strategy.NewExchange(util.Futures)
+	if err != nil {
+		return nil, err
+	}
+	b.InitExchange(strategy.ApiKey(util.Futures))
+	return b, nil
+}
+
+func runStrategy(c *cli.Context) error {
+	setup(c, false)
+	serveMetrics(c)
+	if c.IsSet("paper") {
+		util.Paper = c.Bool("paper")
+	}
+	m := &strategy.Manager{}
+	if err := m.Init(symbolsFlag(c)); err != nil {
+		return err
+	}
+	return m.Run()
+}
+
+func runCopy(c *cli.Context) error {
+	setup(c, true)
+	serveMetrics(c)
+	acc := &strategy.DocumentaryAccount{}
+	return acc.CopyLoop(symbolFlag(c))
+}
+
+func showPositions(c *cli.Context) error {
+	b, err := newClient(c)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%-10s %-6s %14s %14s %14s %8s\n", "symbol", "side", "amount", "entry", "unrealized", "leverage")
+	positions, err := b.GetFuturePositions(symbolFlag(c))
+	if err != nil {
+		return err
+	}
+	for _, p := range positions {
+		fmt.Printf("%-10s %-6s %14v %14v %14v %8v\n", p.Symbol, p.PositionSide, p.PositionAmt, p.EntryPrice, p.UnrealizedProfit, p.Leverage)
+	}
+	return nil
+}
+
+func showOrders(c *cli.Context) error {
+	b, err := newClient(c)
+	if err != nil {
+		return err
+	}
+	orders, err := b.QueryOpenFutureOrders(symbolFlag(c))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%-14s %-24s %-5s %-6s %-8s %12s %12s %12s\n", "orderId", "clientOrderId", "side", "pside", "type", "price", "stopPrice", "quantity")
+	for _, o := range orders {
+		fmt.Printf("%-14v %-24s %-5s %-6s %-8s %12v %12v %12v\n", o.OrderID, o.ClientOrderID, o.Side, o.PositionSide, o.Type, o.Price, o.StopPrice, o.OrigQty)
+	}
+	return nil
+}
+
+func cancelAll(c *cli.Context) error {
+	b, err := newClient(c)
+	if err != nil {
+		return err
+	}
+	symbol := symbolFlag(c)
+	orders, err := b.QueryOpenFutureOrders(symbol)
+	if err != nil {
+		return err
+	}
+	if err := b.CancelAllFutureOrders(symbol); err != nil {
+		return err
+	}
+	for _, o := range orders {
+		fmt.Printf("canceled %v\n", o.OrderID)
+	}
+	return nil
+}
+
+func showKlines(c *cli.Context) error {
+	b, err := newClient(c)
+	if err != nil {
+		return err
+	}
+	klines, err := b.GetFutureKlines(symbolFlag(c), c.Int("limit"), mod.Interval(c.String("interval")))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%-19s %12s %12s %12s %12s %14s\n", "open time", "open", "high", "low", "close", "volume")
+	for _, k := range klines {
+		fmt.Printf("%-19s %12v %12v %12v %12v %14v\n", k.StartTime.Format("2006-01-02 15:04:05"), k.Open, k.High, k.Low, k.Close, k.Volume)
+	}
+	return nil
+}
+
+func runBacktest(c *cli.Context) error {
+	util.ConfigFile = c.String("config")
+	util.InitConfig(false)
+	util.InitLogParam()
+	util.InitHttpParam()
+	util.InitQuantParam()
+	logger.InitLogger()
+
+	var klines []*mod.Kline
+	var err error
+	switch {
+	case c.IsSet("csv"):
+		klines, err = backtest.LoadCSV(c.String("csv"))
+	case c.IsSet("start") && c.IsSet("end"):
+		util.InitMysqlParams()
+		db.InitMysql()
+		klines, err = backtest.LoadMysql(*c.Timestamp("start"), *c.Timestamp("end"))
+	default:
+		return errors.New("need --csv or --start and --end")
+	}
+	if err != nil {
+		return err
+	}
+
+	bt := backtest.New(symbolFlag(c), c.Float64("balance"))
+	if c.IsSet("volume-increase") {
+		bt.Param.VolumeIncrease = c.Float64("volume-increase")
+	}
+	if c.IsSet("spring-price") {
+		bt.Param.SpringPrice = c.Float64("spring-price")
+	}
+	if c.IsSet("profits") {
+		bt.Param.Profits = c.Float64("profits")
+	}
+	if c.IsSet("terraced-price") {
+		bt.Param.TerracedPrice = bt.Param.TerracedPrice[:0]
+		for _, v := range strings.Split(c.String("terraced-price"), ",") {
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return fmt.Errorf("terraced-price : %v", err)
+			}
+			bt.Param.TerracedPrice = append(bt.Param.TerracedPrice, f)
+		}
+	}
+
+	bt.Exchange.MakerFee = c.Float64("maker-fee")
+	bt.Exchange.TakerFee = c.Float64("taker-fee")
+	report, err := bt.Run(klines)
+	if err != nil {
+		return err
+	}
+	fmt.Print(report)
+	return nil
+}
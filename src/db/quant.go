@@ -35,6 +35,17 @@ func PutKline(kline *Kline) error {
 	return nil
 }
 
+// 按开盘时间升序读取 [start, end) 区间内的K线
+func GetKlines(start, end time.Time) ([]*Kline, error) {
+	var klines []*Kline
+	err := GetSession().Table("kline").Where("open_time >= ? and open_time < ?", start, end).Asc("open_time").Find(&klines)
+	if err != nil {
+		Logger.Error("get kline list failed", zap.Error(err))
+		return nil, err
+	}
+	return klines, nil
+}
+
 type Order struct {
 	OrderID         int64                    `xorm:"order_id"`
 	Symbol          string                   `xorm:"symbol"`
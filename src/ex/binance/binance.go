@@ -18,8 +18,25 @@ type Binance interface {
 	Ping() error
 	// Time returns server time.
 	Time() (time.Time, error)
-
-	ExchangeInfo() (string, error)
+	// CoinTime returns coin-margined futures server time.
+	CoinTime() (time.Time, error)
+	// SyncServerTime estimates local clock offset now and then every interval until done is closed.
+	// Signed requests use the corrected time.
+	SyncServerTime(interval, maxDrift time.Duration, done chan struct{}) error
+	CoinSyncServerTime(interval, maxDrift time.Duration, done chan struct{}) error
+	// ClockOffset returns server time minus local time.
+	ClockOffset() time.Duration
+	// WebsocketEvents returns connect and disconnect events of all websocket streams.
+	// Streams reconnect automatically, state should be resynced after a Reconnect event.
+	WebsocketEvents() chan *WebsocketStateEvent
+	// NewCombinedStream returns a client which multiplexes streams over one /stream connection.
+	NewCombinedStream() *CombinedStream
+	NewCoinCombinedStream() *CombinedStream
+
+	// ExchangeInfo returns trading rules of all u-margined futures symbols.
+	ExchangeInfo() (*ExchangeInfo, error)
+	// CoinExchangeInfo returns trading rules of all coin-margined futures symbols.
+	CoinExchangeInfo() (*ExchangeInfo, error)
 
 	// 最新价格
 	NewPrice(nb OrderNewPriceRequest) (*NewPrice, error)
@@ -81,6 +98,12 @@ type Binance interface {
 	NewFutureOrder(nfr NewFutureOrderRequest) (*FutureProcessedOrder, error)
 	// 取消订单
 	CancelFutureOrder(cfr CancelFutureOrderRequest) (*CanceledFutureOrder, error)
+	// 批量下单
+	NewBatchFutureOrder(br NewBatchFutureOrderRequest) ([]*BatchFutureOrder, error)
+	// 批量取消订单
+	CancelBatchFutureOrder(cr CancelBatchFutureOrderRequest) ([]*BatchFutureOrder, error)
+	// 取消全部挂单
+	CancelAllFutureOrder(cr CancelAllFutureOrderRequest) error
 
 	//查询一个挂单
 	QueryOneFutureOrder(qfo QueryFutureOrderRequest) (*ExecutedFutureOrder, error)
@@ -112,6 +135,9 @@ type Binance interface {
 	//最新标记价格和资金费率
 	PremiumAndFundsRate(pfrr PremiumAndFundsRateRequest) (*PremiumAndFundsRateInfo, error)
 
+	//收益流水,资金费用 手续费 已实现盈亏等
+	Income(ir IncomeRequest) ([]*IncomeInfo, error)
+
 	//获取k线
 	FutureKlines(kr KlinesRequest) ([]*Kline, error)
 
@@ -219,6 +245,18 @@ type Binance interface {
 	// 取消订单
 	CoinCancelFutureOrder(cfr CancelFutureOrderRequest) (*CanceledFutureOrder, error)
 
+	// 批量下单
+	CoinNewBatchFutureOrder(br NewBatchFutureOrderRequest) ([]*BatchFutureOrder, error)
+
+	// 批量取消订单
+	CoinCancelBatchFutureOrder(cr CancelBatchFutureOrderRequest) ([]*BatchFutureOrder, error)
+
+	// 取消全部挂单
+	CoinCancelAllFutureOrder(cr CancelAllFutureOrderRequest) error
+
+	// 限频用量
+	RateLimit() RateLimitStats
+
 	// 获取账户余额
 	CoinFutureBalance(fbr FutureBalanceRequest) ([]*FutureBalanceInfo, error)
 
@@ -228,6 +266,12 @@ type Binance interface {
 	//用户成交历史
 	CoinUserTradesHistory(uth CoinUserTradesHistoryRequest) ([]*CoinUserTradesHistoryInfo, error)
 
+	//最新标记价格和资金费率,按 symbol 或 pair 查询
+	CoinPremiumAndFundsRate(pfrr PremiumAndFundsRateRequest) ([]*PremiumAndFundsRateInfo, error)
+
+	//收益流水
+	CoinIncome(ir IncomeRequest) ([]*IncomeInfo, error)
+
 	//币本位websocket  有限档深度信息
 	CoinFutureDepthWebsocket(dwr DepthWebsocketRequest) (chan *DepthEvent, chan struct{}, error)
 
@@ -279,6 +323,10 @@ func (b *binance) Time() (time.Time, error) {
 	return b.Service.Time()
 }
 
+func (b *binance) CoinTime() (time.Time, error) {
+	return b.Service.CoinTime()
+}
+
 // OrderBook represents Bids and Asks.
 type OrderBook struct {
 	LastUpdateID int
@@ -293,12 +341,47 @@ type DepthEvent struct {
 	WSEvent
 	EventTime time.Time
 	OrderBook
+	Gap bool // 之前的推送因为读取太慢被丢弃
 }
 
-func (b *binance) ExchangeInfo() (string, error) {
+// ExchangeInfo represents trading rules of futures symbols.
+type ExchangeInfo struct {
+	ServerTime time.Time
+	Symbols    []*SymbolInfo
+}
+
+// SymbolInfo represents trading rules of one symbol, filters are flattened.
+type SymbolInfo struct {
+	Symbol            string
+	Pair              string
+	ContractType      string
+	Status            string
+	MarginAsset       string  // 保证金币种
+	ContractSize      float64 // 币本位合约面值,u本位为 0
+	PricePrecision    int
+	QuantityPrecision int
+	TriggerProtect    float64 // 条件单触发保护阈值
+	TickSize          float64 // PRICE_FILTER
+	MinPrice          float64
+	MaxPrice          float64
+	StepSize          float64 // LOT_SIZE
+	MinQty            float64
+	MaxQty            float64
+	MinNotional       float64 // MIN_NOTIONAL,币本位没有
+	MaxNumOrders      int     // MAX_NUM_ORDERS
+	MaxNumAlgoOrders  int     // MAX_NUM_ALGO_ORDERS
+	MultiplierUp      float64 // PERCENT_PRICE
+	MultiplierDown    float64
+}
+
+func (b *binance) ExchangeInfo() (*ExchangeInfo, error) {
 	return b.Service.ExchangeInfo()
 }
 
+func (b *binance) CoinExchangeInfo() (*ExchangeInfo, error) {
+	return b.Service.CoinExchangeInfo()
+}
+
 type OrderNewPriceRequest struct {
 	Symbol string
 }
@@ -350,6 +433,15 @@ type AggTradeEvent struct {
 	AggTrade
 }
 
+// 标记价格推送,每秒一次,包含资金费率和下次结算时间
+type MarkPriceEvent struct {
+	WSEvent
+	MarkPrice       float64
+	IndexPrice      float64
+	FundingRate     float64
+	NextFundingTime time.Time
+}
+
 // AggTradesRequest represents AggTrades request data.
 type AggTradesRequest struct {
 	Symbol    string
@@ -575,14 +667,14 @@ type NewFutureOrderRequest struct {
 	ReduceOnly       string       // true, false; 非双开模式下默认false；双开模式下不接受此参数； 使用closePosition不支持此参数。
 	Quantity         float64
 	Price            float64
-	NewClientOrderID string // 用户自定义的订单号，不可以重复出现在挂单中。如空缺系统会自动赋值。
-	StopPrice        float64
-	ClosePosition    string
-	ActivationPrice  float64
-	CallbackRate     float64
+	NewClientOrderID string  // 用户自定义的订单号，不可以重复出现在挂单中。如空缺系统会自动赋值。
+	StopPrice        float64 // 触发价, STOP, STOP_MARKET, TAKE_PROFIT, TAKE_PROFIT_MARKET 需要此参数
+	ClosePosition    string  // true, false; 触发后全部平仓,仅支持 STOP_MARKET 和 TAKE_PROFIT_MARKET；不与 quantity 合用
+	ActivationPrice  float64 // 追踪止损激活价格,仅 TRAILING_STOP_MARKET 需要此参数, 默认为下单当前市场价格
+	CallbackRate     float64 // 追踪止损回调比例,可取值范围[0.1, 5],其中 1代表1% ,仅 TRAILING_STOP_MARKET 需要此参数
 	TimeInForce      TimeInForce
-	WorkingType      string
-	PriceProject     string
+	WorkingType      string // stopPrice 触发类型: MARK_PRICE(标记价格), CONTRACT_PRICE(合约最新价). 默认 CONTRACT_PRICE
+	PriceProtect     string // 条件单触发保护："TRUE","FALSE", 默认"FALSE". 仅 STOP, STOP_MARKET, TAKE_PROFIT, TAKE_PROFIT_MARKET 需要此参数
 	NewOrderRespType string
 	RecvWindow       time.Duration
 	Timestamp        time.Time
@@ -1614,6 +1706,39 @@ func (b *binance) PremiumAndFundsRate(pfrr PremiumAndFundsRateRequest) (*Premium
 	return b.Service.PremiumAndFundsRate(pfrr)
 }
 
+func (b *binance) CoinPremiumAndFundsRate(pfrr PremiumAndFundsRateRequest) ([]*PremiumAndFundsRateInfo, error) {
+	return b.Service.CoinPremiumAndFundsRate(pfrr)
+}
+
+type IncomeRequest struct {
+	Symbol     string
+	IncomeType IncomeType // 为空时返回全部类型
+	StartTime  time.Time
+	EndTime    time.Time
+	Limit      int // 默认100,最大1000
+	RecvWindow time.Duration
+	Timestamp  time.Time
+}
+
+type IncomeInfo struct {
+	Symbol     string
+	IncomeType IncomeType
+	Income     float64 // 正数为收入,负数为支出
+	Asset      string
+	Info       string
+	TranID     int64
+	TradeID    string
+	Time       time.Time
+}
+
+func (b *binance) Income(ir IncomeRequest) ([]*IncomeInfo, error) {
+	return b.Service.Income(ir)
+}
+
+func (b *binance) CoinIncome(ir IncomeRequest) ([]*IncomeInfo, error) {
+	return b.Service.CoinIncome(ir)
+}
+
 type PriceChangeSituationRequest struct {
 	Symbol string
 }
@@ -1855,6 +1980,8 @@ type OrderEvent struct {
 		PositionSide  string      // 持仓方向
 		IsClose       bool        // 是否为触发平仓单; 仅在条件订单情况下会推送此字段
 		Profit        float64     // 该交易实现盈亏
+		ActivatePrice float64     // 跟踪止损激活价格
+		PriceRate     float64     // 跟踪止损回调比例
 	}
 }
 
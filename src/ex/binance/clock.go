@@ -0,0 +1,135 @@
+package binance
+
+import (
+	"sync"
+	"time"
+	. "tinyquant/src/logger"
+
+	"go.uber.org/zap"
+)
+
+// 每次同步取样次数,使用往返时间最短的一次
+const clockSamples = 3
+
+// 本地时钟和服务器时间的偏差
+// 签名请求的 timestamp 使用 Now(),本地时钟漂移时不会因为超出 recvWindow 被拒绝(-1021)
+type Clock struct {
+	sync.RWMutex
+	offset time.Duration // 服务器时间 - 本地时间
+	rtt    time.Duration // 估算偏差时的往返时间
+	synced time.Time
+}
+
+// 按偏差校正后的当前时间,没有同步过时等于本地时间
+func (c *Clock) Now() time.Time {
+	c.RLock()
+	defer c.RUnlock()
+	return time.Now().Add(c.offset)
+}
+
+func (c *Clock) Offset() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+	return c.offset
+}
+
+// 用一次请求的发送时间,收到时间和服务器时间更新偏差,服务器时间按往返时间的中点计算
+func (c *Clock) Sample(sent, received, server time.Time) time.Duration {
+	rtt := received.Sub(sent)
+	offset := server.Sub(sent.Add(rtt / 2))
+	c.Lock()
+	defer c.Unlock()
+	c.offset, c.rtt, c.synced = offset, rtt, received
+	return offset
+}
+
+// 一次取样的发送时间,收到时间和服务器时间
+type clockSample struct {
+	sent, received, server time.Time
+}
+
+func (s clockSample) rtt() time.Duration {
+	return s.received.Sub(s.sent)
+}
+
+// 取样 clockSamples 次,返回往返时间最短的一次,全部失败时返回最后一次的错误
+func bestSample(take func() (clockSample, error)) (clockSample, error) {
+	var best clockSample
+	var lastErr error
+	for i := 0; i < clockSamples; i++ {
+		s, err := take()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if best.server.IsZero() || s.rtt() < best.rtt() {
+			best = s
+		}
+	}
+	return best, lastErr
+}
+
+// 同步一次服务器时间,偏差超过 maxDrift 时告警
+func (as *apiService) syncClock(endpoint string, maxDrift time.Duration) error {
+	best, err := bestSample(func() (clockSample, error) {
+		sent := time.Now()
+		server, err := as.serverTime(endpoint)
+		return clockSample{sent: sent, received: time.Now(), server: server}, err
+	})
+	if best.server.IsZero() {
+		return err
+	}
+	offset := as.Clock.Sample(best.sent, best.received, best.server)
+	if maxDrift > 0 && (offset > maxDrift || offset < -maxDrift) {
+		Logger.Warn("local clock drift", zap.String("url", as.URL), zap.Duration("offset", offset),
+			zap.Duration("rtt", best.rtt()))
+	}
+	return nil
+}
+
+// 先同步一次,之后每隔 interval 在后台同步,关闭 done 停止
+func (as *apiService) syncServerTime(endpoint string, interval, maxDrift time.Duration, done chan struct{}) error {
+	err := as.syncClock(endpoint, maxDrift)
+	if interval <= 0 {
+		return err
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := as.syncClock(endpoint, maxDrift); err != nil {
+					Logger.Warn("sync server time failed", zap.Error(err))
+				}
+			}
+		}
+	}()
+	return err
+}
+
+func (as *apiService) SyncServerTime(interval, maxDrift time.Duration, done chan struct{}) error {
+	return as.syncServerTime("fapi/v1/time", interval, maxDrift, done)
+}
+
+func (as *apiService) CoinSyncServerTime(interval, maxDrift time.Duration, done chan struct{}) error {
+	return as.syncServerTime("dapi/v1/time", interval, maxDrift, done)
+}
+
+func (as *apiService) ClockOffset() time.Duration {
+	return as.Clock.Offset()
+}
+
+func (b *binance) SyncServerTime(interval, maxDrift time.Duration, done chan struct{}) error {
+	return b.Service.SyncServerTime(interval, maxDrift, done)
+}
+
+func (b *binance) CoinSyncServerTime(interval, maxDrift time.Duration, done chan struct{}) error {
+	return b.Service.CoinSyncServerTime(interval, maxDrift, done)
+}
+
+func (b *binance) ClockOffset() time.Duration {
+	return b.Service.ClockOffset()
+}
@@ -0,0 +1,63 @@
+package binance
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func Test_ClockSample(t *testing.T) {
+	t0 := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	c := &Clock{}
+	if c.Offset() != 0 {
+		t.Fatal("offset before sync")
+	}
+
+	// 往返 200ms,服务器时间对应发送后 100ms
+	if offset := c.Sample(t0, t0.Add(200*time.Millisecond), t0.Add(100*time.Millisecond+5*time.Second)); offset != 5*time.Second {
+		t.Errorf("offset %v, want 5s", offset)
+	}
+	// 本地时钟快 3 秒
+	if offset := c.Sample(t0, t0.Add(300*time.Millisecond), t0.Add(150*time.Millisecond-3*time.Second)); offset != -3*time.Second {
+		t.Errorf("offset %v, want -3s", offset)
+	}
+	if c.Offset() != -3*time.Second || c.rtt != 300*time.Millisecond {
+		t.Errorf("offset %v rtt %v", c.Offset(), c.rtt)
+	}
+	if d := time.Now().Add(-3 * time.Second).Sub(c.Now()); d < -time.Second || d > time.Second {
+		t.Errorf("now off by %v", d)
+	}
+}
+
+func Test_BestSample(t *testing.T) {
+	t0 := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	sample := func(rtt time.Duration, offset time.Duration) clockSample {
+		return clockSample{sent: t0, received: t0.Add(rtt), server: t0.Add(rtt/2 + offset)}
+	}
+	errTimeout := errors.New("timeout")
+	take := func(samples []clockSample, errs []error) func() (clockSample, error) {
+		i := 0
+		return func() (clockSample, error) {
+			i++
+			return samples[i-1], errs[i-1]
+		}
+	}
+
+	// 往返时间最短的一次误差最小,失败的取样跳过
+	best, err := bestSample(take(
+		[]clockSample{sample(400*time.Millisecond, -2*time.Second), {}, sample(60*time.Millisecond, -time.Second)},
+		[]error{nil, errTimeout, nil},
+	))
+	if best.rtt() != 60*time.Millisecond || err != errTimeout {
+		t.Errorf("best %+v err %v", best, err)
+	}
+	c := &Clock{}
+	if offset := c.Sample(best.sent, best.received, best.server); offset != -time.Second {
+		t.Errorf("offset %v, want -1s", offset)
+	}
+
+	best, err = bestSample(take([]clockSample{{}, {}, {}}, []error{errTimeout, errTimeout, errTimeout}))
+	if !best.server.IsZero() || err != errTimeout {
+		t.Errorf("best %+v err %v", best, err)
+	}
+}
@@ -467,26 +467,7 @@ func (as *apiService) CoinFutureAccount(far FutureAccountRequest) (*CoinFutureAc
 }
 
 func (as *apiService) CoinNewFutureOrder(or NewFutureOrderRequest) (*FutureProcessedOrder, error) {
-	params := make(map[string]string)
-	params["symbol"] = or.Symbol
-	params["side"] = string(or.Side)
-	params["type"] = string(or.Type)
-
-	if or.PositionSide != "" {
-		params["positionSide"] = string(or.PositionSide)
-	}
-
-	params["timeInForce"] = string(or.TimeInForce)
-	params["quantity"] = strconv.FormatFloat(or.Quantity, 'f', 0, 64)
-	params["price"] = strconv.FormatFloat(or.Price, 'f', 2, 64)
-	params["timestamp"] = strconv.FormatInt(unixMillis(or.Timestamp), 10)
-
-	if or.NewClientOrderID != "" {
-		params["newClientOrderId"] = or.NewClientOrderID
-	}
-	if or.StopPrice != 0.0 {
-		params["stopPrice"] = strconv.FormatFloat(or.StopPrice, 'f', 2, 64)
-	}
+	params := futureOrderParams(or, 0, 2)
 
 	res, err := as.request("POST", "dapi/v1/order", params, true, true)
 	if err != nil {
@@ -502,60 +483,11 @@ func (as *apiService) CoinNewFutureOrder(or NewFutureOrderRequest) (*FutureProce
 		return nil, as.handleError(textRes)
 	}
 
-	rawOrder := struct {
-		Symbol        string  `json:"symbol"`
-		CumBase       string  `json:"cumBase"`       // 成交金额
-		ExecutedQty   string  `json:"executedQty"`   // 成交量
-		ClientOrderId string  `json:"clientOrderId"` // 用户自定义订单号
-		OrderId       int64   `json:"orderId"`       // 系统订单号
-		AvgPrice      string  `json:"avgPrice"`      // 平均成交价
-		OrigQty       string  `json:"origQty"`       // 原始委托数量
-		Price         string  `json:"price"`         // 委托价格
-		Side          string  `json:"side"`          // 买卖方向
-		PositionSide  string  `json:"positionSide"`  // 持仓方向
-		Status        string  `json:"status"`        // 订单状态
-		StopPrice     string  `json:"stopPrice"`     // 触发价
-		ClosePosition bool    `json:"closePosition"` // 是否条件全平仓
-		TimeInForce   string  `json:"timeInForce"`   // 有效方法
-		Type          string  `json:"type"`          // 订单类型
-		OrigType      string  `json:"origType"`      // 触发前订单类型
-		ActivatePrice string  `json:"activatePrice"` // 跟踪止损激活价格， 仅`TRAILING_STOP_MARKET` 订单返回此字段
-		PriceRate     string  `json:"priceRate"`     // 跟踪止损回调比例， 仅`TRAILING_STOP_MARKET` 订单返回此字段
-		WorkingType   string  `json:"workingType"`   // 条件价格触发类型
-		PriceProtect  bool    `json:"priceProtect"`  // 是否开启条件单触发保护
-		UpdateTime    float64 `json:"updateTime"`
-	}{}
-	if err := json.Unmarshal(textRes, &rawOrder); err != nil {
+	rawOrder := &rawFutureOrder{}
+	if err := json.Unmarshal(textRes, rawOrder); err != nil {
 		return nil, errors.Wrap(err, "rawOrder unmarshal failed")
 	}
-
-	//cq, _ := floatFromString(rawOrder.CumBase)
-	eq, _ := floatFromString(rawOrder.ExecutedQty)
-	ap, _ := floatFromString(rawOrder.AvgPrice)
-	oq, _ := floatFromString(rawOrder.OrigQty)
-	p, _ := floatFromString(rawOrder.Price)
-	sp, _ := floatFromString(rawOrder.StopPrice)
-	aep, _ := floatFromString(rawOrder.ActivatePrice)
-	pr, _ := floatFromString(rawOrder.PriceRate)
-	t, _ := timeFromUnixTimestampFloat(rawOrder.UpdateTime)
-
-	return &FutureProcessedOrder{
-		Symbol:        rawOrder.Symbol,
-		ClientOrderId: rawOrder.ClientOrderId,
-		OrderId:       rawOrder.OrderId,
-		//CumBase:       cq,
-		StopPrice:     sp,
-		ActivatePrice: aep,
-		PriceRate:     pr,
-		AvgPrice:      ap,
-		ExecutedQty:   eq,
-		OrigQty:       oq,
-		Price:         p,
-		Side:          rawOrder.Side,
-		PositionSide:  rawOrder.PositionSide,
-		Type:          rawOrder.Type,
-		UpdateTime:    t,
-	}, nil
+	return rawOrder.processed(), nil
 }
 
 func (as *apiService) CoinCancelFutureOrder(cfr CancelFutureOrderRequest) (*CanceledFutureOrder, error) {
@@ -905,3 +837,7 @@ func (as *apiService) CoinAllFutureOrders(afo CoinAllFutureOrdersRequest) ([]*Co
 
 	return eoc, nil
 }
+
+func (as *apiService) CoinIncome(ir IncomeRequest) ([]*IncomeInfo, error) {
+	return as.income("/dapi/v1/income", ir)
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"strconv"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -299,6 +300,10 @@ func (as *apiService) CoinContractPosition(bpr CoinContractPositionRequest) ([]*
 	return fbi, nil
 }
 
+func (as *apiService) CoinExchangeInfo() (*ExchangeInfo, error) {
+	return as.exchangeInfo("dapi/v1/exchangeInfo")
+}
+
 func (as *apiService) CoinGetNewPrice(npr NewPriceRequest) ([]*CoinNewPriceInfo, error) {
 
 	params := make(map[string]string)
@@ -674,3 +679,62 @@ func (as *apiService) CoinGlobalLongShortAccountRatio(glsarr CoinGlobalLongShort
 	}
 	return tri, nil
 }
+
+func (as *apiService) CoinPremiumAndFundsRate(pfrr PremiumAndFundsRateRequest) ([]*PremiumAndFundsRateInfo, error) {
+	params := make(map[string]string)
+	params["symbol"] = pfrr.Symbol
+
+	res, err := as.request("GET", "dapi/v1/premiumIndex", params, false, false)
+	if err != nil {
+		return nil, err
+	}
+	textRes, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read response from CoinPremiumAndFundsRate.GET")
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return nil, as.handleError(textRes)
+	}
+
+	rawPremiumAndFundsRates := []struct {
+		Symbol               string  `json:"symbol"`
+		MarkPrice            string  `json:"markPrice"`
+		IndexPrice           string  `json:"indexPrice"`
+		EstimatedSettlePrice string  `json:"estimatedSettlePrice"`
+		LastFundingRate      string  `json:"lastFundingRate"`
+		NextFundingTime      float64 `json:"nextFundingTime"`
+		InterestRate         string  `json:"interestRate"`
+		Time                 float64 `json:"time"`
+	}{}
+	if err := json.Unmarshal(textRes, &rawPremiumAndFundsRates); err != nil {
+		return nil, errors.Wrap(err, "CoinPremiumAndFundsRate unmarshal failed")
+	}
+
+	var pfs []*PremiumAndFundsRateInfo
+	for _, rp := range rawPremiumAndFundsRates {
+		mp, _ := floatFromString(rp.MarkPrice)
+		ip, _ := floatFromString(rp.IndexPrice)
+		esp, _ := floatFromString(rp.EstimatedSettlePrice)
+		lfr, _ := floatFromString(rp.LastFundingRate)
+		ir, _ := floatFromString(rp.InterestRate)
+		t, _ := timeFromUnixTimestampFloat(rp.Time)
+		// 交割合约没有资金费率,下次结算时间为0
+		var t1 time.Time
+		if rp.NextFundingTime > 0 {
+			t1, _ = timeFromUnixTimestampFloat(rp.NextFundingTime)
+		}
+
+		pfs = append(pfs, &PremiumAndFundsRateInfo{
+			Symbol:               rp.Symbol,
+			MarkPrice:            mp,
+			IndexPrice:           ip,
+			EstimatedSettlePrice: esp,
+			LastFundingRate:      lfr,
+			NextFundingTime:      t1,
+			InterestRate:         ir,
+			Time:                 t,
+		})
+	}
+	return pfs, nil
+}
@@ -3,20 +3,18 @@ package binance
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"strings"
 	. "tinyquant/src/logger"
 	"tinyquant/src/util"
 
-	"github.com/gorilla/websocket"
 	"go.uber.org/zap"
 )
 
 func (as *apiService) CoinFutureDepthWebsocket(dwr DepthWebsocketRequest) (chan *DepthEvent, chan struct{}, error) {
 	url := fmt.Sprintf("wss://fstream.binance.com/ws/%s@depth@100ms", strings.ToLower(dwr.Symbol))
-	c, _, err := websocket.DefaultDialer.Dial(url, nil)
+	c, err := as.dialWebsocket(streamName(url), staticURL(url))
 	if err != nil {
-		log.Fatal("dial:", err)
+		return nil, nil, err
 	}
 
 	done := make(chan struct{})
@@ -33,9 +31,8 @@ func (as *apiService) CoinFutureDepthWebsocket(dwr DepthWebsocketRequest) (chan
 			default:
 				_, message, err := c.ReadMessage()
 				if err != nil {
-					c = ReConnectWebSocket(url)
 					Logger.Error("read message failed", zap.Error(err))
-					continue
+					return
 				}
 				rawDepth := struct {
 					Type          string          `json:"e"`
@@ -51,7 +48,7 @@ func (as *apiService) CoinFutureDepthWebsocket(dwr DepthWebsocketRequest) (chan
 				}{}
 				if err := json.Unmarshal(message, &rawDepth); err != nil {
 					Logger.Error("depth wsUnmarshal failed ", zap.Error(err))
-					return
+					continue
 				}
 				t, _ := timeFromUnixTimestampFloat(rawDepth.Time)
 
@@ -96,7 +93,6 @@ func (as *apiService) CoinFutureDepthWebsocket(dwr DepthWebsocketRequest) (chan
 		}
 	}()
 
-	go as.exitHandler(c, done, url)
 	return dech, done, nil
 }
 
@@ -104,9 +100,9 @@ func (as *apiService) CoinFutureDepthWebsocket(dwr DepthWebsocketRequest) (chan
 func (as *apiService) CoinFutureTradeWebsocket(twr TradeWebsocketRequest) (chan *AggTradeEvent, chan struct{}, error) {
 
 	url := fmt.Sprintf("wss://fstream.binance.com/ws/%s@aggTrade", strings.ToLower(twr.Symbol))
-	c, _, err := websocket.DefaultDialer.Dial(url, nil)
+	c, err := as.dialWebsocket(streamName(url), staticURL(url))
 	if err != nil {
-		log.Fatal("dial:", err)
+		return nil, nil, err
 	}
 
 	done := make(chan struct{})
@@ -123,9 +119,8 @@ func (as *apiService) CoinFutureTradeWebsocket(twr TradeWebsocketRequest) (chan
 			default:
 				_, message, err := c.ReadMessage()
 				if err != nil {
-					c = ReConnectWebSocket(url)
 					Logger.Error("trade websocket read failed ", zap.Error(err))
-					continue
+					return
 				}
 				rawAggTrade := struct {
 					Type         string  `json:"e"`
@@ -141,7 +136,7 @@ func (as *apiService) CoinFutureTradeWebsocket(twr TradeWebsocketRequest) (chan
 				}{}
 				if err := json.Unmarshal(message, &rawAggTrade); err != nil {
 					Logger.Error("trade wsUnmarshal failed ", zap.Error(err))
-					return
+					continue
 				}
 				t, _ := timeFromUnixTimestampFloat(rawAggTrade.Time)
 
@@ -172,7 +167,6 @@ func (as *apiService) CoinFutureTradeWebsocket(twr TradeWebsocketRequest) (chan
 		}
 	}()
 
-	go as.exitHandler(c, done, url)
 	return aggtech, done, nil
 }
 
@@ -180,9 +174,9 @@ func (as *apiService) CoinFutureTradeWebsocket(twr TradeWebsocketRequest) (chan
 func (as *apiService) CoinFutureKlineWebsocket(kwr KlineWebsocketRequest) (chan *KlineEvent, chan struct{}, error) {
 	url := fmt.Sprintf("wss://fstream.binance.com/ws/%s@kline_%s", strings.ToLower(kwr.Symbol), string(kwr.Interval))
 
-	c, _, err := websocket.DefaultDialer.Dial(url, nil)
+	c, err := as.dialWebsocket(streamName(url), staticURL(url))
 	if err != nil {
-		log.Fatal("dial:", err)
+		return nil, nil, err
 	}
 
 	done := make(chan struct{})
@@ -199,9 +193,8 @@ func (as *apiService) CoinFutureKlineWebsocket(kwr KlineWebsocketRequest) (chan
 			default:
 				_, message, err := c.ReadMessage()
 				if err != nil {
-					c = ReConnectWebSocket(url)
 					Logger.Error("kline websocket read failed", zap.Error(err))
-					continue
+					return
 				}
 
 				if strings.Contains(string(message), "result") {
@@ -233,7 +226,7 @@ func (as *apiService) CoinFutureKlineWebsocket(kwr KlineWebsocketRequest) (chan
 				}{}
 				if err := json.Unmarshal(message, &rawKline); err != nil {
 					Logger.Error("kline wsUnmarshal failed ", zap.Error(err))
-					return
+					continue
 				}
 				t, _ := timeFromUnixTimestampFloat(rawKline.Time)
 
@@ -287,17 +280,21 @@ func (as *apiService) CoinFutureKlineWebsocket(kwr KlineWebsocketRequest) (chan
 		}
 	}()
 
-	go as.exitHandler(c, done, url)
 	return kech, done, nil
 }
 
 func (as *apiService) CoinFutureUserDataWebsocket(urwr UserDataWebsocketRequest) (chan *FutureAccountEvent, chan struct{}, error) {
 
-	url := fmt.Sprintf("wss://dstream.binance.com/ws/%s", urwr.ListenKey)
+	key := &userDataKey{
+		key:       urwr.ListenKey,
+		format:    "wss://dstream.binance.com/ws/%s",
+		start:     as.StartCoinFutureUserDataStream,
+		keepAlive: as.KeepAliveCoinFutureUserDataStream,
+	}
 
-	c, _, err := websocket.DefaultDialer.Dial(url, nil)
+	c, err := as.dialWebsocket("userData", key.url)
 	if err != nil {
-		log.Fatal("dial:", err)
+		return nil, nil, err
 	}
 	done := make(chan struct{})
 	aech := make(chan *FutureAccountEvent)
@@ -313,13 +310,13 @@ func (as *apiService) CoinFutureUserDataWebsocket(urwr UserDataWebsocketRequest)
 			default:
 				_, message, err := c.ReadMessage()
 				if err != nil {
-					c = ReConnectWebSocket(url)
 					Logger.Error("user data websocket read failed ", zap.Error(err))
-					continue
+					return
 				}
 
-				if strings.Contains(string(message), util.ListenKeyExpired) { // listenkey 过期
+				if strings.Contains(string(message), util.ListenKeyExpired) { // listenkey 过期,重新申请后重连
 					Logger.Info("websocket receive event ")
+					c.Reconnect()
 					continue
 				}
 
@@ -346,7 +343,7 @@ func (as *apiService) CoinFutureUserDataWebsocket(urwr UserDataWebsocketRequest)
 					}{}
 					if err := json.Unmarshal(message, &accUp); err != nil {
 						Logger.Error("user acc data wsUnmarshal failed ", zap.Error(err))
-						return
+						continue
 					}
 
 					ae := &FutureAccountEvent{
@@ -420,7 +417,7 @@ func (as *apiService) CoinFutureUserDataWebsocket(urwr UserDataWebsocketRequest)
 					}{}
 					if err := json.Unmarshal(message, &orderUp); err != nil {
 						Logger.Error("user data wsUnmarshal failed ", zap.Error(err))
-						return
+						continue
 					}
 
 					oe := &FutureAccountEvent{
@@ -473,7 +470,9 @@ func (as *apiService) CoinFutureUserDataWebsocket(urwr UserDataWebsocketRequest)
 					oe.OE.Order.IsClose = orderUp.Order.IsClose
 					//	oe.OE.Order.IsProtect = orderUp.Order.IsProtect
 					oe.OE.Order.NowType = OrderType(orderUp.Order.NowType)
-					oe.OE.Order.OrderType = orderUp.Order.OrigType
+					oe.OE.Order.OrigType = OrderType(orderUp.Order.OrigType)
+					oe.OE.Order.ActivatePrice, _ = floatFromString(orderUp.Order.TrackStopGoPrice)
+					oe.OE.Order.PriceRate, _ = floatFromString(orderUp.Order.TrackStopBackPrice)
 					oe.OE.Order.PositionSide = orderUp.Order.PositionSide
 					//	oe.OE.Order.TrackStopBackPrice = tsbp
 					//	oe.OE.Order.TrackStopGoPrice = tsgp
@@ -489,16 +488,21 @@ func (as *apiService) CoinFutureUserDataWebsocket(urwr UserDataWebsocketRequest)
 		}
 	}()
 
-	go as.future_exitHandler(c, done, urwr.ListenKey)
+	go key.keepAliveLoop(done)
 	return aech, done, nil
 }
 
 func (as *apiService) CoinAccountInfoWebsocket(udwr UserDataWebsocketRequest) (chan *CoinAccountInfo, chan struct{}, error) {
-	url := fmt.Sprintf("wss://dstream.binance.com/ws/%s@account", udwr.ListenKey)
+	key := &userDataKey{
+		key:       udwr.ListenKey,
+		format:    "wss://dstream.binance.com/ws/%s@account",
+		start:     as.StartCoinFutureUserDataStream,
+		keepAlive: as.KeepAliveCoinFutureUserDataStream,
+	}
 
-	c, _, err := websocket.DefaultDialer.Dial(url, nil)
+	c, err := as.dialWebsocket("userData", key.url)
 	if err != nil {
-		log.Fatal("dial:", err)
+		return nil, nil, err
 	}
 
 	done := make(chan struct{})
@@ -534,7 +538,7 @@ func (as *apiService) CoinAccountInfoWebsocket(udwr UserDataWebsocketRequest) (c
 				}{}
 				if err := json.Unmarshal(message, &webCai); err != nil {
 					Logger.Error("depth wsUnmarshal failed ", zap.Error(err))
-					return
+					continue
 				}
 
 				coinAccountWebInfo := &CoinAccountInfo{
@@ -555,6 +559,6 @@ func (as *apiService) CoinAccountInfoWebsocket(udwr UserDataWebsocketRequest) (c
 
 		}
 	}()
-	go as.future_exitHandler(c, done, udwr.ListenKey)
+	go key.keepAliveLoop(done)
 	return acc, done, nil
 }
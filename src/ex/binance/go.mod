@@ -1,3 +1,9 @@
 module binance
 
 go 1.16
+
+require (
+	github.com/gorilla/websocket v1.5.3
+	github.com/pkg/errors v0.9.1
+	go.uber.org/zap v1.28.0
+)
@@ -0,0 +1,92 @@
+package binance
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+)
+
+// websocket 连接使用的拨号器,用 SetWebsocketConfig 修改
+var Dialer = &websocket.Dialer{
+	Proxy:            nil,
+	HandshakeTimeout: 45 * time.Second,
+}
+
+// http 和 websocket 连接配置
+type HTTPConfig struct {
+	Timeout            time.Duration // 单个请求的超时,包括读取响应
+	DialTimeout        time.Duration // 建立连接的超时
+	IdleConnTimeout    time.Duration // 空闲连接保持时间
+	MaxIdleConns       int           // 最大空闲连接数
+	Proxy              string        // 空 : 不使用代理 env : 读取 HTTPS_PROXY 等环境变量 其他 : 代理地址
+	InsecureSkipVerify bool          // 跳过 TLS 证书校验,只用于调试
+}
+
+func DefaultHTTPConfig() HTTPConfig {
+	return HTTPConfig{
+		Timeout:         10 * time.Second,
+		DialTimeout:     5 * time.Second,
+		IdleConnTimeout: 90 * time.Second,
+		MaxIdleConns:    30,
+	}
+}
+
+func (c HTTPConfig) proxy() (func(*http.Request) (*url.URL, error), error) {
+	switch c.Proxy {
+	case "":
+		return nil, nil
+	case "env":
+		return http.ProxyFromEnvironment, nil
+	}
+	u, err := url.Parse(c.Proxy)
+	if err != nil || u.Host == "" {
+		return nil, errors.Errorf("invalid proxy %q", c.Proxy)
+	}
+	return http.ProxyURL(u), nil
+}
+
+func (c HTTPConfig) tls() *tls.Config {
+	return &tls.Config{InsecureSkipVerify: c.InsecureSkipVerify}
+}
+
+// 创建连接池复用的 http 客户端,每个 apiService 一个
+func NewHTTPClient(c HTTPConfig) (*http.Client, error) {
+	proxy, err := c.proxy()
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{
+		Timeout: c.Timeout,
+		Transport: &http.Transport{
+			Proxy: proxy,
+			DialContext: (&net.Dialer{
+				Timeout:   c.DialTimeout,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			MaxIdleConns:        c.MaxIdleConns,
+			MaxIdleConnsPerHost: c.MaxIdleConns,
+			IdleConnTimeout:     c.IdleConnTimeout,
+			TLSHandshakeTimeout: c.DialTimeout,
+			TLSClientConfig:     c.tls(),
+		},
+	}, nil
+}
+
+// 修改 websocket 的代理和 TLS 配置
+func SetWebsocketConfig(c HTTPConfig) error {
+	proxy, err := c.proxy()
+	if err != nil {
+		return err
+	}
+	Dialer = &websocket.Dialer{
+		Proxy:            proxy,
+		HandshakeTimeout: 45 * time.Second,
+		TLSClientConfig:  c.tls(),
+	}
+	return nil
+}
@@ -27,4 +27,6 @@ type TimeInForce string
 var (
 	GTC = TimeInForce("GTC") // 订单一直有效直到取消
 	IOC = TimeInForce("IOC") // 订单必须立即以限价或更佳的价格成交，否则自动取消
+	FOK = TimeInForce("FOK") // 订单必须立即全部成交，否则全部取消
+	GTX = TimeInForce("GTX") // 只做maker，会立即成交时拒绝下单
 )
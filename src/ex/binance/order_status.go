@@ -17,6 +17,8 @@ type PosithonSideStatus string
 
 type EventType string
 
+type IncomeType string
+
 var (
 	StatusNew             = OrderStatus("NEW")
 	StatusPartiallyFilled = OrderStatus("PARTIALLY_FILLED")
@@ -26,10 +28,16 @@ var (
 	StatusInsurance       = OrderStatus("NEW_INSURANCE")
 	StatusADL             = OrderStatus("NEW_ADL")
 
-	TypeLimit      = OrderType("LIMIT")
-	TypeMarket     = OrderType("MARKET")
-	TypeSTOP       = OrderType("STOP")
-	TypeTakeProfit = OrderType("TAKE_PROFIT")
+	TypeLimit              = OrderType("LIMIT")
+	TypeMarket             = OrderType("MARKET")
+	TypeSTOP               = OrderType("STOP")
+	TypeTakeProfit         = OrderType("TAKE_PROFIT")
+	TypeStopMarket         = OrderType("STOP_MARKET")
+	TypeTakeProfitMarket   = OrderType("TAKE_PROFIT_MARKET")
+	TypeTrailingStopMarket = OrderType("TRAILING_STOP_MARKET")
+
+	WorkingContractPrice = "CONTRACT_PRICE" // 按合约最新价触发
+	WorkingMarkPrice     = "MARK_PRICE"     // 按标记价格触发
 
 	SideBuy  = OrderSide("BUY")
 	SideSell = OrderSide("SELL")
@@ -49,4 +57,10 @@ var (
 
 	PosithonSingleSide = PosithonSideStatus("false") //单向持仓
 	PosithonBothSide   = PosithonSideStatus("true")  //双向持仓
+
+	IncomeTransfer       = IncomeType("TRANSFER")
+	IncomeRealizedPnl    = IncomeType("REALIZED_PNL")
+	IncomeFundingFee     = IncomeType("FUNDING_FEE") // 资金费用
+	IncomeCommission     = IncomeType("COMMISSION")
+	IncomeInsuranceClear = IncomeType("INSURANCE_CLEAR")
 )
@@ -0,0 +1,263 @@
+package binance
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 请求限频
+// 发送前按本地估算的权重和下单次数排队,收到响应后用币安返回的 X-MBX-USED-WEIGHT-1M 和 X-MBX-ORDER-COUNT-* 校正
+// 429/418 按 Retry-After 暂停所有请求,5xx 和超时按指数退避重试
+type RateLimiter struct {
+	sync.Mutex
+	WeightLimit   int           // 每分钟权重上限
+	OrderLimit10s int           // 每10秒下单上限
+	OrderLimitDay int           // 每天下单上限,0 不限制
+	Reserve       float64       // 用到上限的比例后开始等待,留给撤单等紧急请求
+	MaxRetry      int           // 5xx 和超时的最大重试次数
+	Backoff       time.Duration // 第一次重试的等待时间,之后每次翻倍
+
+	weight      window
+	orders10s   window
+	ordersDay   window
+	bannedUntil time.Time
+
+	throttled int64
+	retried   int64
+	banned    int64
+}
+
+// 固定时间窗口内的计数
+type window struct {
+	size  time.Duration
+	start time.Time
+	used  int
+}
+
+func (w *window) at(now time.Time) *window {
+	if start := now.Truncate(w.size); start.After(w.start) {
+		w.start, w.used = start, 0
+	}
+	return w
+}
+
+// 限频的当前用量
+type RateLimitStats struct {
+	UsedWeight  int       // 本分钟已用权重
+	WeightLimit int       // 每分钟权重上限
+	Orders10s   int       // 10秒内下单次数
+	OrdersDay   int       // 当天下单次数
+	Throttled   int64     // 因为接近上限等待过的请求数
+	Retried     int64     // 重试次数
+	Banned      int64     // 收到 429/418 的次数
+	BannedUntil time.Time // 暂停请求到这个时间
+}
+
+// 合约接口的默认限制
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{
+		WeightLimit:   2400,
+		OrderLimit10s: 300,
+		Reserve:       0.9,
+		MaxRetry:      3,
+		Backoff:       500 * time.Millisecond,
+		weight:        window{size: time.Minute},
+		orders10s:     window{size: 10 * time.Second},
+		ordersDay:     window{size: 24 * time.Hour},
+	}
+}
+
+func (b *binance) RateLimit() RateLimitStats {
+	return b.Service.RateLimit()
+}
+
+func (as *apiService) RateLimit() RateLimitStats {
+	return as.Limiter.Stats()
+}
+
+func (l *RateLimiter) Stats() RateLimitStats {
+	l.Lock()
+	defer l.Unlock()
+	now := time.Now()
+	return RateLimitStats{
+		UsedWeight:  l.weight.at(now).used,
+		WeightLimit: l.WeightLimit,
+		Orders10s:   l.orders10s.at(now).used,
+		OrdersDay:   l.ordersDay.at(now).used,
+		Throttled:   l.throttled,
+		Retried:     l.retried,
+		Banned:      l.banned,
+		BannedUntil: l.bannedUntil,
+	}
+}
+
+// 等到可以发送,并预先记上这次请求的权重和下单次数
+func (l *RateLimiter) Wait(ctx context.Context, weight int, order bool) error {
+	throttled := false
+	for {
+		l.Lock()
+		now := time.Now()
+		wait := l.bannedUntil.Sub(now)
+		if w := l.weight.at(now); float64(w.used+weight) > float64(l.WeightLimit)*l.Reserve && w.used > 0 {
+			wait = maxDuration(wait, w.start.Add(w.size).Sub(now))
+		}
+		if order {
+			if w := l.orders10s.at(now); l.OrderLimit10s > 0 && float64(w.used+1) > float64(l.OrderLimit10s)*l.Reserve {
+				wait = maxDuration(wait, w.start.Add(w.size).Sub(now))
+			}
+			if w := l.ordersDay.at(now); l.OrderLimitDay > 0 && w.used+1 > l.OrderLimitDay {
+				wait = maxDuration(wait, w.start.Add(w.size).Sub(now))
+			}
+		}
+		if wait <= 0 {
+			l.weight.used += weight
+			if order {
+				l.orders10s.used++
+				l.ordersDay.used++
+			}
+			if throttled {
+				l.throttled++
+			}
+			l.Unlock()
+			return nil
+		}
+		l.Unlock()
+		throttled = true
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// 用响应头校正用量,429/418 时按 Retry-After 暂停
+func (l *RateLimiter) Update(resp *http.Response) {
+	l.Lock()
+	defer l.Unlock()
+	now := time.Now()
+	for key, vals := range resp.Header {
+		if len(vals) == 0 {
+			continue
+		}
+		n, err := strconv.Atoi(vals[0])
+		if err != nil {
+			continue
+		}
+		key = strings.ToUpper(key)
+		switch {
+		case key == "X-MBX-USED-WEIGHT-1M":
+			l.weight.at(now).used = n
+		case key == "X-MBX-ORDER-COUNT-10S":
+			l.orders10s.at(now).used = n
+		case key == "X-MBX-ORDER-COUNT-1D":
+			l.ordersDay.at(now).used = n
+		}
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusTeapot {
+		l.banned++
+		wait := l.Backoff
+		if sec, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			wait = time.Duration(sec) * time.Second
+		}
+		if until := now.Add(wait); until.After(l.bannedUntil) {
+			l.bannedUntil = until
+		}
+	}
+}
+
+// 是否需要重试,返回等待时间
+// 撤单带 origClientOrderId 时直接重试,下单带 newClientOrderId 时先按 clientOrderId 查询,订单不存在才重发
+func (l *RateLimiter) retry(attempt int, method string, params map[string]string, resp *http.Response, err error) (time.Duration, bool) {
+	if attempt >= l.MaxRetry {
+		return 0, false
+	}
+	if resp != nil && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusTeapot) {
+		// 429 和 418 的请求没有被执行,Wait 会等到 Retry-After 之后
+		l.Lock()
+		l.retried++
+		l.Unlock()
+		return 0, true
+	}
+	if err == nil && (resp == nil || resp.StatusCode < 500) {
+		return 0, false
+	}
+	if err != nil {
+		if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+			return 0, false
+		}
+	}
+	if method != "GET" && params["newClientOrderId"] == "" && params["origClientOrderId"] == "" {
+		return 0, false
+	}
+	l.Lock()
+	l.retried++
+	l.Unlock()
+	return l.Backoff << uint(attempt), true
+}
+
+// 下单超时或 5xx 时订单可能已经被执行,重发前需要先查询
+// 429/418 的请求没有被执行,可以直接重发
+func needLookup(method string, params map[string]string, resp *http.Response) bool {
+	if method != "POST" || params["newClientOrderId"] == "" {
+		return false
+	}
+	return resp == nil || (resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusTeapot)
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// 接口权重,没有列出的按 1 计算
+func requestWeight(method, endpoint string, params map[string]string) int {
+	path := endpoint[strings.LastIndex(endpoint, "/")+1:]
+	limit, _ := strconv.Atoi(params["limit"])
+	switch path {
+	case "klines":
+		switch {
+		case limit == 0 || limit <= 100:
+			return 1
+		case limit <= 500:
+			return 2
+		case limit <= 1000:
+			return 5
+		}
+		return 10
+	case "depth":
+		switch {
+		case limit == 0 || limit <= 50:
+			return 2
+		case limit <= 100:
+			return 5
+		case limit <= 500:
+			return 10
+		}
+		return 20
+	case "openOrders", "24hr":
+		if params["symbol"] == "" {
+			return 40
+		}
+	case "allOrders", "userTrades", "account", "balance", "positionRisk", "batchOrders":
+		return 5
+	}
+	return 1
+}
+
+// 会计入下单次数的请求
+func isOrderRequest(method, endpoint string) bool {
+	if method != "POST" {
+		return false
+	}
+	return strings.HasSuffix(endpoint, "/order") || strings.HasSuffix(endpoint, "/batchOrders")
+}
@@ -0,0 +1,250 @@
+package binance
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// 下单返回 5xx 后先查询,订单已经存在时不重发
+func Test_RequestLookupOrder(t *testing.T) {
+	var posts, gets int
+	placed := false
+	first := http.StatusServiceUnavailable
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "POST":
+			posts++
+			if posts == 1 {
+				w.WriteHeader(first)
+				return
+			}
+			placed = true
+			w.Write([]byte(`{"clientOrderId":"c1","status":"NEW"}`))
+		case "GET":
+			gets++
+			if r.URL.Query().Get("origClientOrderId") != "c1" || r.URL.Query().Get("symbol") != "ETHUSDT" {
+				t.Errorf("query %v", r.URL.RawQuery)
+			}
+			if !placed {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"code":-2013,"msg":"Order does not exist."}`))
+				return
+			}
+			w.Write([]byte(`{"clientOrderId":"c1","status":"FILLED"}`))
+		}
+	}))
+	defer srv.Close()
+
+	as := &apiService{URL: srv.URL, Ctx: context.Background(), Limiter: NewRateLimiter(), Client: srv.Client()}
+	as.Limiter.Backoff = time.Millisecond
+	params := map[string]string{"symbol": "ETHUSDT", "newClientOrderId": "c1"}
+	body := func() string {
+		t.Helper()
+		resp, err := as.request("POST", "fapi/v1/order", params, false, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		text, _ := ioutil.ReadAll(resp.Body)
+		return string(text)
+	}
+
+	// 5xx 时订单没有执行,查询不到后重发
+	if res := body(); posts != 2 || gets != 1 || res != `{"clientOrderId":"c1","status":"NEW"}` {
+		t.Errorf("posts %d gets %d res %v", posts, gets, res)
+	}
+
+	// 5xx 时订单已经执行,返回查询结果
+	posts, gets = 0, 0
+	if res := body(); posts != 1 || gets != 1 || res != `{"clientOrderId":"c1","status":"FILLED"}` {
+		t.Errorf("posts %d gets %d res %v", posts, gets, res)
+	}
+
+	// 429 的请求没有执行,直接重发
+	posts, gets, placed, first = 0, 0, false, http.StatusTooManyRequests
+	if res := body(); posts != 2 || gets != 0 || res != `{"clientOrderId":"c1","status":"NEW"}` {
+		t.Errorf("posts %d gets %d res %v", posts, gets, res)
+	}
+}
+
+func Test_WindowRollover(t *testing.T) {
+	w := &window{size: time.Minute}
+	t0 := time.Date(2022, 6, 1, 0, 0, 10, 0, time.UTC)
+	w.at(t0).used = 5
+	if w.at(t0.Add(49*time.Second)).used != 5 {
+		t.Error("reset inside window")
+	}
+	if got := w.at(t0.Add(50 * time.Second)); got.used != 0 || !got.start.Equal(t0.Add(50*time.Second)) {
+		t.Errorf("window after rollover %+v", got)
+	}
+	// 时间回退时不重置
+	w.used = 3
+	if w.at(t0).used != 3 {
+		t.Error("reset on earlier time")
+	}
+}
+
+func Test_WaitReserve(t *testing.T) {
+	l := NewRateLimiter()
+	l.WeightLimit, l.OrderLimit10s = 100, 10
+	// 加长窗口,测试期间不会跨过窗口边界
+	l.weight.size, l.orders10s.size = 24*time.Hour, 24*time.Hour
+	wait := func(weight int, order bool) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		return l.Wait(ctx, weight, order)
+	}
+
+	// 用量到上限的 90% 之前不等待
+	if err := wait(90, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := wait(1, false); err == nil {
+		t.Error("weight over reserve not throttled")
+	}
+	if s := l.Stats(); s.UsedWeight != 90 {
+		t.Errorf("used weight %v, throttled request counted", s.UsedWeight)
+	}
+
+	// 窗口里第一个请求不受限制
+	l.weight.used = 0
+	if err := wait(200, false); err != nil {
+		t.Error(err)
+	}
+
+	l.weight.used = 0
+	for i := 0; i < 9; i++ {
+		if err := wait(1, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := wait(1, true); err == nil {
+		t.Error("orders over reserve not throttled")
+	}
+	if err := wait(1, false); err != nil {
+		t.Error("non-order request throttled by order limit")
+	}
+
+	// 封禁期间所有请求等待
+	l.bannedUntil = time.Now().Add(time.Minute)
+	if err := wait(0, false); err == nil {
+		t.Error("request sent while banned")
+	}
+}
+
+func Test_Update(t *testing.T) {
+	l := NewRateLimiter()
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}
+	resp.Header.Set("X-MBX-USED-WEIGHT-1M", "120")
+	resp.Header.Set("x-mbx-order-count-10s", "7")
+	resp.Header.Set("X-MBX-ORDER-COUNT-1D", "not a number")
+	l.Update(resp)
+	if s := l.Stats(); s.UsedWeight != 120 || s.Orders10s != 7 || s.OrdersDay != 0 || s.Banned != 0 {
+		t.Errorf("stats %+v", s)
+	}
+
+	now := time.Now()
+	resp = &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}
+	resp.Header.Set("Retry-After", "30")
+	l.Update(resp)
+	if s := l.Stats(); s.Banned != 1 || s.BannedUntil.Before(now.Add(30*time.Second)) || s.BannedUntil.After(now.Add(31*time.Second)) {
+		t.Errorf("banned %v until %v", s.Banned, s.BannedUntil)
+	}
+
+	// 更短的封禁不覆盖,没有 Retry-After 时按 Backoff
+	resp = &http.Response{StatusCode: http.StatusTeapot, Header: http.Header{}}
+	l.Update(resp)
+	if s := l.Stats(); s.Banned != 2 || s.BannedUntil.Before(now.Add(30*time.Second)) {
+		t.Errorf("banned %v until %v", s.Banned, s.BannedUntil)
+	}
+	l = NewRateLimiter()
+	l.Update(resp)
+	if until := l.Stats().BannedUntil; until.Before(now.Add(l.Backoff)) || until.After(time.Now().Add(l.Backoff)) {
+		t.Errorf("banned until %v, want backoff", until)
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func Test_Retry(t *testing.T) {
+	l := NewRateLimiter()
+	status := func(code int) *http.Response { return &http.Response{StatusCode: code} }
+	order := map[string]string{"newClientOrderId": "c1"}
+	cancel := map[string]string{"origClientOrderId": "c1"}
+	for _, c := range []struct {
+		name    string
+		attempt int
+		method  string
+		params  map[string]string
+		resp    *http.Response
+		err     error
+		backoff time.Duration
+		ok      bool
+		lookup  bool
+	}{
+		{"ok", 0, "GET", nil, status(200), nil, 0, false, false},
+		{"client error", 0, "GET", nil, status(400), nil, 0, false, false},
+		{"get 5xx", 1, "GET", nil, status(502), nil, l.Backoff << 1, true, false},
+		{"get timeout", 0, "GET", nil, nil, timeoutError{}, l.Backoff, true, false},
+		{"other error", 0, "GET", nil, nil, context.Canceled, 0, false, false},
+		{"max retry", 3, "GET", nil, status(502), nil, 0, false, false},
+		{"banned", 0, "POST", nil, status(429), nil, 0, true, false},
+		{"order banned", 0, "POST", order, status(418), nil, 0, true, false},
+		{"order 5xx", 0, "POST", order, status(503), nil, l.Backoff, true, true},
+		{"order timeout", 0, "POST", order, nil, timeoutError{}, l.Backoff, true, true},
+		{"order without id", 0, "POST", nil, status(503), nil, 0, false, false},
+		{"cancel 5xx", 0, "DELETE", cancel, status(503), nil, l.Backoff, true, false},
+		{"cancel without id", 0, "DELETE", nil, nil, timeoutError{}, 0, false, false},
+	} {
+		backoff, ok := l.retry(c.attempt, c.method, c.params, c.resp, c.err)
+		if backoff != c.backoff || ok != c.ok {
+			t.Errorf("%v: retry %v %v, want %v %v", c.name, backoff, ok, c.backoff, c.ok)
+		}
+		if ok && needLookup(c.method, c.params, c.resp) != c.lookup {
+			t.Errorf("%v: lookup %v", c.name, !c.lookup)
+		}
+	}
+	if s := l.Stats(); s.Retried != 7 {
+		t.Errorf("retried %v, want 7", s.Retried)
+	}
+}
+
+func Test_RequestWeight(t *testing.T) {
+	for _, c := range []struct {
+		method, endpoint string
+		params           map[string]string
+		want             int
+	}{
+		{"GET", "fapi/v1/klines", nil, 1},
+		{"GET", "fapi/v1/klines", map[string]string{"limit": "100"}, 1},
+		{"GET", "fapi/v1/klines", map[string]string{"limit": "500"}, 2},
+		{"GET", "fapi/v1/klines", map[string]string{"limit": "1000"}, 5},
+		{"GET", "fapi/v1/klines", map[string]string{"limit": "1500"}, 10},
+		{"GET", "fapi/v1/depth", nil, 2},
+		{"GET", "fapi/v1/depth", map[string]string{"limit": "100"}, 5},
+		{"GET", "fapi/v1/depth", map[string]string{"limit": "500"}, 10},
+		{"GET", "fapi/v1/depth", map[string]string{"limit": "1000"}, 20},
+		{"GET", "fapi/v1/openOrders", nil, 40},
+		{"GET", "fapi/v1/openOrders", map[string]string{"symbol": "ETHUSDT"}, 1},
+		{"GET", "fapi/v1/ticker/24hr", nil, 40},
+		{"GET", "fapi/v2/positionRisk", nil, 5},
+		{"POST", "fapi/v1/batchOrders", nil, 5},
+		{"POST", "fapi/v1/order", nil, 1},
+	} {
+		if got := requestWeight(c.method, c.endpoint, c.params); got != c.want {
+			t.Errorf("%v %v %v weight %v, want %v", c.method, c.endpoint, c.params, got, c.want)
+		}
+	}
+	if !isOrderRequest("POST", "fapi/v1/order") || !isOrderRequest("POST", "dapi/v1/batchOrders") ||
+		isOrderRequest("DELETE", "fapi/v1/order") || isOrderRequest("POST", "fapi/v1/listenKey") {
+		t.Error("order request")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -42,7 +43,8 @@ type rawExecutedFutureOrder struct {
 	Time          float64 `json:"time"`
 }
 
-func (as *apiService) NewFutureOrder(or NewFutureOrderRequest) (*FutureProcessedOrder, error) {
+// 下单参数,市价类订单不传价格和 timeInForce,closePosition 时不传数量
+func futureOrderParams(or NewFutureOrderRequest, qtyPrec, pricePrec int) map[string]string {
 	params := make(map[string]string)
 	params["symbol"] = or.Symbol
 	params["side"] = string(or.Side)
@@ -52,17 +54,48 @@ func (as *apiService) NewFutureOrder(or NewFutureOrderRequest) (*FutureProcessed
 		params["positionSide"] = string(or.PositionSide)
 	}
 
-	params["timeInForce"] = string(or.TimeInForce)
-	params["quantity"] = strconv.FormatFloat(or.Quantity, 'f', 8, 64)
-	params["price"] = strconv.FormatFloat(or.Price, 'f', 8, 64)
+	limit := or.Type == TypeLimit || or.Type == TypeSTOP || or.Type == TypeTakeProfit
+	if limit {
+		params["timeInForce"] = string(or.TimeInForce)
+		params["price"] = strconv.FormatFloat(or.Price, 'f', pricePrec, 64)
+	}
+	if or.ClosePosition != "true" {
+		params["quantity"] = strconv.FormatFloat(or.Quantity, 'f', qtyPrec, 64)
+	}
 	params["timestamp"] = strconv.FormatInt(unixMillis(or.Timestamp), 10)
 
 	if or.NewClientOrderID != "" {
 		params["newClientOrderId"] = or.NewClientOrderID
 	}
 	if or.StopPrice != 0.0 {
-		params["stopPrice"] = strconv.FormatFloat(or.StopPrice, 'f', 8, 64)
+		params["stopPrice"] = strconv.FormatFloat(or.StopPrice, 'f', pricePrec, 64)
+	}
+	if or.ReduceOnly != "" {
+		params["reduceOnly"] = or.ReduceOnly
+	}
+	if or.ClosePosition != "" {
+		params["closePosition"] = or.ClosePosition
+	}
+	if or.ActivationPrice != 0.0 {
+		params["activationPrice"] = strconv.FormatFloat(or.ActivationPrice, 'f', pricePrec, 64)
+	}
+	if or.CallbackRate != 0.0 {
+		params["callbackRate"] = strconv.FormatFloat(or.CallbackRate, 'f', -1, 64)
 	}
+	if or.WorkingType != "" {
+		params["workingType"] = or.WorkingType
+	}
+	if or.PriceProtect != "" {
+		params["priceProtect"] = or.PriceProtect
+	}
+	if or.NewOrderRespType != "" {
+		params["newOrderRespType"] = or.NewOrderRespType
+	}
+	return params
+}
+
+func (as *apiService) NewFutureOrder(or NewFutureOrderRequest) (*FutureProcessedOrder, error) {
+	params := futureOrderParams(or, 8, 8)
 
 	res, err := as.request("POST", "fapi/v1/order", params, true, true)
 	if err != nil {
@@ -78,60 +111,11 @@ func (as *apiService) NewFutureOrder(or NewFutureOrderRequest) (*FutureProcessed
 		return nil, as.handleError(textRes)
 	}
 
-	rawOrder := struct {
-		Symbol        string  `json:"symbol"`
-		CumQuote      string  `json:"cumQuote"`      // 成交金额
-		ExecutedQty   string  `json:"executedQty"`   // 成交量
-		ClientOrderId string  `json:"clientOrderId"` // 用户自定义订单号
-		OrderId       int64   `json:"orderId"`       // 系统订单号
-		AvgPrice      string  `json:"avgPrice"`      // 平均成交价
-		OrigQty       string  `json:"origQty"`       // 原始委托数量
-		Price         string  `json:"price"`         // 委托价格
-		Side          string  `json:"side"`          // 买卖方向
-		PositionSide  string  `json:"positionSide"`  // 持仓方向
-		Status        string  `json:"status"`        // 订单状态
-		StopPrice     string  `json:"stopPrice"`     // 触发价
-		ClosePosition bool    `json:"closePosition"` // 是否条件全平仓
-		TimeInForce   string  `json:"timeInForce"`   // 有效方法
-		Type          string  `json:"type"`          // 订单类型
-		OrigType      string  `json:"origType"`      // 触发前订单类型
-		ActivatePrice string  `json:"activatePrice"` // 跟踪止损激活价格， 仅`TRAILING_STOP_MARKET` 订单返回此字段
-		PriceRate     string  `json:"priceRate"`     // 跟踪止损回调比例， 仅`TRAILING_STOP_MARKET` 订单返回此字段
-		WorkingType   string  `json:"workingType"`   // 条件价格触发类型
-		PriceProtect  bool    `json:"priceProtect"`  // 是否开启条件单触发保护
-		UpdateTime    float64 `json:"updateTime"`
-	}{}
-	if err := json.Unmarshal(textRes, &rawOrder); err != nil {
+	rawOrder := &rawFutureOrder{}
+	if err := json.Unmarshal(textRes, rawOrder); err != nil {
 		return nil, errors.Wrap(err, "rawOrder unmarshal failed")
 	}
-
-	cq, _ := floatFromString(rawOrder.CumQuote)
-	eq, _ := floatFromString(rawOrder.ExecutedQty)
-	ap, _ := floatFromString(rawOrder.AvgPrice)
-	oq, _ := floatFromString(rawOrder.OrigQty)
-	p, _ := floatFromString(rawOrder.Price)
-	sp, _ := floatFromString(rawOrder.StopPrice)
-	aep, _ := floatFromString(rawOrder.ActivatePrice)
-	pr, _ := floatFromString(rawOrder.PriceRate)
-	t, _ := timeFromUnixTimestampFloat(rawOrder.UpdateTime)
-
-	return &FutureProcessedOrder{
-		Symbol:        rawOrder.Symbol,
-		ClientOrderId: rawOrder.ClientOrderId,
-		OrderId:       rawOrder.OrderId,
-		CumQuote:      cq,
-		StopPrice:     sp,
-		ActivatePrice: aep,
-		PriceRate:     pr,
-		AvgPrice:      ap,
-		ExecutedQty:   eq,
-		OrigQty:       oq,
-		Price:         p,
-		Side:          rawOrder.Side,
-		PositionSide:  rawOrder.PositionSide,
-		Type:          rawOrder.Type,
-		UpdateTime:    t,
-	}, nil
+	return rawOrder.processed(), nil
 }
 
 func (as *apiService) NewOrder(or NewOrderRequest) (*ProcessedOrder, error) {
@@ -1662,3 +1646,82 @@ func executedOrderFromRaw(reo *rawExecutedOrder) (*ExecutedOrder, error) {
 		Time:          t,
 	}, nil
 }
+
+func (as *apiService) Income(ir IncomeRequest) ([]*IncomeInfo, error) {
+	return as.income("/fapi/v1/income", ir)
+}
+
+// u本位和币本位的收益流水格式相同,币本位的 tranId 是字符串
+func (as *apiService) income(path string, ir IncomeRequest) ([]*IncomeInfo, error) {
+	params := make(map[string]string)
+	params["timestamp"] = strconv.FormatInt(unixMillis(ir.Timestamp), 10)
+	if ir.Symbol != "" {
+		params["symbol"] = ir.Symbol
+	}
+	if ir.IncomeType != "" {
+		params["incomeType"] = string(ir.IncomeType)
+	}
+	if !ir.StartTime.IsZero() {
+		params["startTime"] = strconv.FormatInt(unixMillis(ir.StartTime), 10)
+	}
+	if !ir.EndTime.IsZero() {
+		params["endTime"] = strconv.FormatInt(unixMillis(ir.EndTime), 10)
+	}
+	if ir.Limit != 0 {
+		params["limit"] = strconv.Itoa(ir.Limit)
+	}
+	if ir.RecvWindow != 0 {
+		params["recvWindow"] = strconv.FormatInt(recvWindow(ir.RecvWindow), 10)
+	}
+
+	res, err := as.request("GET", path, params, true, true)
+	if err != nil {
+		return nil, err
+	}
+	textRes, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read response from Income.GET")
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return nil, as.handleError(textRes)
+	}
+	return incomes(textRes)
+}
+
+func incomes(textRes []byte) ([]*IncomeInfo, error) {
+	rawIncomes := []struct {
+		Symbol     string          `json:"symbol"`
+		IncomeType string          `json:"incomeType"`
+		Income     string          `json:"income"`
+		Asset      string          `json:"asset"`
+		Info       string          `json:"info"`
+		Time       float64         `json:"time"`
+		TranID     json.RawMessage `json:"tranId"`
+		TradeID    string          `json:"tradeId"`
+	}{}
+	if err := json.Unmarshal(textRes, &rawIncomes); err != nil {
+		return nil, errors.Wrap(err, "rawIncomes unmarshal failed")
+	}
+
+	res := make([]*IncomeInfo, 0, len(rawIncomes))
+	for _, ri := range rawIncomes {
+		t, err := timeFromUnixTimestampFloat(ri.Time)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot parse Time")
+		}
+		income, _ := floatFromString(ri.Income)
+		tranID, _ := strconv.ParseInt(strings.Trim(string(ri.TranID), `"`), 10, 64)
+		res = append(res, &IncomeInfo{
+			Symbol:     ri.Symbol,
+			IncomeType: IncomeType(ri.IncomeType),
+			Income:     income,
+			Asset:      ri.Asset,
+			Info:       ri.Info,
+			TranID:     tranID,
+			TradeID:    ri.TradeID,
+			Time:       t,
+		})
+	}
+	return res, nil
+}
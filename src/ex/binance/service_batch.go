@@ -0,0 +1,262 @@
+package binance
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	MaxBatchOrders = 5  // 批量下单每次最多5个
+	MaxBatchCancel = 10 // 批量撤单每次最多10个
+)
+
+// 下单和批量下单返回的订单
+type rawFutureOrder struct {
+	Symbol        string  `json:"symbol"`
+	CumQuote      string  `json:"cumQuote"`      // 成交金额
+	CumBase       string  `json:"cumBase"`       // 成交额(标的数量),币本位返回
+	ExecutedQty   string  `json:"executedQty"`   // 成交量
+	ClientOrderId string  `json:"clientOrderId"` // 用户自定义订单号
+	OrderId       int64   `json:"orderId"`       // 系统订单号
+	AvgPrice      string  `json:"avgPrice"`      // 平均成交价
+	OrigQty       string  `json:"origQty"`       // 原始委托数量
+	Price         string  `json:"price"`         // 委托价格
+	Side          string  `json:"side"`          // 买卖方向
+	PositionSide  string  `json:"positionSide"`  // 持仓方向
+	Status        string  `json:"status"`        // 订单状态
+	StopPrice     string  `json:"stopPrice"`     // 触发价
+	ClosePosition bool    `json:"closePosition"` // 是否条件全平仓
+	TimeInForce   string  `json:"timeInForce"`   // 有效方法
+	Type          string  `json:"type"`          // 订单类型
+	OrigType      string  `json:"origType"`      // 触发前订单类型
+	ActivatePrice string  `json:"activatePrice"` // 跟踪止损激活价格， 仅`TRAILING_STOP_MARKET` 订单返回此字段
+	PriceRate     string  `json:"priceRate"`     // 跟踪止损回调比例， 仅`TRAILING_STOP_MARKET` 订单返回此字段
+	WorkingType   string  `json:"workingType"`   // 条件价格触发类型
+	PriceProtect  bool    `json:"priceProtect"`  // 是否开启条件单触发保护
+	UpdateTime    float64 `json:"updateTime"`
+}
+
+func (r *rawFutureOrder) processed() *FutureProcessedOrder {
+	cq, _ := floatFromString(r.CumQuote)
+	eq, _ := floatFromString(r.ExecutedQty)
+	ap, _ := floatFromString(r.AvgPrice)
+	oq, _ := floatFromString(r.OrigQty)
+	p, _ := floatFromString(r.Price)
+	sp, _ := floatFromString(r.StopPrice)
+	aep, _ := floatFromString(r.ActivatePrice)
+	pr, _ := floatFromString(r.PriceRate)
+	t, _ := timeFromUnixTimestampFloat(r.UpdateTime)
+
+	return &FutureProcessedOrder{
+		Symbol:        r.Symbol,
+		ClientOrderId: r.ClientOrderId,
+		OrderId:       r.OrderId,
+		CumQuote:      cq,
+		StopPrice:     sp,
+		ActivatePrice: aep,
+		PriceRate:     pr,
+		AvgPrice:      ap,
+		ExecutedQty:   eq,
+		OrigQty:       oq,
+		Price:         p,
+		Side:          r.Side,
+		PositionSide:  r.PositionSide,
+		Status:        r.Status,
+		ClosePosition: r.ClosePosition,
+		TimeInForce:   r.TimeInForce,
+		Type:          r.Type,
+		OrigType:      r.OrigType,
+		WorkingType:   r.WorkingType,
+		PriceProtect:  r.PriceProtect,
+		UpdateTime:    t,
+	}
+}
+
+// 批量下单,最多 MaxBatchOrders 个
+type NewBatchFutureOrderRequest struct {
+	Orders     []NewFutureOrderRequest
+	RecvWindow time.Duration
+	Timestamp  time.Time
+}
+
+// 批量撤单,OrderIDs 和 OrigClientOrderIDs 二选一,最多 MaxBatchCancel 个
+type CancelBatchFutureOrderRequest struct {
+	Symbol             string
+	OrderIDs           []int64
+	OrigClientOrderIDs []string
+	RecvWindow         time.Duration
+	Timestamp          time.Time
+}
+
+// 撤销交易对的全部挂单
+type CancelAllFutureOrderRequest struct {
+	Symbol     string
+	RecvWindow time.Duration
+	Timestamp  time.Time
+}
+
+// 批量接口中单个订单的结果,失败时 Err 不为空
+type BatchFutureOrder struct {
+	Order *FutureProcessedOrder
+	Err   error
+}
+
+func (b *binance) NewBatchFutureOrder(br NewBatchFutureOrderRequest) ([]*BatchFutureOrder, error) {
+	return b.Service.NewBatchFutureOrder(br)
+}
+
+func (b *binance) CancelBatchFutureOrder(cr CancelBatchFutureOrderRequest) ([]*BatchFutureOrder, error) {
+	return b.Service.CancelBatchFutureOrder(cr)
+}
+
+func (b *binance) CancelAllFutureOrder(cr CancelAllFutureOrderRequest) error {
+	return b.Service.CancelAllFutureOrder(cr)
+}
+
+func (b *binance) CoinNewBatchFutureOrder(br NewBatchFutureOrderRequest) ([]*BatchFutureOrder, error) {
+	return b.Service.CoinNewBatchFutureOrder(br)
+}
+
+func (b *binance) CoinCancelBatchFutureOrder(cr CancelBatchFutureOrderRequest) ([]*BatchFutureOrder, error) {
+	return b.Service.CoinCancelBatchFutureOrder(cr)
+}
+
+func (b *binance) CoinCancelAllFutureOrder(cr CancelAllFutureOrderRequest) error {
+	return b.Service.CoinCancelAllFutureOrder(cr)
+}
+
+func (as *apiService) NewBatchFutureOrder(br NewBatchFutureOrderRequest) ([]*BatchFutureOrder, error) {
+	return as.batchFutureOrder("fapi/v1/batchOrders", br, 8, 8)
+}
+
+func (as *apiService) CoinNewBatchFutureOrder(br NewBatchFutureOrderRequest) ([]*BatchFutureOrder, error) {
+	return as.batchFutureOrder("dapi/v1/batchOrders", br, 0, 2)
+}
+
+func (as *apiService) CancelBatchFutureOrder(cr CancelBatchFutureOrderRequest) ([]*BatchFutureOrder, error) {
+	return as.cancelBatchFutureOrder("fapi/v1/batchOrders", cr)
+}
+
+func (as *apiService) CoinCancelBatchFutureOrder(cr CancelBatchFutureOrderRequest) ([]*BatchFutureOrder, error) {
+	return as.cancelBatchFutureOrder("dapi/v1/batchOrders", cr)
+}
+
+func (as *apiService) CancelAllFutureOrder(cr CancelAllFutureOrderRequest) error {
+	return as.cancelAllFutureOrder("fapi/v1/allOpenOrders", cr)
+}
+
+func (as *apiService) CoinCancelAllFutureOrder(cr CancelAllFutureOrderRequest) error {
+	return as.cancelAllFutureOrder("dapi/v1/allOpenOrders", cr)
+}
+
+func (as *apiService) batchFutureOrder(endpoint string, br NewBatchFutureOrderRequest, qtyPrec, pricePrec int) ([]*BatchFutureOrder, error) {
+	if len(br.Orders) == 0 || len(br.Orders) > MaxBatchOrders {
+		return nil, errors.Errorf("batch orders size %d out of [1, %d]", len(br.Orders), MaxBatchOrders)
+	}
+	orders := make([]map[string]string, 0, len(br.Orders))
+	for _, or := range br.Orders {
+		p := futureOrderParams(or, qtyPrec, pricePrec)
+		delete(p, "timestamp")
+		orders = append(orders, p)
+	}
+	batch, err := json.Marshal(orders)
+	if err != nil {
+		return nil, errors.Wrap(err, "batchOrders marshal failed")
+	}
+	params := map[string]string{
+		"batchOrders": string(batch),
+		"timestamp":   strconv.FormatInt(unixMillis(br.Timestamp), 10),
+	}
+	if br.RecvWindow != 0 {
+		params["recvWindow"] = strconv.FormatInt(recvWindow(br.RecvWindow), 10)
+	}
+	return as.batchRequest("POST", endpoint, params)
+}
+
+func (as *apiService) cancelBatchFutureOrder(endpoint string, cr CancelBatchFutureOrderRequest) ([]*BatchFutureOrder, error) {
+	n := len(cr.OrderIDs) + len(cr.OrigClientOrderIDs)
+	if n == 0 || n > MaxBatchCancel {
+		return nil, errors.Errorf("batch cancel size %d out of [1, %d]", n, MaxBatchCancel)
+	}
+	params := map[string]string{
+		"symbol":    cr.Symbol,
+		"timestamp": strconv.FormatInt(unixMillis(cr.Timestamp), 10),
+	}
+	if len(cr.OrderIDs) > 0 {
+		ids, _ := json.Marshal(cr.OrderIDs)
+		params["orderIdList"] = string(ids)
+	}
+	if len(cr.OrigClientOrderIDs) > 0 {
+		ids, _ := json.Marshal(cr.OrigClientOrderIDs)
+		params["origClientOrderIdList"] = string(ids)
+	}
+	if cr.RecvWindow != 0 {
+		params["recvWindow"] = strconv.FormatInt(recvWindow(cr.RecvWindow), 10)
+	}
+	return as.batchRequest("DELETE", endpoint, params)
+}
+
+// 批量接口返回数组,每一项是订单或者错误
+func (as *apiService) batchRequest(method, endpoint string, params map[string]string) ([]*BatchFutureOrder, error) {
+	res, err := as.request(method, endpoint, params, true, true)
+	if err != nil {
+		return nil, err
+	}
+	textRes, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read response from batchOrders")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 200 {
+		return nil, as.handleError(textRes)
+	}
+
+	rawItems := []json.RawMessage{}
+	if err := json.Unmarshal(textRes, &rawItems); err != nil {
+		return nil, errors.Wrap(err, "batchOrders unmarshal failed")
+	}
+	items := make([]*BatchFutureOrder, 0, len(rawItems))
+	for _, raw := range rawItems {
+		e := &Error{}
+		if err := json.Unmarshal(raw, e); err == nil && e.Code != 0 {
+			items = append(items, &BatchFutureOrder{Err: e})
+			continue
+		}
+		o := &rawFutureOrder{}
+		if err := json.Unmarshal(raw, o); err != nil {
+			items = append(items, &BatchFutureOrder{Err: errors.Wrap(err, "batch order unmarshal failed")})
+			continue
+		}
+		items = append(items, &BatchFutureOrder{Order: o.processed()})
+	}
+	return items, nil
+}
+
+func (as *apiService) cancelAllFutureOrder(endpoint string, cr CancelAllFutureOrderRequest) error {
+	params := map[string]string{
+		"symbol":    cr.Symbol,
+		"timestamp": strconv.FormatInt(unixMillis(cr.Timestamp), 10),
+	}
+	if cr.RecvWindow != 0 {
+		params["recvWindow"] = strconv.FormatInt(recvWindow(cr.RecvWindow), 10)
+	}
+	res, err := as.request("DELETE", endpoint, params, true, true)
+	if err != nil {
+		return err
+	}
+	textRes, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return errors.Wrap(err, "unable to read response from allOpenOrders")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 200 {
+		return as.handleError(textRes)
+	}
+	return nil
+}
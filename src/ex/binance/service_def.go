@@ -2,14 +2,12 @@ package binance
 
 import (
 	"context"
-	"crypto/tls"
 	"fmt"
-	"net"
+	"io/ioutil"
 	"net/http"
-	"net/url"
+	"strconv"
 	"time"
 
-	"github.com/gorilla/websocket"
 	"github.com/pkg/errors"
 )
 
@@ -20,7 +18,15 @@ import (
 type Service interface {
 	Ping() error
 	Time() (time.Time, error)
-	ExchangeInfo() (string, error)
+	CoinTime() (time.Time, error)
+	SyncServerTime(interval, maxDrift time.Duration, done chan struct{}) error
+	CoinSyncServerTime(interval, maxDrift time.Duration, done chan struct{}) error
+	ClockOffset() time.Duration
+	WebsocketEvents() chan *WebsocketStateEvent
+	NewCombinedStream() *CombinedStream
+	NewCoinCombinedStream() *CombinedStream
+	ExchangeInfo() (*ExchangeInfo, error)
+	CoinExchangeInfo() (*ExchangeInfo, error)
 
 	NewPrice(nb OrderNewPriceRequest) (*NewPrice, error)
 	OrderBook(obr OrderBookRequest) (*OrderBook, error)
@@ -50,6 +56,9 @@ type Service interface {
 	NewFutureOrder(nfr NewFutureOrderRequest) (*FutureProcessedOrder, error)
 
 	CancelFutureOrder(cfr CancelFutureOrderRequest) (*CanceledFutureOrder, error)
+	NewBatchFutureOrder(br NewBatchFutureOrderRequest) ([]*BatchFutureOrder, error)
+	CancelBatchFutureOrder(cr CancelBatchFutureOrderRequest) ([]*BatchFutureOrder, error)
+	CancelAllFutureOrder(cr CancelAllFutureOrderRequest) error
 
 	QueryOneFutureOrder(qfo QueryFutureOrderRequest) (*ExecutedFutureOrder, error)
 
@@ -72,6 +81,7 @@ type Service interface {
 	UserTradesHistory(uth UserTradesHistoryRequest) ([]*UserTradesHistoryInfo, error)
 
 	PremiumAndFundsRate(pfrr PremiumAndFundsRateRequest) (*PremiumAndFundsRateInfo, error)
+	Income(ir IncomeRequest) ([]*IncomeInfo, error)
 
 	PriceChangeSituation(pcsr PriceChangeSituationRequest) (*PriceChangeSituationInfo, error)
 
@@ -149,6 +159,12 @@ type Service interface {
 
 	//撤销订单
 	CoinCancelFutureOrder(cor CancelFutureOrderRequest) (*CanceledFutureOrder, error)
+	CoinNewBatchFutureOrder(br NewBatchFutureOrderRequest) ([]*BatchFutureOrder, error)
+	CoinCancelBatchFutureOrder(cr CancelBatchFutureOrderRequest) ([]*BatchFutureOrder, error)
+	CoinCancelAllFutureOrder(cr CancelAllFutureOrderRequest) error
+
+	// 限频用量
+	RateLimit() RateLimitStats
 
 	// 查询当前所有挂单
 	CoinQueryFutureOrder(qfo CoinQueryFutureOrderRequest) ([]*ExecutedFutureOrder, error)
@@ -160,6 +176,8 @@ type Service interface {
 	CoinFutureTradeWebsocket(twr TradeWebsocketRequest) (chan *AggTradeEvent, chan struct{}, error)
 
 	CoinUserTradesHistory(uth CoinUserTradesHistoryRequest) ([]*CoinUserTradesHistoryInfo, error)
+	CoinPremiumAndFundsRate(pfrr PremiumAndFundsRateRequest) ([]*PremiumAndFundsRateInfo, error)
+	CoinIncome(ir IncomeRequest) ([]*IncomeInfo, error)
 
 	CoinFutureKlineWebsocket(kwr KlineWebsocketRequest) (chan *KlineEvent, chan struct{}, error)
 
@@ -177,10 +195,15 @@ type Service interface {
 }
 
 type apiService struct {
-	URL    string
-	APIKey string
-	Signer Signer
-	Ctx    context.Context
+	URL     string
+	APIKey  string
+	Signer  Signer
+	Ctx     context.Context
+	Limiter *RateLimiter
+	Client  *http.Client
+	Clock   Clock
+
+	wsEvents chan *WebsocketStateEvent
 }
 
 // NewAPIService creates instance of Service.
@@ -188,44 +211,117 @@ type apiService struct {
 // If logger or ctx are not provided, NopLogger and Background context are used as default.
 // You can use context for one-time request cancel (e.g. when shutting down the app).
 func NewAPIService(url, apiKey string, signer Signer, ctx context.Context) Service {
+	as, _ := NewAPIServiceWithConfig(url, apiKey, signer, ctx, DefaultHTTPConfig())
+	return as
+}
 
+// NewAPIServiceWithConfig creates instance of Service with its own pooled http client.
+func NewAPIServiceWithConfig(url, apiKey string, signer Signer, ctx context.Context, cfg HTTPConfig) (Service, error) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
-	return &apiService{
-		URL:    url,
-		APIKey: apiKey,
-		Signer: signer,
-		Ctx:    ctx,
+	client, err := NewHTTPClient(cfg)
+	if err != nil {
+		return nil, err
 	}
+	return &apiService{
+		URL:     url,
+		APIKey:  apiKey,
+		Signer:  signer,
+		Ctx:     ctx,
+		Limiter: NewRateLimiter(),
+		Client:  client,
+
+		wsEvents: make(chan *WebsocketStateEvent, wsEventBuffer),
+	}, nil
 }
 
 func (as *apiService) request(method string, endpoint string, params map[string]string,
 	apiKey bool, sign bool) (*http.Response, error) {
-	transport := &http.Transport{
-		DialContext: (&net.Dialer{
-			Timeout:   5 * time.Second,
-			KeepAlive: 5 * time.Second,
-		}).DialContext,
-		MaxIdleConns:        30,               //最大空闲连接数
-		MaxIdleConnsPerHost: 60,               //最大与服务器的连接数  默认是2
-		IdleConnTimeout:     30 * time.Second, //空闲连接保持时间
-		Proxy: func(_ *http.Request) (*url.URL, error) {
-			return url.Parse("http://127.0.0.1:7890")
-		},
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, // disable verify
-	}
-	client := &http.Client{
-		Transport: transport,
+	url := fmt.Sprintf("%s/%s", as.URL, endpoint)
+	weight, order := requestWeight(method, endpoint, params), isOrderRequest(method, endpoint)
+
+	for attempt := 0; ; attempt++ {
+		if err := as.Limiter.Wait(as.Ctx, weight, order); err != nil {
+			return nil, errors.Wrap(err, "rate limit wait failed")
+		}
+		resp, err := as.do(method, url, params, apiKey, sign)
+		if resp != nil {
+			as.Limiter.Update(resp)
+		}
+		backoff, ok := as.Limiter.retry(attempt, method, params, resp, err)
+		if !ok {
+			if err != nil {
+				return nil, errors.Wrap(err, "client do failed")
+			}
+			return resp, nil
+		}
+		lookup := needLookup(method, params, resp)
+		if resp != nil {
+			ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		if backoff > 0 {
+			select {
+			case <-as.Ctx.Done():
+				return nil, errors.Wrap(as.Ctx.Err(), "request canceled")
+			case <-time.After(backoff):
+			}
+		}
+		if lookup {
+			// 订单已经存在时返回查询结果,不能重复下单
+			found, err := as.lookupOrder(endpoint, params, apiKey, sign)
+			if err != nil {
+				return nil, errors.Wrap(err, "order status unknown")
+			}
+			if found != nil {
+				return found, nil
+			}
+		}
 	}
+}
 
-	url := fmt.Sprintf("%s/%s", as.URL, endpoint)
+// 订单不存在的错误码
+const errOrderNotExist = -2013
+
+// 按 clientOrderId 查询下单结果,订单不存在时返回 nil, nil
+func (as *apiService) lookupOrder(endpoint string, params map[string]string, apiKey bool, sign bool) (*http.Response, error) {
+	query := map[string]string{
+		"symbol":            params["symbol"],
+		"origClientOrderId": params["newClientOrderId"],
+	}
+	if recvWindow, ok := params["recvWindow"]; ok {
+		query["recvWindow"] = recvWindow
+	}
+	if err := as.Limiter.Wait(as.Ctx, requestWeight("GET", endpoint, query), false); err != nil {
+		return nil, errors.Wrap(err, "rate limit wait failed")
+	}
+	resp, err := as.do("GET", fmt.Sprintf("%s/%s", as.URL, endpoint), query, apiKey, sign)
+	if err != nil {
+		return nil, errors.Wrap(err, "query order failed")
+	}
+	as.Limiter.Update(resp)
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	textRes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read response from order query")
+	}
+	if e, ok := as.handleError(textRes).(*Error); ok && e.Code == errOrderNotExist {
+		return nil, nil
+	}
+	return nil, errors.Errorf("query order %s failed: %s", params["newClientOrderId"], textRes)
+}
 
+func (as *apiService) do(method, url string, params map[string]string,
+	apiKey bool, sign bool) (*http.Response, error) {
 	req, err := http.NewRequest(method, url, nil)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to create request")
 	}
-	req.WithContext(as.Ctx)
+	req = req.WithContext(as.Ctx)
 
 	q := req.URL.Query()
 	for key, val := range params {
@@ -235,29 +331,13 @@ func (as *apiService) request(method string, endpoint string, params map[string]
 		req.Header.Add("X-MBX-APIKEY", as.APIKey)
 	}
 	if sign {
+		// 时间戳在签名前按服务器时间校正,重试时也会更新
+		q.Set("timestamp", strconv.FormatInt(unixMillis(as.Clock.Now()), 10))
 		//level.Debug(as.Logger).Log("queryString", q.Encode())
 		q.Add("signature", as.Signer.Sign([]byte(q.Encode())))
 		//level.Debug(as.Logger).Log("signature", as.Signer.Sign([]byte(q.Encode())))
 	}
 	req.URL.RawQuery = q.Encode()
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, errors.Wrap(err, "client do failed")
-	}
-	return resp, nil
-}
-
-func ReConnectWebSocket(url string) *websocket.Conn {
-	c, _, err := websocket.DefaultDialer.Dial(url, nil)
-	if err != nil {
-		panic("connect websocket failed")
-	}
-	return c
-}
-
-func init() {
-	websocket.DefaultDialer.Proxy = func(*http.Request) (*url.URL, error) {
-		return url.Parse("http://127.0.0.1:7890")
-	}
+	return as.Client.Do(req)
 }
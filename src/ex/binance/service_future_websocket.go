@@ -3,21 +3,20 @@ package binance
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"strings"
 	"time"
 	. "tinyquant/src/logger"
 	"tinyquant/src/util"
 
-	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
 	"go.uber.org/zap"
 )
 
 func (as *apiService) FutureDepthWebsocket(dwr DepthWebsocketRequest) (chan *DepthEvent, chan struct{}, error) {
 	url := fmt.Sprintf("wss://fstream.binance.com/ws/%s@depth@100ms", strings.ToLower(dwr.Symbol))
-	c, _, err := websocket.DefaultDialer.Dial(url, nil)
+	c, err := as.dialWebsocket(streamName(url), staticURL(url))
 	if err != nil {
-		log.Fatal("dial:", err)
+		return nil, nil, err
 	}
 
 	done := make(chan struct{})
@@ -34,77 +33,28 @@ func (as *apiService) FutureDepthWebsocket(dwr DepthWebsocketRequest) (chan *Dep
 			default:
 				_, message, err := c.ReadMessage()
 				if err != nil {
-					c = ReConnectWebSocket(url)
 					Logger.Error("trade websocket read failed ", zap.Error(err))
-					continue
-				}
-				rawDepth := struct {
-					Type          string          `json:"e"`
-					Time          float64         `json:"E"`
-					EventTime     float64         `json:"T"`
-					Symbol        string          `json:"s"`
-					LastUID       int             `json:"U"`
-					UpdateID      int             `json:"u"`
-					BeforeUID     int             `json:"pu"`
-					BidDepthDelta [][]interface{} `json:"b"`
-					AskDepthDelta [][]interface{} `json:"a"`
-				}{}
-				if err := json.Unmarshal(message, &rawDepth); err != nil {
-					Logger.Error("depth wsUnmarshal failed ", zap.Error(err))
 					return
 				}
-				t, _ := timeFromUnixTimestampFloat(rawDepth.Time)
-
-				et, _ := timeFromUnixTimestampFloat(rawDepth.EventTime)
-				de := &DepthEvent{
-					WSEvent: WSEvent{
-						Type:   rawDepth.Type,
-						Time:   t,
-						Symbol: rawDepth.Symbol,
-					},
-					EventTime: et,
-				}
-
-				de.BeforeUID = rawDepth.BeforeUID
-				de.LastUpdateID = rawDepth.LastUID
-				de.UpdateID = rawDepth.UpdateID
-
-				for _, b := range rawDepth.BidDepthDelta {
-					p, _ := floatFromString(b[0])
-
-					q, _ := floatFromString(b[1])
-
-					de.Bids = append(de.Bids, &Order{
-						Price:    p,
-						Quantity: q,
-					})
-				}
-
-				for _, b := range rawDepth.AskDepthDelta {
-					p, _ := floatFromString(b[0])
-
-					q, _ := floatFromString(b[1])
-
-					de.Asks = append(de.Asks, &Order{
-						Price:    p,
-						Quantity: q,
-					})
+				de, err := futureDepthEvent(message)
+				if err != nil {
+					Logger.Error("depth websocket message failed", zap.Error(err))
+					continue
 				}
 				dech <- de
 			}
 		}
 	}()
 
-	go as.exitHandler(c, done, url)
 	return dech, done, nil
 }
 
 func (as *apiService) FutureKlineWebsocket(kwr KlineWebsocketRequest) (chan *KlineEvent, chan struct{}, error) {
 	url := fmt.Sprintf("wss://fstream.binance.com/ws/%s@kline_%s", strings.ToLower(kwr.Symbol), string(kwr.Interval))
 
-	c, _, err := websocket.DefaultDialer.Dial(url, nil)
+	c, err := as.dialWebsocket(streamName(url), staticURL(url))
 	if err != nil {
-		log.Fatal("dial:", err)
+		return nil, nil, err
 	}
 
 	done := make(chan struct{})
@@ -121,95 +71,22 @@ func (as *apiService) FutureKlineWebsocket(kwr KlineWebsocketRequest) (chan *Kli
 			default:
 				_, message, err := c.ReadMessage()
 				if err != nil {
-					c = ReConnectWebSocket(url)
 					Logger.Error("kline websocket read failed", zap.Error(err))
-					continue
+					return
 				}
 
-				if strings.Contains(string(message), "result") {
+				ke, err := futureKlineEvent(message)
+				if err != nil {
+					Logger.Error("kline websocket message failed", zap.Error(err))
 					continue
 				}
-
-				rawKline := struct {
-					Type     string  `json:"e"`
-					Time     float64 `json:"E"`
-					Symbol   string  `json:"S"`
-					OpenTime float64 `json:"t"`
-					Kline    struct {
-						Interval                 string  `json:"i"`
-						FirstTradeID             int64   `json:"f"` // 这根K线期间第一笔成交ID
-						LastTradeID              int64   `json:"L"` // 这根K线期间末一笔成交ID
-						Final                    bool    `json:"x"` // 这根K线是否完结(是否已经开始下一根K线)
-						OpenTime                 float64 `json:"t"`
-						CloseTime                float64 `json:"T"`
-						Open                     string  `json:"o"`
-						High                     string  `json:"h"`
-						Low                      string  `json:"l"`
-						Close                    string  `json:"c"`
-						Volume                   string  `json:"v"` // 这根K线期间成交量
-						NumberOfTrades           int     `json:"n"` // 这根K线期间成交笔数
-						QuoteAssetVolume         string  `json:"q"` // 这根K线期间成交额
-						TakerBuyBaseAssetVolume  string  `json:"V"` // 主动买入的成交量
-						TakerBuyQuoteAssetVolume string  `json:"Q"` // 主动买入的成交额
-					} `json:"k"`
-				}{}
-				if err := json.Unmarshal(message, &rawKline); err != nil {
-					Logger.Error("kline wsUnmarshal failed ", zap.Error(err))
-					return
+				if ke != nil {
+					kech <- ke
 				}
-				t, _ := timeFromUnixTimestampFloat(rawKline.Time)
-
-				ot, _ := timeFromUnixTimestampFloat(rawKline.Kline.OpenTime)
-
-				ct, _ := timeFromUnixTimestampFloat(rawKline.Kline.CloseTime)
-
-				open, _ := floatFromString(rawKline.Kline.Open)
-
-				cls, _ := floatFromString(rawKline.Kline.Close)
-
-				high, _ := floatFromString(rawKline.Kline.High)
-
-				low, _ := floatFromString(rawKline.Kline.Low)
-
-				vol, _ := floatFromString(rawKline.Kline.Volume)
-
-				qav, _ := floatFromString(rawKline.Kline.QuoteAssetVolume)
-
-				tbbav, _ := floatFromString(rawKline.Kline.TakerBuyBaseAssetVolume)
-
-				tbqav, _ := floatFromString(rawKline.Kline.TakerBuyQuoteAssetVolume)
-
-				ke := &KlineEvent{
-					WSEvent: WSEvent{
-						Type:   rawKline.Type,
-						Time:   t,
-						Symbol: rawKline.Symbol,
-					},
-					Interval:     Interval(rawKline.Kline.Interval),
-					FirstTradeID: rawKline.Kline.FirstTradeID,
-					LastTradeID:  rawKline.Kline.LastTradeID,
-					Final:        rawKline.Kline.Final,
-					Kline: Kline{
-						OpenTime:                 ot,
-						CloseTime:                ct,
-						Open:                     open,
-						Close:                    cls,
-						High:                     high,
-						Low:                      low,
-						Volume:                   vol,
-						NumberOfTrades:           rawKline.Kline.NumberOfTrades,
-						QuoteAssetVolume:         qav,
-						TakerBuyBaseAssetVolume:  tbbav,
-						TakerBuyQuoteAssetVolume: tbqav,
-						Final:                    rawKline.Kline.Final,
-					},
-				}
-				kech <- ke
 			}
 		}
 	}()
 
-	go as.exitHandler(c, done, url)
 	return kech, done, nil
 }
 
@@ -217,9 +94,9 @@ func (as *apiService) FutureKlineWebsocket(kwr KlineWebsocketRequest) (chan *Kli
 func (as *apiService) FutureTradeWebsocket(twr TradeWebsocketRequest) (chan *AggTradeEvent, chan struct{}, error) {
 
 	url := fmt.Sprintf("wss://fstream.binance.com/ws/%s@aggTrade", strings.ToLower(twr.Symbol))
-	c, _, err := websocket.DefaultDialer.Dial(url, nil)
+	c, err := as.dialWebsocket(streamName(url), staticURL(url))
 	if err != nil {
-		log.Fatal("dial:", err)
+		return nil, nil, err
 	}
 
 	done := make(chan struct{})
@@ -236,66 +113,253 @@ func (as *apiService) FutureTradeWebsocket(twr TradeWebsocketRequest) (chan *Agg
 			default:
 				_, message, err := c.ReadMessage()
 				if err != nil {
-					c = ReConnectWebSocket(url)
 					Logger.Error("trade websocket read failed ", zap.Error(err))
-					continue
-				}
-				rawAggTrade := struct {
-					Type         string  `json:"e"`
-					Time         float64 `json:"E"`
-					Symbol       string  `json:"s"`
-					TradeID      int     `json:"a"`
-					Price        string  `json:"p"`
-					Quantity     string  `json:"q"`
-					FirstTradeID int     `json:"f"`
-					LastTradeID  int     `json:"l"`
-					Timestamp    float64 `json:"T"`
-					IsMaker      bool    `json:"m"`
-				}{}
-				if err := json.Unmarshal(message, &rawAggTrade); err != nil {
-					Logger.Error("trade wsUnmarshal failed ", zap.Error(err))
 					return
 				}
-				t, _ := timeFromUnixTimestampFloat(rawAggTrade.Time)
-
-				price, _ := floatFromString(rawAggTrade.Price)
-
-				qty, _ := floatFromString(rawAggTrade.Quantity)
-
-				ts, _ := timeFromUnixTimestampFloat(rawAggTrade.Timestamp)
-
-				ae := &AggTradeEvent{
-					WSEvent: WSEvent{
-						Type:   rawAggTrade.Type,
-						Time:   t,
-						Symbol: rawAggTrade.Symbol,
-					},
-					AggTrade: AggTrade{
-						ID:           rawAggTrade.TradeID,
-						Price:        price,
-						Quantity:     qty,
-						FirstTradeID: rawAggTrade.FirstTradeID,
-						LastTradeID:  rawAggTrade.LastTradeID,
-						Timestamp:    ts,
-						BuyerMaker:   rawAggTrade.IsMaker,
-					},
+				ae, err := futureAggTradeEvent(message)
+				if err != nil {
+					Logger.Error("trade websocket message failed", zap.Error(err))
+					continue
 				}
 				aggtech <- ae
 			}
 		}
 	}()
 
-	go as.exitHandler(c, done, url)
 	return aggtech, done, nil
 }
 
+// 解析u本位深度推送
+func futureDepthEvent(message []byte) (*DepthEvent, error) {
+	rawDepth := struct {
+		Type          string          `json:"e"`
+		Time          float64         `json:"E"`
+		EventTime     float64         `json:"T"`
+		Symbol        string          `json:"s"`
+		LastUID       int             `json:"U"`
+		UpdateID      int             `json:"u"`
+		BeforeUID     int             `json:"pu"`
+		BidDepthDelta [][]interface{} `json:"b"`
+		AskDepthDelta [][]interface{} `json:"a"`
+	}{}
+	if err := json.Unmarshal(message, &rawDepth); err != nil {
+		return nil, errors.Wrap(err, "depth wsUnmarshal failed")
+	}
+	t, _ := timeFromUnixTimestampFloat(rawDepth.Time)
+
+	et, _ := timeFromUnixTimestampFloat(rawDepth.EventTime)
+	de := &DepthEvent{
+		WSEvent: WSEvent{
+			Type:   rawDepth.Type,
+			Time:   t,
+			Symbol: rawDepth.Symbol,
+		},
+		EventTime: et,
+	}
+
+	de.BeforeUID = rawDepth.BeforeUID
+	de.LastUpdateID = rawDepth.LastUID
+	de.UpdateID = rawDepth.UpdateID
+
+	for _, b := range rawDepth.BidDepthDelta {
+		p, _ := floatFromString(b[0])
+
+		q, _ := floatFromString(b[1])
+
+		de.Bids = append(de.Bids, &Order{
+			Price:    p,
+			Quantity: q,
+		})
+	}
+
+	for _, b := range rawDepth.AskDepthDelta {
+		p, _ := floatFromString(b[0])
+
+		q, _ := floatFromString(b[1])
+
+		de.Asks = append(de.Asks, &Order{
+			Price:    p,
+			Quantity: q,
+		})
+	}
+	return de, nil
+}
+
+// 解析K线推送,订阅应答返回 nil
+func futureKlineEvent(message []byte) (*KlineEvent, error) {
+	if strings.Contains(string(message), "result") {
+		return nil, nil
+	}
+
+	rawKline := struct {
+		Type     string  `json:"e"`
+		Time     float64 `json:"E"`
+		Symbol   string  `json:"S"`
+		OpenTime float64 `json:"t"`
+		Kline    struct {
+			Interval                 string  `json:"i"`
+			FirstTradeID             int64   `json:"f"` // 这根K线期间第一笔成交ID
+			LastTradeID              int64   `json:"L"` // 这根K线期间末一笔成交ID
+			Final                    bool    `json:"x"` // 这根K线是否完结(是否已经开始下一根K线)
+			OpenTime                 float64 `json:"t"`
+			CloseTime                float64 `json:"T"`
+			Open                     string  `json:"o"`
+			High                     string  `json:"h"`
+			Low                      string  `json:"l"`
+			Close                    string  `json:"c"`
+			Volume                   string  `json:"v"` // 这根K线期间成交量
+			NumberOfTrades           int     `json:"n"` // 这根K线期间成交笔数
+			QuoteAssetVolume         string  `json:"q"` // 这根K线期间成交额
+			TakerBuyBaseAssetVolume  string  `json:"V"` // 主动买入的成交量
+			TakerBuyQuoteAssetVolume string  `json:"Q"` // 主动买入的成交额
+		} `json:"k"`
+	}{}
+	if err := json.Unmarshal(message, &rawKline); err != nil {
+		return nil, errors.Wrap(err, "kline wsUnmarshal failed")
+	}
+	t, _ := timeFromUnixTimestampFloat(rawKline.Time)
+
+	ot, _ := timeFromUnixTimestampFloat(rawKline.Kline.OpenTime)
+
+	ct, _ := timeFromUnixTimestampFloat(rawKline.Kline.CloseTime)
+
+	open, _ := floatFromString(rawKline.Kline.Open)
+
+	cls, _ := floatFromString(rawKline.Kline.Close)
+
+	high, _ := floatFromString(rawKline.Kline.High)
+
+	low, _ := floatFromString(rawKline.Kline.Low)
+
+	vol, _ := floatFromString(rawKline.Kline.Volume)
+
+	qav, _ := floatFromString(rawKline.Kline.QuoteAssetVolume)
+
+	tbbav, _ := floatFromString(rawKline.Kline.TakerBuyBaseAssetVolume)
+
+	tbqav, _ := floatFromString(rawKline.Kline.TakerBuyQuoteAssetVolume)
+
+	ke := &KlineEvent{
+		WSEvent: WSEvent{
+			Type:   rawKline.Type,
+			Time:   t,
+			Symbol: rawKline.Symbol,
+		},
+		Interval:     Interval(rawKline.Kline.Interval),
+		FirstTradeID: rawKline.Kline.FirstTradeID,
+		LastTradeID:  rawKline.Kline.LastTradeID,
+		Final:        rawKline.Kline.Final,
+		Kline: Kline{
+			OpenTime:                 ot,
+			CloseTime:                ct,
+			Open:                     open,
+			Close:                    cls,
+			High:                     high,
+			Low:                      low,
+			Volume:                   vol,
+			NumberOfTrades:           rawKline.Kline.NumberOfTrades,
+			QuoteAssetVolume:         qav,
+			TakerBuyBaseAssetVolume:  tbbav,
+			TakerBuyQuoteAssetVolume: tbqav,
+			Final:                    rawKline.Kline.Final,
+		},
+	}
+	return ke, nil
+}
+
+// 解析归集交易推送
+func futureAggTradeEvent(message []byte) (*AggTradeEvent, error) {
+	rawAggTrade := struct {
+		Type         string  `json:"e"`
+		Time         float64 `json:"E"`
+		Symbol       string  `json:"s"`
+		TradeID      int     `json:"a"`
+		Price        string  `json:"p"`
+		Quantity     string  `json:"q"`
+		FirstTradeID int     `json:"f"`
+		LastTradeID  int     `json:"l"`
+		Timestamp    float64 `json:"T"`
+		IsMaker      bool    `json:"m"`
+	}{}
+	if err := json.Unmarshal(message, &rawAggTrade); err != nil {
+		return nil, errors.Wrap(err, "trade wsUnmarshal failed")
+	}
+	t, _ := timeFromUnixTimestampFloat(rawAggTrade.Time)
+
+	price, _ := floatFromString(rawAggTrade.Price)
+
+	qty, _ := floatFromString(rawAggTrade.Quantity)
+
+	ts, _ := timeFromUnixTimestampFloat(rawAggTrade.Timestamp)
+
+	ae := &AggTradeEvent{
+		WSEvent: WSEvent{
+			Type:   rawAggTrade.Type,
+			Time:   t,
+			Symbol: rawAggTrade.Symbol,
+		},
+		AggTrade: AggTrade{
+			ID:           rawAggTrade.TradeID,
+			Price:        price,
+			Quantity:     qty,
+			FirstTradeID: rawAggTrade.FirstTradeID,
+			LastTradeID:  rawAggTrade.LastTradeID,
+			Timestamp:    ts,
+			BuyerMaker:   rawAggTrade.IsMaker,
+		},
+	}
+	return ae, nil
+}
+
+// 解析标记价格推送
+func futureMarkPriceEvent(message []byte) (*MarkPriceEvent, error) {
+	rawMarkPrice := struct {
+		Type            string  `json:"e"`
+		Time            float64 `json:"E"`
+		Symbol          string  `json:"s"`
+		MarkPrice       string  `json:"p"`
+		IndexPrice      string  `json:"i"`
+		FundingRate     string  `json:"r"`
+		NextFundingTime float64 `json:"T"`
+	}{}
+	if err := json.Unmarshal(message, &rawMarkPrice); err != nil {
+		return nil, errors.Wrap(err, "mark price wsUnmarshal failed")
+	}
+	t, _ := timeFromUnixTimestampFloat(rawMarkPrice.Time)
+	mp, _ := floatFromString(rawMarkPrice.MarkPrice)
+	ip, _ := floatFromString(rawMarkPrice.IndexPrice)
+	// 交割合约的资金费率为空
+	fr, _ := floatFromString(rawMarkPrice.FundingRate)
+	var nft time.Time
+	if rawMarkPrice.NextFundingTime > 0 {
+		nft, _ = timeFromUnixTimestampFloat(rawMarkPrice.NextFundingTime)
+	}
+
+	return &MarkPriceEvent{
+		WSEvent: WSEvent{
+			Type:   rawMarkPrice.Type,
+			Time:   t,
+			Symbol: rawMarkPrice.Symbol,
+		},
+		MarkPrice:       mp,
+		IndexPrice:      ip,
+		FundingRate:     fr,
+		NextFundingTime: nft,
+	}, nil
+}
+
 func (as *apiService) FutureUserDataWebsocket(urwr UserDataWebsocketRequest) (chan *FutureAccountEvent, chan struct{}, error) {
 
-	url := fmt.Sprintf("wss://fstream.binance.com/ws/%s", urwr.ListenKey)
+	key := &userDataKey{
+		key:       urwr.ListenKey,
+		format:    "wss://fstream.binance.com/ws/%s",
+		start:     as.StartFutureUserDataStream,
+		keepAlive: as.KeepAliveFutureUserDataStream,
+	}
 
-	c, _, err := websocket.DefaultDialer.Dial(url, nil)
+	c, err := as.dialWebsocket("userData", key.url)
 	if err != nil {
-		log.Fatal("dial:", err)
+		return nil, nil, err
 	}
 	done := make(chan struct{})
 	aech := make(chan *FutureAccountEvent)
@@ -311,16 +375,13 @@ func (as *apiService) FutureUserDataWebsocket(urwr UserDataWebsocketRequest) (ch
 			default:
 				_, message, err := c.ReadMessage()
 				if err != nil {
-					c = ReConnectWebSocket(url)
 					Logger.Error("user data websocket read failed ", zap.Error(err))
-					continue
+					return
 				}
 
-				if strings.Contains(string(message), util.ListenKeyExpired) { // listenkey 过期
+				if strings.Contains(string(message), util.ListenKeyExpired) { // listenkey 过期,重新申请后重连
 					Logger.Info("websocket receive event ")
-					stream, _ := as.StartFutureUserDataStream()
-					url := fmt.Sprintf("wss://fstream.binance.com/ws/%s", stream.ListenKey)
-					c = ReConnectWebSocket(url)
+					c.Reconnect()
 					continue
 				}
 
@@ -356,7 +417,7 @@ func (as *apiService) FutureUserDataWebsocket(urwr UserDataWebsocketRequest) (ch
 					}{}
 					if err := json.Unmarshal(message, &accUp); err != nil {
 						Logger.Error("user acc data wsUnmarshal failed ", zap.Error(err))
-						return
+						continue
 					}
 
 					ae := &FutureAccountEvent{
@@ -442,12 +503,14 @@ func (as *apiService) FutureUserDataWebsocket(urwr UserDataWebsocketRequest) (ch
 							OrigType      string  `json:"ot"` // 原始订单类型
 							PositionSide  string  `json:"ps"` // 持仓方向
 							IsClose       bool    `json:"cp"` // 是否为触发平仓单
+							ActivatePrice string  `json:"AP"` // 跟踪止损激活价格
+							CallbackRate  string  `json:"cr"` // 跟踪止损回调比例
 							Profit        string  `json:"rp"` // 该交易实现盈亏
 						} `json:"o"`
 					}{}
 					if err := json.Unmarshal(message, &orderUp); err != nil {
 						Logger.Error("user data wsUnmarshal failed ", zap.Error(err))
-						return
+						continue
 					}
 
 					oe := &FutureAccountEvent{
@@ -496,7 +559,9 @@ func (as *apiService) FutureUserDataWebsocket(urwr UserDataWebsocketRequest) (ch
 					oe.OE.Order.IsReduce = orderUp.Order.IsReduce
 					oe.OE.Order.IsClose = orderUp.Order.IsClose
 					oe.OE.Order.NowType = OrderType(orderUp.Order.NowType)
-					oe.OE.Order.OrderType = orderUp.Order.OrigType
+					oe.OE.Order.OrigType = OrderType(orderUp.Order.OrigType)
+					oe.OE.Order.ActivatePrice, _ = floatFromString(orderUp.Order.ActivatePrice)
+					oe.OE.Order.PriceRate, _ = floatFromString(orderUp.Order.CallbackRate)
 					oe.OE.Order.PositionSide = orderUp.Order.PositionSide
 					aech <- oe
 
@@ -510,54 +575,6 @@ func (as *apiService) FutureUserDataWebsocket(urwr UserDataWebsocketRequest) (ch
 		}
 	}()
 
-	go as.future_exitHandler(c, done, urwr.ListenKey)
+	go key.keepAliveLoop(done)
 	return aech, done, nil
 }
-
-func (as *apiService) future_exitHandler(c *websocket.Conn, done chan struct{}, listenKey string) {
-	ticker := time.NewTicker(5 * time.Minute)
-	defer ticker.Stop()
-	defer c.Close()
-
-	r := req{
-		Method: "SET_PROPERTY",
-		Id:     5,
-	}
-	r.Params = append(r.Params, "combined", false)
-
-	data, err := json.Marshal(r)
-	if err != nil {
-		Logger.Error("write websocket message marshal failed ", zap.Error(err))
-		return
-	}
-	err = c.WriteMessage(websocket.TextMessage, data)
-	if err != nil {
-		Logger.Error("websocket write message failed ", zap.Error(err))
-		return
-	}
-
-	for {
-		select {
-		case _ = <-ticker.C:
-
-			err := c.WriteMessage(websocket.PongMessage, nil)
-			if err != nil {
-				url := fmt.Sprintf("wss://fstream.binance.com/ws/%s", listenKey)
-				c = ReConnectWebSocket(url)
-				Logger.Error("websocket write message failed ", zap.Error(err))
-				return
-			}
-
-			as.KeepAliveFutureUserDataStream(&Stream{
-				ListenKey: listenKey,
-			})
-		case <-as.Ctx.Done():
-			select {
-			case <-done:
-			case <-time.After(time.Second):
-			}
-			Logger.Info("websocket close connect ")
-			return
-		}
-	}
-}
@@ -21,8 +21,16 @@ func (as *apiService) Ping() error {
 }
 
 func (as *apiService) Time() (time.Time, error) {
+	return as.serverTime("fapi/v1/time")
+}
+
+func (as *apiService) CoinTime() (time.Time, error) {
+	return as.serverTime("dapi/v1/time")
+}
+
+func (as *apiService) serverTime(endpoint string) (time.Time, error) {
 	params := make(map[string]string)
-	res, err := as.request("GET", "api/v1/time", params, false, false)
+	res, err := as.request("GET", endpoint, params, false, false)
 	if err != nil {
 		return time.Time{}, err
 	}
@@ -31,33 +39,112 @@ func (as *apiService) Time() (time.Time, error) {
 		return time.Time{}, errors.Wrap(err, "unable to read response from Time")
 	}
 	defer res.Body.Close()
+
+	if res.StatusCode != 200 {
+		return time.Time{}, as.handleError(textRes)
+	}
 	var rawTime struct {
-		ServerTime string `json:"serverTime"`
+		ServerTime float64 `json:"serverTime"`
 	}
 	if err := json.Unmarshal(textRes, &rawTime); err != nil {
 		return time.Time{}, errors.Wrap(err, "timeResponse unmarshal failed")
 	}
-	t, err := timeFromUnixTimestampFloat(rawTime)
-	if err != nil {
-		return time.Time{}, err
-	}
-	return t, nil
+	return timeFromUnixTimestampFloat(rawTime.ServerTime)
+}
+
+func (as *apiService) ExchangeInfo() (*ExchangeInfo, error) {
+	return as.exchangeInfo("fapi/v1/exchangeInfo")
 }
 
-func (as *apiService) ExchangeInfo() (string, error) {
+func (as *apiService) exchangeInfo(endpoint string) (*ExchangeInfo, error) {
 	params := make(map[string]string)
-	params["symbol"] = "ETHUSDT"
-	res, err := as.request("GET", "fapi/v1/exchangeInfo", params, false, false)
+	res, err := as.request("GET", endpoint, params, false, false)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	textRes, err := ioutil.ReadAll(res.Body)
 	if err != nil {
-		return "", errors.Wrap(err, "unable to read response from Time")
+		return nil, errors.Wrap(err, "unable to read response from exchangeInfo")
 	}
 	defer res.Body.Close()
 
-	return string(textRes), nil
+	if res.StatusCode != 200 {
+		return nil, as.handleError(textRes)
+	}
+
+	rawInfo := struct {
+		ServerTime float64 `json:"serverTime"`
+		Symbols    []struct {
+			Symbol            string  `json:"symbol"`
+			Pair              string  `json:"pair"`
+			ContractType      string  `json:"contractType"`
+			Status            string  `json:"status"`
+			ContractStatus    string  `json:"contractStatus"`
+			MarginAsset       string  `json:"marginAsset"`
+			ContractSize      float64 `json:"contractSize"`
+			PricePrecision    int     `json:"pricePrecision"`
+			QuantityPrecision int     `json:"quantityPrecision"`
+			TriggerProtect    string  `json:"triggerProtect"`
+			Filters           []struct {
+				FilterType     string `json:"filterType"`
+				MinPrice       string `json:"minPrice"`
+				MaxPrice       string `json:"maxPrice"`
+				TickSize       string `json:"tickSize"`
+				StepSize       string `json:"stepSize"`
+				MinQty         string `json:"minQty"`
+				MaxQty         string `json:"maxQty"`
+				Notional       string `json:"notional"`
+				Limit          int    `json:"limit"`
+				MultiplierUp   string `json:"multiplierUp"`
+				MultiplierDown string `json:"multiplierDown"`
+			} `json:"filters"`
+		} `json:"symbols"`
+	}{}
+	if err := json.Unmarshal(textRes, &rawInfo); err != nil {
+		return nil, errors.Wrap(err, "rawExchangeInfo unmarshal failed")
+	}
+
+	t, _ := timeFromUnixTimestampFloat(rawInfo.ServerTime)
+	info := &ExchangeInfo{ServerTime: t}
+	for _, rs := range rawInfo.Symbols {
+		si := &SymbolInfo{
+			Symbol:            rs.Symbol,
+			Pair:              rs.Pair,
+			ContractType:      rs.ContractType,
+			Status:            rs.Status,
+			MarginAsset:       rs.MarginAsset,
+			ContractSize:      rs.ContractSize,
+			PricePrecision:    rs.PricePrecision,
+			QuantityPrecision: rs.QuantityPrecision,
+		}
+		if si.Status == "" {
+			si.Status = rs.ContractStatus
+		}
+		si.TriggerProtect, _ = strconv.ParseFloat(rs.TriggerProtect, 64)
+		for _, f := range rs.Filters {
+			switch f.FilterType {
+			case "PRICE_FILTER":
+				si.TickSize, _ = strconv.ParseFloat(f.TickSize, 64)
+				si.MinPrice, _ = strconv.ParseFloat(f.MinPrice, 64)
+				si.MaxPrice, _ = strconv.ParseFloat(f.MaxPrice, 64)
+			case "LOT_SIZE":
+				si.StepSize, _ = strconv.ParseFloat(f.StepSize, 64)
+				si.MinQty, _ = strconv.ParseFloat(f.MinQty, 64)
+				si.MaxQty, _ = strconv.ParseFloat(f.MaxQty, 64)
+			case "MIN_NOTIONAL":
+				si.MinNotional, _ = strconv.ParseFloat(f.Notional, 64)
+			case "MAX_NUM_ORDERS":
+				si.MaxNumOrders = f.Limit
+			case "MAX_NUM_ALGO_ORDERS":
+				si.MaxNumAlgoOrders = f.Limit
+			case "PERCENT_PRICE":
+				si.MultiplierUp, _ = strconv.ParseFloat(f.MultiplierUp, 64)
+				si.MultiplierDown, _ = strconv.ParseFloat(f.MultiplierDown, 64)
+			}
+		}
+		info.Symbols = append(info.Symbols, si)
+	}
+	return info, nil
 }
 
 func (as *apiService) NewPrice(nb OrderNewPriceRequest) (*NewPrice, error) {
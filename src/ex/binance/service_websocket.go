@@ -3,20 +3,17 @@ package binance
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"strings"
-	"time"
 	. "tinyquant/src/logger"
 
-	"github.com/gorilla/websocket"
 	"go.uber.org/zap"
 )
 
 func (as *apiService) DepthWebsocket(dwr DepthWebsocketRequest) (chan *DepthEvent, chan struct{}, error) {
 	url := fmt.Sprintf("wss://fstream.binance.com/ws/%s@depth", strings.ToLower(dwr.Symbol))
-	c, _, err := websocket.DefaultDialer.Dial(url, nil)
+	c, err := as.dialWebsocket(streamName(url), staticURL(url))
 	if err != nil {
-		log.Fatal("dial:", err)
+		return nil, nil, err
 	}
 
 	done := make(chan struct{})
@@ -46,7 +43,7 @@ func (as *apiService) DepthWebsocket(dwr DepthWebsocketRequest) (chan *DepthEven
 				}{}
 				if err := json.Unmarshal(message, &rawDepth); err != nil {
 					Logger.Error("depth wsUnmarshal failed ", zap.Error(err))
-					return
+					continue
 				}
 				t, _ := timeFromUnixTimestampFloat(rawDepth.Time)
 
@@ -72,16 +69,15 @@ func (as *apiService) DepthWebsocket(dwr DepthWebsocketRequest) (chan *DepthEven
 		}
 	}()
 
-	go as.exitHandler(c, done, url)
 	return dech, done, nil
 }
 
 func (as *apiService) KlineWebsocket(kwr KlineWebsocketRequest) (chan *KlineEvent, chan struct{}, error) {
 	url := fmt.Sprintf("wss://fstream.binance.com/ws/%s@kline_%s", strings.ToLower(kwr.Symbol), string(kwr.Interval))
 
-	c, _, err := websocket.DefaultDialer.Dial(url, nil)
+	c, err := as.dialWebsocket(streamName(url), staticURL(url))
 	if err != nil {
-		log.Fatal("dial:", err)
+		return nil, nil, err
 	}
 
 	done := make(chan struct{})
@@ -131,7 +127,7 @@ func (as *apiService) KlineWebsocket(kwr KlineWebsocketRequest) (chan *KlineEven
 				}{}
 				if err := json.Unmarshal(message, &rawKline); err != nil {
 					Logger.Error("kline wsUnmarshal failed ", zap.Error(err))
-					return
+					continue
 				}
 				t, _ := timeFromUnixTimestampFloat(rawKline.Time)
 
@@ -184,16 +180,15 @@ func (as *apiService) KlineWebsocket(kwr KlineWebsocketRequest) (chan *KlineEven
 		}
 	}()
 
-	go as.exitHandler(c, done, url)
 	return kech, done, nil
 }
 
 //
 func (as *apiService) TradeWebsocket(twr TradeWebsocketRequest) (chan *AggTradeEvent, chan struct{}, error) {
 	url := fmt.Sprintf("wss://fstream.binance.com/ws/%s@aggTrade", strings.ToLower(twr.Symbol))
-	c, _, err := websocket.DefaultDialer.Dial(url, nil)
+	c, err := as.dialWebsocket(streamName(url), staticURL(url))
 	if err != nil {
-		log.Fatal("dial:", err)
+		return nil, nil, err
 	}
 
 	done := make(chan struct{})
@@ -227,7 +222,7 @@ func (as *apiService) TradeWebsocket(twr TradeWebsocketRequest) (chan *AggTradeE
 				}{}
 				if err := json.Unmarshal(message, &rawAggTrade); err != nil {
 					Logger.Error("trade wsUnmarshal failed ", zap.Error(err))
-					return
+					continue
 				}
 				t, _ := timeFromUnixTimestampFloat(rawAggTrade.Time)
 
@@ -258,16 +253,15 @@ func (as *apiService) TradeWebsocket(twr TradeWebsocketRequest) (chan *AggTradeE
 		}
 	}()
 
-	go as.exitHandler(c, done, url)
 	return aggtech, done, nil
 }
 
 func (as *apiService) UserDataWebsocket(urwr UserDataWebsocketRequest) (chan *AccountEvent, chan struct{}, error) {
 	strUrl := fmt.Sprintf("wss://fstream.binance.com/ws/%s", urwr.ListenKey)
 
-	c, _, err := websocket.DefaultDialer.Dial(strUrl, nil)
+	c, err := as.dialWebsocket("userData", staticURL(strUrl))
 	if err != nil {
-		log.Fatal("dial:", err)
+		return nil, nil, err
 	}
 
 	done := make(chan struct{})
@@ -291,7 +285,6 @@ func (as *apiService) UserDataWebsocket(urwr UserDataWebsocketRequest) (chan *Ac
 				rawAccount := struct {
 					Type            string  `json:"e"`
 					Time            float64 `json:"E"`
-					OpenTime        float64 `json:"t"`
 					MakerCommision  int64   `json:"m"`
 					TakerCommision  int64   `json:"t"`
 					BuyerCommision  int64   `json:"b"`
@@ -307,7 +300,7 @@ func (as *apiService) UserDataWebsocket(urwr UserDataWebsocketRequest) (chan *Ac
 				}{}
 				if err := json.Unmarshal(message, &rawAccount); err != nil {
 					Logger.Error("user data wsUnmarshal failed ", zap.Error(err))
-					return
+					continue
 				}
 				t, _ := timeFromUnixTimestampFloat(rawAccount.Time)
 
@@ -342,7 +335,6 @@ func (as *apiService) UserDataWebsocket(urwr UserDataWebsocketRequest) (chan *Ac
 		}
 	}()
 
-	go as.exitHandler(c, done, strUrl)
 	return aech, done, nil
 }
 
@@ -351,47 +343,3 @@ type req struct {
 	Params []interface{} `json:"params"`
 	Id     int           `json:"id"`
 }
-
-func (as *apiService) exitHandler(c *websocket.Conn, done chan struct{}, url string) {
-	ticker := time.NewTicker(10 * time.Minute)
-	defer ticker.Stop()
-	defer c.Close()
-
-	r := req{
-		Method: "SET_PROPERTY",
-		Id:     5,
-	}
-	r.Params = append(r.Params, "combined", false)
-
-	data, err := json.Marshal(r)
-	if err != nil {
-		Logger.Error("write websocket message marshal failed ", zap.Error(err))
-		return
-	}
-	err = c.WriteMessage(websocket.TextMessage, data)
-	if err != nil {
-		c = ReConnectWebSocket(url)
-		Logger.Error("websocket write message failed ", zap.Error(err))
-		return
-	}
-
-	for {
-		select {
-		case _ = <-ticker.C:
-
-			err = c.WriteMessage(websocket.PongMessage, nil)
-			if err != nil {
-				c = ReConnectWebSocket(url)
-				Logger.Error("websocket write message failed ", zap.Error(err))
-				continue
-			}
-		case <-as.Ctx.Done():
-			select {
-			case <-done:
-			case <-time.After(time.Second):
-			}
-			Logger.Info("websocket close connect ")
-			return
-		}
-	}
-}
@@ -0,0 +1,331 @@
+package binance
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	. "tinyquant/src/logger"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// 单条组合推送连接最多订阅的流
+const MaxCombinedStreams = 200
+
+// 每个流的通道缓冲,满了以后丢弃推送,不阻塞共用连接上的其他流
+const combinedBuffer = 256
+
+// 组合推送,多个流共用一条 /stream 连接,按流名称分发到各自的通道
+// 第一次订阅时建立连接,之后用 SUBSCRIBE 和 UNSUBSCRIBE 动态增减,重连时地址包含当前所有订阅
+// 拨号之后、连上之前加入的流不在地址里,连上后补发 SUBSCRIBE
+type CombinedStream struct {
+	sync.Mutex
+	as   *apiService
+	host string // wss://fstream.binance.com
+
+	connMu   sync.Mutex // 建立连接时持有,拨号时会读取订阅列表,不能持有 Mutex
+	ws       *wsConn
+	handlers map[string]*subscription // 流名称 -> 订阅
+	dialed   map[string]bool          // 最近一次拨号地址中的流
+	id       int
+}
+
+// 一个流的订阅,取消订阅后关闭通道,之后在途的消息直接丢弃
+type subscription struct {
+	sync.Mutex
+	stream  string
+	handle  func(message []byte) error // 解析并发送到通道
+	close   func()
+	closed  bool
+	dropped int64 // 通道满被丢弃的推送数量
+}
+
+func (s *subscription) deliver(message []byte) error {
+	s.Lock()
+	defer s.Unlock()
+	if s.closed {
+		return nil
+	}
+	return s.handle(message)
+}
+
+func (s *subscription) stop() {
+	s.Lock()
+	defer s.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	if s.close != nil {
+		s.close()
+	}
+}
+
+// 读取太慢丢弃一条推送,每100条记录一次日志
+func (s *subscription) drop() {
+	if n := atomic.AddInt64(&s.dropped, 1); n == 1 || n%100 == 0 {
+		Logger.Warn("combined stream consumer too slow, event dropped", zap.String("stream", s.stream), zap.Int64("dropped", n))
+	}
+}
+
+func (as *apiService) NewCombinedStream() *CombinedStream {
+	return &CombinedStream{as: as, host: "wss://fstream.binance.com", handlers: make(map[string]*subscription)}
+}
+
+func (as *apiService) NewCoinCombinedStream() *CombinedStream {
+	return &CombinedStream{as: as, host: "wss://dstream.binance.com", handlers: make(map[string]*subscription)}
+}
+
+func (b *binance) NewCombinedStream() *CombinedStream {
+	return b.Service.NewCombinedStream()
+}
+
+func (b *binance) NewCoinCombinedStream() *CombinedStream {
+	return b.Service.NewCoinCombinedStream()
+}
+
+// 订阅K线
+func (cs *CombinedStream) SubscribeKline(symbol string, interval Interval) (chan *KlineEvent, error) {
+	ch := make(chan *KlineEvent, combinedBuffer)
+	sub := &subscription{stream: fmt.Sprintf("%s@kline_%s", strings.ToLower(symbol), string(interval)), close: func() { close(ch) }}
+	sub.handle = func(message []byte) error {
+		ke, err := futureKlineEvent(message)
+		if ke != nil {
+			select {
+			case ch <- ke:
+			default:
+				sub.drop()
+			}
+		}
+		return err
+	}
+	return ch, cs.subscribe(sub)
+}
+
+// 订阅100ms增量深度,丢弃推送后下一条发出的推送带 Gap 标记,订单簿需要重新同步
+func (cs *CombinedStream) SubscribeDepth(symbol string) (chan *DepthEvent, error) {
+	ch := make(chan *DepthEvent, combinedBuffer)
+	sub := &subscription{stream: fmt.Sprintf("%s@depth@100ms", strings.ToLower(symbol)), close: func() { close(ch) }}
+	gap := false
+	sub.handle = func(message []byte) error {
+		de, err := futureDepthEvent(message)
+		if de != nil {
+			de.Gap = gap
+			select {
+			case ch <- de:
+				gap = false
+			default:
+				gap = true
+				sub.drop()
+			}
+		}
+		return err
+	}
+	return ch, cs.subscribe(sub)
+}
+
+// 订阅归集交易
+func (cs *CombinedStream) SubscribeAggTrade(symbol string) (chan *AggTradeEvent, error) {
+	ch := make(chan *AggTradeEvent, combinedBuffer)
+	sub := &subscription{stream: fmt.Sprintf("%s@aggTrade", strings.ToLower(symbol)), close: func() { close(ch) }}
+	sub.handle = func(message []byte) error {
+		ae, err := futureAggTradeEvent(message)
+		if ae != nil {
+			select {
+			case ch <- ae:
+			default:
+				sub.drop()
+			}
+		}
+		return err
+	}
+	return ch, cs.subscribe(sub)
+}
+
+// 订阅每秒一次的标记价格和资金费率
+func (cs *CombinedStream) SubscribeMarkPrice(symbol string) (chan *MarkPriceEvent, error) {
+	ch := make(chan *MarkPriceEvent, combinedBuffer)
+	sub := &subscription{stream: fmt.Sprintf("%s@markPrice@1s", strings.ToLower(symbol)), close: func() { close(ch) }}
+	sub.handle = func(message []byte) error {
+		me, err := futureMarkPriceEvent(message)
+		if me != nil {
+			select {
+			case ch <- me:
+			default:
+				sub.drop()
+			}
+		}
+		return err
+	}
+	return ch, cs.subscribe(sub)
+}
+
+// 订阅一个流,handle 收到的是 data 部分,在共用的读取循环中调用,不能阻塞
+// 连接断开或者还没有连上时不返回错误,重连后会订阅,只有重复订阅和超过数量时返回错误
+func (cs *CombinedStream) Subscribe(stream string, handle func(message []byte) error) error {
+	return cs.subscribe(&subscription{stream: stream, handle: handle})
+}
+
+func (cs *CombinedStream) subscribe(sub *subscription) error {
+	stream := sub.stream
+	cs.Lock()
+	if _, ok := cs.handlers[stream]; ok {
+		cs.Unlock()
+		return errors.Errorf("stream %s already subscribed", stream)
+	}
+	if len(cs.handlers) >= MaxCombinedStreams {
+		cs.Unlock()
+		return errors.Errorf("combined stream subscribed %d streams", len(cs.handlers))
+	}
+	cs.handlers[stream] = sub
+	ws := cs.ws
+	cs.Unlock()
+
+	if ws == nil {
+		// 自己建立的连接地址中已经包含这个流,别人建立的需要再订阅
+		if cs.connect() {
+			return nil
+		}
+		ws = cs.conn()
+	}
+	cs.send(ws, "SUBSCRIBE", stream)
+	return nil
+}
+
+// 取消订阅并关闭通道
+func (cs *CombinedStream) Unsubscribe(stream string) error {
+	cs.Lock()
+	sub, ok := cs.handlers[stream]
+	if !ok {
+		cs.Unlock()
+		return errors.Errorf("stream %s not subscribed", stream)
+	}
+	delete(cs.handlers, stream)
+	ws := cs.ws
+	cs.Unlock()
+	sub.stop()
+
+	if ws == nil {
+		return nil
+	}
+	cs.send(ws, "UNSUBSCRIBE", stream)
+	return nil
+}
+
+// 因为通道满被丢弃的推送数量
+func (cs *CombinedStream) Dropped(stream string) int64 {
+	cs.Lock()
+	sub, ok := cs.handlers[stream]
+	cs.Unlock()
+	if !ok {
+		return 0
+	}
+	return atomic.LoadInt64(&sub.dropped)
+}
+
+// 当前订阅的流
+func (cs *CombinedStream) Streams() []string {
+	cs.Lock()
+	defer cs.Unlock()
+	streams := make([]string, 0, len(cs.handlers))
+	for stream := range cs.handlers {
+		streams = append(streams, stream)
+	}
+	sort.Strings(streams)
+	return streams
+}
+
+// 连接关闭后 done 关闭,还没有订阅过时返回 nil
+func (cs *CombinedStream) Done() chan struct{} {
+	if ws := cs.conn(); ws != nil {
+		return ws.done
+	}
+	return nil
+}
+
+func (cs *CombinedStream) conn() *wsConn {
+	cs.Lock()
+	defer cs.Unlock()
+	return cs.ws
+}
+
+// 建立连接,已经有连接时返回 false,拨号失败时在后台重连,不返回错误
+func (cs *CombinedStream) connect() (fresh bool) {
+	cs.connMu.Lock()
+	defer cs.connMu.Unlock()
+	if cs.conn() != nil {
+		return false
+	}
+	ws := cs.as.newWebsocket("combined", cs.url, cs.route)
+	ws.onConnect = cs.resubscribe
+	cs.Lock()
+	cs.ws = ws
+	cs.Unlock()
+	ws.keep()
+	return true
+}
+
+// 连上后订阅不在拨号地址中的流,包括连接断开期间发送失败的订阅
+func (cs *CombinedStream) resubscribe(ws *wsConn) {
+	cs.Lock()
+	var streams []string
+	for stream := range cs.handlers {
+		if !cs.dialed[stream] {
+			streams = append(streams, stream)
+		}
+	}
+	cs.Unlock()
+	sort.Strings(streams)
+	for _, stream := range streams {
+		cs.send(ws, "SUBSCRIBE", stream)
+	}
+}
+
+func (cs *CombinedStream) url() (string, error) {
+	streams := cs.Streams()
+	if len(streams) == 0 {
+		return "", errors.New("no stream subscribed")
+	}
+	dialed := make(map[string]bool, len(streams))
+	for _, stream := range streams {
+		dialed[stream] = true
+	}
+	cs.Lock()
+	cs.dialed = dialed
+	cs.Unlock()
+	return fmt.Sprintf("%s/stream?streams=%s", cs.host, strings.Join(streams, "/")), nil
+}
+
+func (cs *CombinedStream) send(ws *wsConn, method, stream string) {
+	cs.Lock()
+	cs.id++
+	r := req{Method: method, Params: []interface{}{stream}, Id: cs.id}
+	cs.Unlock()
+	if err := ws.writeJSON(r); err != nil {
+		Logger.Warn("combined stream request failed, retry after reconnect",
+			zap.String("method", method), zap.String("stream", stream), zap.Error(err))
+	}
+}
+
+// 组合推送的消息格式为 {"stream":"<流名称>","data":<原始消息>}
+func (cs *CombinedStream) route(message []byte) error {
+	msg := struct {
+		Stream string          `json:"stream"`
+		Data   json.RawMessage `json:"data"`
+	}{}
+	if err := json.Unmarshal(message, &msg); err != nil {
+		return errors.Wrap(err, "combined stream unmarshal failed")
+	}
+	cs.Lock()
+	sub, ok := cs.handlers[msg.Stream]
+	cs.Unlock()
+	if !ok {
+		// 取消订阅后还在途的消息
+		return nil
+	}
+	return sub.deliver(msg.Data)
+}
@@ -0,0 +1,197 @@
+package binance
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// 不建立连接的组合推送,订阅请求发送失败只记录日志
+func testCombinedStream() *CombinedStream {
+	as := &apiService{Ctx: context.Background(), wsEvents: make(chan *WebsocketStateEvent, wsEventBuffer)}
+	cs := as.NewCombinedStream()
+	cs.ws = as.newWebsocket("combined", cs.url, cs.route)
+	return cs
+}
+
+func klineMessage(stream string, final bool) []byte {
+	data, _ := json.Marshal(map[string]interface{}{
+		"stream": stream,
+		"data": map[string]interface{}{
+			"e": "kline", "E": 1654041600000, "S": "ETHUSDT",
+			"k": map[string]interface{}{"i": "1m", "t": 1654041600000, "T": 1654041659999, "o": "1800", "c": "1810", "h": "1815", "l": "1795", "v": "12.5", "x": final},
+		},
+	})
+	return data
+}
+
+func depthMessage(stream string, u int) []byte {
+	data, _ := json.Marshal(map[string]interface{}{
+		"stream": stream,
+		"data": map[string]interface{}{
+			"e": "depthUpdate", "E": 1654041600000, "T": 1654041600000, "s": "ETHUSDT",
+			"U": u, "u": u, "pu": u - 1, "b": [][]string{{"1800", "1"}}, "a": [][]string{},
+		},
+	})
+	return data
+}
+
+func Test_CombinedRoute(t *testing.T) {
+	cs := testCombinedStream()
+	kech, err := cs.SubscribeKline("ETHUSDT", Interval("1m"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dech, _ := cs.SubscribeDepth("ETHUSDT")
+	if _, err := cs.SubscribeKline("ethusdt", Interval("1m")); err == nil {
+		t.Error("duplicate subscribe accepted")
+	}
+
+	if err := cs.route(klineMessage("ethusdt@kline_1m", true)); err != nil {
+		t.Fatal(err)
+	}
+	if ke := <-kech; ke.Close != 1810 || !ke.Final || ke.Symbol != "ETHUSDT" {
+		t.Errorf("kline %+v", ke)
+	}
+	cs.route(depthMessage("ethusdt@depth@100ms", 10))
+	if de := <-dech; de.UpdateID != 10 || len(de.Bids) != 1 || de.Gap {
+		t.Errorf("depth %+v", de)
+	}
+	if len(kech) != 0 {
+		t.Error("depth routed to kline")
+	}
+
+	// 没有订阅的流直接丢弃
+	if err := cs.route(klineMessage("btcusdt@kline_1m", true)); err != nil {
+		t.Error(err)
+	}
+	if err := cs.route([]byte(`not json`)); err == nil {
+		t.Error("bad message accepted")
+	}
+}
+
+func Test_CombinedDrop(t *testing.T) {
+	cs := testCombinedStream()
+	kech, _ := cs.SubscribeKline("ETHUSDT", Interval("1m"))
+	dech, _ := cs.SubscribeDepth("ETHUSDT")
+
+	for i := 0; i < combinedBuffer+2; i++ {
+		cs.route(klineMessage("ethusdt@kline_1m", false))
+	}
+	if n := cs.Dropped("ethusdt@kline_1m"); n != 2 || len(kech) != combinedBuffer {
+		t.Errorf("dropped %d buffered %d", n, len(kech))
+	}
+
+	// 通道满丢弃一条,读取后下一条推送带 Gap 标记
+	for i := 0; i <= combinedBuffer; i++ {
+		cs.route(depthMessage("ethusdt@depth@100ms", i+1))
+	}
+	if n := cs.Dropped("ethusdt@depth@100ms"); n != 1 {
+		t.Errorf("depth dropped %d", n)
+	}
+	for i := 0; i < combinedBuffer; i++ {
+		if de := <-dech; de.Gap {
+			t.Fatalf("depth %d flagged before drop", de.UpdateID)
+		}
+	}
+	cs.route(depthMessage("ethusdt@depth@100ms", 1000))
+	cs.route(depthMessage("ethusdt@depth@100ms", 1001))
+	if de := <-dech; !de.Gap || de.UpdateID != 1000 {
+		t.Errorf("depth after drop %+v", de)
+	}
+	if de := <-dech; de.Gap {
+		t.Error("gap flag not cleared")
+	}
+}
+
+func Test_CombinedUnsubscribe(t *testing.T) {
+	cs := testCombinedStream()
+	kech, _ := cs.SubscribeKline("ETHUSDT", Interval("1m"))
+	if err := cs.Unsubscribe("ethusdt@kline_1m"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-kech; ok {
+		t.Error("channel not closed")
+	}
+	// 取消订阅后在途的消息不会发送到关闭的通道
+	if err := cs.route(klineMessage("ethusdt@kline_1m", true)); err != nil {
+		t.Error(err)
+	}
+	if err := cs.Unsubscribe("ethusdt@kline_1m"); err == nil {
+		t.Error("unsubscribe twice")
+	}
+	if len(cs.Streams()) != 0 {
+		t.Errorf("streams %v", cs.Streams())
+	}
+}
+
+// 拨号之后才加入的流,连上后补发 SUBSCRIBE
+func Test_CombinedResubscribe(t *testing.T) {
+	queries := make(chan string, 1)
+	release := make(chan struct{})
+	requests := make(chan req, 4)
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries <- r.URL.Query().Get("streams")
+		<-release
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		for {
+			var r req
+			if err := c.ReadJSON(&r); err != nil {
+				return
+			}
+			requests <- r
+			c.WriteMessage(websocket.TextMessage, []byte(`{"result":null,"id":1}`))
+			c.WriteMessage(websocket.TextMessage, klineMessage("btcusdt@kline_1m", true))
+		}
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	as := &apiService{Ctx: ctx, wsEvents: make(chan *WebsocketStateEvent, wsEventBuffer)}
+	cs := as.NewCombinedStream()
+	cs.host = "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	if _, err := cs.SubscribeKline("ETHUSDT", Interval("1m")); err != nil {
+		t.Fatal(err)
+	}
+	if q := <-queries; q != "ethusdt@kline_1m" {
+		t.Fatalf("dial streams %v", q)
+	}
+	// 还没有连上,发送失败
+	kech, _ := cs.SubscribeKline("BTCUSDT", Interval("1m"))
+	close(release)
+
+	select {
+	case r := <-requests:
+		if r.Method != "SUBSCRIBE" || len(r.Params) != 1 || r.Params[0] != "btcusdt@kline_1m" {
+			t.Errorf("request %+v", r)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscribe not resent after connect")
+	}
+	select {
+	case ke := <-kech:
+		if ke.Close != 1810 {
+			t.Errorf("kline %+v", ke)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("resubscribed stream not routed")
+	}
+	select {
+	case r := <-requests:
+		t.Errorf("unexpected request %+v", r)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
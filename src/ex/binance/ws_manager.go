@@ -0,0 +1,349 @@
+package binance
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	. "tinyquant/src/logger"
+
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	wsMinBackoff   = time.Second
+	wsMaxBackoff   = time.Minute
+	wsReadTimeout  = 5 * time.Minute               // 币安每3分钟发送 ping,超过这个时间没有收到任何消息认为连接已经断开
+	wsPongInterval = 5 * time.Minute               // 主动发送 pong 保活
+	wsRotate       = 23*time.Hour + 30*time.Minute // 币安24小时断开连接,提前主动重连
+	wsEventBuffer  = 64
+)
+
+// 连接状态事件,重连期间的推送会丢失,收到 Reconnect 的 Connected 事件后需要重新同步状态
+type WebsocketStateEvent struct {
+	Stream    string // 订阅的流,例如 ethusdt@depth@100ms
+	Connected bool
+	Reconnect bool  // 断开后重新连接
+	Err       error // 断开原因
+	Time      time.Time
+}
+
+// 一条自动重连的推送连接
+type wsConn struct {
+	as     *apiService
+	ctx    context.Context // as.Ctx 结束或者 Close 之后不再重连
+	cancel context.CancelFunc
+	stream string
+	url    func() (string, error)     // 每次连接时重新获取地址,listenKey 会变化
+	handle func(message []byte) error // 返回的错误只记录日志
+	done   chan struct{}
+
+	rotate    time.Duration                                             // 主动重连的间隔,默认 wsRotate
+	connect   func(url string) (*websocket.Conn, *http.Response, error) // 为空时使用 Dialer
+	onConnect func(ws *wsConn)                                          // 每次连上后、读取推送之前调用,补发订阅请求
+
+	mu   sync.Mutex
+	conn *websocket.Conn // 当前连接,发送订阅请求使用
+}
+
+func (as *apiService) newWebsocket(stream string, url func() (string, error), handle func(message []byte) error) *wsConn {
+	ctx, cancel := context.WithCancel(as.Ctx)
+	return &wsConn{as: as, ctx: ctx, cancel: cancel, stream: stream, url: url, handle: handle, done: make(chan struct{}), rotate: wsRotate}
+}
+
+// 逐条读取推送的连接,用法和 websocket.Conn 相同,断开后在后台按指数退避重连
+// ReadMessage 只在 as.Ctx 结束或者 Close 之后返回错误
+type wsReader struct {
+	*wsConn
+	messages chan []byte
+}
+
+// 建立推送连接,第一次连接失败时返回错误
+func (as *apiService) dialWebsocket(stream string, url func() (string, error)) (*wsReader, error) {
+	r := &wsReader{messages: make(chan []byte)}
+	r.wsConn = as.newWebsocket(stream, url, r.push)
+	c, err := r.dial()
+	if err != nil {
+		r.cancel()
+		return nil, err
+	}
+	as.emitWebsocketState(&WebsocketStateEvent{Stream: stream, Connected: true, Time: time.Now()})
+	go r.run(c)
+	return r, nil
+}
+
+func (r *wsReader) push(message []byte) error {
+	select {
+	case r.messages <- message:
+	case <-r.ctx.Done():
+	}
+	return nil
+}
+
+func (r *wsReader) ReadMessage() (int, []byte, error) {
+	select {
+	case message := <-r.messages:
+		return websocket.TextMessage, message, nil
+	case <-r.done:
+		return 0, nil, errors.New("websocket closed")
+	}
+}
+
+// 第一次连接失败时不返回错误,在后台按指数退避重连,推送交给 handle 处理
+// 失败和连上都通过 WebsocketEvents 通知,失败后连上的事件 Reconnect 为 true
+func (ws *wsConn) keep() {
+	go func() {
+		c, err := ws.dial()
+		if err != nil {
+			Logger.Warn("websocket dial failed, retry in background", zap.String("stream", ws.stream), zap.Error(err))
+			ws.as.emitWebsocketState(&WebsocketStateEvent{Stream: ws.stream, Err: err, Time: time.Now()})
+			if c = ws.redial(); c == nil {
+				close(ws.done)
+				return
+			}
+		}
+		ws.as.emitWebsocketState(&WebsocketStateEvent{Stream: ws.stream, Connected: true, Reconnect: err != nil, Time: time.Now()})
+		ws.run(c)
+	}()
+}
+
+// 停止重连并关闭当前连接
+func (ws *wsConn) Close() error {
+	ws.cancel()
+	return nil
+}
+
+// 关闭当前连接,在后台重新连接,例如 listenKey 过期后重新申请
+func (ws *wsConn) Reconnect() {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.conn != nil {
+		ws.conn.Close()
+	}
+}
+
+// 在当前连接上发送请求,断开期间返回错误
+func (ws *wsConn) writeJSON(v interface{}) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.conn == nil {
+		return errors.New("websocket not connected")
+	}
+	return ws.conn.WriteJSON(v)
+}
+
+func (ws *wsConn) dial() (*websocket.Conn, error) {
+	url, err := ws.url()
+	if err != nil {
+		return nil, err
+	}
+	connect := ws.connect
+	if connect == nil {
+		connect = func(url string) (*websocket.Conn, *http.Response, error) { return Dialer.Dial(url, nil) }
+	}
+	c, _, err := connect(url)
+	if err != nil {
+		return nil, errors.Wrap(err, "websocket dial failed")
+	}
+	return c, nil
+}
+
+func (ws *wsConn) run(c *websocket.Conn) {
+	defer close(ws.done)
+	for {
+		err := ws.serve(c)
+		if ws.ctx.Err() != nil {
+			ws.as.emitWebsocketState(&WebsocketStateEvent{Stream: ws.stream, Time: time.Now()})
+			Logger.Info("websocket closed", zap.String("stream", ws.stream))
+			return
+		}
+		Logger.Warn("websocket disconnected", zap.String("stream", ws.stream), zap.Error(err))
+		ws.as.emitWebsocketState(&WebsocketStateEvent{Stream: ws.stream, Err: err, Time: time.Now()})
+
+		if c = ws.redial(); c == nil {
+			return
+		}
+		Logger.Info("websocket reconnected", zap.String("stream", ws.stream))
+		ws.as.emitWebsocketState(&WebsocketStateEvent{Stream: ws.stream, Connected: true, Reconnect: true, Time: time.Now()})
+	}
+}
+
+// 按指数退避重连,停止重连时返回 nil
+func (ws *wsConn) redial() *websocket.Conn {
+	backoff := wsMinBackoff
+	for {
+		c, err := ws.dial()
+		if err == nil {
+			return c
+		}
+		Logger.Warn("websocket redial failed", zap.String("stream", ws.stream), zap.Duration("backoff", backoff), zap.Error(err))
+		select {
+		case <-ws.ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		backoff = nextBackoff(backoff)
+	}
+}
+
+// 退避时间翻倍,最长 wsMaxBackoff
+func nextBackoff(backoff time.Duration) time.Duration {
+	if backoff *= 2; backoff > wsMaxBackoff {
+		backoff = wsMaxBackoff
+	}
+	return backoff
+}
+
+// 读取一条连接直到断开,返回断开原因
+func (ws *wsConn) serve(c *websocket.Conn) error {
+	var once sync.Once
+	var rotated int32
+	stop := make(chan struct{})
+	closeConn := func() { once.Do(func() { c.Close() }) }
+	defer close(stop)
+	defer closeConn()
+
+	ws.mu.Lock()
+	ws.conn = c
+	ws.mu.Unlock()
+	defer func() {
+		ws.mu.Lock()
+		ws.conn = nil
+		ws.mu.Unlock()
+	}()
+	if ws.onConnect != nil {
+		ws.onConnect(ws)
+	}
+
+	c.SetReadDeadline(time.Now().Add(wsReadTimeout))
+	c.SetPingHandler(func(data string) error {
+		c.SetReadDeadline(time.Now().Add(wsReadTimeout))
+		err := c.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(10*time.Second))
+		if err == websocket.ErrCloseSent {
+			return nil
+		}
+		return err
+	})
+
+	// 退出,定时 pong 和24小时前的主动重连都在这里,关闭连接让 ReadMessage 返回
+	go func() {
+		ticker := time.NewTicker(wsPongInterval)
+		defer ticker.Stop()
+		rotate := time.NewTimer(ws.rotate)
+		defer rotate.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ws.ctx.Done():
+				c.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+				closeConn()
+				return
+			case <-rotate.C:
+				atomic.StoreInt32(&rotated, 1)
+				closeConn()
+				return
+			case <-ticker.C:
+				if err := c.WriteControl(websocket.PongMessage, nil, time.Now().Add(10*time.Second)); err != nil {
+					closeConn()
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		_, message, err := c.ReadMessage()
+		if err != nil {
+			if atomic.LoadInt32(&rotated) == 1 {
+				return errors.New("planned reconnect before 24h limit")
+			}
+			return err
+		}
+		c.SetReadDeadline(time.Now().Add(wsReadTimeout))
+		// 订阅和设置属性的应答
+		if bytes.HasPrefix(message, []byte(`{"result"`)) {
+			continue
+		}
+		if err := ws.handle(message); err != nil {
+			Logger.Error("websocket message handle failed", zap.String("stream", ws.stream), zap.Error(err))
+		}
+	}
+}
+
+// 连接状态事件不阻塞推送,没有及时读取时丢弃
+func (as *apiService) emitWebsocketState(ev *WebsocketStateEvent) {
+	select {
+	case as.wsEvents <- ev:
+	default:
+		Logger.Warn("websocket state event dropped", zap.String("stream", ev.Stream), zap.Bool("connected", ev.Connected))
+	}
+}
+
+func (as *apiService) WebsocketEvents() chan *WebsocketStateEvent {
+	return as.wsEvents
+}
+
+func (b *binance) WebsocketEvents() chan *WebsocketStateEvent {
+	return b.Service.WebsocketEvents()
+}
+
+// 行情推送的地址不变
+func staticURL(url string) func() (string, error) {
+	return func() (string, error) { return url, nil }
+}
+
+// 地址最后一段作为流名称
+func streamName(url string) string {
+	return url[strings.LastIndex(url, "/")+1:]
+}
+
+// 账户推送的 listenKey,重连时重新申请,没有过期时币安返回同一个并延长有效期
+type userDataKey struct {
+	sync.Mutex
+	key       string
+	format    string
+	start     func() (*Stream, error)
+	keepAlive func(s *Stream) error
+	renew     bool
+}
+
+func (k *userDataKey) url() (string, error) {
+	k.Lock()
+	defer k.Unlock()
+	if k.renew || k.key == "" {
+		s, err := k.start()
+		if err != nil {
+			return "", errors.Wrap(err, "start user data stream failed")
+		}
+		k.key = s.ListenKey
+	}
+	k.renew = true
+	return fmt.Sprintf(k.format, k.key), nil
+}
+
+// 每30分钟延长 listenKey 有效期,done 关闭后停止
+func (k *userDataKey) keepAliveLoop(done chan struct{}) {
+	ticker := time.NewTicker(30 * time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			k.Lock()
+			key := k.key
+			k.Unlock()
+			if err := k.keepAlive(&Stream{ListenKey: key}); err != nil {
+				Logger.Warn("keep alive user data stream failed", zap.Error(err))
+			}
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package binance
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"tinyquant/src/logger"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// 单元测试不读取配置
+func init() {
+	logger.Logger = zap.NewNop()
+}
+
+func Test_NextBackoff(t *testing.T) {
+	want := []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second, 16 * time.Second, 32 * time.Second, time.Minute, time.Minute}
+	backoff := wsMinBackoff
+	for i, w := range want {
+		if backoff = nextBackoff(backoff); backoff != w {
+			t.Fatalf("backoff %d : %v, want %v", i, backoff, w)
+		}
+	}
+}
+
+// 重连失败时在退避期间响应关闭
+func Test_RedialStop(t *testing.T) {
+	as := &apiService{Ctx: context.Background(), wsEvents: make(chan *WebsocketStateEvent, wsEventBuffer)}
+	var dials int
+	ws := as.newWebsocket("test", staticURL("ws://test"), func([]byte) error { return nil })
+	ws.connect = func(url string) (*websocket.Conn, *http.Response, error) {
+		dials++
+		return nil, nil, errors.New("refused")
+	}
+	time.AfterFunc(20*time.Millisecond, func() { ws.Close() })
+	if c := ws.redial(); c != nil || dials != 1 {
+		t.Errorf("redial %v after %d dials", c, dials)
+	}
+}
+
+// 没有读取状态事件时不阻塞推送
+func Test_EmitWebsocketStateFull(t *testing.T) {
+	as := &apiService{wsEvents: make(chan *WebsocketStateEvent, 1)}
+	emitted := make(chan struct{})
+	go func() {
+		as.emitWebsocketState(&WebsocketStateEvent{Stream: "a"})
+		as.emitWebsocketState(&WebsocketStateEvent{Stream: "b"})
+		close(emitted)
+	}()
+	select {
+	case <-emitted:
+	case <-time.After(time.Second):
+		t.Fatal("emit blocked on full channel")
+	}
+	if ev := <-as.WebsocketEvents(); ev.Stream != "a" {
+		t.Errorf("event %v, want the first one", ev.Stream)
+	}
+}
+
+// 到达 rotate 时间主动重连,推送继续
+func Test_WebsocketRotate(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		c.WriteMessage(websocket.TextMessage, []byte(`{"e":"test"}`))
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	as := &apiService{Ctx: ctx, wsEvents: make(chan *WebsocketStateEvent, wsEventBuffer)}
+
+	var mu sync.Mutex
+	var dials []time.Time
+	messages := make(chan string, 16)
+	ws := as.newWebsocket("test", staticURL("ws"+strings.TrimPrefix(srv.URL, "http")), func(message []byte) error {
+		messages <- string(message)
+		return nil
+	})
+	ws.rotate = 100 * time.Millisecond
+	ws.connect = func(url string) (*websocket.Conn, *http.Response, error) {
+		mu.Lock()
+		dials = append(dials, time.Now())
+		mu.Unlock()
+		return Dialer.Dial(url, nil)
+	}
+	c, err := ws.dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go ws.run(c)
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-messages:
+		case <-time.After(time.Second):
+			t.Fatalf("message %d not received", i)
+		}
+	}
+	ws.Close()
+	<-ws.done
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(dials) < 3 {
+		t.Fatalf("dials %d, want 3", len(dials))
+	}
+	for i := 1; i < len(dials); i++ {
+		if d := dials[i].Sub(dials[i-1]); d < ws.rotate || d > ws.rotate+500*time.Millisecond {
+			t.Errorf("reconnect %d after %v, want %v", i, d, ws.rotate)
+		}
+	}
+	var reconnects int
+	for len(as.wsEvents) > 0 {
+		if ev := <-as.wsEvents; ev.Connected && ev.Reconnect {
+			reconnects++
+		}
+	}
+	if reconnects != len(dials)-1 {
+		t.Errorf("reconnect events %d, dials %d", reconnects, len(dials))
+	}
+}
@@ -0,0 +1,429 @@
+package indicator
+
+import "math"
+
+// 技术指标,每根收盘的K线更新一次,每次更新 O(1)
+// 指标本身不加锁,挂到 MyKlineQueue 上时由队列的锁保护
+
+// 指标的输入
+type Bar struct {
+	High   float64
+	Low    float64
+	Close  float64
+	Volume float64
+}
+
+type Indicator interface {
+	Update(b Bar)
+	Ready() bool // 数据足够,Value 有意义
+	Reset()
+}
+
+// 固定长度的滑动窗口
+type ring struct {
+	buf  []float64
+	head int
+	n    int
+}
+
+func newRing(n int) ring {
+	return ring{buf: make([]float64, n)}
+}
+
+// 放入一个值,窗口满时返回被挤出的值
+func (r *ring) push(v float64) (old float64, evicted bool) {
+	if r.n == len(r.buf) {
+		old, evicted = r.buf[r.head], true
+	} else {
+		r.n++
+	}
+	r.buf[r.head] = v
+	r.head = (r.head + 1) % len(r.buf)
+	return old, evicted
+}
+
+func (r *ring) full() bool {
+	return r.n == len(r.buf)
+}
+
+func (r *ring) reset() {
+	r.head, r.n = 0, 0
+}
+
+// 简单移动平均
+type SMA struct {
+	Period int
+	win    ring
+	sum    float64
+}
+
+func NewSMA(period int) *SMA {
+	return &SMA{Period: period, win: newRing(period)}
+}
+
+func (s *SMA) Update(b Bar) { s.Add(b.Close) }
+
+func (s *SMA) Add(v float64) {
+	if old, ok := s.win.push(v); ok {
+		s.sum -= old
+	}
+	s.sum += v
+}
+
+func (s *SMA) Value() float64 {
+	if s.win.n == 0 {
+		return 0
+	}
+	return s.sum / float64(s.win.n)
+}
+
+func (s *SMA) Ready() bool { return s.win.full() }
+
+func (s *SMA) Reset() {
+	s.win.reset()
+	s.sum = 0
+}
+
+// 指数移动平均,前 Period 个值用简单平均作为初始值
+type EMA struct {
+	Period int
+	alpha  float64
+	value  float64
+	count  int
+}
+
+func NewEMA(period int) *EMA {
+	return &EMA{Period: period, alpha: 2 / float64(period+1)}
+}
+
+func (e *EMA) Update(b Bar) { e.Add(b.Close) }
+
+func (e *EMA) Add(v float64) {
+	e.count++
+	if e.count <= e.Period {
+		e.value += (v - e.value) / float64(e.count)
+		return
+	}
+	e.value += e.alpha * (v - e.value)
+}
+
+func (e *EMA) Value() float64 { return e.value }
+
+func (e *EMA) Ready() bool { return e.count >= e.Period }
+
+func (e *EMA) Reset() {
+	e.value, e.count = 0, 0
+}
+
+// Wilder 平滑,RSI ATR ADX 使用
+type wilder struct {
+	period int
+	value  float64
+	count  int
+}
+
+func (w *wilder) add(v float64) {
+	w.count++
+	if w.count <= w.period {
+		w.value += (v - w.value) / float64(w.count)
+		return
+	}
+	w.value = (w.value*float64(w.period-1) + v) / float64(w.period)
+}
+
+func (w *wilder) ready() bool { return w.count >= w.period }
+
+func (w *wilder) reset() {
+	w.value, w.count = 0, 0
+}
+
+// 相对强弱指数
+type RSI struct {
+	Period int
+	gain   wilder
+	loss   wilder
+	prev   float64
+	seen   bool
+}
+
+func NewRSI(period int) *RSI {
+	return &RSI{Period: period, gain: wilder{period: period}, loss: wilder{period: period}}
+}
+
+func (r *RSI) Update(b Bar) {
+	if r.seen {
+		change := b.Close - r.prev
+		r.gain.add(math.Max(change, 0))
+		r.loss.add(math.Max(-change, 0))
+	}
+	r.prev, r.seen = b.Close, true
+}
+
+// 0~100,没有下跌时为 100
+func (r *RSI) Value() float64 {
+	if r.loss.value == 0 {
+		if r.gain.value == 0 {
+			return 50
+		}
+		return 100
+	}
+	return 100 - 100/(1+r.gain.value/r.loss.value)
+}
+
+func (r *RSI) Ready() bool { return r.gain.ready() }
+
+func (r *RSI) Reset() {
+	r.gain.reset()
+	r.loss.reset()
+	r.seen = false
+}
+
+// 平均真实波幅
+type ATR struct {
+	Period int
+	tr     wilder
+	prev   float64
+	seen   bool
+}
+
+func NewATR(period int) *ATR {
+	return &ATR{Period: period, tr: wilder{period: period}}
+}
+
+func (a *ATR) Update(b Bar) {
+	a.tr.add(trueRange(b, a.prev, a.seen))
+	a.prev, a.seen = b.Close, true
+}
+
+func (a *ATR) Value() float64 { return a.tr.value }
+
+func (a *ATR) Ready() bool { return a.tr.ready() }
+
+func (a *ATR) Reset() {
+	a.tr.reset()
+	a.seen = false
+}
+
+func trueRange(b Bar, prevClose float64, seen bool) float64 {
+	tr := b.High - b.Low
+	if seen {
+		tr = math.Max(tr, math.Max(math.Abs(b.High-prevClose), math.Abs(b.Low-prevClose)))
+	}
+	return tr
+}
+
+// 布林带,标准差按总体计算
+type Bollinger struct {
+	Period int
+	K      float64 // 标准差倍数
+	win    ring
+	sum    float64
+	sumSq  float64
+}
+
+func NewBollinger(period int, k float64) *Bollinger {
+	return &Bollinger{Period: period, K: k, win: newRing(period)}
+}
+
+func (bb *Bollinger) Update(b Bar) {
+	if old, ok := bb.win.push(b.Close); ok {
+		bb.sum -= old
+		bb.sumSq -= old * old
+	}
+	bb.sum += b.Close
+	bb.sumSq += b.Close * b.Close
+}
+
+func (bb *Bollinger) Mid() float64 {
+	if bb.win.n == 0 {
+		return 0
+	}
+	return bb.sum / float64(bb.win.n)
+}
+
+func (bb *Bollinger) StdDev() float64 {
+	if bb.win.n == 0 {
+		return 0
+	}
+	mid := bb.Mid()
+	// 累计误差可能让方差略小于0
+	return math.Sqrt(math.Max(bb.sumSq/float64(bb.win.n)-mid*mid, 0))
+}
+
+func (bb *Bollinger) Upper() float64 { return bb.Mid() + bb.K*bb.StdDev() }
+
+func (bb *Bollinger) Lower() float64 { return bb.Mid() - bb.K*bb.StdDev() }
+
+// 带宽占中轨的比例
+func (bb *Bollinger) Width() float64 {
+	if mid := bb.Mid(); mid != 0 {
+		return 2 * bb.K * bb.StdDev() / mid
+	}
+	return 0
+}
+
+func (bb *Bollinger) Ready() bool { return bb.win.full() }
+
+func (bb *Bollinger) Reset() {
+	bb.win.reset()
+	bb.sum, bb.sumSq = 0, 0
+}
+
+// 平滑异同移动平均
+type MACD struct {
+	fast   *EMA
+	slow   *EMA
+	signal *EMA
+}
+
+func NewMACD(fast, slow, signal int) *MACD {
+	return &MACD{fast: NewEMA(fast), slow: NewEMA(slow), signal: NewEMA(signal)}
+}
+
+func (m *MACD) Update(b Bar) {
+	m.fast.Add(b.Close)
+	m.slow.Add(b.Close)
+	if m.slow.Ready() {
+		m.signal.Add(m.MACD())
+	}
+}
+
+// 快线减慢线
+func (m *MACD) MACD() float64 { return m.fast.Value() - m.slow.Value() }
+
+func (m *MACD) Signal() float64 { return m.signal.Value() }
+
+func (m *MACD) Hist() float64 { return m.MACD() - m.Signal() }
+
+func (m *MACD) Ready() bool { return m.signal.Ready() }
+
+func (m *MACD) Reset() {
+	m.fast.Reset()
+	m.slow.Reset()
+	m.signal.Reset()
+}
+
+// 成交量加权均价,按典型价格 (高+低+收)/3 计算,Period 为0时累计全部K线
+type VWAP struct {
+	Period int
+	pv     ring
+	vol    ring
+	sumPV  float64
+	sumVol float64
+	count  int
+}
+
+func NewVWAP(period int) *VWAP {
+	v := &VWAP{Period: period}
+	if period > 0 {
+		v.pv, v.vol = newRing(period), newRing(period)
+	}
+	return v
+}
+
+func (v *VWAP) Update(b Bar) {
+	pv := (b.High + b.Low + b.Close) / 3 * b.Volume
+	if v.Period > 0 {
+		if old, ok := v.pv.push(pv); ok {
+			v.sumPV -= old
+		}
+		if old, ok := v.vol.push(b.Volume); ok {
+			v.sumVol -= old
+		}
+	}
+	v.sumPV += pv
+	v.sumVol += b.Volume
+	v.count++
+}
+
+func (v *VWAP) Value() float64 {
+	if v.sumVol == 0 {
+		return 0
+	}
+	return v.sumPV / v.sumVol
+}
+
+func (v *VWAP) Ready() bool {
+	if v.Period > 0 {
+		return v.pv.full()
+	}
+	return v.count > 0
+}
+
+func (v *VWAP) Reset() {
+	v.pv.reset()
+	v.vol.reset()
+	v.sumPV, v.sumVol, v.count = 0, 0, 0
+}
+
+// 平均趋向指数,ADX 需要 2*Period 根K线
+type ADX struct {
+	Period  int
+	tr      wilder
+	plusDM  wilder
+	minusDM wilder
+	adx     wilder
+	prev    Bar
+	seen    bool
+}
+
+func NewADX(period int) *ADX {
+	return &ADX{Period: period, tr: wilder{period: period}, plusDM: wilder{period: period},
+		minusDM: wilder{period: period}, adx: wilder{period: period}}
+}
+
+func (a *ADX) Update(b Bar) {
+	if a.seen {
+		up, down := b.High-a.prev.High, a.prev.Low-b.Low
+		var plus, minus float64
+		if up > down && up > 0 {
+			plus = up
+		}
+		if down > up && down > 0 {
+			minus = down
+		}
+		a.tr.add(trueRange(b, a.prev.Close, true))
+		a.plusDM.add(plus)
+		a.minusDM.add(minus)
+		if a.tr.ready() {
+			a.adx.add(a.dx())
+		}
+	}
+	a.prev, a.seen = b, true
+}
+
+// +DI
+func (a *ADX) PlusDI() float64 {
+	if a.tr.value == 0 {
+		return 0
+	}
+	return 100 * a.plusDM.value / a.tr.value
+}
+
+// -DI
+func (a *ADX) MinusDI() float64 {
+	if a.tr.value == 0 {
+		return 0
+	}
+	return 100 * a.minusDM.value / a.tr.value
+}
+
+func (a *ADX) dx() float64 {
+	plus, minus := a.PlusDI(), a.MinusDI()
+	if plus+minus == 0 {
+		return 0
+	}
+	return 100 * math.Abs(plus-minus) / (plus + minus)
+}
+
+func (a *ADX) Value() float64 { return a.adx.value }
+
+func (a *ADX) Ready() bool { return a.adx.ready() }
+
+func (a *ADX) Reset() {
+	a.tr.reset()
+	a.plusDM.reset()
+	a.minusDM.reset()
+	a.adx.reset()
+	a.seen = false
+}
@@ -0,0 +1,139 @@
+package indicator_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"tinyquant/src/indicator"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func closeBar(c float64) indicator.Bar {
+	return indicator.Bar{High: c, Low: c, Close: c, Volume: 1}
+}
+
+// 滑动窗口的结果和直接计算的一致
+func Test_SMAWindow(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	sma := indicator.NewSMA(10)
+	bb := indicator.NewBollinger(10, 2)
+	var closes []float64
+	for i := 0; i < 200; i++ {
+		c := 100 + r.Float64()*10
+		closes = append(closes, c)
+		sma.Update(closeBar(c))
+		bb.Update(closeBar(c))
+
+		win := closes
+		if len(win) > 10 {
+			win = win[len(win)-10:]
+		}
+		var sum, sq float64
+		for _, v := range win {
+			sum += v
+		}
+		mean := sum / float64(len(win))
+		for _, v := range win {
+			sq += (v - mean) * (v - mean)
+		}
+		if !near(sma.Value(), mean) || !near(bb.Mid(), mean) || !near(bb.StdDev(), math.Sqrt(sq/float64(len(win)))) {
+			t.Fatalf("bar %d sma %v bollinger %v %v want %v", i, sma.Value(), bb.Mid(), bb.StdDev(), mean)
+		}
+	}
+	if !sma.Ready() || !bb.Ready() {
+		t.Error("not ready")
+	}
+}
+
+func Test_EMA(t *testing.T) {
+	ema := indicator.NewEMA(3)
+	for _, c := range []float64{1, 2, 3} {
+		ema.Add(c)
+	}
+	// 前3个值的简单平均作为初始值
+	if !ema.Ready() || ema.Value() != 2 {
+		t.Fatalf("ema seed %v", ema.Value())
+	}
+	ema.Add(6)
+	if ema.Value() != 4 {
+		t.Errorf("ema %v", ema.Value())
+	}
+}
+
+func Test_RSI(t *testing.T) {
+	rsi := indicator.NewRSI(14)
+	for i := 0; i < 20; i++ {
+		rsi.Update(closeBar(float64(100 + i)))
+	}
+	if !rsi.Ready() || rsi.Value() != 100 {
+		t.Errorf("rising rsi %v", rsi.Value())
+	}
+	rsi.Reset()
+	for i := 0; i < 30; i++ {
+		rsi.Update(closeBar(float64(100 + i%2)))
+	}
+	if v := rsi.Value(); v < 45 || v > 55 {
+		t.Errorf("flat rsi %v", v)
+	}
+}
+
+func Test_ATR(t *testing.T) {
+	atr := indicator.NewATR(14)
+	for i := 0; i < 14; i++ {
+		atr.Update(indicator.Bar{High: 102, Low: 98, Close: 100})
+	}
+	if !atr.Ready() || atr.Value() != 4 {
+		t.Fatalf("atr %v", atr.Value())
+	}
+	// 跳空时真实波幅按前收盘计算
+	atr.Update(indicator.Bar{High: 112, Low: 110, Close: 111})
+	if want := (4*13 + 12) / 14.0; !near(atr.Value(), want) {
+		t.Errorf("atr %v want %v", atr.Value(), want)
+	}
+}
+
+func Test_MACD(t *testing.T) {
+	macd := indicator.NewMACD(12, 26, 9)
+	for i := 0; i < 40; i++ {
+		macd.Update(closeBar(100))
+	}
+	if !macd.Ready() || macd.MACD() != 0 || macd.Hist() != 0 {
+		t.Fatalf("flat macd %v %v", macd.MACD(), macd.Hist())
+	}
+	for i := 0; i < 10; i++ {
+		macd.Update(closeBar(float64(101 + i)))
+	}
+	if macd.MACD() <= 0 || macd.Hist() <= 0 {
+		t.Errorf("rising macd %v %v", macd.MACD(), macd.Hist())
+	}
+}
+
+func Test_VWAP(t *testing.T) {
+	vwap := indicator.NewVWAP(2)
+	vwap.Update(indicator.Bar{High: 10, Low: 10, Close: 10, Volume: 1})
+	vwap.Update(indicator.Bar{High: 20, Low: 20, Close: 20, Volume: 3})
+	if vwap.Value() != 17.5 {
+		t.Errorf("vwap %v", vwap.Value())
+	}
+	vwap.Update(indicator.Bar{High: 30, Low: 30, Close: 30, Volume: 1})
+	if vwap.Value() != 22.5 {
+		t.Errorf("rolling vwap %v", vwap.Value())
+	}
+}
+
+func Test_ADX(t *testing.T) {
+	adx := indicator.NewADX(14)
+	for i := 0; i < 28; i++ {
+		if adx.Ready() {
+			t.Fatalf("ready after %d bars", i)
+		}
+		p := float64(100 + i)
+		adx.Update(indicator.Bar{High: p + 1, Low: p - 1, Close: p})
+	}
+	if !adx.Ready() || adx.PlusDI() <= adx.MinusDI() || adx.Value() < 90 {
+		t.Errorf("trending adx %v +di %v -di %v", adx.Value(), adx.PlusDI(), adx.MinusDI())
+	}
+}
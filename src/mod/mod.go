@@ -38,14 +38,28 @@ type Kline struct {
 	Close       float64
 	High        float64
 	Low         float64
+	VWAP        float64 // 成交均价,只有成交推送合成的K线有值
 	Final       bool
 }
 
+// 归集成交,同一时间同一价格同一方向的成交合并为一条
+type AggTrade struct {
+	ID           int
+	Price        float64
+	Quantity     float64
+	FirstTradeID int
+	LastTradeID  int
+	Time         time.Time
+	BuyerMaker   bool // 买方是挂单方,即主动卖出
+}
+
 type Depth struct {
 	LastUpdateID int
 	BeforeUID    int
 	UpdateID     int
 	MessageTime  time.Time
+	Gap          bool     // 之前的增量被丢弃,需要重新同步
+	Snapshot     bool     // 推送的是全量深度而不是增量,例如火币
 	Bids         []*Order // 买方出价
 	Asks         []*Order //卖方出价
 }
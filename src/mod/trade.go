@@ -0,0 +1,352 @@
+package mod
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 交易所无关的订单、持仓、余额和账户事件,各交易所的适配器负责转换
+
+type OrderSide string
+
+type PositionSide string
+
+type OrderType string
+
+type OrderStatus string
+
+type TimeInForce string
+
+type EventType string
+
+type MarginType string
+
+type Interval string
+
+type IncomeType string
+
+var (
+	SideBuy  = OrderSide("BUY")
+	SideSell = OrderSide("SELL")
+
+	BOTH  = PositionSide("BOTH")
+	LONG  = PositionSide("LONG")
+	SHORT = PositionSide("SHORT")
+
+	TypeLimit              = OrderType("LIMIT")
+	TypeMarket             = OrderType("MARKET")
+	TypeStop               = OrderType("STOP")
+	TypeTakeProfit         = OrderType("TAKE_PROFIT")
+	TypeStopMarket         = OrderType("STOP_MARKET")
+	TypeTakeProfitMarket   = OrderType("TAKE_PROFIT_MARKET")
+	TypeTrailingStopMarket = OrderType("TRAILING_STOP_MARKET") // 跟踪止损
+
+	StatusNew             = OrderStatus("NEW")
+	StatusPartiallyFilled = OrderStatus("PARTIALLY_FILLED")
+	StatusFilled          = OrderStatus("FILLED")
+	StatusCanceled        = OrderStatus("CANCELED")
+	StatusExpired         = OrderStatus("EXPIRED")
+	StatusInsurance       = OrderStatus("NEW_INSURANCE")
+	StatusADL             = OrderStatus("NEW_ADL")
+
+	GTC = TimeInForce("GTC") // 一直有效直到取消
+	IOC = TimeInForce("IOC") // 无法立即成交的部分取消
+	FOK = TimeInForce("FOK") // 无法立即全部成交则全部取消
+	GTX = TimeInForce("GTX") // 只做maker,会立即成交时被拒绝
+
+	EventNew        = EventType("NEW")
+	EventCanceled   = EventType("CANCELED")
+	EventCalculated = EventType("CALCULATED")
+	EventExpired    = EventType("EXPIRED")
+	EventTrade      = EventType("TRADE")
+
+	MarginIsolated = MarginType("ISOLATED") // 逐仓
+	MarginCrossed  = MarginType("CROSSED")  // 全仓
+
+	WorkingContractPrice = "CONTRACT_PRICE" // 条件单按合约最新价触发
+	WorkingMarkPrice     = "MARK_PRICE"     // 条件单按标记价格触发
+
+	Minute         = Interval("1m")
+	ThreeMinutes   = Interval("3m")
+	FiveMinutes    = Interval("5m")
+	FifteenMinutes = Interval("15m")
+	ThirtyMinutes  = Interval("30m")
+	Hour           = Interval("1h")
+	TwoHours       = Interval("2h")
+	FourHours      = Interval("4h")
+	SixHours       = Interval("6h")
+	EightHours     = Interval("8h")
+	TwelveHours    = Interval("12h")
+	Day            = Interval("1d")
+	ThreeDays      = Interval("3d")
+	Week           = Interval("1w")
+	Month          = Interval("1M")
+
+	IncomeTransfer    = IncomeType("TRANSFER")
+	IncomeRealizedPnl = IncomeType("REALIZED_PNL")
+	IncomeFundingFee  = IncomeType("FUNDING_FEE") // 资金费用
+	IncomeCommission  = IncomeType("COMMISSION")
+)
+
+// K线周期的时长,月按30天计算,不认识的周期返回0
+func (i Interval) Duration() time.Duration {
+	s := string(i)
+	if len(s) < 2 {
+		return 0
+	}
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil {
+		return 0
+	}
+	unit := map[byte]time.Duration{
+		'm': time.Minute,
+		'h': time.Hour,
+		'd': 24 * time.Hour,
+		'w': 7 * 24 * time.Hour,
+		'M': 30 * 24 * time.Hour,
+	}[s[len(s)-1]]
+	return time.Duration(n) * unit
+}
+
+// GTX 订单会立即成交被交易所拒绝
+var ErrPostOnlyRejected = errors.New("post only order rejected")
+
+// 下单参数
+type OrderRequest struct {
+	Symbol        string
+	Side          OrderSide
+	PositionSide  PositionSide // 双向持仓下必须为 LONG 或 SHORT
+	Type          OrderType    // 为空时按 StopPrice 判断 LIMIT 或 STOP
+	TimeInForce   TimeInForce
+	Quantity      float64 // ClosePosition 时不传
+	Price         float64 // 市价类订单不传
+	StopPrice     float64 // 条件单触发价格
+	ClientOrderID string
+
+	ReduceOnly      bool    // 只减仓,双向持仓下不支持
+	ClosePosition   bool    // 触发后平掉全部仓位,只支持 STOP_MARKET 和 TAKE_PROFIT_MARKET
+	ActivationPrice float64 // 跟踪止损激活价格,为0时按下单时的价格激活
+	CallbackRate    float64 // 跟踪止损回调比例,1 代表 1%
+	WorkingType     string  // 条件单触发价格类型 CONTRACT_PRICE 或 MARK_PRICE
+	PriceProtect    bool    // 条件单触发保护
+}
+
+// 是否需要委托价格,市价类订单按触发时的市场价格成交
+func (t OrderType) HasPrice() bool {
+	return t == "" || t == TypeLimit || t == TypeStop || t == TypeTakeProfit
+}
+
+// 条件单,价格到达触发价后才挂出
+func (t OrderType) IsConditional() bool {
+	return t != "" && t != TypeLimit && t != TypeMarket
+}
+
+// 止损类条件单
+func (t OrderType) IsStop() bool {
+	return t == TypeStop || t == TypeStopMarket || t == TypeTrailingStopMarket
+}
+
+type FutureOrder struct {
+	Symbol        string
+	OrderID       int64
+	ClientOrderID string
+	Price         float64 // 委托价格
+	OrigQty       float64 // 委托数量
+	AvgPrice      float64 // 平均成交价
+	ExecutedQty   float64 // 已成交量
+	Status        OrderStatus
+	TimeInForce   TimeInForce
+	Type          OrderType
+	OrigType      OrderType // 触发前订单类型
+	Side          OrderSide
+	PositionSide  PositionSide
+	StopPrice     float64 // 条件单触发价格
+	ClosePosition bool    // 是否条件全平仓
+	ReduceOnly    bool    // 是否只减仓
+	ActivatePrice float64 // 跟踪止损激活价格
+	PriceRate     float64 // 跟踪止损回调比例
+	WorkingType   string  // 条件价格触发类型
+	PriceProtect  bool    // 是否开启条件单触发保护
+	Time          time.Time
+	UpdateTime    time.Time
+}
+
+// 是否是开仓方向的订单
+func (o *FutureOrder) IsOpen() bool {
+	return (o.PositionSide == LONG && o.Side == SideBuy) || (o.PositionSide == SHORT && o.Side == SideSell)
+}
+
+type Position struct {
+	Symbol           string
+	PositionSide     PositionSide
+	PositionAmt      float64 // 持仓数量,空单为负数
+	EntryPrice       float64 // 持仓成本价
+	UnrealizedProfit float64 // 未实现盈亏
+	Leverage         float64 // 杠杆倍率
+	Isolated         bool    // 是否逐仓
+	UpdateTime       time.Time
+}
+
+type Balance struct {
+	Asset              string
+	Balance            float64 // 总余额
+	CrossWalletBalance float64 // 全仓余额
+	CrossUnPnl         float64 // 全仓未实现盈亏
+	AvailableBalance   float64 // 下单可用余额
+	MaxWithdrawAmount  float64 // 最大可转出余额
+	MarginAvailable    bool    // 是否可用作联合保证金
+	UpdateTime         time.Time
+}
+
+// 账户推送,EventName 为 ACCOUNT_UPDATE 时 Account 不为空,ORDER_TRADE_UPDATE 时 Order 不为空
+type AccountEvent struct {
+	EventName string
+	Time      time.Time
+	Account   *AccountUpdate
+	Order     *OrderUpdate
+}
+
+// 账户推送的流名称
+const UserDataStream = "userData"
+
+// 推送连接状态,Reconnect 为 true 时断开期间的推送已经丢失,需要重新查询持仓和余额
+type StreamEvent struct {
+	Stream    string // 断开的流,账户推送为 UserDataStream
+	Connected bool
+	Reconnect bool
+	Err       error // 断开原因
+	Time      time.Time
+}
+
+type AccountUpdate struct {
+	Reason    string // 变动原因
+	Balances  []*BalanceUpdate
+	Positions []*Position
+}
+
+type BalanceUpdate struct {
+	Asset              string
+	WalletBalance      float64 // 钱包余额
+	CrossWalletBalance float64 // 除去逐仓保证金的钱包余额
+	BalanceChange      float64 // 除去盈亏和手续费的余额变化
+}
+
+type OrderUpdate struct {
+	FutureOrder
+	Event           EventType // 本次事件的执行类型
+	LastQty         float64   // 末次成交量
+	LastPrice       float64   // 末次成交价格
+	Commission      float64   // 手续费
+	CommissionAsset string
+	IsMaker         bool
+	RealizedProfit  float64 // 该成交实现盈亏
+}
+
+// 标记价格和资金费率,费率为正时多头向空头支付
+type FundingRate struct {
+	Symbol          string
+	MarkPrice       float64
+	IndexPrice      float64
+	Rate            float64   // 本期预测费率,结算前会变化
+	NextFundingTime time.Time // 下次结算时间,没有资金费率的合约为零值
+	Time            time.Time
+}
+
+// 持仓在下次结算时支付的资金费用,负数为收取
+func (f *FundingRate) Cost(side PositionSide, notional float64) float64 {
+	if side == SHORT {
+		return -notional * f.Rate
+	}
+	return notional * f.Rate
+}
+
+// 收益流水,Amount 为正是收入,为负是支出
+type Income struct {
+	Symbol string
+	Type   IncomeType
+	Amount float64
+	Asset  string
+	Info   string
+	TranID int64 // 同一类型内唯一
+	Time   time.Time
+}
+
+// 交易对的下单规则,为 0 的项不做限制
+type SymbolInfo struct {
+	Symbol            string
+	Status            string
+	MarginAsset       string // 保证金币种,现货为计价币种,为空时按 util.ACCOUNTASSET
+	PricePrecision    int
+	QuantityPrecision int
+	TickSize          float64 // 价格最小变动
+	MinPrice          float64
+	MaxPrice          float64
+	StepSize          float64 // 数量最小变动
+	MinQty            float64
+	MaxQty            float64
+	MinNotional       float64 // 最小名义价值 价格*数量
+	MaxNumOrders      int     // 最多挂单数
+	MaxNumAlgoOrders  int     // 最多条件单数
+	TriggerProtect    float64 // 条件单触发保护阈值
+	ContractSize      float64 // 币本位合约面值
+}
+
+// 价格取整到最近的 TickSize
+func (s *SymbolInfo) RoundPrice(price float64) float64 {
+	return snap(price, s.TickSize, math.Round)
+}
+
+// 数量取整到最近的 StepSize
+func (s *SymbolInfo) RoundQty(qty float64) float64 {
+	return snap(qty, s.StepSize, math.Round)
+}
+
+// 数量向下取整到 StepSize,下单数量不会超过持仓
+func (s *SymbolInfo) FloorQty(qty float64) float64 {
+	return snap(qty, s.StepSize, func(f float64) float64 {
+		return math.Floor(f + 1e-9)
+	})
+}
+
+// 检查取整后的价格和数量是否满足下单规则
+func (s *SymbolInfo) Check(price, qty float64) error {
+	if qty <= 0 {
+		return fmt.Errorf("%s quantity %v must be positive", s.Symbol, qty)
+	}
+	if s.MinQty != 0 && qty < s.MinQty {
+		return fmt.Errorf("%s quantity %v less than min %v", s.Symbol, qty, s.MinQty)
+	}
+	if s.MaxQty != 0 && qty > s.MaxQty {
+		return fmt.Errorf("%s quantity %v greater than max %v", s.Symbol, qty, s.MaxQty)
+	}
+	if price == 0 {
+		return nil
+	}
+	if s.MinPrice != 0 && price < s.MinPrice {
+		return fmt.Errorf("%s price %v less than min %v", s.Symbol, price, s.MinPrice)
+	}
+	if s.MaxPrice != 0 && price > s.MaxPrice {
+		return fmt.Errorf("%s price %v greater than max %v", s.Symbol, price, s.MaxPrice)
+	}
+	if s.MinNotional != 0 && price*qty < s.MinNotional {
+		return fmt.Errorf("%s notional %v less than min %v", s.Symbol, price*qty, s.MinNotional)
+	}
+	return nil
+}
+
+// 按步长取整,结果按步长的小数位格式化,去掉浮点误差
+func snap(v, step float64, round func(float64) float64) float64 {
+	if step <= 0 {
+		return v
+	}
+	decimals := 0
+	if str := strconv.FormatFloat(step, 'f', -1, 64); strings.Contains(str, ".") {
+		decimals = len(str) - strings.Index(str, ".") - 1
+	}
+	res, _ := strconv.ParseFloat(strconv.FormatFloat(round(v/step)*step, 'f', decimals, 64), 64)
+	return res
+}
@@ -0,0 +1,52 @@
+package mod_test
+
+import (
+	"testing"
+	"time"
+	"tinyquant/src/mod"
+)
+
+func Test_SymbolInfo(t *testing.T) {
+	info := &mod.SymbolInfo{Symbol: "BTCUSDT", TickSize: 0.1, StepSize: 0.001, MinQty: 0.001, MinNotional: 5}
+
+	if p := info.RoundPrice(30000.26); p != 30000.3 {
+		t.Errorf("round price = %v, want 30000.3", p)
+	}
+	if q := info.FloorQty(0.0129); q != 0.012 {
+		t.Errorf("floor qty = %v, want 0.012", q)
+	}
+	// 0.1+0.2 的浮点误差不能让数量少一个 step
+	if q := info.FloorQty(0.1 + 0.2); q != 0.3 {
+		t.Errorf("floor qty = %v, want 0.3", q)
+	}
+	if err := info.Check(30000, 0.0002); err == nil {
+		t.Error("quantity below min qty should be rejected")
+	}
+	if err := info.Check(3000, 0.001); err == nil {
+		t.Error("notional 3 below min 5 should be rejected")
+	}
+	if err := info.Check(30000, 0.01); err != nil {
+		t.Error(err)
+	}
+
+	ticks := &mod.SymbolInfo{TickSize: 0.25}
+	if p := ticks.RoundPrice(10.3); p != 10.25 {
+		t.Errorf("round price = %v, want 10.25", p)
+	}
+}
+
+func Test_IntervalDuration(t *testing.T) {
+	cases := map[mod.Interval]time.Duration{
+		mod.Minute:         time.Minute,
+		mod.FifteenMinutes: 15 * time.Minute,
+		mod.FourHours:      4 * time.Hour,
+		mod.ThreeDays:      72 * time.Hour,
+		mod.Week:           7 * 24 * time.Hour,
+		mod.Interval("x"):  0,
+	}
+	for i, want := range cases {
+		if d := i.Duration(); d != want {
+			t.Errorf("%v duration = %v, want %v", i, d, want)
+		}
+	}
+}
@@ -0,0 +1,516 @@
+package convert
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"tinyquant/src/mod"
+
+	"github.com/rootpd/binance"
+)
+
+// 币安u本位和币本位共用的返回类型转换为 mod 中的类型
+
+func OrderRequest(req *mod.OrderRequest) binance.NewFutureOrderRequest {
+	t := binance.NewFutureOrderRequest{
+		Symbol:           req.Symbol,
+		Quantity:         req.Quantity,
+		Price:            req.Price,
+		Side:             binance.OrderSide(req.Side),
+		PositionSide:     binance.PositionSide(req.PositionSide),
+		TimeInForce:      binance.TimeInForce(req.TimeInForce),
+		Type:             binance.OrderType(req.Type),
+		StopPrice:        req.StopPrice,
+		NewClientOrderID: req.ClientOrderID,
+		ActivationPrice:  req.ActivationPrice,
+		CallbackRate:     req.CallbackRate,
+		WorkingType:      req.WorkingType,
+		Timestamp:        time.Now(),
+		RecvWindow:       5 * time.Second,
+	}
+	if req.ReduceOnly {
+		t.ReduceOnly = "true"
+	}
+	if req.ClosePosition {
+		t.ClosePosition = "true"
+	}
+	if req.PriceProtect {
+		t.PriceProtect = "TRUE"
+	}
+	if t.TimeInForce == "" {
+		t.TimeInForce = binance.GTC
+	}
+	if t.Type == "" {
+		t.Type = binance.TypeLimit
+		if t.StopPrice != 0 {
+			t.Type = binance.TypeSTOP
+		}
+	}
+	return t
+}
+
+func ExecutedOrder(o *binance.ExecutedFutureOrder) *mod.FutureOrder {
+	avgPrice, _ := strconv.ParseFloat(o.AvgPrice, 64)
+	return &mod.FutureOrder{
+		Symbol:        o.Symbol,
+		OrderID:       o.OrderID,
+		ClientOrderID: o.ClientOrderID,
+		Price:         o.Price,
+		OrigQty:       o.OrigQty,
+		AvgPrice:      avgPrice,
+		ExecutedQty:   o.ExecutedQty,
+		Status:        mod.OrderStatus(o.Status),
+		TimeInForce:   mod.TimeInForce(o.TimeInForce),
+		Type:          mod.OrderType(o.Type),
+		OrigType:      mod.OrderType(o.OrigType),
+		Side:          mod.OrderSide(o.Side),
+		PositionSide:  mod.PositionSide(o.PositionSide),
+		StopPrice:     o.StopPrice,
+		ClosePosition: o.ClosePosition,
+		ReduceOnly:    o.ReduceOnly,
+		ActivatePrice: o.ActivetePrice,
+		PriceRate:     o.PriceRate,
+		WorkingType:   o.WorkingType,
+		PriceProtect:  o.PriceProtect,
+		Time:          o.Time,
+		UpdateTime:    o.UpdateTime,
+	}
+}
+
+func ExecutedOrders(orders []*binance.ExecutedFutureOrder) []*mod.FutureOrder {
+	res := make([]*mod.FutureOrder, 0, len(orders))
+	for _, o := range orders {
+		res = append(res, ExecutedOrder(o))
+	}
+	return res
+}
+
+// 币安拒绝 GTX 订单的错误码
+const postOnlyRejectCode = -5022
+
+// 下单结果转换,GTX 订单被拒绝(或直接过期)时返回 mod.ErrPostOnlyRejected
+func NewOrderResult(o *binance.FutureProcessedOrder, err error) (*mod.FutureOrder, error) {
+	if err != nil {
+		if e, ok := err.(*binance.Error); ok && e.Code == postOnlyRejectCode {
+			return nil, mod.ErrPostOnlyRejected
+		}
+		return nil, err
+	}
+	res := ProcessedOrder(o)
+	if res.TimeInForce == mod.GTX && res.Status == mod.StatusExpired {
+		return nil, mod.ErrPostOnlyRejected
+	}
+	return res, nil
+}
+
+// 按每批最多 binance.MaxBatchOrders 个分批下单,结果和请求一一对应
+func NewOrderResults(reqs []*mod.OrderRequest, send func(binance.NewBatchFutureOrderRequest) ([]*binance.BatchFutureOrder, error)) ([]*mod.FutureOrder, []error) {
+	orders := make([]*mod.FutureOrder, len(reqs))
+	errs := make([]error, len(reqs))
+	for start := 0; start < len(reqs); start += binance.MaxBatchOrders {
+		end := start + binance.MaxBatchOrders
+		if end > len(reqs) {
+			end = len(reqs)
+		}
+		br := binance.NewBatchFutureOrderRequest{Timestamp: time.Now(), RecvWindow: 5 * time.Second}
+		for _, req := range reqs[start:end] {
+			br.Orders = append(br.Orders, OrderRequest(req))
+		}
+		items, err := send(br)
+		batchResults(items, err, orders[start:end], errs[start:end])
+	}
+	return orders, errs
+}
+
+// 按每批最多 binance.MaxBatchCancel 个分批撤单
+func CancelResults(symbol string, orderids []int64, send func(binance.CancelBatchFutureOrderRequest) ([]*binance.BatchFutureOrder, error)) ([]*mod.FutureOrder, []error) {
+	orders := make([]*mod.FutureOrder, len(orderids))
+	errs := make([]error, len(orderids))
+	for start := 0; start < len(orderids); start += binance.MaxBatchCancel {
+		end := start + binance.MaxBatchCancel
+		if end > len(orderids) {
+			end = len(orderids)
+		}
+		items, err := send(binance.CancelBatchFutureOrderRequest{
+			Symbol:     symbol,
+			OrderIDs:   orderids[start:end],
+			Timestamp:  time.Now(),
+			RecvWindow: 5 * time.Second,
+		})
+		batchResults(items, err, orders[start:end], errs[start:end])
+	}
+	return orders, errs
+}
+
+func batchResults(items []*binance.BatchFutureOrder, err error, orders []*mod.FutureOrder, errs []error) {
+	for i := range orders {
+		switch {
+		case err != nil:
+			errs[i] = err
+		case i >= len(items):
+			errs[i] = errors.New("batch response missing order")
+		default:
+			orders[i], errs[i] = NewOrderResult(items[i].Order, items[i].Err)
+		}
+	}
+}
+
+func ProcessedOrder(o *binance.FutureProcessedOrder) *mod.FutureOrder {
+	return &mod.FutureOrder{
+		Symbol:        o.Symbol,
+		OrderID:       o.OrderId,
+		ClientOrderID: o.ClientOrderId,
+		Price:         o.Price,
+		OrigQty:       o.OrigQty,
+		AvgPrice:      o.AvgPrice,
+		ExecutedQty:   o.ExecutedQty,
+		Status:        mod.OrderStatus(o.Status),
+		TimeInForce:   mod.TimeInForce(o.TimeInForce),
+		Type:          mod.OrderType(o.Type),
+		OrigType:      mod.OrderType(o.OrigType),
+		Side:          mod.OrderSide(o.Side),
+		PositionSide:  mod.PositionSide(o.PositionSide),
+		StopPrice:     o.StopPrice,
+		ClosePosition: o.ClosePosition,
+		ActivatePrice: o.ActivatePrice,
+		PriceRate:     o.PriceRate,
+		WorkingType:   o.WorkingType,
+		PriceProtect:  o.PriceProtect,
+		Time:          time.Unix(0, o.Time*int64(time.Millisecond)),
+		UpdateTime:    o.UpdateTime,
+	}
+}
+
+func CanceledOrder(o *binance.CanceledFutureOrder) *mod.FutureOrder {
+	return &mod.FutureOrder{
+		Symbol:        o.Symbol,
+		OrderID:       o.OrderID,
+		ClientOrderID: o.OrigClientOrderID,
+		Price:         o.Price,
+		OrigQty:       o.OrigQty,
+		ExecutedQty:   o.ExecutedQty,
+		Status:        mod.OrderStatus(o.Status),
+		TimeInForce:   mod.TimeInForce(o.TimeInForce),
+		Type:          mod.OrderType(o.Type),
+		OrigType:      mod.OrderType(o.OrigType),
+		Side:          mod.OrderSide(o.Side),
+		PositionSide:  mod.PositionSide(o.PositionSide),
+		StopPrice:     o.StopPrice,
+		ClosePosition: o.ClosePosition,
+		WorkingType:   o.WorkingType,
+		PriceProtect:  o.PriceProtect,
+		Time:          o.Time,
+		UpdateTime:    o.Time,
+	}
+}
+
+func Balances(bs []*binance.FutureBalanceInfo) []*mod.Balance {
+	res := make([]*mod.Balance, 0, len(bs))
+	for _, v := range bs {
+		res = append(res, &mod.Balance{
+			Asset:              v.Asset,
+			Balance:            v.Balance,
+			CrossWalletBalance: v.CrossWalletBalance,
+			CrossUnPnl:         v.CrossUnPnl,
+			AvailableBalance:   v.AvailableBalance,
+			MaxWithdrawAmount:  v.MaxWithdrawAmount,
+			MarginAvailable:    v.MarginAvailable,
+			UpdateTime:         v.UpdateTime,
+		})
+	}
+	return res
+}
+
+func Position(p *binance.FuturePositions) *mod.Position {
+	return &mod.Position{
+		Symbol:           p.Symbol,
+		PositionSide:     mod.PositionSide(p.PositionSide),
+		PositionAmt:      p.PositionAmt,
+		EntryPrice:       p.EntryPrice,
+		UnrealizedProfit: p.UnrealizedProfit,
+		Leverage:         p.Leverage,
+		Isolated:         p.Isolated,
+		UpdateTime:       p.UpdateTime,
+	}
+}
+
+func OrderBook(ob *binance.OrderBook) *mod.Depth {
+	d := &mod.Depth{
+		LastUpdateID: ob.LastUpdateID,
+		BeforeUID:    ob.BeforeUID,
+		UpdateID:     ob.UpdateID,
+		MessageTime:  ob.MessageTime,
+		Bids:         make([]*mod.Order, 0, len(ob.Bids)),
+		Asks:         make([]*mod.Order, 0, len(ob.Asks)),
+	}
+	for _, v := range ob.Bids {
+		d.Bids = append(d.Bids, &mod.Order{Price: v.Price, Quantity: v.Quantity})
+	}
+	for _, v := range ob.Asks {
+		d.Asks = append(d.Asks, &mod.Order{Price: v.Price, Quantity: v.Quantity})
+	}
+	return d
+}
+
+func Klines(ks []*binance.Kline) []*mod.Kline {
+	res := make([]*mod.Kline, 0, len(ks))
+	for _, k := range ks {
+		res = append(res, &mod.Kline{
+			StartTime:   k.OpenTime,
+			CloseTime:   k.CloseTime,
+			Volume:      k.Volume,
+			BuyVolume:   k.TakerBuyBaseAssetVolume,
+			SellVolume:  k.Volume - k.TakerBuyBaseAssetVolume,
+			Quote:       k.QuoteAssetVolume,
+			BuyQuote:    k.TakerBuyQuoteAssetVolume,
+			SellQuote:   k.QuoteAssetVolume - k.TakerBuyQuoteAssetVolume,
+			TradeNumber: k.NumberOfTrades,
+			Open:        k.Open,
+			Close:       k.Close,
+			High:        k.High,
+			Low:         k.Low,
+			Final:       true,
+		})
+	}
+	return res
+}
+
+func AccountEvent(ev *binance.FutureAccountEvent) *mod.AccountEvent {
+	res := &mod.AccountEvent{EventName: ev.EventName}
+	if ae := ev.AE; ae != nil {
+		res.Time = msTime(ae.EventTime)
+		res.Account = &mod.AccountUpdate{Reason: ae.Acc.Event}
+		for _, v := range ae.Acc.Balance {
+			res.Account.Balances = append(res.Account.Balances, &mod.BalanceUpdate{
+				Asset:              v.Symbol,
+				WalletBalance:      v.WalletBalance,
+				CrossWalletBalance: v.CurBalance,
+				BalanceChange:      v.BalanceChange,
+			})
+		}
+		for _, v := range ae.Acc.Property {
+			res.Account.Positions = append(res.Account.Positions, &mod.Position{
+				Symbol:           v.Symbol,
+				PositionSide:     mod.PositionSide(v.PS),
+				PositionAmt:      v.Pa,
+				EntryPrice:       v.EP,
+				UnrealizedProfit: v.UP,
+				Isolated:         v.MT == "isolated",
+				UpdateTime:       res.Time,
+			})
+		}
+	}
+	if oe := ev.OE; oe != nil {
+		o := oe.Order
+		res.Time = msTime(oe.EventTime)
+		res.Order = &mod.OrderUpdate{
+			FutureOrder: mod.FutureOrder{
+				Symbol:        o.Symbol,
+				OrderID:       o.ID,
+				ClientOrderID: o.ClientOrderID,
+				Price:         o.Price,
+				OrigQty:       o.OrigQty,
+				AvgPrice:      o.AvgPrice,
+				ExecutedQty:   o.ExecutedQty,
+				Status:        mod.OrderStatus(o.OrderStatus),
+				TimeInForce:   mod.TimeInForce(o.TimeInForce),
+				Type:          mod.OrderType(o.OrderType),
+				OrigType:      mod.OrderType(o.OrigType),
+				Side:          mod.OrderSide(o.Side),
+				PositionSide:  mod.PositionSide(o.PositionSide),
+				StopPrice:     o.StopPrice,
+				ClosePosition: o.IsClose,
+				ReduceOnly:    o.IsReduce,
+				ActivatePrice: o.ActivatePrice,
+				PriceRate:     o.PriceRate,
+				WorkingType:   string(o.NowType),
+				Time:          o.Time,
+				UpdateTime:    o.Time,
+			},
+			Event:           mod.EventType(o.NewEvent),
+			LastQty:         o.LastQty,
+			LastPrice:       o.LastPrice,
+			Commission:      o.RateQ,
+			CommissionAsset: o.RateAssetType,
+			IsMaker:         o.IsTaker,
+			RealizedProfit:  o.Profit,
+		}
+	}
+	return res
+}
+
+// 转发账户推送,done 关闭后停止
+func AccountWs(in chan *binance.FutureAccountEvent, done chan struct{}) chan *mod.AccountEvent {
+	out := make(chan *mod.AccountEvent)
+	go func() {
+		for {
+			select {
+			case ev := <-in:
+				out <- AccountEvent(ev)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// 订阅失败时代替连接的 done 返回,已经关闭,读取推送的循环直接退出
+func ClosedDone() chan struct{} {
+	done := make(chan struct{})
+	close(done)
+	return done
+}
+
+// 转发深度推送,done 关闭后停止,取消订阅后关闭 out
+func DepthWs(in chan *binance.DepthEvent, done chan struct{}) chan *mod.Depth {
+	out := make(chan *mod.Depth)
+	go func() {
+		for {
+			select {
+			case ev, ok := <-in:
+				if !ok {
+					close(out)
+					return
+				}
+				d := OrderBook(&ev.OrderBook)
+				d.MessageTime = ev.EventTime
+				d.Gap = ev.Gap
+				out <- d
+			case <-done:
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// 转发归集成交推送,done 关闭后停止,取消订阅后关闭 out
+func AggTradeWs(in chan *binance.AggTradeEvent, done chan struct{}) chan *mod.AggTrade {
+	out := make(chan *mod.AggTrade)
+	go func() {
+		for {
+			select {
+			case ev, ok := <-in:
+				if !ok {
+					close(out)
+					return
+				}
+				out <- &mod.AggTrade{
+					ID:           ev.ID,
+					Price:        ev.Price,
+					Quantity:     ev.Quantity,
+					FirstTradeID: ev.FirstTradeID,
+					LastTradeID:  ev.LastTradeID,
+					Time:         ev.Timestamp,
+					BuyerMaker:   ev.BuyerMaker,
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func MarkPriceWs(in chan *binance.MarkPriceEvent, done chan struct{}) chan *mod.FundingRate {
+	out := make(chan *mod.FundingRate)
+	go func() {
+		for {
+			select {
+			case ev, ok := <-in:
+				if !ok {
+					close(out)
+					return
+				}
+				out <- &mod.FundingRate{
+					Symbol:          ev.Symbol,
+					MarkPrice:       ev.MarkPrice,
+					IndexPrice:      ev.IndexPrice,
+					Rate:            ev.FundingRate,
+					NextFundingTime: ev.NextFundingTime,
+					Time:            ev.Time,
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func FundingRate(p *binance.PremiumAndFundsRateInfo) *mod.FundingRate {
+	return &mod.FundingRate{
+		Symbol:          p.Symbol,
+		MarkPrice:       p.MarkPrice,
+		IndexPrice:      p.IndexPrice,
+		Rate:            p.LastFundingRate,
+		NextFundingTime: p.NextFundingTime,
+		Time:            p.Time,
+	}
+}
+
+// 交易所返回的流水按时间升序
+func Incomes(is []*binance.IncomeInfo) []*mod.Income {
+	res := make([]*mod.Income, 0, len(is))
+	for _, v := range is {
+		res = append(res, &mod.Income{
+			Symbol: v.Symbol,
+			Type:   mod.IncomeType(v.IncomeType),
+			Amount: v.Income,
+			Asset:  v.Asset,
+			Info:   v.Info,
+			TranID: v.TranID,
+			Time:   v.Time,
+		})
+	}
+	return res
+}
+
+// 转发推送连接状态
+func StreamEvents(in chan *binance.WebsocketStateEvent) chan *mod.StreamEvent {
+	out := make(chan *mod.StreamEvent, cap(in))
+	go func() {
+		for ev := range in {
+			out <- &mod.StreamEvent{
+				Stream:    ev.Stream,
+				Connected: ev.Connected,
+				Reconnect: ev.Reconnect,
+				Err:       ev.Err,
+				Time:      ev.Time,
+			}
+		}
+		close(out)
+	}()
+	return out
+}
+
+func SymbolInfos(info *binance.ExchangeInfo) []*mod.SymbolInfo {
+	res := make([]*mod.SymbolInfo, 0, len(info.Symbols))
+	for _, v := range info.Symbols {
+		res = append(res, &mod.SymbolInfo{
+			Symbol:            v.Symbol,
+			Status:            v.Status,
+			MarginAsset:       v.MarginAsset,
+			PricePrecision:    v.PricePrecision,
+			QuantityPrecision: v.QuantityPrecision,
+			TickSize:          v.TickSize,
+			MinPrice:          v.MinPrice,
+			MaxPrice:          v.MaxPrice,
+			StepSize:          v.StepSize,
+			MinQty:            v.MinQty,
+			MaxQty:            v.MaxQty,
+			MinNotional:       v.MinNotional,
+			MaxNumOrders:      v.MaxNumOrders,
+			MaxNumAlgoOrders:  v.MaxNumAlgoOrders,
+			TriggerProtect:    v.TriggerProtect,
+			ContractSize:      v.ContractSize,
+		})
+	}
+	return res
+}
+
+func msTime(ms float64) time.Time {
+	return time.Unix(0, int64(ms)*int64(time.Millisecond))
+}
@@ -0,0 +1,153 @@
+package convert_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"tinyquant/src/mod"
+	convert "tinyquant/src/quant/binance_convert"
+	"tinyquant/src/util"
+
+	"github.com/rootpd/binance"
+)
+
+func Test_OrderRequest(t *testing.T) {
+	req := convert.OrderRequest(&mod.OrderRequest{Symbol: "ETHUSDT", Price: 1000, StopPrice: 1001})
+	if req.Type != binance.TypeSTOP || req.TimeInForce != binance.GTC {
+		t.Errorf("type = %v tif = %v, want STOP GTC", req.Type, req.TimeInForce)
+	}
+	req = convert.OrderRequest(&mod.OrderRequest{Symbol: "ETHUSDT", Price: 1000})
+	if req.Type != binance.TypeLimit {
+		t.Errorf("type = %v, want LIMIT", req.Type)
+	}
+	req = convert.OrderRequest(&mod.OrderRequest{Symbol: "ETHUSDT", Type: mod.TypeTrailingStopMarket, ActivationPrice: 1200, CallbackRate: 1,
+		WorkingType: mod.WorkingMarkPrice, ReduceOnly: true, PriceProtect: true})
+	if req.Type != binance.TypeTrailingStopMarket || req.CallbackRate != 1 || req.ActivationPrice != 1200 ||
+		req.ReduceOnly != "true" || req.PriceProtect != "TRUE" || req.WorkingType != "MARK_PRICE" {
+		t.Errorf("trailing request = %+v", req)
+	}
+	req = convert.OrderRequest(&mod.OrderRequest{Symbol: "ETHUSDT", Type: mod.TypeStopMarket, StopPrice: 900, ClosePosition: true})
+	if req.ClosePosition != "true" || req.ReduceOnly != "" {
+		t.Errorf("close position request = %+v", req)
+	}
+}
+
+func Test_NewOrderResult(t *testing.T) {
+	if _, err := convert.NewOrderResult(nil, &binance.Error{Code: -5022, Message: "post only"}); err != mod.ErrPostOnlyRejected {
+		t.Errorf("err = %v, want post only rejected", err)
+	}
+	o := &binance.FutureProcessedOrder{Symbol: "ETHUSDT", Status: "EXPIRED", TimeInForce: "GTX"}
+	if _, err := convert.NewOrderResult(o, nil); err != mod.ErrPostOnlyRejected {
+		t.Errorf("expired GTX err = %v, want post only rejected", err)
+	}
+	o.TimeInForce = "GTC"
+	if res, err := convert.NewOrderResult(o, nil); err != nil || res.Status != mod.StatusExpired {
+		t.Errorf("res = %+v err = %v", res, err)
+	}
+}
+
+func Test_NewOrderResults(t *testing.T) {
+	reqs := make([]*mod.OrderRequest, 7)
+	for i := range reqs {
+		reqs[i] = &mod.OrderRequest{Symbol: "ETHUSDT", Price: float64(1000 + i), Quantity: 1}
+	}
+	var sizes []int
+	send := func(br binance.NewBatchFutureOrderRequest) ([]*binance.BatchFutureOrder, error) {
+		sizes = append(sizes, len(br.Orders))
+		items := make([]*binance.BatchFutureOrder, 0, len(br.Orders))
+		for _, o := range br.Orders {
+			if o.Price == 1001 {
+				items = append(items, &binance.BatchFutureOrder{Err: &binance.Error{Code: -5022}})
+				continue
+			}
+			items = append(items, &binance.BatchFutureOrder{Order: &binance.FutureProcessedOrder{Symbol: o.Symbol, Price: o.Price, Status: "NEW"}})
+		}
+		return items, nil
+	}
+	orders, errs := convert.NewOrderResults(reqs, send)
+	if len(sizes) != 2 || sizes[0] != 5 || sizes[1] != 2 {
+		t.Fatalf("batch sizes = %v, want [5 2]", sizes)
+	}
+	if errs[1] != mod.ErrPostOnlyRejected || orders[1] != nil {
+		t.Errorf("order 1 = %+v err = %v, want post only rejected", orders[1], errs[1])
+	}
+	if errs[6] != nil || orders[6].Price != 1006 {
+		t.Errorf("order 6 = %+v err = %v", orders[6], errs[6])
+	}
+}
+
+func Test_AccountEvent(t *testing.T) {
+	oe := &binance.OrderEvent{EventTime: 1654041600000}
+	oe.Order.Symbol = "ETHUSDT"
+	oe.Order.ID = 7
+	oe.Order.Side = "SELL"
+	oe.Order.PositionSide = "SHORT"
+	oe.Order.NewEvent = binance.EventTrade
+	oe.Order.OrderStatus = binance.StatusFilled
+	oe.Order.Profit = 1.5
+
+	ev := convert.AccountEvent(&binance.FutureAccountEvent{EventName: "ORDER_TRADE_UPDATE", OE: oe})
+	o := ev.Order
+	if o == nil || ev.Account != nil {
+		t.Fatalf("event = %+v", ev)
+	}
+	if o.OrderID != 7 || o.Side != mod.SideSell || o.PositionSide != mod.SHORT || o.Event != mod.EventTrade ||
+		o.Status != mod.StatusFilled || o.RealizedProfit != 1.5 || o.IsOpen() != true {
+		t.Errorf("order = %+v", o)
+	}
+	if ev.Time.Unix() != 1654041600 {
+		t.Errorf("time = %v", ev.Time)
+	}
+}
+
+func Test_HTTPConfig(t *testing.T) {
+	util.HttpTimeout, util.HttpProxy = 3*time.Second, "http://127.0.0.1:7890"
+	defer func() { util.HttpTimeout, util.HttpProxy = 0, "" }()
+
+	c := convert.HTTPConfig()
+	if c.Timeout != 3*time.Second || c.DialTimeout != binance.DefaultHTTPConfig().DialTimeout || c.InsecureSkipVerify {
+		t.Errorf("config = %+v", c)
+	}
+	if _, err := binance.NewHTTPClient(c); err != nil {
+		t.Error(err)
+	}
+	c.Proxy = "127.0.0.1:7890"
+	if _, err := binance.NewHTTPClient(c); err == nil {
+		t.Error("proxy without scheme should fail")
+	}
+}
+
+func Test_StreamEvents(t *testing.T) {
+	in := make(chan *binance.WebsocketStateEvent, 2)
+	out := convert.StreamEvents(in)
+	in <- &binance.WebsocketStateEvent{Stream: "userData", Err: errors.New("eof")}
+	in <- &binance.WebsocketStateEvent{Stream: "userData", Connected: true, Reconnect: true}
+	close(in)
+
+	ev := <-out
+	if ev.Stream != mod.UserDataStream || ev.Connected || ev.Err == nil {
+		t.Errorf("disconnect event = %+v", ev)
+	}
+	ev = <-out
+	if !ev.Connected || !ev.Reconnect {
+		t.Errorf("reconnect event = %+v", ev)
+	}
+	if _, ok := <-out; ok {
+		t.Error("out should be closed after in")
+	}
+}
+
+func Test_DepthWs(t *testing.T) {
+	in := make(chan *binance.DepthEvent, 1)
+	out := convert.DepthWs(in, make(chan struct{}))
+	in <- &binance.DepthEvent{OrderBook: binance.OrderBook{UpdateID: 10, BeforeUID: 9}, Gap: true}
+	if d := <-out; d.UpdateID != 10 || !d.Gap {
+		t.Errorf("depth = %+v", d)
+	}
+	// 取消订阅后关闭
+	close(in)
+	if _, ok := <-out; ok {
+		t.Error("out not closed")
+	}
+}
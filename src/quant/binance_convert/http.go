@@ -0,0 +1,27 @@
+package convert
+
+import (
+	"tinyquant/src/util"
+
+	"github.com/rootpd/binance"
+)
+
+// 从配置读取 http 连接参数,未配置的项使用默认值
+func HTTPConfig() binance.HTTPConfig {
+	c := binance.DefaultHTTPConfig()
+	if util.HttpTimeout > 0 {
+		c.Timeout = util.HttpTimeout
+	}
+	if util.HttpDialTimeout > 0 {
+		c.DialTimeout = util.HttpDialTimeout
+	}
+	if util.HttpIdleConnTimeout > 0 {
+		c.IdleConnTimeout = util.HttpIdleConnTimeout
+	}
+	if util.HttpMaxIdleConns > 0 {
+		c.MaxIdleConns = util.HttpMaxIdleConns
+	}
+	c.Proxy = util.HttpProxy
+	c.InsecureSkipVerify = util.HttpInsecure
+	return c
+}
@@ -0,0 +1,29 @@
+package convert
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+
+	"github.com/rootpd/binance"
+)
+
+// 限频用量发布到 expvar 的 binance_ratelimit 下,用 /debug/vars 查看
+var (
+	rateLimits   = expvar.NewMap("binance_ratelimit")
+	rateLimitMu  sync.Mutex
+	rateLimitSeq = map[string]int{}
+)
+
+// 每个客户端一项,名字为 prefix.序号,同时跟多个账户时序号区分账户
+func PublishRateLimit(prefix string, b binance.Binance) string {
+	rateLimitMu.Lock()
+	name := fmt.Sprintf("%s.%d", prefix, rateLimitSeq[prefix])
+	rateLimitSeq[prefix]++
+	rateLimitMu.Unlock()
+
+	rateLimits.Set(name, expvar.Func(func() interface{} {
+		return b.RateLimit()
+	}))
+	return name
+}
@@ -3,7 +3,9 @@ package future
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
+	convert "tinyquant/src/quant/binance_convert"
 	"tinyquant/src/util"
 
 	"github.com/rootpd/binance"
@@ -11,6 +13,17 @@ import (
 
 type Binance struct {
 	binance.Binance
+
+	streamOnce sync.Once
+	stream     *binance.CombinedStream
+}
+
+// K线和深度推送共用一条组合推送连接
+func (b *Binance) combined() *binance.CombinedStream {
+	b.streamOnce.Do(func() {
+		b.stream = b.NewCoinCombinedStream()
+	})
+	return b.stream
 }
 
 func (b *Binance) InitBinance(apikey, secretkey string) {
@@ -19,13 +32,21 @@ func (b *Binance) InitBinance(apikey, secretkey string) {
 		Key: []byte(secretkey),
 	}
 	ctx := context.Background()
+	cfg := convert.HTTPConfig()
+	if err := binance.SetWebsocketConfig(cfg); err != nil {
+		panic(err)
+	}
 	// use second return value for cancelling request
-	binanceService := binance.NewAPIService(
+	binanceService, err := binance.NewAPIServiceWithConfig(
 		"https://dapi.binance.com",
 		apikey,
 		hmacSigner,
 		ctx,
+		cfg,
 	)
+	if err != nil {
+		panic(err)
+	}
 
 	b.Binance = binance.NewBinance(binanceService)
 
@@ -105,8 +126,6 @@ func (b *Binance) NewOrder() {
 
 	symbol := "FILUSDT"
 
-	precision := util.Order_Precision[symbol]
-
 	t := binance.NewOrderRequest{
 		Symbol:      symbol,
 		Quantity:    util.ToFloat64("1.12"),
@@ -115,7 +134,6 @@ func (b *Binance) NewOrder() {
 		TimeInForce: binance.GTC,
 		Type:        binance.TypeLimit,
 		Timestamp:   time.Now(),
-		Precision:   int32(precision),
 	}
 
 	err := b.NewOrderTest(t)
@@ -143,10 +161,13 @@ func (b *Binance) GetWithDrawHistory() {
 }
 
 func (b *Binance) GetExchangeInfo() {
-	str, err := b.ExchangeInfo()
+	info, err := b.CoinExchangeInfo()
 	if err != nil {
 		fmt.Println(err)
+		return
 	}
 
-	fmt.Println("exchangeinfo : ", str)
+	for _, v := range info.Symbols {
+		fmt.Printf("exchangeinfo : %+v\n", v)
+	}
 }
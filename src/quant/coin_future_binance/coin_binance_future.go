@@ -3,8 +3,10 @@ package future
 import (
 	"fmt"
 	"time"
+	. "tinyquant/src/logger"
 
 	"github.com/rootpd/binance"
+	"go.uber.org/zap"
 )
 
 func (b *Binance) GetFutureKlines(symbol string, limit int, interval binance.Interval) ([]*binance.Kline, error) {
@@ -220,19 +222,24 @@ func (b *Binance) GetUserPoundageInfo(Symbol string) (*binance.UserPoundageInfo,
 
 }
 
-func (b *Binance) NewBinanceFutureOrder(symbol string, quantity float64, price float64, side binance.OrderSide, positionSide binance.PositionSide) (*binance.FutureProcessedOrder, error) {
+func (b *Binance) NewBinanceFutureOrder(symbol string, quantity float64, price float64, stopprice float64, side binance.OrderSide, positionSide binance.PositionSide, customOrderId string) (*binance.FutureProcessedOrder, error) {
 
 	t := binance.NewFutureOrderRequest{
-		Symbol:       symbol,
-		Quantity:     quantity,
-		Price:        price,
-		Side:         side,
-		PositionSide: positionSide,
-		TimeInForce:  binance.GTC,
-		Type:         binance.TypeLimit,
-		Timestamp:    time.Now(),
-		//StopPrice:    3102.0,
-		RecvWindow: 5 * time.Second,
+		Symbol:           symbol,
+		Quantity:         quantity,
+		Price:            price,
+		Side:             side,
+		PositionSide:     positionSide,
+		TimeInForce:      binance.GTC,
+		Type:             binance.TypeLimit,
+		Timestamp:        time.Now(),
+		RecvWindow:       5 * time.Second,
+		NewClientOrderID: customOrderId,
+		StopPrice:        stopprice,
+	}
+
+	if t.StopPrice != 0 {
+		t.Type = binance.TypeSTOP
 	}
 
 	return b.CoinNewFutureOrder(t)
@@ -263,15 +270,27 @@ func (b *Binance) GetFutureBalance() ([]*binance.FutureBalanceInfo, error) {
 
 }
 
-func (b *Binance) GetFutureAccount() (*binance.CoinFutureAccountInfo, error) {
+// 获取交易对的持仓 币本位
+func (b *Binance) GetFutureAccount(symbol string) []*binance.CoinFuturePositions {
 
 	t := binance.FutureAccountRequest{
 		Timestamp:  time.Now(),
 		RecvWindow: 5 * time.Second,
 	}
 
-	return b.CoinFutureAccount(t)
+	ts, err := b.CoinFutureAccount(t)
+	if err != nil {
+		Logger.Error("GetFutureAccount failed", zap.Error(err))
+		return nil
+	}
 
+	res := make([]*binance.CoinFuturePositions, 0)
+	for _, v := range ts.Positions {
+		if v.Symbol == symbol {
+			res = append(res, v)
+		}
+	}
+	return res
 }
 
 //账户成交历史
@@ -287,3 +306,30 @@ func (b *Binance) GetUserTradesHistory(Symbol string) ([]*binance.CoinUserTrades
 	return b.CoinUserTradesHistory(t)
 
 }
+
+//最新标记价格和资金费率
+func (b *Binance) GetPremiumAndFundsRate(symbol string) (*binance.PremiumAndFundsRateInfo, error) {
+	ts, err := b.CoinPremiumAndFundsRate(binance.PremiumAndFundsRateRequest{Symbol: symbol})
+	if err != nil {
+		return nil, err
+	}
+	for _, pf := range ts {
+		if pf.Symbol == symbol {
+			return pf, nil
+		}
+	}
+	return nil, fmt.Errorf("can not get premium index of %s", symbol)
+}
+
+//收益流水
+func (b *Binance) GetIncomeHistory(symbol string, incomeType binance.IncomeType, start time.Time) ([]*binance.IncomeInfo, error) {
+	t := binance.IncomeRequest{
+		Symbol:     symbol,
+		IncomeType: incomeType,
+		StartTime:  start,
+		Limit:      1000,
+		Timestamp:  time.Now(),
+		RecvWindow: 5 * time.Second,
+	}
+	return b.CoinIncome(t)
+}
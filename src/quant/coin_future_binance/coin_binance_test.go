@@ -106,7 +106,7 @@ func Test_CoinFutureBalance(t *testing.T) {
 }
 
 func Test_CoinFutureAccount(t *testing.T) {
-	Binance.GetFutureAccount()
+	Binance.GetFutureAccount(util.COIN_ETHUSD)
 }
 
 func Test_NewFutureOrder(t *testing.T) {
@@ -130,7 +130,7 @@ func Test_NewFutureOrder(t *testing.T) {
 			//t.Error(err)
 		}
 	*/
-	order, err := Binance.NewBinanceFutureOrder(symbol, quantity, price, side, position_side)
+	order, err := Binance.NewBinanceFutureOrder(symbol, quantity, price, 0, side, position_side, "")
 	if err != nil {
 		t.Error(err)
 	}
@@ -1,74 +1,62 @@
 package future
 
 import (
-	"fmt"
-	"os"
-	"os/signal"
 	"tinyquant/src/mod"
 
 	"github.com/rootpd/binance"
 )
 
-// 获取 交易流
-func (b *Binance) GetTradeWs() {
+// 获取 归集交易
+func (b *Binance) GetFutureTradeWs(symbol string) (chan *binance.AggTradeEvent, chan struct{}, error) {
 
-	interrupt := make(chan os.Signal, 1)
-	signal.Notify(interrupt, os.Interrupt)
-
-	kech, done, err := b.CoinFutureTradeWebsocket(binance.TradeWebsocketRequest{
-		Symbol: "FILUSDT",
-	})
+	kech, err := b.combined().SubscribeAggTrade(symbol)
 	if err != nil {
-		panic(err)
+		return nil, nil, err
 	}
-	go func() {
-		for {
-			select {
-			case ke := <-kech:
-				fmt.Println("xxxx", ke)
-			case <-done:
-				break
-			}
-		}
-	}()
-	fmt.Println("canceling context")
-	<-done
-	fmt.Println("exit")
-	<-interrupt
-	fmt.Println("interrupt")
+
+	return kech, b.combined().Done(), nil
 }
 
 // 获取 深度
-func (b *Binance) GetFutureDepthWs(symbol string) (chan *binance.DepthEvent, chan struct{}) {
+func (b *Binance) GetFutureDepthWs(symbol string) (chan *binance.DepthEvent, chan struct{}, error) {
 
-	kech, done, err := b.CoinFutureDepthWebsocket(binance.DepthWebsocketRequest{
-		Symbol: symbol,
-	})
+	kech, err := b.combined().SubscribeDepth(symbol)
 	if err != nil {
-		panic(err)
+		return nil, nil, err
 	}
 
-	return kech, done
+	return kech, b.combined().Done(), nil
 }
 
-func (b *Binance) GetKlineWs(symbol string, interval binance.Interval) chan *mod.Kline {
+// 获取 标记价格和资金费率
+func (b *Binance) GetFutureMarkPriceWs(symbol string) (chan *binance.MarkPriceEvent, chan struct{}, error) {
 
-	binance_kline := make(chan *mod.Kline)
+	kech, err := b.combined().SubscribeMarkPrice(symbol)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return kech, b.combined().Done(), nil
+}
+
+// 连接失败时在后台重连,只有重复订阅或者订阅数量超过上限时返回错误
+func (b *Binance) GetKlineWs(symbol string, interval binance.Interval) (chan *mod.Kline, error) {
 
-	interrupt := make(chan os.Signal, 1)
-	signal.Notify(interrupt, os.Interrupt)
+	binance_kline := make(chan *mod.Kline)
 
-	kech, done, err := b.CoinFutureKlineWebsocket(binance.KlineWebsocketRequest{
-		Symbol:   symbol,
-		Interval: interval,
-	})
+	kech, err := b.combined().SubscribeKline(symbol, interval)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
+	done := b.combined().Done()
 	go func() {
 		for {
 			select {
-			case ke := <-kech:
+			case ke, ok := <-kech:
+				if !ok {
+					close(binance_kline)
+					return
+				}
 				t := &mod.Kline{
 					StartTime:   ke.OpenTime,
 					CloseTime:   ke.CloseTime,
@@ -89,10 +77,10 @@ func (b *Binance) GetKlineWs(symbol string, interval binance.Interval) chan *mod
 				binance_kline <- t
 
 			case <-done:
-				break
+				return
 			}
 		}
 	}()
 
-	return binance_kline
+	return binance_kline, nil
 }
@@ -0,0 +1,369 @@
+package future
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	. "tinyquant/src/logger"
+	"tinyquant/src/mod"
+	"tinyquant/src/quant"
+	convert "tinyquant/src/quant/binance_convert"
+	"tinyquant/src/util"
+
+	"github.com/rootpd/binance"
+	"go.uber.org/zap"
+)
+
+// 币本位合约实现 quant.Exchange
+// 交易所按张计算数量,对外统一换算成币的数量,和 u本位保持一致
+type Exchange struct {
+	Binance
+	symbols quant.SymbolInfoCache
+
+	streamOnce sync.Once
+	streams    chan *mod.StreamEvent
+}
+
+var _ quant.Exchange = (*Exchange)(nil)
+
+// 币的数量换算成张数,不在 util.ContractSize 中的交易对不换算
+func toContracts(symbol string, qty, price float64) float64 {
+	size, ok := util.ContractSize[symbol]
+	if !ok {
+		return qty
+	}
+	if price == 0 {
+		return 0
+	}
+	return math.Round(qty * price / size)
+}
+
+func toCoins(symbol string, contracts, price float64) float64 {
+	size, ok := util.ContractSize[symbol]
+	if !ok {
+		return contracts
+	}
+	if price == 0 {
+		return 0
+	}
+	return contracts * size / price
+}
+
+func coinOrder(o *mod.FutureOrder) {
+	price := o.Price
+	if price == 0 {
+		price = o.AvgPrice
+	}
+	avg := o.AvgPrice
+	if avg == 0 {
+		avg = price
+	}
+	o.OrigQty = toCoins(o.Symbol, o.OrigQty, price)
+	o.ExecutedQty = toCoins(o.Symbol, o.ExecutedQty, avg)
+}
+
+func coinPosition(p *mod.Position) {
+	p.PositionAmt = toCoins(p.Symbol, p.PositionAmt, p.EntryPrice)
+}
+
+func (e *Exchange) InitExchange(apikey, secretkey string) {
+	e.InitBinance(apikey, secretkey)
+	convert.PublishRateLimit("dapi", e.Binance.Binance)
+	if err := e.CoinSyncServerTime(util.TimeSyncInterval, util.MaxClockDrift, nil); err != nil {
+		Logger.Warn("sync server time failed", zap.Error(err))
+	}
+	e.symbols.Load = func() ([]*mod.SymbolInfo, error) {
+		info, err := e.CoinExchangeInfo()
+		if err != nil {
+			return nil, err
+		}
+		infos := convert.SymbolInfos(info)
+		for _, v := range infos {
+			coinSymbolInfo(v)
+		}
+		return infos, nil
+	}
+}
+
+// 交易所的数量限制按张计算,对外的数量是币,由下单时换算张数保证整数
+// 最小名义价值为一张合约的面值
+func coinSymbolInfo(s *mod.SymbolInfo) {
+	s.StepSize, s.MinQty, s.MaxQty = 0, 0, 0
+	s.MinNotional = s.ContractSize
+}
+
+func (e *Exchange) NewFutureOrder(req *mod.OrderRequest) (*mod.FutureOrder, error) {
+	r, err := e.contractRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	o, err := convert.NewOrderResult(e.CoinNewFutureOrder(convert.OrderRequest(r)))
+	if err != nil {
+		return nil, err
+	}
+	coinOrder(o)
+	return o, nil
+}
+
+// 下单数量换算成张数,全部平仓不需要数量
+func (e *Exchange) contractRequest(req *mod.OrderRequest) (*mod.OrderRequest, error) {
+	if req.ClosePosition {
+		return req, nil
+	}
+	price := req.Price
+	if price == 0 {
+		p, err := e.GetNewPrice(req.Symbol)
+		if err != nil {
+			return nil, err
+		}
+		price = p
+	}
+	r := *req
+	r.Quantity = toContracts(req.Symbol, req.Quantity, price)
+	if r.Quantity <= 0 {
+		return nil, fmt.Errorf("quantity %v is less than one contract", req.Quantity)
+	}
+	return &r, nil
+}
+
+// 换算失败的订单不发送
+func (e *Exchange) NewFutureOrders(reqs []*mod.OrderRequest) ([]*mod.FutureOrder, []error) {
+	orders := make([]*mod.FutureOrder, len(reqs))
+	errs := make([]error, len(reqs))
+	var send []*mod.OrderRequest
+	var index []int
+	for i, req := range reqs {
+		r, err := e.contractRequest(req)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		send = append(send, r)
+		index = append(index, i)
+	}
+	res, resErrs := convert.NewOrderResults(send, e.CoinNewBatchFutureOrder)
+	for j, i := range index {
+		orders[i], errs[i] = res[j], resErrs[j]
+		if orders[i] != nil {
+			coinOrder(orders[i])
+		}
+	}
+	return orders, errs
+}
+
+func (e *Exchange) CancelFutureOrder(symbol string, orderid int64) (*mod.FutureOrder, error) {
+	res, err := e.CancelBinanceFutureOrder(symbol, orderid)
+	if err != nil {
+		return nil, err
+	}
+	o := convert.CanceledOrder(res)
+	coinOrder(o)
+	return o, nil
+}
+
+func (e *Exchange) CancelFutureOrders(symbol string, orderids []int64) ([]*mod.FutureOrder, []error) {
+	orders, errs := convert.CancelResults(symbol, orderids, e.CoinCancelBatchFutureOrder)
+	for _, o := range orders {
+		if o != nil {
+			coinOrder(o)
+		}
+	}
+	return orders, errs
+}
+
+func (e *Exchange) CancelAllFutureOrders(symbol string) error {
+	return e.CoinCancelAllFutureOrder(binance.CancelAllFutureOrderRequest{Symbol: symbol, Timestamp: time.Now(), RecvWindow: 5 * time.Second})
+}
+
+// 币本位没有封装按自定义订单号查询,从当前挂单中查找
+func (e *Exchange) QueryFutureOrder(symbol string, clientOrderID string) (*mod.FutureOrder, error) {
+	orders, err := e.QueryOpenFutureOrders(symbol)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range orders {
+		if o.ClientOrderID == clientOrderID {
+			return o, nil
+		}
+	}
+	return nil, errors.New("Order does not exist.")
+}
+
+func (e *Exchange) QueryOpenFutureOrders(symbol string) ([]*mod.FutureOrder, error) {
+	res, err := e.QueryAllFutureOrder(symbol)
+	if err != nil {
+		return nil, err
+	}
+	orders := convert.ExecutedOrders(res)
+	for _, o := range orders {
+		coinOrder(o)
+	}
+	return orders, nil
+}
+
+func (e *Exchange) GetFutureBalance() ([]*mod.Balance, error) {
+	res, err := e.Binance.GetFutureBalance()
+	if err != nil {
+		return nil, err
+	}
+	return convert.Balances(res), nil
+}
+
+func (e *Exchange) GetFuturePositions(symbol string) ([]*mod.Position, error) {
+	res := make([]*mod.Position, 0, 2)
+	for _, v := range e.GetFutureAccount(symbol) {
+		p := &mod.Position{
+			Symbol:           v.Symbol,
+			PositionSide:     mod.PositionSide(v.PositionSide),
+			PositionAmt:      v.PositionAmt,
+			EntryPrice:       v.EntryPrice,
+			UnrealizedProfit: v.UnrealizedProfit,
+			Leverage:         v.Leverage,
+			Isolated:         v.Isolated,
+			UpdateTime:       v.UpdateTime,
+		}
+		coinPosition(p)
+		res = append(res, p)
+	}
+	return res, nil
+}
+
+func (e *Exchange) AdjustLeverage(symbol string, leverage int) error {
+	return e.AdjustBinanceLeverage(symbol, leverage)
+}
+
+func (e *Exchange) ChangeMarginType(symbol string, marginType mod.MarginType) error {
+	return e.ChangeBinanceMarginType(symbol, binance.PositionStatus(marginType))
+}
+
+func (e *Exchange) ChangePositionSide(dual bool) error {
+	if dual {
+		return e.ChangeBinanceUserPositionSide(binance.PosithonBothSide)
+	}
+	return e.ChangeBinanceUserPositionSide(binance.PosithonSingleSide)
+}
+
+func (e *Exchange) GetDepth(symbol string, limit int) (*mod.Depth, error) {
+	res, err := e.Binance.GetDepth(symbol, limit)
+	if err != nil {
+		return nil, err
+	}
+	return convert.OrderBook(res), nil
+}
+
+func (e *Exchange) GetNewPrice(symbol string) (float64, error) {
+	res, err := e.GetBinanceNewPrice(symbol)
+	if err != nil {
+		return 0, err
+	}
+	return res.Price, nil
+}
+
+func (e *Exchange) GetFutureKlines(symbol string, limit int, interval mod.Interval) ([]*mod.Kline, error) {
+	res, err := e.Binance.GetFutureKlines(symbol, limit, binance.Interval(interval))
+	if err != nil {
+		return nil, err
+	}
+	return convert.Klines(res), nil
+}
+
+func (e *Exchange) GetSymbolInfo(symbol string) (*mod.SymbolInfo, error) {
+	return e.symbols.Get(symbol)
+}
+
+func (e *Exchange) GetDepthWs(symbol string) (chan *mod.Depth, chan struct{}) {
+	ch, done, err := e.GetFutureDepthWs(symbol)
+	if err != nil {
+		Logger.Error("subscribe depth failed", zap.String("symbol", symbol), zap.Error(err))
+		done = convert.ClosedDone()
+	}
+	return convert.DepthWs(ch, done), done
+}
+
+func (e *Exchange) GetTradeWs(symbol string) (chan *mod.AggTrade, chan struct{}) {
+	ch, done, err := e.GetFutureTradeWs(symbol)
+	if err != nil {
+		Logger.Error("subscribe trade failed", zap.String("symbol", symbol), zap.Error(err))
+		done = convert.ClosedDone()
+	}
+	return convert.AggTradeWs(ch, done), done
+}
+
+func (e *Exchange) GetAccountWs() (chan *mod.AccountEvent, chan struct{}) {
+	ch, done := e.Binance.GetAccountWs()
+	in := convert.AccountWs(ch, done)
+	out := make(chan *mod.AccountEvent)
+	go func() {
+		for {
+			select {
+			case ev := <-in:
+				coinEvent(ev)
+				out <- ev
+			case <-done:
+				return
+			}
+		}
+	}()
+	return out, done
+}
+
+func coinEvent(ev *mod.AccountEvent) {
+	if ev.Account != nil {
+		for _, p := range ev.Account.Positions {
+			coinPosition(p)
+		}
+	}
+	if o := ev.Order; o != nil {
+		coinOrder(&o.FutureOrder)
+		o.LastQty = toCoins(o.Symbol, o.LastQty, o.LastPrice)
+	}
+}
+
+func (e *Exchange) GetKlineWs(symbol string, interval mod.Interval) chan *mod.Kline {
+	ch, err := e.Binance.GetKlineWs(symbol, binance.Interval(interval))
+	if err != nil {
+		Logger.Error("subscribe kline failed", zap.String("symbol", symbol), zap.String("interval", string(interval)), zap.Error(err))
+		ch = make(chan *mod.Kline)
+		close(ch)
+	}
+	return ch
+}
+
+func (e *Exchange) GetStreamEvents() chan *mod.StreamEvent {
+	e.streamOnce.Do(func() {
+		e.streams = convert.StreamEvents(e.WebsocketEvents())
+	})
+	return e.streams
+}
+
+// 交割合约没有资金费率,返回错误
+func (e *Exchange) GetFundingRate(symbol string) (*mod.FundingRate, error) {
+	res, err := e.GetPremiumAndFundsRate(symbol)
+	if err != nil {
+		return nil, err
+	}
+	if res.NextFundingTime.IsZero() {
+		return nil, fmt.Errorf("%s has no funding rate", symbol)
+	}
+	return convert.FundingRate(res), nil
+}
+
+func (e *Exchange) GetIncomes(symbol string, incomeType mod.IncomeType, start time.Time) ([]*mod.Income, error) {
+	res, err := e.GetIncomeHistory(symbol, binance.IncomeType(incomeType), start)
+	if err != nil {
+		return nil, err
+	}
+	return convert.Incomes(res), nil
+}
+
+func (e *Exchange) GetMarkPriceWs(symbol string) (chan *mod.FundingRate, chan struct{}) {
+	ch, done, err := e.GetFutureMarkPriceWs(symbol)
+	if err != nil {
+		Logger.Error("subscribe mark price failed", zap.String("symbol", symbol), zap.Error(err))
+		done = convert.ClosedDone()
+	}
+	return convert.MarkPriceWs(ch, done), done
+}
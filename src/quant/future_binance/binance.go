@@ -3,8 +3,10 @@ package future
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 	. "tinyquant/src/logger"
+	convert "tinyquant/src/quant/binance_convert"
 	"tinyquant/src/util"
 
 	"github.com/rootpd/binance"
@@ -12,6 +14,17 @@ import (
 
 type Binance struct {
 	binance.Binance
+
+	streamOnce sync.Once
+	stream     *binance.CombinedStream
+}
+
+// K线和深度推送共用一条组合推送连接
+func (b *Binance) combined() *binance.CombinedStream {
+	b.streamOnce.Do(func() {
+		b.stream = b.NewCombinedStream()
+	})
+	return b.stream
 }
 
 func (b *Binance) InitBinance(apikey, secretkey string) {
@@ -21,13 +34,21 @@ func (b *Binance) InitBinance(apikey, secretkey string) {
 		Key: []byte(secretkey),
 	}
 	ctx := context.Background()
+	cfg := convert.HTTPConfig()
+	if err := binance.SetWebsocketConfig(cfg); err != nil {
+		panic(err)
+	}
 	// use second return value for cancelling request
-	binanceService := binance.NewAPIService(
+	binanceService, err := binance.NewAPIServiceWithConfig(
 		"https://fapi.binance.com",
 		apikey,
 		hmacSigner,
 		ctx,
+		cfg,
 	)
+	if err != nil {
+		panic(err)
+	}
 
 	b.Binance = binance.NewBinance(binanceService)
 
@@ -108,8 +129,6 @@ func (b *Binance) NewOrder() {
 
 	symbol := "FILUSDT"
 
-	precision := util.Order_Precision[symbol]
-
 	t := binance.NewOrderRequest{
 		Symbol:      symbol,
 		Quantity:    util.ToFloat64("1.12"),
@@ -118,7 +137,6 @@ func (b *Binance) NewOrder() {
 		TimeInForce: binance.GTC,
 		Type:        binance.TypeLimit,
 		Timestamp:   time.Now(),
-		Precision:   int32(precision),
 	}
 
 	err := b.NewOrderTest(t)
@@ -146,10 +164,13 @@ func (b *Binance) GetWithDrawHistory() {
 }
 
 func (b *Binance) GetExchangeInfo() {
-	str, err := b.ExchangeInfo()
+	info, err := b.ExchangeInfo()
 	if err != nil {
 		fmt.Println(err)
+		return
 	}
 
-	fmt.Println("exchangeinfo : ", str)
+	for _, v := range info.Symbols {
+		fmt.Printf("exchangeinfo : %+v\n", v)
+	}
 }
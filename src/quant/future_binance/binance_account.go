@@ -1,8 +1,6 @@
 package future
 
 import (
-	"time"
-
 	"github.com/rootpd/binance"
 )
 
@@ -21,17 +19,5 @@ func (b *Binance) GetAccountWs() (chan *binance.FutureAccountEvent, chan struct{
 		panic(err)
 	}
 
-	go func() {
-		ticker := time.NewTicker(50 * time.Minute)
-
-		defer ticker.Stop()
-
-		for {
-			<-ticker.C
-
-			b.KeepAliveFutureUserDataStream(stream)
-		}
-	}()
-
 	return kech, done
 }
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"time"
 	. "tinyquant/src/logger"
-	"tinyquant/src/util"
 
 	"github.com/rootpd/binance"
 	"go.uber.org/zap"
@@ -274,15 +273,24 @@ func (b *Binance) GetUserTradesHistory() {
 }
 
 //最新标记价格和资金费率
-func (b *Binance) GetPremiumAndFundsRate() {
+func (b *Binance) GetPremiumAndFundsRate(symbol string) (*binance.PremiumAndFundsRateInfo, error) {
 	t := binance.PremiumAndFundsRateRequest{
-		Symbol: "ETHUSDT",
+		Symbol: symbol,
 	}
-	ts, err := b.PremiumAndFundsRate(t)
-	if err != nil {
-		fmt.Println("GetPremiumAndFundsRate: ", err)
+	return b.PremiumAndFundsRate(t)
+}
+
+//收益流水
+func (b *Binance) GetIncomeHistory(symbol string, incomeType binance.IncomeType, start time.Time) ([]*binance.IncomeInfo, error) {
+	t := binance.IncomeRequest{
+		Symbol:     symbol,
+		IncomeType: incomeType,
+		StartTime:  start,
+		Limit:      1000,
+		Timestamp:  time.Now(),
+		RecvWindow: 5 * time.Second,
 	}
-	fmt.Println("xxxx", ts)
+	return b.Income(t)
 }
 
 //24hr价格变动情况
@@ -415,7 +423,7 @@ func (b *Binance) GetFutureKlines(symbol string, limit int, interval binance.Int
 func (b *Binance) ChangeBinanceMarginType(symbol string, s binance.PositionStatus) error {
 
 	t := binance.MarginTypeRequest{
-		Symbol:     util.ETHUSDT,
+		Symbol:     symbol,
 		Timestamp:  time.Now(),
 		RecvWindow: 5 * time.Second,
 		MarginType: s,
@@ -44,7 +44,8 @@ func Test_GetWithDrawHistory(t *testing.T) {
 
 func Test_GetTradeWs(t *testing.T) {
 
-	Binance.GetTradeWs()
+	ch, _, _ := Binance.GetFutureTradeWs("FILUSDT")
+	t.Logf("%+v", <-ch)
 }
 
 func Test_GetKlineWs(t *testing.T) {
@@ -69,8 +70,15 @@ func Test_GetDepth(t *testing.T) {
 }
 
 func Test_ExchangeInfo(t *testing.T) {
-	s, _ := Binance.ExchangeInfo()
-	fmt.Println(s)
+	info, err := Binance.ExchangeInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range info.Symbols {
+		if v.Symbol == util.ETHUSDT {
+			t.Logf("%+v", v)
+		}
+	}
 }
 
 func Test_GetAllFutureOrders(t *testing.T) {
@@ -111,7 +119,11 @@ func TestUserTradesHistory(t *testing.T) {
 }
 
 func Test_GetPremiumAndFundsRate(t *testing.T) {
-	Binance.GetPremiumAndFundsRate()
+	res, err := Binance.GetPremiumAndFundsRate("ETHUSDT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("%+v", res)
 }
 
 func Test_GetPriceChangeSituation(t *testing.T) {
@@ -0,0 +1,210 @@
+package future
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	. "tinyquant/src/logger"
+	"tinyquant/src/mod"
+	"tinyquant/src/quant"
+	convert "tinyquant/src/quant/binance_convert"
+	"tinyquant/src/util"
+
+	"github.com/rootpd/binance"
+	"go.uber.org/zap"
+)
+
+// u本位合约实现 quant.Exchange
+type Exchange struct {
+	Binance
+	symbols quant.SymbolInfoCache
+
+	streamOnce sync.Once
+	streams    chan *mod.StreamEvent
+}
+
+var _ quant.Exchange = (*Exchange)(nil)
+
+func (e *Exchange) InitExchange(apikey, secretkey string) {
+	e.InitBinance(apikey, secretkey)
+	convert.PublishRateLimit("fapi", e.Binance.Binance)
+	if err := e.SyncServerTime(util.TimeSyncInterval, util.MaxClockDrift, nil); err != nil {
+		Logger.Warn("sync server time failed", zap.Error(err))
+	}
+	e.symbols.Load = func() ([]*mod.SymbolInfo, error) {
+		info, err := e.ExchangeInfo()
+		if err != nil {
+			return nil, err
+		}
+		return convert.SymbolInfos(info), nil
+	}
+}
+
+func (e *Exchange) NewFutureOrder(req *mod.OrderRequest) (*mod.FutureOrder, error) {
+	return convert.NewOrderResult(e.Binance.NewFutureOrder(convert.OrderRequest(req)))
+}
+
+func (e *Exchange) CancelFutureOrder(symbol string, orderid int64) (*mod.FutureOrder, error) {
+	res, err := e.CancelBinanceFutureOrder(symbol, orderid)
+	if err != nil {
+		return nil, err
+	}
+	return convert.CanceledOrder(res), nil
+}
+
+func (e *Exchange) NewFutureOrders(reqs []*mod.OrderRequest) ([]*mod.FutureOrder, []error) {
+	return convert.NewOrderResults(reqs, e.NewBatchFutureOrder)
+}
+
+func (e *Exchange) CancelFutureOrders(symbol string, orderids []int64) ([]*mod.FutureOrder, []error) {
+	return convert.CancelResults(symbol, orderids, e.CancelBatchFutureOrder)
+}
+
+func (e *Exchange) CancelAllFutureOrders(symbol string) error {
+	return e.CancelAllFutureOrder(binance.CancelAllFutureOrderRequest{Symbol: symbol, Timestamp: time.Now(), RecvWindow: 5 * time.Second})
+}
+
+func (e *Exchange) QueryFutureOrder(symbol string, clientOrderID string) (*mod.FutureOrder, error) {
+	res, err := e.QueryBinanceOneFutureOrder(symbol, clientOrderID)
+	if err != nil {
+		return nil, err
+	}
+	return convert.ExecutedOrder(res), nil
+}
+
+func (e *Exchange) QueryOpenFutureOrders(symbol string) ([]*mod.FutureOrder, error) {
+	res, err := e.QueryBinanceAllFutureOrder(symbol)
+	if err != nil {
+		return nil, err
+	}
+	return convert.ExecutedOrders(res), nil
+}
+
+func (e *Exchange) GetFutureBalance() ([]*mod.Balance, error) {
+	res, err := e.Binance.GetFutureBalance()
+	if err != nil {
+		return nil, err
+	}
+	return convert.Balances(res), nil
+}
+
+func (e *Exchange) GetFuturePositions(symbol string) ([]*mod.Position, error) {
+	res := make([]*mod.Position, 0, 2)
+	for _, v := range e.GetFutureAccount(symbol) {
+		res = append(res, convert.Position(v))
+	}
+	return res, nil
+}
+
+func (e *Exchange) AdjustLeverage(symbol string, leverage int) error {
+	return e.AdjustBinanceLeverage(symbol, leverage)
+}
+
+func (e *Exchange) ChangeMarginType(symbol string, marginType mod.MarginType) error {
+	return e.ChangeBinanceMarginType(symbol, binance.PositionStatus(marginType))
+}
+
+func (e *Exchange) ChangePositionSide(dual bool) error {
+	if dual {
+		return e.ChangeBinanceUserPositionSide(binance.PosithonBothSide)
+	}
+	return e.ChangeBinanceUserPositionSide(binance.PosithonSingleSide)
+}
+
+func (e *Exchange) GetDepth(symbol string, limit int) (*mod.Depth, error) {
+	res, err := e.Binance.GetDepth(symbol, limit)
+	if err != nil {
+		return nil, err
+	}
+	return convert.OrderBook(res), nil
+}
+
+func (e *Exchange) GetNewPrice(symbol string) (float64, error) {
+	res, err := e.GetBinanceNewPrice(symbol)
+	if err != nil {
+		return 0, err
+	}
+	return res.Price, nil
+}
+
+func (e *Exchange) GetFutureKlines(symbol string, limit int, interval mod.Interval) ([]*mod.Kline, error) {
+	res, err := e.Binance.GetFutureKlines(symbol, limit, binance.Interval(interval))
+	if err != nil {
+		return nil, err
+	}
+	return convert.Klines(res), nil
+}
+
+func (e *Exchange) GetSymbolInfo(symbol string) (*mod.SymbolInfo, error) {
+	return e.symbols.Get(symbol)
+}
+
+func (e *Exchange) GetDepthWs(symbol string) (chan *mod.Depth, chan struct{}) {
+	ch, done, err := e.GetFutureDepthWs(symbol)
+	if err != nil {
+		Logger.Error("subscribe depth failed", zap.String("symbol", symbol), zap.Error(err))
+		done = convert.ClosedDone()
+	}
+	return convert.DepthWs(ch, done), done
+}
+
+func (e *Exchange) GetTradeWs(symbol string) (chan *mod.AggTrade, chan struct{}) {
+	ch, done, err := e.GetFutureTradeWs(symbol)
+	if err != nil {
+		Logger.Error("subscribe trade failed", zap.String("symbol", symbol), zap.Error(err))
+		done = convert.ClosedDone()
+	}
+	return convert.AggTradeWs(ch, done), done
+}
+
+func (e *Exchange) GetAccountWs() (chan *mod.AccountEvent, chan struct{}) {
+	ch, done := e.Binance.GetAccountWs()
+	return convert.AccountWs(ch, done), done
+}
+
+func (e *Exchange) GetStreamEvents() chan *mod.StreamEvent {
+	e.streamOnce.Do(func() {
+		e.streams = convert.StreamEvents(e.WebsocketEvents())
+	})
+	return e.streams
+}
+
+func (e *Exchange) GetKlineWs(symbol string, interval mod.Interval) chan *mod.Kline {
+	ch, err := e.Binance.GetKlineWs(symbol, binance.Interval(interval))
+	if err != nil {
+		Logger.Error("subscribe kline failed", zap.String("symbol", symbol), zap.String("interval", string(interval)), zap.Error(err))
+		ch = make(chan *mod.Kline)
+		close(ch)
+	}
+	return ch
+}
+
+// 交割合约没有资金费率,返回错误
+func (e *Exchange) GetFundingRate(symbol string) (*mod.FundingRate, error) {
+	res, err := e.GetPremiumAndFundsRate(symbol)
+	if err != nil {
+		return nil, err
+	}
+	if res.NextFundingTime.IsZero() {
+		return nil, fmt.Errorf("%s has no funding rate", symbol)
+	}
+	return convert.FundingRate(res), nil
+}
+
+func (e *Exchange) GetIncomes(symbol string, incomeType mod.IncomeType, start time.Time) ([]*mod.Income, error) {
+	res, err := e.GetIncomeHistory(symbol, binance.IncomeType(incomeType), start)
+	if err != nil {
+		return nil, err
+	}
+	return convert.Incomes(res), nil
+}
+
+func (e *Exchange) GetMarkPriceWs(symbol string) (chan *mod.FundingRate, chan struct{}) {
+	ch, done, err := e.GetFutureMarkPriceWs(symbol)
+	if err != nil {
+		Logger.Error("subscribe mark price failed", zap.String("symbol", symbol), zap.Error(err))
+		done = convert.ClosedDone()
+	}
+	return convert.MarkPriceWs(ch, done), done
+}
@@ -1,178 +1,216 @@
 package huobi
 
 import (
+	"math"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+	. "tinyquant/src/logger"
 	"tinyquant/src/mod"
+	"tinyquant/src/quant"
 
-	"github.com/huobirdcenter/huobi_golang/config"
-	"github.com/huobirdcenter/huobi_golang/logging/applogger"
 	"github.com/huobirdcenter/huobi_golang/pkg/client"
-	"github.com/huobirdcenter/huobi_golang/pkg/client/marketwebsocketclient"
-	"github.com/huobirdcenter/huobi_golang/pkg/model/market"
-	"github.com/huobirdcenter/huobi_golang/pkg/model/order"
+	"github.com/huobirdcenter/huobi_golang/pkg/model/common"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
 )
 
-var acc_cl *client.AccountClient
-var order_cl *client.OrderClient
-var market_cl *client.MarketClient
+const DefaultHost = "api.huobi.pro"
 
-func init() {
-	//acc_cl = new(client.AccountClient).Init(util.HUOBI_ACCESS_KEY, util.HUOBI_SECRET_KEY, util.HUOBI_HOST)
-	//order_cl = new(client.OrderClient).Init(util.HUOBI_ACCESS_KEY, util.HUOBI_SECRET_KEY, util.HUOBI_HOST)
-	//market_cl = new(client.MarketClient).Init(util.HUOBI_HOST)
+// 火币现货实现 quant.Exchange
+// 现货没有合约仓位,基础币种的余额当作 LONG 仓位,不支持做空
+type Exchange struct {
+	sync.Mutex
+	Host      string // 为空时使用 DefaultHost
+	AccountId string // 现货账户 id,为空时初始化自动查询
+
+	accessKey string
+	secretKey string
+	acc       *client.AccountClient
+	order     *client.OrderClient
+	market    *client.MarketClient
+	symbols   quant.SymbolInfoCache
+
+	positions map[string]*mod.Position // 基础币种 -> 持仓,均价由成交推送在本地计算
 }
 
-/*
-account: {Id:12770533 Type:spot Subtype: State:working}   // 现货账户
-account: {Id:16464709 Type:otc Subtype: State:working}    // OTC账户
-account: {Id:17607343 Type:margin Subtype:xrpusdt State:working}  //逐仓杠杆账户
-*/
+var _ quant.Exchange = (*Exchange)(nil)
 
-func GetAccountInfo() {
+func (e *Exchange) InitExchange(apikey, secretkey string) {
+	if e.Host == "" {
+		e.Host = DefaultHost
+	}
+	e.accessKey, e.secretKey = apikey, secretkey
+	e.acc = new(client.AccountClient).Init(apikey, secretkey, e.Host)
+	e.order = new(client.OrderClient).Init(apikey, secretkey, e.Host)
+	e.market = new(client.MarketClient).Init(e.Host)
+	e.positions = make(map[string]*mod.Position)
+	common := new(client.CommonClient).Init(e.Host)
+	e.symbols.Load = func() ([]*mod.SymbolInfo, error) {
+		symbols, err := common.GetSymbols()
+		if err != nil {
+			return nil, err
+		}
+		res := make([]*mod.SymbolInfo, 0, len(symbols))
+		for _, v := range symbols {
+			res = append(res, symbolInfo(&v))
+		}
+		return res, nil
+	}
 
-	resp, err := acc_cl.GetAccountInfo()
+	if e.AccountId != "" {
+		return
+	}
+	accounts, err := e.acc.GetAccountInfo()
 	if err != nil {
-		applogger.Error("Get account error: %s", err)
-	} else {
-		applogger.Info("Get account, count=%d", len(resp))
-		for _, result := range resp {
-			applogger.Info("account: %+v", result)
+		Logger.Error("get huobi account failed", zap.Error(err))
+		return
+	}
+	for _, v := range accounts {
+		if v.Type == "spot" {
+			e.AccountId = strconv.FormatInt(v.Id, 10)
+			break
 		}
 	}
+	Logger.Sugar().Infof("huobi spot account : %v", e.AccountId)
+}
+
+// 火币用精度表示最小变动单位
+func symbolInfo(s *common.Symbol) *mod.SymbolInfo {
+	info := &mod.SymbolInfo{
+		Symbol:            strings.ToUpper(s.Symbol),
+		Status:            s.State,
+		MarginAsset:       strings.ToUpper(s.QuoteCurrency),
+		PricePrecision:    s.PricePrecision,
+		QuantityPrecision: s.AmountPrecision,
+		TickSize:          math.Pow10(-s.PricePrecision),
+		StepSize:          math.Pow10(-s.AmountPrecision),
+	}
+	info.MinQty, _ = s.LimitOrderMinOrderAmt.Float64()
+	info.MaxQty, _ = s.LimitOrderMaxOrderAmt.Float64()
+	info.MinNotional, _ = s.MinOrderValue.Float64()
+	return info
 }
 
-func GetAccountBalance() {
+// 火币常见的计价币种,用于从交易对中拆出基础币种
+var quoteCurrencies = []string{"usdt", "husd", "usdc", "btc", "eth", "ht", "trx"}
 
-	resp, err := acc_cl.GetAccountBalance(config.AccountId)
-	if err != nil {
-		applogger.Error("Get account balance error: %s", err)
-	} else {
-		applogger.Info("Get account balance: id=%d, type=%s, state=%s, count=%d",
-			resp.Id, resp.Type, resp.State, len(resp.List))
-		if resp.List != nil {
-			for _, result := range resp.List {
-				applogger.Info("Account balance: %+v", result)
-			}
+func baseCurrency(symbol string) string {
+	s := strings.ToLower(symbol)
+	for _, q := range quoteCurrencies {
+		if strings.HasSuffix(s, q) && len(s) > len(q) {
+			return strings.TrimSuffix(s, q)
 		}
 	}
+	return s
 }
 
-func PlaceOrder() {
-
-	request := order.PlaceOrderRequest{
-		AccountId: config.AccountId,
-		Type:      "buy-limit",
-		Source:    "spot-api",
-		Symbol:    "btcusdt",
-		Price:     "1.1",
-		Amount:    "1",
+// 取得交易对的本地持仓,不存在时创建,调用方需要持有锁
+func (e *Exchange) position(symbol string) *mod.Position {
+	base := baseCurrency(symbol)
+	p, ok := e.positions[base]
+	if !ok {
+		p = &mod.Position{Symbol: strings.ToUpper(symbol), PositionSide: mod.LONG, Leverage: 1}
+		e.positions[base] = p
 	}
-	resp, err := order_cl.PlaceOrder(&request)
-	if err != nil {
-		applogger.Error(err.Error())
-	} else {
-		switch resp.Status {
-		case "ok":
-			applogger.Info("Place order successfully, order id: %s", resp.Data)
-		case "error":
-			applogger.Error("Place order error: %s", resp.ErrorMessage)
+	return p
+}
+
+// 成交后更新本地持仓均价
+func (e *Exchange) fill(symbol string, side mod.OrderSide, qty, price float64) {
+	e.Lock()
+	defer e.Unlock()
+	p := e.position(symbol)
+	if side == mod.SideBuy {
+		if p.PositionAmt+qty > 0 {
+			p.EntryPrice = (p.EntryPrice*p.PositionAmt + price*qty) / (p.PositionAmt + qty)
 		}
+		p.PositionAmt += qty
+	} else {
+		p.PositionAmt -= qty
 	}
+	if p.PositionAmt <= 0 {
+		p.PositionAmt, p.EntryPrice = 0, 0
+	}
+	p.UpdateTime = time.Now()
 }
 
-// 获取 所有 交易品种的 ticker
-func GetAllSymbolTickers() {
-	resp, err := market_cl.GetAllSymbolsLast24hCandlesticksAskBid()
-	if err != nil {
-		applogger.Error(err.Error())
-	} else {
-		for _, tick := range resp {
-			applogger.Info("Symbol: %s, High: %v, Low: %v, Ask[%v, %v], Bid[%v, %v]",
-				tick.Symbol, tick.High, tick.Low, tick.Ask, tick.AskSize, tick.Bid, tick.BidSize)
-		}
+// 火币的订单类型形如 buy-limit sell-stop-limit buy-market
+func parseOrderType(t string) (mod.OrderSide, mod.OrderType) {
+	side := mod.SideBuy
+	if strings.HasPrefix(t, "sell") {
+		side = mod.SideSell
+	}
+	switch {
+	case strings.Contains(t, "stop"):
+		return side, mod.TypeStop
+	case strings.Contains(t, "market"):
+		return side, mod.TypeMarket
 	}
+	return side, mod.TypeLimit
 }
 
-// 获取 品种的 k线
+func orderType(side mod.OrderSide, t mod.OrderType) string {
+	if t == mod.TypeStop {
+		return strings.ToLower(string(side)) + "-stop-limit"
+	}
+	return strings.ToLower(string(side)) + "-limit"
+}
 
-func GetSymbolKline() {
-	optionalRequest := market.GetCandlestickOptionalRequest{Period: market.MIN1, Size: 10}
-	resp, err := market_cl.GetCandlestick("btcusdt", optionalRequest)
-	if err != nil {
-		applogger.Error(err.Error())
-	} else {
-		for _, kline := range resp {
-			applogger.Info("High=%v, Low=%v", kline.High, kline.Low)
-		}
+func parseStatus(state string) mod.OrderStatus {
+	switch state {
+	case "partial-filled":
+		return mod.StatusPartiallyFilled
+	case "filled":
+		return mod.StatusFilled
+	case "canceled", "partial-canceled":
+		return mod.StatusCanceled
 	}
+	return mod.StatusNew
+}
+
+// K线周期对应的火币 period 和时长
+var periods = map[mod.Interval]struct {
+	name string
+	dur  time.Duration
+}{
+	mod.Minute:         {"1min", time.Minute},
+	mod.FiveMinutes:    {"5min", 5 * time.Minute},
+	mod.FifteenMinutes: {"15min", 15 * time.Minute},
+	mod.ThirtyMinutes:  {"30min", 30 * time.Minute},
+	mod.Hour:           {"60min", time.Hour},
+	mod.FourHours:      {"4hour", 4 * time.Hour},
+	mod.Day:            {"1day", 24 * time.Hour},
+	mod.Week:           {"1week", 7 * 24 * time.Hour},
+	mod.Month:          {"1mon", 30 * 24 * time.Hour},
+}
+
+func kline(id int64, dur time.Duration, open, close, high, low, amount, vol decimal.Decimal, count int) *mod.Kline {
+	start := time.Unix(id, 0)
+	k := &mod.Kline{
+		StartTime:   start,
+		CloseTime:   start.Add(dur - time.Millisecond),
+		TradeNumber: count,
+	}
+	k.Open, _ = open.Float64()
+	k.Close, _ = close.Float64()
+	k.High, _ = high.Float64()
+	k.Low, _ = low.Float64()
+	k.Volume, _ = amount.Float64()
+	k.Quote, _ = vol.Float64()
+	return k
+}
+
+func parseFloat(s string) float64 {
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
 }
 
-func GetKlineWs() chan *mod.Kline {
-	client := new(marketwebsocketclient.CandlestickWebSocketClient).Init(config.Host)
-	huobi_kline := make(chan *mod.Kline)
-
-	client.SetHandler(
-		func() {
-			client.Request("filusdt", "1min", 1569361140, 1569366420, "2305")
-
-			client.Subscribe("filusdt", "1min", "2118")
-		},
-		func(response interface{}) {
-			resp, ok := response.(market.SubscribeCandlestickResponse)
-			if ok {
-				if &resp != nil {
-					if resp.Tick != nil {
-						t := resp.Tick
-
-						open, _ := t.Open.Float64()
-						close, _ := t.Close.Float64()
-						high, _ := t.High.Float64()
-						low, _ := t.Low.Float64()
-
-						kl := &mod.Kline{
-							StartTime: time.Now(),
-							Open:      open,
-							Close:     close,
-							High:      high,
-							Low:       low,
-						}
-
-						huobi_kline <- kl
-
-					}
-
-					if resp.Data != nil {
-						// applogger.Info("WebSocket returned data, count=%d", len(resp.Data))
-						for _, t := range resp.Data {
-
-							open, _ := t.Open.Float64()
-							close, _ := t.Close.Float64()
-							high, _ := t.High.Float64()
-							low, _ := t.Low.Float64()
-
-							kl := &mod.Kline{
-								StartTime: time.Now(),
-								Open:      open,
-								Close:     close,
-								High:      high,
-								Low:       low,
-							}
-
-							huobi_kline <- kl
-						}
-					}
-				}
-			} else {
-				applogger.Warn("Unknown response: %v", resp)
-			}
-
-		})
-
-	client.Connect(true)
-
-	return huobi_kline
-	// client.UnSubscribe("btcusdt", "1min", "2118")
-
-	//client.Close()
-	// applogger.Info("Client closed")
+func msTime(ms int64) time.Time {
+	return time.Unix(0, ms*int64(time.Millisecond))
 }
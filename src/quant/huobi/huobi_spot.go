@@ -0,0 +1,372 @@
+package huobi
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"tinyquant/src/mod"
+
+	"github.com/huobirdcenter/huobi_golang/pkg/model"
+	"github.com/huobirdcenter/huobi_golang/pkg/model/market"
+	"github.com/huobirdcenter/huobi_golang/pkg/model/order"
+)
+
+// 只支持限价单和止盈止损限价单
+func (e *Exchange) NewFutureOrder(req *mod.OrderRequest) (*mod.FutureOrder, error) {
+	if req.PositionSide == mod.SHORT {
+		return nil, errors.New("huobi spot does not support short positions")
+	}
+	t := req.Type
+	if t == "" {
+		t = mod.TypeLimit
+		if req.StopPrice != 0 {
+			t = mod.TypeStop
+		}
+	}
+	if t != mod.TypeLimit && t != mod.TypeStop {
+		return nil, fmt.Errorf("unsupported order type : %v", t)
+	}
+
+	r := &order.PlaceOrderRequest{
+		AccountId:     e.AccountId,
+		Symbol:        strings.ToLower(req.Symbol),
+		Type:          orderType(req.Side, t),
+		Amount:        formatFloat(req.Quantity),
+		Price:         formatFloat(req.Price),
+		Source:        "spot-api",
+		ClientOrderId: req.ClientOrderID,
+	}
+	if t == mod.TypeStop {
+		r.StopPrice = formatFloat(req.StopPrice)
+		r.Operator = "lte"
+		if req.Side == mod.SideBuy {
+			r.Operator = "gte"
+		}
+	}
+	resp, err := e.order.PlaceOrder(r)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Status != "ok" {
+		return nil, fmt.Errorf("%s : %s", resp.ErrorCode, resp.ErrorMessage)
+	}
+	id, err := strconv.ParseInt(resp.Data, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return &mod.FutureOrder{
+		Symbol:        strings.ToUpper(req.Symbol),
+		OrderID:       id,
+		ClientOrderID: req.ClientOrderID,
+		Price:         req.Price,
+		OrigQty:       req.Quantity,
+		Status:        mod.StatusNew,
+		TimeInForce:   mod.GTC,
+		Type:          t,
+		OrigType:      t,
+		Side:          req.Side,
+		PositionSide:  mod.LONG,
+		StopPrice:     req.StopPrice,
+		Time:          now,
+		UpdateTime:    now,
+	}, nil
+}
+
+// 火币撤单是异步的,返回时订单只是提交了撤销
+func (e *Exchange) CancelFutureOrder(symbol string, orderid int64) (*mod.FutureOrder, error) {
+	resp, err := e.order.CancelOrderById(strconv.FormatInt(orderid, 10))
+	if err != nil {
+		return nil, err
+	}
+	if resp.Status != "ok" {
+		return nil, fmt.Errorf("%s : %s", resp.ErrorCode, resp.ErrorMessage)
+	}
+	return &mod.FutureOrder{
+		Symbol:     strings.ToUpper(symbol),
+		OrderID:    orderid,
+		Status:     mod.StatusCanceled,
+		UpdateTime: time.Now(),
+	}, nil
+}
+
+// 火币批量下单不支持止盈止损单,逐个下单
+func (e *Exchange) NewFutureOrders(reqs []*mod.OrderRequest) ([]*mod.FutureOrder, []error) {
+	orders := make([]*mod.FutureOrder, len(reqs))
+	errs := make([]error, len(reqs))
+	for i, req := range reqs {
+		orders[i], errs[i] = e.NewFutureOrder(req)
+	}
+	return orders, errs
+}
+
+func (e *Exchange) CancelFutureOrders(symbol string, orderids []int64) ([]*mod.FutureOrder, []error) {
+	orders := make([]*mod.FutureOrder, len(orderids))
+	errs := make([]error, len(orderids))
+	ids := make([]string, len(orderids))
+	for i, id := range orderids {
+		ids[i] = strconv.FormatInt(id, 10)
+	}
+	resp, err := e.order.CancelOrdersByIds(&order.CancelOrdersByIdsRequest{OrderIds: ids})
+	if err == nil && resp.Status != "ok" {
+		err = fmt.Errorf("%s : %s", resp.ErrorCode, resp.ErrorMessage)
+	}
+	failed := make(map[string]error)
+	if err == nil && resp.Data != nil {
+		for _, f := range resp.Data.Failed {
+			failed[f.OrderId] = fmt.Errorf("%s : %s", f.ErrorCode, f.ErrorMessage)
+		}
+	}
+	now := time.Now()
+	for i, id := range orderids {
+		if err != nil {
+			errs[i] = err
+		} else if errs[i] = failed[ids[i]]; errs[i] == nil {
+			orders[i] = &mod.FutureOrder{Symbol: strings.ToUpper(symbol), OrderID: id, Status: mod.StatusCanceled, UpdateTime: now}
+		}
+	}
+	return orders, errs
+}
+
+func (e *Exchange) CancelAllFutureOrders(symbol string) error {
+	resp, err := e.order.CancelOrdersByCriteria(&order.CancelOrdersByCriteriaRequest{AccountId: e.AccountId, Symbol: strings.ToLower(symbol)})
+	if err != nil {
+		return err
+	}
+	if resp.Status != "ok" {
+		return fmt.Errorf("%s : %s", resp.ErrorCode, resp.ErrorMessage)
+	}
+	return nil
+}
+
+func (e *Exchange) QueryFutureOrder(symbol string, clientOrderID string) (*mod.FutureOrder, error) {
+	req := new(model.GetRequest).Init()
+	req.AddParam("clientOrderId", clientOrderID)
+	resp, err := e.order.GetOrderByCriteria(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Status != "ok" || resp.Data == nil {
+		return nil, fmt.Errorf("%s : %s", resp.ErrorCode, resp.ErrorMessage)
+	}
+	d := resp.Data
+	side, t := parseOrderType(d.Type)
+	return &mod.FutureOrder{
+		Symbol:        strings.ToUpper(d.Symbol),
+		OrderID:       d.Id,
+		ClientOrderID: d.ClientOrderId,
+		Price:         parseFloat(d.Price),
+		OrigQty:       parseFloat(d.Amount),
+		ExecutedQty:   parseFloat(d.FilledAmount),
+		Status:        parseStatus(d.State),
+		TimeInForce:   mod.GTC,
+		Type:          t,
+		OrigType:      t,
+		Side:          side,
+		PositionSide:  mod.LONG,
+		Time:          msTime(d.CreatedAt),
+		UpdateTime:    time.Now(),
+	}, nil
+}
+
+func (e *Exchange) QueryOpenFutureOrders(symbol string) ([]*mod.FutureOrder, error) {
+	req := new(model.GetRequest).Init()
+	req.AddParam("account-id", e.AccountId)
+	req.AddParam("symbol", strings.ToLower(symbol))
+	resp, err := e.order.GetOpenOrders(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Status != "ok" {
+		return nil, fmt.Errorf("%s : %s", resp.ErrorCode, resp.ErrorMessage)
+	}
+	res := make([]*mod.FutureOrder, 0, len(resp.Data))
+	for _, d := range resp.Data {
+		side, t := parseOrderType(d.Type)
+		o := &mod.FutureOrder{
+			Symbol:        strings.ToUpper(d.Symbol),
+			OrderID:       d.Id,
+			ClientOrderID: d.ClientOrderId,
+			Status:        parseStatus(d.State),
+			TimeInForce:   mod.GTC,
+			Type:          t,
+			OrigType:      t,
+			Side:          side,
+			PositionSide:  mod.LONG,
+			Time:          msTime(d.CreatedAt),
+			UpdateTime:    msTime(d.CreatedAt),
+		}
+		o.Price, _ = d.Price.Float64()
+		o.OrigQty, _ = d.Amount.Float64()
+		o.ExecutedQty, _ = d.FilledAmount.Float64()
+		o.StopPrice, _ = d.StopPrice.Float64()
+		res = append(res, o)
+	}
+	return res, nil
+}
+
+// 现货余额分为可用(trade)和冻结(frozen)两部分
+func (e *Exchange) GetFutureBalance() ([]*mod.Balance, error) {
+	resp, err := e.acc.GetAccountBalance(e.AccountId)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	balances := make(map[string]*mod.Balance)
+	res := make([]*mod.Balance, 0)
+	for _, v := range resp.List {
+		amount := parseFloat(v.Balance)
+		if amount == 0 {
+			continue
+		}
+		b, ok := balances[v.Currency]
+		if !ok {
+			b = &mod.Balance{Asset: strings.ToUpper(v.Currency), UpdateTime: now}
+			balances[v.Currency] = b
+			res = append(res, b)
+		}
+		b.Balance += amount
+		b.CrossWalletBalance += amount
+		if v.Type == "trade" {
+			b.AvailableBalance += amount
+			b.MaxWithdrawAmount += amount
+		}
+	}
+	return res, nil
+}
+
+// 基础币种的余额作为 LONG 仓位,SHORT 仓位恒为 0
+func (e *Exchange) GetFuturePositions(symbol string) ([]*mod.Position, error) {
+	resp, err := e.acc.GetAccountBalance(e.AccountId)
+	if err != nil {
+		return nil, err
+	}
+	base := baseCurrency(symbol)
+	amount := 0.0
+	for _, v := range resp.List {
+		if v.Currency == base {
+			amount += parseFloat(v.Balance)
+		}
+	}
+
+	e.Lock()
+	defer e.Unlock()
+	long := e.position(symbol)
+	if amount == 0 {
+		long.EntryPrice = 0
+	}
+	long.PositionAmt = amount
+	long.UpdateTime = time.Now()
+	l := *long
+	short := mod.Position{Symbol: long.Symbol, PositionSide: mod.SHORT, Leverage: 1, UpdateTime: long.UpdateTime}
+	return []*mod.Position{&l, &short}, nil
+}
+
+func (e *Exchange) AdjustLeverage(symbol string, leverage int) error {
+	if leverage != 1 {
+		return errors.New("huobi spot does not support leverage")
+	}
+	return nil
+}
+
+// 现货没有保证金模式,直接忽略
+func (e *Exchange) ChangeMarginType(symbol string, marginType mod.MarginType) error {
+	return nil
+}
+
+// 现货只能持有 LONG 仓位,两种持仓模式都按 LONG 处理
+func (e *Exchange) ChangePositionSide(dual bool) error {
+	return nil
+}
+
+func (e *Exchange) GetDepth(symbol string, limit int) (*mod.Depth, error) {
+	// 火币只支持 5 10 20 档,其它档位返回全部 150 档
+	size := 0
+	switch limit {
+	case market.DEPTH_SIZE_FIVE, market.DEPTH_SIZE_TEN, market.DEPTH_SIZE_TWENTY:
+		size = limit
+	}
+	d, err := e.market.GetDepth(strings.ToLower(symbol), market.STEP0, market.GetDepthOptionalRequest{Size: size})
+	if err != nil {
+		return nil, err
+	}
+	return depth(d), nil
+}
+
+func (e *Exchange) GetNewPrice(symbol string) (float64, error) {
+	t, err := e.market.GetLatestTrade(strings.ToLower(symbol))
+	if err != nil {
+		return 0, err
+	}
+	if len(t.Data) == 0 {
+		return 0, errors.New("no trade")
+	}
+	price, _ := t.Data[0].Price.Float64()
+	return price, nil
+}
+
+func (e *Exchange) GetFutureKlines(symbol string, limit int, interval mod.Interval) ([]*mod.Kline, error) {
+	p, ok := periods[interval]
+	if !ok {
+		return nil, fmt.Errorf("unsupported interval : %v", interval)
+	}
+	ks, err := e.market.GetCandlestick(strings.ToLower(symbol), market.GetCandlestickOptionalRequest{Period: p.name, Size: limit})
+	if err != nil {
+		return nil, err
+	}
+	// 火币按时间倒序返回
+	res := make([]*mod.Kline, 0, len(ks))
+	for i := len(ks) - 1; i >= 0; i-- {
+		k := ks[i]
+		kl := kline(k.Id, p.dur, k.Open, k.Close, k.High, k.Low, k.Amount, k.Vol, int(k.Count))
+		kl.Final = true
+		res = append(res, kl)
+	}
+	return res, nil
+}
+
+func (e *Exchange) GetSymbolInfo(symbol string) (*mod.SymbolInfo, error) {
+	return e.symbols.Get(strings.ToUpper(symbol))
+}
+
+// 火币的深度都是全量的,Version 递增
+func depth(d *market.Depth) *mod.Depth {
+	res := &mod.Depth{
+		LastUpdateID: int(d.Version),
+		UpdateID:     int(d.Version),
+		MessageTime:  msTime(d.Timestamp),
+		Bids:         make([]*mod.Order, 0, len(d.Bids)),
+		Asks:         make([]*mod.Order, 0, len(d.Asks)),
+	}
+	for _, v := range d.Bids {
+		if len(v) < 2 {
+			continue
+		}
+		o := &mod.Order{}
+		o.Price, _ = v[0].Float64()
+		o.Quantity, _ = v[1].Float64()
+		res.Bids = append(res.Bids, o)
+	}
+	for _, v := range d.Asks {
+		if len(v) < 2 {
+			continue
+		}
+		o := &mod.Order{}
+		o.Price, _ = v[0].Float64()
+		o.Quantity, _ = v[1].Float64()
+		res.Asks = append(res.Asks, o)
+	}
+	return res
+}
+
+// 现货没有资金费率
+func (e *Exchange) GetFundingRate(symbol string) (*mod.FundingRate, error) {
+	return nil, errors.New("huobi spot has no funding rate")
+}
+
+func (e *Exchange) GetIncomes(symbol string, incomeType mod.IncomeType, start time.Time) ([]*mod.Income, error) {
+	return nil, nil
+}
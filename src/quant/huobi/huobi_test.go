@@ -2,26 +2,67 @@ package huobi_test
 
 import (
 	"testing"
+	"time"
+	"tinyquant/src/mod"
 	"tinyquant/src/quant/huobi"
+	"tinyquant/src/util"
+
+	. "tinyquant/src/logger"
 )
 
-func Test_GetAccountInfo(t *testing.T) {
+var Huobi *huobi.Exchange
+
+func init() {
+	InitLogger()
+	Huobi = &huobi.Exchange{}
+	Huobi.InitExchange(util.HUOBI_ACCESS_KEY, util.HUOBI_SECRET_KEY)
+}
 
-	huobi.GetAccountInfo()
+func Test_GetFutureBalance(t *testing.T) {
+	res, err := Huobi.GetFutureBalance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range res {
+		t.Logf("%+v", v)
+	}
 }
 
-func Test_GetAccountBalance(t *testing.T) {
-	huobi.GetAccountBalance()
+func Test_GetFuturePositions(t *testing.T) {
+	res, err := Huobi.GetFuturePositions(util.ETHUSDT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range res {
+		t.Logf("%+v", v)
+	}
 }
 
-func Test_GetAllSymbolTickers(t *testing.T) {
-	huobi.GetAllSymbolTickers()
+func Test_GetFutureKlines(t *testing.T) {
+	res, err := Huobi.GetFutureKlines(util.ETHUSDT, 10, mod.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < len(res); i++ {
+		if !res[i].StartTime.After(res[i-1].StartTime) {
+			t.Errorf("klines not in order : %v %v", res[i-1].StartTime, res[i].StartTime)
+		}
+	}
 }
 
-func Test_GetSymbolKline(t *testing.T) {
-	huobi.GetSymbolKline()
+func Test_GetNewPrice(t *testing.T) {
+	price, err := Huobi.GetNewPrice(util.ETHUSDT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(price)
 }
 
 func Test_GetKlineWs(t *testing.T) {
-	huobi.GetKlineWs()
+	select {
+	case k := <-Huobi.GetKlineWs(util.ETHUSDT, mod.Minute):
+		t.Logf("%+v", k)
+	case <-time.After(10 * time.Second):
+		t.Fatal("no kline received")
+	}
 }
@@ -0,0 +1,234 @@
+package huobi
+
+import (
+	"strings"
+	"time"
+	. "tinyquant/src/logger"
+	"tinyquant/src/mod"
+	"tinyquant/src/util"
+
+	"github.com/huobirdcenter/huobi_golang/pkg/client/accountwebsocketclient"
+	"github.com/huobirdcenter/huobi_golang/pkg/client/marketwebsocketclient"
+	"github.com/huobirdcenter/huobi_golang/pkg/client/orderwebsocketclient"
+	"github.com/huobirdcenter/huobi_golang/pkg/model/account"
+	"github.com/huobirdcenter/huobi_golang/pkg/model/auth"
+	"github.com/huobirdcenter/huobi_golang/pkg/model/market"
+	"github.com/huobirdcenter/huobi_golang/pkg/model/order"
+)
+
+// 火币的 websocket 客户端断线后自动重连,返回的 done 不会被关闭
+func (e *Exchange) GetKlineWs(symbol string, interval mod.Interval) chan *mod.Kline {
+	res := make(chan *mod.Kline)
+	p, ok := periods[interval]
+	if !ok {
+		Logger.Sugar().Errorf("unsupported interval : %v", interval)
+		return res
+	}
+
+	var last *mod.Kline
+	cl := new(marketwebsocketclient.CandlestickWebSocketClient).Init(e.Host)
+	cl.SetHandler(
+		func() {
+			cl.Subscribe(strings.ToLower(symbol), p.name, "tinyquant")
+		},
+		func(response interface{}) {
+			resp, ok := response.(market.SubscribeCandlestickResponse)
+			if !ok || resp.Tick == nil {
+				return
+			}
+			t := resp.Tick
+			k := kline(t.Id, p.dur, t.Open, t.Close, t.High, t.Low, t.Amount, t.Vol, t.Count)
+			// 火币推送不带收盘标记,开始时间变化时补发上一根收盘的K线
+			if last != nil && !last.StartTime.Equal(k.StartTime) {
+				last.Final = true
+				res <- last
+			}
+			last = k
+			kl := *k
+			res <- &kl
+		})
+	cl.Connect(true)
+	return res
+}
+
+func (e *Exchange) GetDepthWs(symbol string) (chan *mod.Depth, chan struct{}) {
+	res := make(chan *mod.Depth)
+	done := make(chan struct{})
+	cl := new(marketwebsocketclient.DepthWebSocketClient).Init(e.Host)
+	cl.SetHandler(
+		func() {
+			cl.Subscribe(strings.ToLower(symbol), market.STEP0, "tinyquant")
+		},
+		func(response interface{}) {
+			resp, ok := response.(market.SubscribeDepthResponse)
+			if !ok || resp.Tick == nil {
+				return
+			}
+			d := depth(resp.Tick)
+			d.Snapshot = true
+			res <- d
+		})
+	cl.Connect(true)
+	return res, done
+}
+
+// 火币的成交没有归集,每笔成交单独推送
+func (e *Exchange) GetTradeWs(symbol string) (chan *mod.AggTrade, chan struct{}) {
+	res := make(chan *mod.AggTrade)
+	done := make(chan struct{})
+	cl := new(marketwebsocketclient.TradeWebSocketClient).Init(e.Host)
+	cl.SetHandler(
+		func() {
+			cl.Subscribe(strings.ToLower(symbol), "tinyquant")
+		},
+		func(response interface{}) {
+			resp, ok := response.(market.SubscribeTradeResponse)
+			if !ok || resp.Tick == nil {
+				return
+			}
+			for _, t := range resp.Tick.Data {
+				price, _ := t.Price.Float64()
+				qty, _ := t.Amount.Float64()
+				res <- &mod.AggTrade{
+					ID:           int(t.TradeId),
+					Price:        price,
+					Quantity:     qty,
+					FirstTradeID: int(t.TradeId),
+					LastTradeID:  int(t.TradeId),
+					Time:         msTime(t.Timestamp),
+					BuyerMaker:   t.Direction == "sell",
+				}
+			}
+		})
+	cl.Connect(true)
+	return res, done
+}
+
+// 现货没有标记价格,返回的通道不会有推送
+func (e *Exchange) GetMarkPriceWs(symbol string) (chan *mod.FundingRate, chan struct{}) {
+	return make(chan *mod.FundingRate), make(chan struct{})
+}
+
+// 火币 SDK 自动重连,不提供连接状态
+func (e *Exchange) GetStreamEvents() chan *mod.StreamEvent {
+	return nil
+}
+
+// 订单推送和余额推送是两条连接,合并成 mod.AccountEvent
+func (e *Exchange) GetAccountWs() (chan *mod.AccountEvent, chan struct{}) {
+	res := make(chan *mod.AccountEvent)
+	done := make(chan struct{})
+
+	ocl := new(orderwebsocketclient.SubscribeOrderWebSocketV2Client).Init(e.accessKey, e.secretKey, e.Host)
+	ocl.SetHandler(
+		func(resp *auth.WebSocketV2AuthenticationResponse) {
+			if !resp.IsSuccess() {
+				Logger.Sugar().Errorf("huobi order ws auth failed : %v", resp.Message)
+				return
+			}
+			ocl.Subscribe("*", "tinyquant")
+		},
+		func(response interface{}) {
+			resp, ok := response.(order.SubscribeOrderV2Response)
+			if !ok || resp.Data == nil {
+				return
+			}
+			if ev := e.orderEvent(&resp); ev != nil {
+				res <- ev
+			}
+		})
+	ocl.Connect(true)
+
+	acl := new(accountwebsocketclient.SubscribeAccountWebSocketV2Client).Init(e.accessKey, e.secretKey, e.Host)
+	acl.SetHandler(
+		func(resp *auth.WebSocketV2AuthenticationResponse) {
+			if !resp.IsSuccess() {
+				Logger.Sugar().Errorf("huobi account ws auth failed : %v", resp.Message)
+				return
+			}
+			acl.Subscribe("0", "tinyquant")
+		},
+		func(response interface{}) {
+			resp, ok := response.(account.SubscribeAccountV2Response)
+			if !ok || resp.Data == nil {
+				return
+			}
+			res <- e.accountEvent(&resp)
+		})
+	acl.Connect(true)
+
+	return res, done
+}
+
+func (e *Exchange) orderEvent(resp *order.SubscribeOrderV2Response) *mod.AccountEvent {
+	d := resp.Data
+	_, t := parseOrderType(d.Type)
+	o := &mod.OrderUpdate{
+		FutureOrder: mod.FutureOrder{
+			Symbol:        strings.ToUpper(d.Symbol),
+			OrderID:       d.OrderId,
+			ClientOrderID: d.ClientOrderId,
+			Price:         parseFloat(d.OrderPrice),
+			OrigQty:       parseFloat(d.OrderSize),
+			Status:        parseStatus(d.OrderStatus),
+			TimeInForce:   mod.GTC,
+			Type:          t,
+			OrigType:      t,
+			Side:          mod.OrderSide(strings.ToUpper(d.OrderSide)),
+			PositionSide:  mod.LONG,
+			Time:          msTime(d.OrderCreateTime),
+			UpdateTime:    msTime(d.LastActTime),
+		},
+	}
+	switch d.EventType {
+	case "creation":
+		o.Event = mod.EventNew
+	case "trade":
+		o.Event = mod.EventTrade
+		o.LastQty = parseFloat(d.TradeVolume)
+		o.LastPrice = parseFloat(d.TradePrice)
+		o.IsMaker = !d.Aggressor
+		o.ExecutedQty = o.OrigQty - parseFloat(d.RemainAmt)
+		o.AvgPrice = o.LastPrice
+		o.UpdateTime = msTime(d.TradeTime)
+		e.fill(o.Symbol, o.Side, o.LastQty, o.LastPrice)
+	case "cancellation":
+		o.Event = mod.EventCanceled
+	default:
+		// trigger deletion 等止盈止损单事件不影响本地状态
+		return nil
+	}
+	return &mod.AccountEvent{EventName: util.ORDER_TRADE_UPDATE, Time: o.UpdateTime, Order: o}
+}
+
+// 基础币种的余额变动同时作为 LONG 仓位的变动推送
+func (e *Exchange) accountEvent(resp *account.SubscribeAccountV2Response) *mod.AccountEvent {
+	d := resp.Data
+	balance := parseFloat(d.Balance)
+	update := &mod.AccountUpdate{
+		Reason: d.ChangeType,
+		Balances: []*mod.BalanceUpdate{{
+			Asset:              strings.ToUpper(d.Currency),
+			WalletBalance:      balance,
+			CrossWalletBalance: balance,
+		}},
+	}
+
+	e.Lock()
+	if p, ok := e.positions[d.Currency]; ok {
+		p.PositionAmt = balance
+		if balance == 0 {
+			p.EntryPrice = 0
+		}
+		p.UpdateTime = msTime(d.ChangeTime)
+		pos := *p
+		update.Positions = []*mod.Position{&pos}
+	}
+	e.Unlock()
+
+	t := msTime(d.ChangeTime)
+	if d.ChangeTime == 0 {
+		t = time.Now()
+	}
+	return &mod.AccountEvent{EventName: util.ACCOUNT_UPDATE, Time: t, Account: update}
+}
@@ -0,0 +1,361 @@
+package paper
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"tinyquant/src/mod"
+	"tinyquant/src/quant"
+	"tinyquant/src/util"
+)
+
+// 模拟盘,挂单保存在本地,按推送的K线最高价和最低价撮合
+// Market 不为空时行情数据从 Market 获取,为空时通过 Feed 回放K线
+type Exchange struct {
+	sync.Mutex
+	Market        quant.Exchange
+	MatchInterval mod.Interval // 用于撮合的K线周期
+	MakerFee      float64      // 挂单手续费率
+	TakerFee      float64      // 吃单手续费率
+
+	Balance  float64 // 钱包余额
+	Fees     float64 // 累计手续费
+	Realized float64 // 累计已实现盈亏
+	Fills    int     // 成交次数
+	Trades   int     // 平仓次数
+	Wins     int     // 盈利的平仓次数
+
+	orders    []*simOrder
+	positions map[string]map[mod.PositionSide]*mod.Position // symbol -> 持仓方向 -> 持仓
+	bars      map[string]*mod.Kline                         // 每个交易对最新的K线
+	events    []*mod.AccountEvent
+	notify    chan struct{}
+	klineWs   map[string][]chan *mod.Kline
+	nextID    int64
+	now       time.Time
+}
+
+var _ quant.Exchange = (*Exchange)(nil)
+
+type simOrder struct {
+	*mod.FutureOrder
+	taker   bool      // 下单时已经可以成交,按吃单计算
+	barTime time.Time // 下单时K线的结束时间
+	high    float64   // 下单时K线已经走过的最高价
+	low     float64   // 下单时K线已经走过的最低价
+	active  bool      // 跟踪止损已激活
+	extreme float64   // 跟踪止损激活后卖单的最高价或买单的最低价
+}
+
+func New(market quant.Exchange, balance float64) *Exchange {
+	return &Exchange{
+		Market:        market,
+		MatchInterval: mod.Minute,
+		MakerFee:      0.0002,
+		TakerFee:      0.0004,
+		Balance:       balance,
+		positions:     make(map[string]map[mod.PositionSide]*mod.Position),
+		bars:          make(map[string]*mod.Kline),
+		klineWs:       make(map[string][]chan *mod.Kline),
+	}
+}
+
+// 当前时间,回放时为最新K线的结束时间
+func (e *Exchange) Now() time.Time {
+	e.Lock()
+	defer e.Unlock()
+	return e.clock()
+}
+
+func (e *Exchange) clock() time.Time {
+	if e.Market != nil {
+		return time.Now()
+	}
+	return e.now
+}
+
+// 推送一根K线: 先撮合挂单,再转发给 GetKlineWs 的订阅者
+func (e *Exchange) Feed(symbol string, k *mod.Kline) {
+	e.Match(symbol, k)
+	e.Lock()
+	subs := e.klineWs[symbol]
+	e.Unlock()
+	for _, ch := range subs {
+		ch <- k
+	}
+}
+
+// 按固定间隔回放历史K线
+func (e *Exchange) Replay(symbol string, klines []*mod.Kline, interval time.Duration) {
+	for _, k := range klines {
+		e.Feed(symbol, k)
+		time.Sleep(interval)
+	}
+}
+
+// 用K线撮合该交易对的挂单,成交顺序按下单顺序
+func (e *Exchange) Match(symbol string, bar *mod.Kline) {
+	e.Lock()
+	defer e.Unlock()
+	e.bars[symbol] = bar
+	if bar.CloseTime.After(e.now) {
+		e.now = bar.CloseTime
+	}
+
+	open := e.orders[:0]
+	for _, o := range e.orders {
+		if o.Symbol != symbol {
+			open = append(open, o)
+			continue
+		}
+		price, taker, ok := matchPrice(o, bar)
+		if !ok {
+			open = append(open, o)
+			continue
+		}
+		e.fill(o, price, taker)
+	}
+	e.orders = open
+
+	for _, pos := range e.positions[symbol] {
+		pos.UnrealizedProfit = (bar.Close - pos.EntryPrice) * pos.PositionAmt
+	}
+}
+
+// 判断挂单在这根K线内能否成交,返回成交价格
+// 下单所在的K线只用下单之后新走出的最高价和最低价撮合
+func matchPrice(o *simOrder, bar *mod.Kline) (float64, bool, bool) {
+	high, low, open := bar.High, bar.Low, bar.Open
+	if bar.CloseTime.Equal(o.barTime) {
+		high, low, open = bar.Close, bar.Close, bar.Close
+		if bar.High > o.high {
+			high = bar.High
+		}
+		if bar.Low < o.low {
+			low = bar.Low
+		}
+	}
+
+	buy := o.Side == mod.SideBuy
+	switch o.Type {
+	case mod.TypeStop:
+		if buy && high >= o.StopPrice {
+			return math.Min(math.Max(o.StopPrice, open), o.Price), true, true
+		}
+		if !buy && low <= o.StopPrice {
+			return math.Max(math.Min(o.StopPrice, open), o.Price), true, true
+		}
+		return 0, false, false
+	case mod.TypeStopMarket:
+		if buy && high >= o.StopPrice {
+			return math.Max(o.StopPrice, open), true, true
+		}
+		if !buy && low <= o.StopPrice {
+			return math.Min(o.StopPrice, open), true, true
+		}
+		return 0, false, false
+	case mod.TypeTakeProfit, mod.TypeTakeProfitMarket:
+		// 止盈单买入在价格跌到触发价时触发,卖出在涨到触发价时触发
+		var price float64
+		if buy && low <= o.StopPrice {
+			price = math.Min(o.StopPrice, open)
+		} else if !buy && high >= o.StopPrice {
+			price = math.Max(o.StopPrice, open)
+		} else {
+			return 0, false, false
+		}
+		if o.Type == mod.TypeTakeProfitMarket {
+			return price, true, true
+		}
+		if buy {
+			return math.Min(price, o.Price), true, low <= o.Price
+		}
+		return math.Max(price, o.Price), true, high >= o.Price
+	case mod.TypeTrailingStopMarket:
+		return trailingPrice(o, high, low, open)
+	}
+	if o.taker {
+		// 下单时价格已穿过挂单价,按开盘价吃单
+		if buy {
+			return math.Min(o.Price, open), true, true
+		}
+		return math.Max(o.Price, open), true, true
+	}
+	if buy && low <= o.Price {
+		return o.Price, false, true
+	}
+	if !buy && high >= o.Price {
+		return o.Price, false, true
+	}
+	return 0, false, false
+}
+
+// 跟踪止损: 先用之前的极值判断这根K线是否回调触发,再用这根K线更新极值
+// K线内最高价和最低价的先后顺序未知,这样不会用同一根K线的高点和低点触发
+func trailingPrice(o *simOrder, high, low, open float64) (float64, bool, bool) {
+	buy := o.Side == mod.SideBuy
+	rate := o.PriceRate / 100
+	if o.active {
+		if buy {
+			if stop := o.extreme * (1 + rate); high >= stop {
+				return math.Max(stop, open), true, true
+			}
+		} else if stop := o.extreme * (1 - rate); low <= stop {
+			return math.Min(stop, open), true, true
+		}
+	} else if o.ActivatePrice == 0 {
+		o.active, o.extreme = true, open
+	} else if (buy && low <= o.ActivatePrice) || (!buy && high >= o.ActivatePrice) {
+		o.active, o.extreme = true, o.ActivatePrice
+	}
+	if o.active {
+		if buy {
+			o.extreme = math.Min(o.extreme, low)
+		} else {
+			o.extreme = math.Max(o.extreme, high)
+		}
+	}
+	return 0, false, false
+}
+
+func (e *Exchange) position(symbol string, positionSide mod.PositionSide) *mod.Position {
+	ps, ok := e.positions[symbol]
+	if !ok {
+		ps = map[mod.PositionSide]*mod.Position{
+			mod.LONG:  {Symbol: symbol, PositionSide: mod.LONG, Leverage: float64(util.BinanceLeverage)},
+			mod.SHORT: {Symbol: symbol, PositionSide: mod.SHORT, Leverage: float64(util.BinanceLeverage)},
+		}
+		e.positions[symbol] = ps
+	}
+	return ps[positionSide]
+}
+
+func (e *Exchange) fill(o *simOrder, price float64, taker bool) {
+	pos := e.position(o.Symbol, o.PositionSide)
+	amt := math.Abs(pos.PositionAmt)
+	qty := o.OrigQty
+	if o.ClosePosition {
+		qty = amt
+	}
+	profit := 0.0
+
+	if o.IsOpen() {
+		pos.EntryPrice = (pos.EntryPrice*amt + price*qty) / (amt + qty)
+		amt += qty
+	} else {
+		if amt == 0 {
+			// 没有仓位的平仓单直接过期
+			o.Status = mod.StatusExpired
+			e.pushOrderEvent(o, mod.EventExpired, 0, 0)
+			return
+		}
+		qty = math.Min(qty, amt)
+		if o.PositionSide == mod.LONG {
+			profit = (price - pos.EntryPrice) * qty
+		} else {
+			profit = (pos.EntryPrice - price) * qty
+		}
+		amt -= qty
+		if amt < 1e-9 {
+			amt = 0
+			pos.EntryPrice = 0
+		}
+		e.Realized += profit
+		e.Trades++
+		if profit > 0 {
+			e.Wins++
+		}
+	}
+	if o.PositionSide == mod.SHORT {
+		pos.PositionAmt = -amt
+	} else {
+		pos.PositionAmt = amt
+	}
+	pos.UpdateTime = e.clock()
+
+	rate := e.MakerFee
+	if taker {
+		rate = e.TakerFee
+	}
+	fee := price * qty * rate
+	e.Fees += fee
+	e.Balance += profit - fee
+	e.Fills++
+
+	o.ExecutedQty = qty
+	o.AvgPrice = price
+	o.Status = mod.StatusFilled
+	o.UpdateTime = e.clock()
+
+	e.pushAccountEvent(pos)
+	e.pushOrderEvent(o, mod.EventTrade, price, profit)
+}
+
+// 钱包余额加未实现盈亏
+func (e *Exchange) Equity() float64 {
+	e.Lock()
+	defer e.Unlock()
+	return e.Balance + e.unrealized()
+}
+
+func (e *Exchange) unrealized() float64 {
+	unPnl := 0.0
+	for _, ps := range e.positions {
+		for _, pos := range ps {
+			unPnl += pos.UnrealizedProfit
+		}
+	}
+	return unPnl
+}
+
+// 取出还没推送的账户事件,不订阅 GetAccountWs 时由调用方同步处理
+func (e *Exchange) PopEvents() []*mod.AccountEvent {
+	e.Lock()
+	defer e.Unlock()
+	events := e.events
+	e.events = nil
+	return events
+}
+
+func (e *Exchange) pushEvent(ev *mod.AccountEvent) {
+	e.events = append(e.events, ev)
+	if e.notify != nil {
+		select {
+		case e.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (e *Exchange) pushOrderEvent(o *simOrder, event mod.EventType, lastPrice, profit float64) {
+	ou := &mod.OrderUpdate{
+		FutureOrder:    *o.FutureOrder,
+		Event:          event,
+		LastQty:        o.ExecutedQty,
+		LastPrice:      lastPrice,
+		RealizedProfit: profit,
+	}
+	e.pushEvent(&mod.AccountEvent{EventName: util.ORDER_TRADE_UPDATE, Time: e.clock(), Order: ou})
+}
+
+func (e *Exchange) pushAccountEvent(pos *mod.Position) {
+	p := *pos
+	au := &mod.AccountUpdate{
+		Reason: "ORDER",
+		Balances: []*mod.BalanceUpdate{{
+			Asset:              asset(pos.Symbol),
+			WalletBalance:      e.Balance,
+			CrossWalletBalance: e.Balance,
+		}},
+		Positions: []*mod.Position{&p},
+	}
+	e.pushEvent(&mod.AccountEvent{EventName: util.ACCOUNT_UPDATE, Time: e.clock(), Account: au})
+}
+
+func asset(symbol string) string {
+	if a, ok := util.ACCOUNTASSET[symbol]; ok {
+		return a
+	}
+	return "USDT"
+}
@@ -0,0 +1,276 @@
+package paper
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"tinyquant/src/mod"
+)
+
+func (e *Exchange) InitExchange(apikey, secretkey string) {}
+
+func (e *Exchange) NewFutureOrder(req *mod.OrderRequest) (*mod.FutureOrder, error) {
+	e.Lock()
+	defer e.Unlock()
+	t := req.Type
+	if t == "" {
+		t = mod.TypeLimit
+		if req.StopPrice != 0 {
+			t = mod.TypeStop
+		}
+	}
+	if (req.Quantity <= 0 && !req.ClosePosition) || (t.HasPrice() && req.Price <= 0) {
+		return nil, fmt.Errorf("invalid order quantity : %v price : %v", req.Quantity, req.Price)
+	}
+	if t == mod.TypeTrailingStopMarket && req.CallbackRate <= 0 {
+		return nil, fmt.Errorf("invalid callback rate : %v", req.CallbackRate)
+	}
+	tif := req.TimeInForce
+	if tif == "" {
+		tif = mod.GTC
+	}
+	bar, hasBar := e.bars[req.Symbol]
+	if t == mod.TypeMarket && !hasBar {
+		return nil, errors.New("no kline for market order")
+	}
+	if t == mod.TypeLimit && tif == mod.GTX && hasBar &&
+		((req.Side == mod.SideBuy && req.Price >= bar.Close) || (req.Side == mod.SideSell && req.Price <= bar.Close)) {
+		return nil, mod.ErrPostOnlyRejected
+	}
+	e.nextID++
+	o := &simOrder{FutureOrder: &mod.FutureOrder{
+		Symbol:        req.Symbol,
+		OrderID:       e.nextID,
+		ClientOrderID: req.ClientOrderID,
+		Price:         req.Price,
+		OrigQty:       req.Quantity,
+		Status:        mod.StatusNew,
+		TimeInForce:   tif,
+		Type:          t,
+		OrigType:      t,
+		Side:          req.Side,
+		StopPrice:     req.StopPrice,
+		PositionSide:  req.PositionSide,
+		ClosePosition: req.ClosePosition,
+		ReduceOnly:    req.ReduceOnly,
+		ActivatePrice: req.ActivationPrice,
+		PriceRate:     req.CallbackRate,
+		WorkingType:   req.WorkingType,
+		PriceProtect:  req.PriceProtect,
+		Time:          e.clock(),
+		UpdateTime:    e.clock(),
+	}}
+	if hasBar {
+		o.barTime, o.high, o.low = bar.CloseTime, bar.High, bar.Low
+		if o.Type == mod.TypeLimit {
+			o.taker = (o.Side == mod.SideBuy && o.Price >= bar.Close) || (o.Side == mod.SideSell && o.Price <= bar.Close)
+		}
+		if o.Type == mod.TypeTrailingStopMarket && o.ActivatePrice == 0 {
+			// 没有激活价格时按下单时的价格激活
+			o.active, o.extreme = true, bar.Close
+		}
+	}
+	e.pushOrderEvent(o, mod.EventNew, 0, 0)
+	switch {
+	case t == mod.TypeMarket:
+		e.fill(o, bar.Close, true)
+	case t == mod.TypeLimit && (tif == mod.IOC || tif == mod.FOK):
+		// K线撮合没有部分成交,IOC 和 FOK 一样要么立即全部成交要么过期
+		if o.taker {
+			price := math.Min(o.Price, bar.Close)
+			if o.Side == mod.SideSell {
+				price = math.Max(o.Price, bar.Close)
+			}
+			e.fill(o, price, true)
+		} else {
+			o.Status = mod.StatusExpired
+			e.pushOrderEvent(o, mod.EventExpired, 0, 0)
+		}
+	default:
+		e.orders = append(e.orders, o)
+	}
+
+	res := *o.FutureOrder
+	return &res, nil
+}
+
+func (e *Exchange) CancelFutureOrder(symbol string, orderid int64) (*mod.FutureOrder, error) {
+	e.Lock()
+	defer e.Unlock()
+	for i, o := range e.orders {
+		if o.OrderID != orderid || o.Symbol != symbol {
+			continue
+		}
+		e.orders = append(e.orders[:i], e.orders[i+1:]...)
+		o.Status = mod.StatusCanceled
+		o.UpdateTime = e.clock()
+		e.pushOrderEvent(o, mod.EventCanceled, 0, 0)
+		res := *o.FutureOrder
+		return &res, nil
+	}
+	return nil, errors.New("Unknown order sent.")
+}
+
+// 模拟盘没有请求开销,批量接口逐个处理
+func (e *Exchange) NewFutureOrders(reqs []*mod.OrderRequest) ([]*mod.FutureOrder, []error) {
+	orders := make([]*mod.FutureOrder, len(reqs))
+	errs := make([]error, len(reqs))
+	for i, req := range reqs {
+		orders[i], errs[i] = e.NewFutureOrder(req)
+	}
+	return orders, errs
+}
+
+func (e *Exchange) CancelFutureOrders(symbol string, orderids []int64) ([]*mod.FutureOrder, []error) {
+	orders := make([]*mod.FutureOrder, len(orderids))
+	errs := make([]error, len(orderids))
+	for i, id := range orderids {
+		orders[i], errs[i] = e.CancelFutureOrder(symbol, id)
+	}
+	return orders, errs
+}
+
+func (e *Exchange) CancelAllFutureOrders(symbol string) error {
+	e.Lock()
+	defer e.Unlock()
+	open := e.orders[:0]
+	for _, o := range e.orders {
+		if o.Symbol != symbol {
+			open = append(open, o)
+			continue
+		}
+		o.Status = mod.StatusCanceled
+		o.UpdateTime = e.clock()
+		e.pushOrderEvent(o, mod.EventCanceled, 0, 0)
+	}
+	e.orders = open
+	return nil
+}
+
+func (e *Exchange) QueryFutureOrder(symbol string, clientOrderID string) (*mod.FutureOrder, error) {
+	e.Lock()
+	defer e.Unlock()
+	for _, o := range e.orders {
+		if o.Symbol == symbol && o.ClientOrderID == clientOrderID {
+			order := *o.FutureOrder
+			return &order, nil
+		}
+	}
+	return nil, errors.New("Order does not exist.")
+}
+
+func (e *Exchange) QueryOpenFutureOrders(symbol string) ([]*mod.FutureOrder, error) {
+	e.Lock()
+	defer e.Unlock()
+	res := make([]*mod.FutureOrder, 0, len(e.orders))
+	for _, o := range e.orders {
+		if o.Symbol != symbol {
+			continue
+		}
+		order := *o.FutureOrder
+		res = append(res, &order)
+	}
+	return res, nil
+}
+
+func (e *Exchange) GetFutureBalance() ([]*mod.Balance, error) {
+	e.Lock()
+	defer e.Unlock()
+	unPnl := e.unrealized()
+	info := &mod.Balance{
+		Balance:            e.Balance,
+		CrossWalletBalance: e.Balance,
+		CrossUnPnl:         unPnl,
+		AvailableBalance:   e.Balance + unPnl,
+		MaxWithdrawAmount:  e.Balance,
+		UpdateTime:         e.clock(),
+	}
+	// 模拟盘只有一份保证金,每种保证金资产都返回同样的余额
+	res := make([]*mod.Balance, 0, 2)
+	for _, a := range []string{"USDT", "BUSD"} {
+		b := *info
+		b.Asset = a
+		res = append(res, &b)
+	}
+	return res, nil
+}
+
+func (e *Exchange) GetFuturePositions(symbol string) ([]*mod.Position, error) {
+	e.Lock()
+	defer e.Unlock()
+	long := *e.position(symbol, mod.LONG)
+	short := *e.position(symbol, mod.SHORT)
+	return []*mod.Position{&long, &short}, nil
+}
+
+func (e *Exchange) GetDepth(symbol string, limit int) (*mod.Depth, error) {
+	if e.Market == nil {
+		return &mod.Depth{}, nil
+	}
+	return e.Market.GetDepth(symbol, limit)
+}
+
+func (e *Exchange) AdjustLeverage(symbol string, leverage int) error {
+	e.Lock()
+	defer e.Unlock()
+	e.position(symbol, mod.LONG).Leverage = float64(leverage)
+	e.position(symbol, mod.SHORT).Leverage = float64(leverage)
+	return nil
+}
+
+func (e *Exchange) GetNewPrice(symbol string) (float64, error) {
+	e.Lock()
+	bar, ok := e.bars[symbol]
+	e.Unlock()
+	if ok {
+		return bar.Close, nil
+	}
+	if e.Market == nil {
+		return 0, errors.New("no kline")
+	}
+	return e.Market.GetNewPrice(symbol)
+}
+
+func (e *Exchange) ChangeMarginType(symbol string, marginType mod.MarginType) error {
+	e.Lock()
+	defer e.Unlock()
+	e.position(symbol, mod.LONG).Isolated = marginType == mod.MarginIsolated
+	e.position(symbol, mod.SHORT).Isolated = marginType == mod.MarginIsolated
+	return nil
+}
+
+func (e *Exchange) ChangePositionSide(dual bool) error {
+	if !dual {
+		return errors.New("paper trading only supports hedge mode")
+	}
+	return nil
+}
+
+func (e *Exchange) GetFutureKlines(symbol string, limit int, interval mod.Interval) ([]*mod.Kline, error) {
+	if e.Market == nil {
+		return nil, errors.New("no market for paper trading")
+	}
+	return e.Market.GetFutureKlines(symbol, limit, interval)
+}
+
+// 回放时没有交易所的规则,价格按 0.01 数量按 0.001 取整
+func (e *Exchange) GetSymbolInfo(symbol string) (*mod.SymbolInfo, error) {
+	if e.Market == nil {
+		return &mod.SymbolInfo{Symbol: symbol, TickSize: 0.01, StepSize: 0.001}, nil
+	}
+	return e.Market.GetSymbolInfo(symbol)
+}
+
+func (e *Exchange) GetFundingRate(symbol string) (*mod.FundingRate, error) {
+	if e.Market == nil {
+		return nil, errors.New("no market for paper trading")
+	}
+	return e.Market.GetFundingRate(symbol)
+}
+
+// 模拟盘不结算资金费用,没有收益流水
+func (e *Exchange) GetIncomes(symbol string, incomeType mod.IncomeType, start time.Time) ([]*mod.Income, error) {
+	return nil, nil
+}
@@ -0,0 +1,214 @@
+package paper_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"tinyquant/src/mod"
+	"tinyquant/src/quant/paper"
+	"tinyquant/src/util"
+)
+
+var start = time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func bar(i int, open, high, low, close float64) *mod.Kline {
+	t := start.Add(time.Duration(i) * time.Minute)
+	return &mod.Kline{StartTime: t, CloseTime: t.Add(time.Minute - time.Millisecond), Open: open, High: high, Low: low, Close: close}
+}
+
+func position(t *testing.T, p *paper.Exchange, side mod.PositionSide) *mod.Position {
+	ps, _ := p.GetFuturePositions(util.ETHUSDT)
+	for _, v := range ps {
+		if v.PositionSide == side {
+			return v
+		}
+	}
+	t.Fatalf("no %s position", side)
+	return nil
+}
+
+func order(p *paper.Exchange, quantity, price, stopPrice float64, side mod.OrderSide, positionSide mod.PositionSide, id string) (*mod.FutureOrder, error) {
+	return p.NewFutureOrder(&mod.OrderRequest{
+		Symbol:        util.ETHUSDT,
+		Side:          side,
+		PositionSide:  positionSide,
+		Quantity:      quantity,
+		Price:         price,
+		StopPrice:     stopPrice,
+		ClientOrderID: id,
+	})
+}
+
+// 下单所在的K线之前走过的最高价不能成交挂单
+func Test_SameBarMatch(t *testing.T) {
+	p := paper.New(nil, 1000)
+	p.Match(util.ETHUSDT, bar(0, 100, 102, 99, 100))
+	if _, err := order(p, 1, 101, 0, mod.SideSell, mod.SHORT, "1"); err != nil {
+		t.Fatal(err)
+	}
+	p.Match(util.ETHUSDT, bar(0, 100, 102, 99, 100.5))
+	if p.Fills != 0 {
+		t.Fatal("order filled by high before it was placed")
+	}
+	p.Match(util.ETHUSDT, bar(0, 100, 102.5, 99, 101.2))
+	if p.Fills != 1 {
+		t.Fatal("order not filled by new high")
+	}
+	pos := position(t, p, mod.SHORT)
+	if pos.PositionAmt != -1 || pos.EntryPrice != 101 {
+		t.Errorf("short position = %v @ %v, want -1 @ 101", pos.PositionAmt, pos.EntryPrice)
+	}
+}
+
+func Test_StopOrder(t *testing.T) {
+	p := paper.New(nil, 1000)
+	p.Match(util.ETHUSDT, bar(0, 100, 100, 100, 100))
+	order(p, 2, 99.8, 0, mod.SideBuy, mod.LONG, "open")
+	p.Match(util.ETHUSDT, bar(1, 100, 100.5, 99.5, 100))
+	if position(t, p, mod.LONG).PositionAmt != 2 {
+		t.Fatal("long position not opened")
+	}
+
+	order(p, 2, 94, 95, mod.SideSell, mod.LONG, "stop")
+	p.Match(util.ETHUSDT, bar(2, 100, 101, 96, 97))
+	if p.Trades != 0 {
+		t.Fatal("stop triggered above stop price")
+	}
+	// 跳空低开,按开盘价成交,不低于限价
+	p.Match(util.ETHUSDT, bar(3, 94.5, 95, 90, 91))
+	if p.Trades != 1 || p.Wins != 0 {
+		t.Fatalf("trades = %d wins = %d", p.Trades, p.Wins)
+	}
+	if want := (94.5 - 99.8) * 2; math.Abs(p.Realized-want) > 1e-9 {
+		t.Errorf("realized = %v, want %v", p.Realized, want)
+	}
+	if want := 99.8*2*p.MakerFee + 94.5*2*p.TakerFee; math.Abs(p.Fees-want) > 1e-9 {
+		t.Errorf("fees = %v, want %v", p.Fees, want)
+	}
+	if pos := position(t, p, mod.LONG); pos.PositionAmt != 0 || pos.EntryPrice != 0 {
+		t.Errorf("long position = %v @ %v, want flat", pos.PositionAmt, pos.EntryPrice)
+	}
+}
+
+// 跟踪止损从激活后的最高价回调 1% 触发,止盈市价单全部平仓
+func Test_TrailingStop(t *testing.T) {
+	p := paper.New(nil, 1000)
+	p.Match(util.ETHUSDT, bar(0, 100, 100, 100, 100))
+	order(p, 2, 99.8, 0, mod.SideBuy, mod.LONG, "open")
+	p.Match(util.ETHUSDT, bar(1, 100, 100.5, 99.5, 100))
+
+	_, err := p.NewFutureOrder(&mod.OrderRequest{
+		Symbol:       util.ETHUSDT,
+		Side:         mod.SideSell,
+		PositionSide: mod.LONG,
+		Type:         mod.TypeTrailingStopMarket,
+		Quantity:     1,
+		CallbackRate: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 同一根K线先涨到105再跌到101,不按105回调触发
+	p.Match(util.ETHUSDT, bar(2, 100, 105, 101, 104))
+	if p.Trades != 0 {
+		t.Fatal("trailing stop triggered inside the bar that set the high")
+	}
+	p.Match(util.ETHUSDT, bar(3, 104, 104.5, 103.5, 103.8))
+	if p.Trades != 1 {
+		t.Fatalf("trades = %d, want 1", p.Trades)
+	}
+	if want := (105*0.99 - 99.8) * 1; math.Abs(p.Realized-want) > 1e-9 {
+		t.Errorf("realized = %v, want %v", p.Realized, want)
+	}
+
+	_, err = p.NewFutureOrder(&mod.OrderRequest{
+		Symbol:        util.ETHUSDT,
+		Side:          mod.SideSell,
+		PositionSide:  mod.LONG,
+		Type:          mod.TypeTakeProfitMarket,
+		StopPrice:     110,
+		ClosePosition: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Match(util.ETHUSDT, bar(4, 104, 111, 104, 110))
+	if pos := position(t, p, mod.LONG); p.Trades != 2 || pos.PositionAmt != 0 {
+		t.Errorf("trades = %d long position = %v, want closed", p.Trades, pos.PositionAmt)
+	}
+}
+
+func Test_TimeInForce(t *testing.T) {
+	p := paper.New(nil, 1000)
+	p.Match(util.ETHUSDT, bar(0, 100, 101, 99, 100))
+	req := &mod.OrderRequest{Symbol: util.ETHUSDT, Side: mod.SideBuy, PositionSide: mod.LONG, Quantity: 1, Price: 100.5, TimeInForce: mod.GTX}
+	if _, err := p.NewFutureOrder(req); err != mod.ErrPostOnlyRejected {
+		t.Fatalf("crossing GTX order err = %v, want post only rejected", err)
+	}
+
+	req.TimeInForce = mod.IOC
+	o, err := p.NewFutureOrder(req)
+	if err != nil || o.Status != mod.StatusFilled || o.AvgPrice != 100 {
+		t.Fatalf("IOC order = %+v err = %v, want filled at 100", o, err)
+	}
+	req.Price, req.TimeInForce = 99.5, mod.FOK
+	if o, _ = p.NewFutureOrder(req); o.Status != mod.StatusExpired {
+		t.Errorf("FOK order status = %v, want expired", o.Status)
+	}
+
+	req = &mod.OrderRequest{Symbol: util.ETHUSDT, Side: mod.SideSell, PositionSide: mod.LONG, Type: mod.TypeMarket, Quantity: 1}
+	if o, _ = p.NewFutureOrder(req); o.Status != mod.StatusFilled || o.AvgPrice != 100 {
+		t.Errorf("market order = %+v, want filled at 100", o)
+	}
+	if pos := position(t, p, mod.LONG); pos.PositionAmt != 0 {
+		t.Errorf("long position = %v, want 0", pos.PositionAmt)
+	}
+	if orders, _ := p.QueryOpenFutureOrders(util.ETHUSDT); len(orders) != 0 {
+		t.Errorf("open orders = %v, want none", len(orders))
+	}
+}
+
+func Test_AccountWs(t *testing.T) {
+	p := paper.New(nil, 1000)
+	accWs, _ := p.GetAccountWs()
+	klineWs := p.GetKlineWs(util.ETHUSDT, mod.Minute)
+	go func() {
+		for range klineWs {
+		}
+	}()
+
+	p.Feed(util.ETHUSDT, bar(0, 100, 100, 100, 100))
+	res, err := order(p, 1, 99, 0, mod.SideBuy, mod.LONG, "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Feed(util.ETHUSDT, bar(1, 100, 100, 98, 99.5))
+
+	want := []struct {
+		name  string
+		event mod.EventType
+	}{
+		{util.ORDER_TRADE_UPDATE, mod.EventNew},
+		{util.ACCOUNT_UPDATE, ""},
+		{util.ORDER_TRADE_UPDATE, mod.EventTrade},
+	}
+	for i, w := range want {
+		select {
+		case ev := <-accWs:
+			if ev.EventName != w.name {
+				t.Fatalf("event %d = %s, want %s", i, ev.EventName, w.name)
+			}
+			if ev.Order != nil {
+				if ev.Order.Event != w.event || ev.Order.OrderID != res.OrderID || ev.Order.ClientOrderID != "abc" {
+					t.Errorf("event %d = %+v", i, ev.Order)
+				}
+			}
+			if ev.Account != nil && ev.Account.Positions[0].PositionAmt != 1 {
+				t.Errorf("position = %v, want 1", ev.Account.Positions[0].PositionAmt)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for event %d", i)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package paper
+
+import (
+	"tinyquant/src/mod"
+)
+
+// 订阅K线,有 Market 时转发实盘K线,撮合周期的K线会先撮合挂单
+func (e *Exchange) GetKlineWs(symbol string, interval mod.Interval) chan *mod.Kline {
+	out := make(chan *mod.Kline)
+	if e.Market == nil {
+		if interval == e.MatchInterval {
+			e.Lock()
+			e.klineWs[symbol] = append(e.klineWs[symbol], out)
+			e.Unlock()
+		}
+		return out
+	}
+
+	in := e.Market.GetKlineWs(symbol, interval)
+	go func() {
+		for k := range in {
+			if interval == e.MatchInterval {
+				e.Match(symbol, k)
+			}
+			out <- k
+		}
+		close(out)
+	}()
+	return out
+}
+
+func (e *Exchange) GetDepthWs(symbol string) (chan *mod.Depth, chan struct{}) {
+	if e.Market == nil {
+		return make(chan *mod.Depth), make(chan struct{})
+	}
+	return e.Market.GetDepthWs(symbol)
+}
+
+func (e *Exchange) GetTradeWs(symbol string) (chan *mod.AggTrade, chan struct{}) {
+	if e.Market == nil {
+		return make(chan *mod.AggTrade), make(chan struct{})
+	}
+	return e.Market.GetTradeWs(symbol)
+}
+
+func (e *Exchange) GetMarkPriceWs(symbol string) (chan *mod.FundingRate, chan struct{}) {
+	if e.Market == nil {
+		return make(chan *mod.FundingRate), make(chan struct{})
+	}
+	return e.Market.GetMarkPriceWs(symbol)
+}
+
+// 本地撮合的账户推送不会断开,只转发行情的连接状态
+func (e *Exchange) GetStreamEvents() chan *mod.StreamEvent {
+	if e.Market == nil {
+		return nil
+	}
+	return e.Market.GetStreamEvents()
+}
+
+// 订阅后撮合产生的账户事件通过通道推送,不再由 PopEvents 取出
+func (e *Exchange) GetAccountWs() (chan *mod.AccountEvent, chan struct{}) {
+	ch := make(chan *mod.AccountEvent)
+	done := make(chan struct{})
+
+	e.Lock()
+	e.notify = make(chan struct{}, 1)
+	notify := e.notify
+	if len(e.events) > 0 {
+		notify <- struct{}{}
+	}
+	e.Unlock()
+
+	go func() {
+		for range notify {
+			for _, ev := range e.PopEvents() {
+				ch <- ev
+			}
+		}
+	}()
+	return ch, done
+}
@@ -1,34 +1,46 @@
 package quant
 
 import (
+	"time"
 	"tinyquant/src/mod"
-
-	"github.com/rootpd/binance"
 )
 
-type Binance interface {
-	InitBinance(apikey, secretkey string)
-	NewBinanceFutureOrder(symbol string, quantity float64, price float64, stopprice float64, side binance.OrderSide, positionSide binance.PositionSide, id string) (*binance.FutureProcessedOrder, error)
-
-	CancelBinanceFutureOrder(symbol string, orderid int64) (*binance.CanceledFutureOrder, error)
-	QueryBinanceOneFutureOrder(symbol string, id string) (*binance.ExecutedFutureOrder, error)
-	QueryBinanceAllFutureOrder(symbol string) ([]*binance.ExecutedFutureOrder, error)
-
-	GetFutureBalance() ([]*binance.FutureBalanceInfo, error)
-	GetFutureAccount(symbol string) []*binance.FuturePositions
-
-	GetDepth(symbol string, limit int) (*binance.OrderBook, error)
-
-	AdjustBinanceLeverage(symbol string, leverage int) error
-
-	GetGlobalLongShortAccountRatio() ([]*binance.GlobalLongShortAccountRatioInfo, error)
-	GetBinanceNewPrice(symbol string) (*binance.NewPriceInfo, error)
-	ChangeBinanceMarginType(symbol string, s binance.PositionStatus) error
-	ChangeBinanceUserPositionSide(s binance.PosithonSideStatus) error
-	GetFutureDepthWs(symbol string) (chan *binance.DepthEvent, chan struct{})
-	GetAccountWs() (chan *binance.FutureAccountEvent, chan struct{})
-
-	GetFutureKlines(symbol string, limit int, interval binance.Interval) ([]*binance.Kline, error)
-
-	GetKlineWs(symbol string, interval binance.Interval) chan *mod.Kline
+// 交易所无关的合约交易接口,策略只依赖这里和 mod 中的类型
+type Exchange interface {
+	InitExchange(apikey, secretkey string)
+
+	NewFutureOrder(req *mod.OrderRequest) (*mod.FutureOrder, error)
+	CancelFutureOrder(symbol string, orderid int64) (*mod.FutureOrder, error)
+	QueryFutureOrder(symbol string, clientOrderID string) (*mod.FutureOrder, error)
+	QueryOpenFutureOrders(symbol string) ([]*mod.FutureOrder, error)
+
+	// 批量下单和撤单,结果和请求一一对应,失败的订单对应的 error 不为空
+	NewFutureOrders(reqs []*mod.OrderRequest) ([]*mod.FutureOrder, []error)
+	CancelFutureOrders(symbol string, orderids []int64) ([]*mod.FutureOrder, []error)
+	CancelAllFutureOrders(symbol string) error
+
+	GetFutureBalance() ([]*mod.Balance, error)
+	GetFuturePositions(symbol string) ([]*mod.Position, error)
+
+	AdjustLeverage(symbol string, leverage int) error
+	ChangeMarginType(symbol string, marginType mod.MarginType) error
+	ChangePositionSide(dual bool) error // dual 为 true 时双向持仓
+
+	GetDepth(symbol string, limit int) (*mod.Depth, error)
+	GetNewPrice(symbol string) (float64, error)
+	GetFutureKlines(symbol string, limit int, interval mod.Interval) ([]*mod.Kline, error)
+	GetSymbolInfo(symbol string) (*mod.SymbolInfo, error) // 下单规则,实现方负责缓存
+
+	// 资金费率,没有资金费率的市场返回错误
+	GetFundingRate(symbol string) (*mod.FundingRate, error)
+	// start 之后的收益流水,按时间升序,incomeType 为空时返回全部类型,单次最多1000条
+	GetIncomes(symbol string, incomeType mod.IncomeType, start time.Time) ([]*mod.Income, error)
+
+	GetDepthWs(symbol string) (chan *mod.Depth, chan struct{})
+	GetAccountWs() (chan *mod.AccountEvent, chan struct{})
+	GetKlineWs(symbol string, interval mod.Interval) chan *mod.Kline
+	GetTradeWs(symbol string) (chan *mod.AggTrade, chan struct{}) // 归集成交
+	GetStreamEvents() chan *mod.StreamEvent // 所有推送的连接状态,同一个客户端返回同一个通道,没有时返回 nil
+	// 每秒推送标记价格和资金费率
+	GetMarkPriceWs(symbol string) (chan *mod.FundingRate, chan struct{})
 }
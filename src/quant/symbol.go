@@ -0,0 +1,66 @@
+package quant
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	. "tinyquant/src/logger"
+	"tinyquant/src/mod"
+
+	"go.uber.org/zap"
+)
+
+const DefaultSymbolInfoTTL = time.Hour
+
+// 交易规则缓存,过期后在下一次查询时刷新,刷新失败时继续使用旧的规则
+type SymbolInfoCache struct {
+	sync.Mutex
+	TTL  time.Duration // 为 0 时使用 DefaultSymbolInfoTTL
+	Load func() ([]*mod.SymbolInfo, error)
+
+	symbols map[string]*mod.SymbolInfo
+	updated time.Time
+}
+
+func (c *SymbolInfoCache) Get(symbol string) (*mod.SymbolInfo, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	ttl := c.TTL
+	if ttl == 0 {
+		ttl = DefaultSymbolInfoTTL
+	}
+	if c.symbols == nil || time.Since(c.updated) > ttl {
+		if err := c.refresh(); err != nil {
+			if c.symbols == nil {
+				return nil, err
+			}
+			Logger.Error("refresh symbol info failed", zap.Error(err))
+			//一分钟后再重试,避免每次下单都请求交易所
+			c.updated = time.Now().Add(time.Minute - ttl)
+		}
+	}
+	info, ok := c.symbols[symbol]
+	if !ok {
+		return nil, fmt.Errorf("unknown symbol : %v", symbol)
+	}
+	return info, nil
+}
+
+func (c *SymbolInfoCache) refresh() error {
+	if c.Load == nil {
+		return fmt.Errorf("symbol info loader not set")
+	}
+	infos, err := c.Load()
+	if err != nil {
+		return err
+	}
+	symbols := make(map[string]*mod.SymbolInfo, len(infos))
+	for _, v := range infos {
+		symbols[v.Symbol] = v
+	}
+	c.symbols = symbols
+	c.updated = time.Now()
+	return nil
+}
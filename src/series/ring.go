@@ -0,0 +1,92 @@
+package series
+
+// 固定容量的环形缓冲区,按写入顺序保存,满了以后覆盖最旧的元素
+// 下标 0 是最旧的,Len()-1 是最新的,本身不加锁
+type Ring struct {
+	data  []interface{}
+	start int // 最旧元素的位置
+	size  int
+}
+
+func NewRing(capacity int) *Ring {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Ring{data: make([]interface{}, capacity)}
+}
+
+func (r *Ring) Cap() int { return len(r.data) }
+
+func (r *Ring) Len() int { return r.size }
+
+func (r *Ring) Full() bool { return r.size == len(r.data) }
+
+// 追加到最新,满了时返回被覆盖的最旧元素
+func (r *Ring) Push(v interface{}) (evicted interface{}, ok bool) {
+	if r.Full() {
+		evicted, ok = r.data[r.start], true
+		r.data[r.start] = v
+		r.start = (r.start + 1) % len(r.data)
+		return evicted, ok
+	}
+	r.data[(r.start+r.size)%len(r.data)] = v
+	r.size++
+	return nil, false
+}
+
+// 第 i 个元素,0 最旧,越界时返回 nil
+func (r *Ring) At(i int) interface{} {
+	if i < 0 || i >= r.size {
+		return nil
+	}
+	return r.data[(r.start+i)%len(r.data)]
+}
+
+// 最新的元素,为空时返回 nil
+func (r *Ring) Newest() interface{} {
+	return r.At(r.size - 1)
+}
+
+// 替换最新的元素,为空时追加
+func (r *Ring) SetNewest(v interface{}) {
+	if r.size == 0 {
+		r.Push(v)
+		return
+	}
+	r.data[(r.start+r.size-1)%len(r.data)] = v
+}
+
+// 下标在 [from, to) 的元素,从旧到新,超出范围的部分截掉
+func (r *Ring) Window(from, to int) []interface{} {
+	if from < 0 {
+		from = 0
+	}
+	if to > r.size {
+		to = r.size
+	}
+	if from >= to {
+		return nil
+	}
+	res := make([]interface{}, 0, to-from)
+	for i := from; i < to; i++ {
+		res = append(res, r.data[(r.start+i)%len(r.data)])
+	}
+	return res
+}
+
+// 最新的 n 个元素,从旧到新
+func (r *Ring) Last(n int) []interface{} {
+	return r.Window(r.size-n, r.size)
+}
+
+// 全部元素,从旧到新
+func (r *Ring) Slice() []interface{} {
+	return r.Window(0, r.size)
+}
+
+func (r *Ring) Clear() {
+	for i := range r.data {
+		r.data[i] = nil
+	}
+	r.start, r.size = 0, 0
+}
@@ -0,0 +1,92 @@
+package series_test
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"tinyquant/src/series"
+)
+
+// 用切片实现的参照,每次操作后和 Ring 比较
+type reference struct {
+	cap  int
+	data []interface{}
+}
+
+func (r *reference) push(v interface{}) (interface{}, bool) {
+	r.data = append(r.data, v)
+	if len(r.data) > r.cap {
+		evicted := r.data[0]
+		r.data = r.data[1:]
+		return evicted, true
+	}
+	return nil, false
+}
+
+func (r *reference) window(from, to int) []interface{} {
+	if from < 0 {
+		from = 0
+	}
+	if to > len(r.data) {
+		to = len(r.data)
+	}
+	if from >= to {
+		return nil
+	}
+	return append([]interface{}{}, r.data[from:to]...)
+}
+
+func Test_RingProperties(t *testing.T) {
+	for seed := int64(0); seed < 50; seed++ {
+		rnd := rand.New(rand.NewSource(seed))
+		capacity := 1 + rnd.Intn(10)
+		ring := series.NewRing(capacity)
+		ref := &reference{cap: capacity}
+
+		for step := 0; step < 300; step++ {
+			switch op := rnd.Intn(20); {
+			case op == 0:
+				ring.Clear()
+				ref.data = nil
+			case op < 4:
+				v := rnd.Int()
+				ring.SetNewest(v)
+				if len(ref.data) == 0 {
+					ref.push(v)
+				} else {
+					ref.data[len(ref.data)-1] = v
+				}
+			default:
+				v := rnd.Int()
+				e1, ok1 := ring.Push(v)
+				e2, ok2 := ref.push(v)
+				if ok1 != ok2 || e1 != e2 {
+					t.Fatalf("seed %d step %d push evicted %v %v want %v %v", seed, step, e1, ok1, e2, ok2)
+				}
+			}
+
+			if ring.Len() != len(ref.data) || ring.Full() != (len(ref.data) == capacity) {
+				t.Fatalf("seed %d step %d len %d full %v want %d", seed, step, ring.Len(), ring.Full(), len(ref.data))
+			}
+			if !reflect.DeepEqual(ring.Slice(), ref.window(0, len(ref.data))) {
+				t.Fatalf("seed %d step %d slice %v want %v", seed, step, ring.Slice(), ref.data)
+			}
+			n := rnd.Intn(capacity + 2)
+			if got, want := ring.Last(n), ref.window(len(ref.data)-n, len(ref.data)); !reflect.DeepEqual(got, want) {
+				t.Fatalf("seed %d step %d last(%d) %v want %v", seed, step, n, got, want)
+			}
+			from, to := rnd.Intn(capacity+2)-1, rnd.Intn(capacity+2)
+			if got, want := ring.Window(from, to), ref.window(from, to); !reflect.DeepEqual(got, want) {
+				t.Fatalf("seed %d step %d window(%d, %d) %v want %v", seed, step, from, to, got, want)
+			}
+			i := rnd.Intn(capacity+2) - 1
+			var want interface{}
+			if i >= 0 && i < len(ref.data) {
+				want = ref.data[i]
+			}
+			if ring.At(i) != want {
+				t.Fatalf("seed %d step %d at(%d) %v want %v", seed, step, i, ring.At(i), want)
+			}
+		}
+	}
+}
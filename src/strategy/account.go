@@ -1,22 +1,25 @@
 package strategy
 
 import (
-	"os"
+	"fmt"
 	"sync"
 	"time"
 	. "tinyquant/src/logger"
+	"tinyquant/src/mod"
 	quant "tinyquant/src/quant"
+	coin_fb "tinyquant/src/quant/coin_future_binance"
 	fb "tinyquant/src/quant/future_binance"
+	"tinyquant/src/quant/huobi"
+	"tinyquant/src/quant/paper"
 	"tinyquant/src/util"
 
-	"github.com/rootpd/binance"
 	"go.uber.org/zap"
 )
 
 type BinanceFutureAsset struct {
 	*sync.RWMutex
 	Symbol   string
-	Binance  quant.Binance
+	Exchange quant.Exchange
 	Name     string
 	Type     string
 	Quantity float64
@@ -31,43 +34,62 @@ type BinanceFutureAsset struct {
 	//PositionQty        float64 // 持仓量
 }
 
-func (acc *BinanceFutureAsset) InitAccount(symbol string) error {
-	acc.Symbol = symbol
-	if f, ok := os.LookupEnv("futures"); ok {
-
-		if f == "usdt" {
-			Logger.Info("tinyquant u本位 启动")
-			Binance = &fb.Binance{}
-		}
+// 根据合约类型创建交易客户端
+func NewExchange(futures string) (quant.Exchange, error) {
+	switch futures {
+	case "usdt":
+		return &fb.Exchange{}, nil
+	case "coin":
+		return &coin_fb.Exchange{}, nil
+	case "huobi":
+		return &huobi.Exchange{Host: util.HUOBI_HOST, AccountId: util.HUOBI_ACCOUNT_ID}, nil
+	}
+	return nil, fmt.Errorf("unsupported futures type : %q", futures)
+}
 
-		// if f == "coin" {
-		// Logger.Info("tinyquant 币本位 启动")
-		// Binance = &coin_fb.Binance{}
-		// }
+// 交易所对应的 api key
+func ApiKey(futures string) (string, string) {
+	if futures == "huobi" {
+		return util.HUOBI_ACCESS_KEY, util.HUOBI_SECRET_KEY
+	}
+	return util.BINANCE_API_KEY, util.BINANCE_SECRET_KEY
+}
 
-	} else {
-		panic("Get Binance API failed")
+// 交易所对应的客户端,模拟盘时只用实盘行情,订单在本地撮合
+func NewClient(futures string) (quant.Exchange, error) {
+	b, err := NewExchange(futures)
+	if err != nil {
+		return nil, err
+	}
+	b.InitExchange(ApiKey(futures))
+	if util.Paper {
+		Logger.Sugar().Infof("模拟盘启动 初始资金 : %v", util.PaperBalance)
+		util.PlaceTest = false
+		return paper.New(b, util.PaperBalance), nil
 	}
+	return b, nil
+}
 
-	//客户端初始化
-	Binance.InitBinance(util.BINANCE_API_KEY, util.BINANCE_SECRET_KEY)
+// 使用 acc.Exchange 调整交易对的杠杆和持仓模式并加载余额
+func (acc *BinanceFutureAsset) InitAccount(symbol string) error {
+	acc.Symbol = symbol
 
 	// 调整当前杠杆倍数 403?
-	err := Binance.AdjustBinanceLeverage(symbol, util.BinanceLeverage)
+	err := acc.Exchange.AdjustLeverage(symbol, util.BinanceLeverage)
 	if err != nil {
 		Logger.Error("adjust leverage failed", zap.Error(err))
 		// return err
 	}
 
 	// 更改持仓模式
-	err = Binance.ChangeBinanceUserPositionSide(binance.PosithonBothSide)
+	err = acc.Exchange.ChangePositionSide(true)
 	if err != nil {
 		Logger.Error("change user position side failed", zap.Error(err))
 		// return err
 	}
 
 	// 改变全仓模式
-	err = Binance.ChangeBinanceMarginType(symbol, binance.POSITION_CROSSED) // 全仓
+	err = acc.Exchange.ChangeMarginType(symbol, mod.MarginCrossed) // 全仓
 	if err != nil {
 		Logger.Error("change margin type failed", zap.Error(err))
 		//return err
@@ -94,15 +116,16 @@ func (acc *BinanceFutureAsset) ReloadAccount() {
 
 func (acc *BinanceFutureAsset) LoadAccount() error {
 	// 获取账户余额
-	ba, err := Binance.GetFutureBalance()
+	ba, err := acc.Exchange.GetFutureBalance()
 	if err != nil {
 		Logger.Error("Get Future balance failed ", zap.Error(err))
 		return err
 	}
+	asset := marginAsset(acc.Exchange, acc.Symbol)
 	acc.Lock()
 	defer acc.Unlock()
 	for _, v := range ba {
-		if util.ACCOUNTASSET[acc.Symbol] == v.Asset {
+		if asset == v.Asset {
 			// Logger.Info("当前资产 :", zap.Any("asset", v))
 			acc.Asset = v.Asset
 			acc.Balance = v.Balance                       // 总余额，包括已持仓的和当前盈利
@@ -0,0 +1,124 @@
+package strategy
+
+import (
+	"fmt"
+	"time"
+	"tinyquant/src/mod"
+)
+
+// 成交推送合成K线的方式
+type BarType string
+
+const (
+	TimeBar   BarType = "time"   // 每 Size 秒
+	TickBar   BarType = "tick"   // 每 Size 笔成交
+	VolumeBar BarType = "volume" // 每 Size 成交量
+	DollarBar BarType = "dollar" // 每 Size 成交额
+)
+
+// 用归集成交合成K线,主动买卖量、成交额和成交笔数都是精确值
+// 一笔成交不拆分,成交量和成交额K线在达到 Size 的那笔成交后收盘,会略超过 Size
+// 时间K线在下一个周期的第一笔成交到达时收盘
+type BarBuilder struct {
+	Type  BarType
+	Size  float64
+	bar   *mod.Kline
+	ticks float64
+}
+
+func NewBarBuilder(t BarType, size float64) (*BarBuilder, error) {
+	switch t {
+	case TimeBar, TickBar, VolumeBar, DollarBar:
+	default:
+		return nil, fmt.Errorf("unknown bar type : %v", t)
+	}
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid bar size : %v", size)
+	}
+	if t == TimeBar && time.Duration(size*float64(time.Second)) < time.Millisecond {
+		return nil, fmt.Errorf("time bar size too small : %v", size)
+	}
+	return &BarBuilder{Type: t, Size: size}, nil
+}
+
+// 加入一笔成交,返回收盘的K线
+func (b *BarBuilder) Add(t *mod.AggTrade) *mod.Kline {
+	var final *mod.Kline
+	if b.Type == TimeBar && b.bar != nil && !t.Time.Before(b.bar.CloseTime.Add(time.Millisecond)) {
+		final = b.finish()
+	}
+	if b.bar == nil {
+		b.open(t)
+	}
+
+	k := b.bar
+	if t.Price > k.High {
+		k.High = t.Price
+	}
+	if t.Price < k.Low {
+		k.Low = t.Price
+	}
+	k.Close = t.Price
+	quote := t.Price * t.Quantity
+	k.Volume += t.Quantity
+	k.Quote += quote
+	if t.BuyerMaker {
+		k.SellVolume += t.Quantity
+		k.SellQuote += quote
+	} else {
+		k.BuyVolume += t.Quantity
+		k.BuyQuote += quote
+	}
+	k.TradeNumber += t.LastTradeID - t.FirstTradeID + 1
+	// 数量为0的成交不影响均价,整根K线都是0时 VWAP 为0
+	if k.Volume > 0 {
+		k.VWAP = k.Quote / k.Volume
+	}
+	b.ticks++
+	if b.Type != TimeBar {
+		k.CloseTime = t.Time
+	}
+
+	if final == nil && b.full() {
+		final = b.finish()
+	}
+	return final
+}
+
+// 当前没有收盘的K线
+func (b *BarBuilder) Current() *mod.Kline {
+	if b.bar == nil {
+		return nil
+	}
+	k := *b.bar
+	return &k
+}
+
+func (b *BarBuilder) open(t *mod.AggTrade) {
+	b.bar = &mod.Kline{StartTime: t.Time, CloseTime: t.Time, Open: t.Price, High: t.Price, Low: t.Price}
+	b.ticks = 0
+	if b.Type == TimeBar {
+		size := time.Duration(b.Size * float64(time.Second))
+		b.bar.StartTime = t.Time.Truncate(size)
+		b.bar.CloseTime = b.bar.StartTime.Add(size - time.Millisecond)
+	}
+}
+
+func (b *BarBuilder) full() bool {
+	switch b.Type {
+	case TickBar:
+		return b.ticks >= b.Size
+	case VolumeBar:
+		return b.bar.Volume >= b.Size
+	case DollarBar:
+		return b.bar.Quote >= b.Size
+	}
+	return false
+}
+
+func (b *BarBuilder) finish() *mod.Kline {
+	k := b.bar
+	k.Final = true
+	b.bar = nil
+	return k
+}
@@ -0,0 +1,74 @@
+package strategy_test
+
+import (
+	"math"
+	"testing"
+	"time"
+	"tinyquant/src/mod"
+	"tinyquant/src/strategy"
+)
+
+func aggTrade(id int, ms int64, price, qty float64, buyerMaker bool) *mod.AggTrade {
+	return &mod.AggTrade{ID: id, FirstTradeID: id * 10, LastTradeID: id*10 + 1, Price: price, Quantity: qty,
+		Time: time.Unix(0, ms*int64(time.Millisecond)), BuyerMaker: buyerMaker}
+}
+
+func Test_BarBuilder(t *testing.T) {
+	if _, err := strategy.NewBarBuilder("renko", 1); err == nil {
+		t.Error("unknown bar type accepted")
+	}
+
+	// 5秒K线,下一个周期的成交到达时收盘
+	b, _ := strategy.NewBarBuilder(strategy.TimeBar, 5)
+	if b.Add(aggTrade(1, 1000, 100, 1, false)) != nil || b.Add(aggTrade(2, 3000, 102, 3, true)) != nil {
+		t.Fatal("time bar closed early")
+	}
+	k := b.Add(aggTrade(3, 5000, 101, 1, false))
+	if k == nil || !k.Final {
+		t.Fatal("time bar not closed")
+	}
+	if k.Open != 100 || k.Close != 102 || k.High != 102 || k.Low != 100 || k.Volume != 4 ||
+		k.BuyVolume != 1 || k.SellVolume != 3 || k.TradeNumber != 4 || math.Abs(k.VWAP-101.5) > 1e-9 {
+		t.Errorf("time bar %+v", k)
+	}
+	if !k.StartTime.Equal(time.Unix(0, 0)) || !k.CloseTime.Equal(time.Unix(4, 999*int64(time.Millisecond))) {
+		t.Errorf("time bar period %v %v", k.StartTime, k.CloseTime)
+	}
+	if cur := b.Current(); cur == nil || cur.Open != 101 {
+		t.Errorf("current bar %+v", cur)
+	}
+
+	// 成交量K线在超过 Size 的那笔成交后收盘,不拆分成交
+	b, _ = strategy.NewBarBuilder(strategy.VolumeBar, 2)
+	b.Add(aggTrade(1, 1000, 100, 1.5, false))
+	if k = b.Add(aggTrade(2, 1200, 101, 1, true)); k == nil || k.Volume != 2.5 || !k.CloseTime.Equal(time.Unix(1, 200*int64(time.Millisecond))) {
+		t.Errorf("volume bar %+v", k)
+	}
+	if b.Current() != nil {
+		t.Error("volume bar not reset")
+	}
+
+	b, _ = strategy.NewBarBuilder(strategy.TickBar, 2)
+	b.Add(aggTrade(1, 1000, 100, 1, false))
+	if k = b.Add(aggTrade(2, 1000, 100, 1, false)); k == nil {
+		t.Error("tick bar not closed")
+	}
+
+	// 第一笔成交数量为0时均价不能是 NaN
+	b, _ = strategy.NewBarBuilder(strategy.TickBar, 2)
+	b.Add(aggTrade(1, 1000, 100, 0, false))
+	if cur := b.Current(); math.IsNaN(cur.VWAP) || cur.VWAP != 0 {
+		t.Errorf("zero quantity vwap %v", cur.VWAP)
+	}
+	if k = b.Add(aggTrade(2, 1000, 102, 2, false)); k == nil || k.VWAP != 102 {
+		t.Errorf("vwap after zero quantity %+v", k)
+	}
+
+	b, _ = strategy.NewBarBuilder(strategy.DollarBar, 1000)
+	if b.Add(aggTrade(1, 1000, 100, 9, false)) != nil {
+		t.Error("dollar bar closed early")
+	}
+	if k = b.Add(aggTrade(2, 1000, 100, 1, false)); k == nil || k.Quote != 1000 || k.BuyQuote != 1000 {
+		t.Errorf("dollar bar %+v", k)
+	}
+}
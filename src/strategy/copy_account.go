@@ -1,16 +1,26 @@
 package strategy
 
 import (
+	"fmt"
+	"sync"
+
 	. "tinyquant/src/logger"
+	"tinyquant/src/mod"
+	coin_fb "tinyquant/src/quant/coin_future_binance"
 	fb "tinyquant/src/quant/future_binance"
 	"tinyquant/src/util"
 
-	"github.com/rootpd/binance"
 	"go.uber.org/zap"
 )
 
 type DocumentaryAccount struct {
 	Account []*BinanceFutureAsset
+	orders  map[string][]*copyOrder // 主账户订单号 -> 跟单账户订单
+}
+
+type copyOrder struct {
+	acc     *BinanceFutureAsset
+	orderID int64
 }
 
 func (acc *DocumentaryAccount) InitDocumentaryAccount(symbol string) error {
@@ -26,33 +36,40 @@ func (acc *DocumentaryAccount) InitDocumentaryAccount(symbol string) error {
 	apilist := util.DocumentaryApi.Binance
 	for _, cfg := range apilist {
 
-		Acc := &BinanceFutureAsset{}
+		Acc := &BinanceFutureAsset{RWMutex: &sync.RWMutex{}}
 		var s string
 
+		// 跟单账户和主账户的合约类型可以不同,按跟单账户的类型选择交易对
 		switch cfg.Type {
 		case "usdt":
-			Acc.Binance = &fb.Binance{}
+			Acc.Exchange = &fb.Exchange{}
 			s = symbol
-			// case "coin":
-			// 	Acc.Binance = &coin_fb.Binance{}
-			// 	s = util.COIN_ETHUSD
+			if symbol == util.COIN_ETHUSD {
+				s = util.ETHUSDT
+			}
+		case "coin":
+			Acc.Exchange = &coin_fb.Exchange{}
+			s = util.COIN_ETHUSD
+		default:
+			return fmt.Errorf("unsupported account type : %q", cfg.Type)
 		}
+		Acc.Symbol = s
 
 		Acc.Quantity = util.Round(cfg.Quantity, 3)
 		Acc.Name = cfg.Name
 		Acc.Type = cfg.Type
 
-		Acc.Binance.InitBinance(cfg.ApiKey, cfg.SecretKey)
+		Acc.Exchange.InitExchange(cfg.ApiKey, cfg.SecretKey)
 
 		// 调整当前杠杆倍数
-		err := Acc.Binance.AdjustBinanceLeverage(s, util.BinanceLeverage)
+		err := Acc.Exchange.AdjustLeverage(s, util.BinanceLeverage)
 		if err != nil {
 			Logger.Error("adjust leverage failed", zap.Error(err))
 		}
 
 		// 更改持仓模式
 
-		err = Acc.Binance.ChangeBinanceUserPositionSide(binance.PosithonBothSide)
+		err = Acc.Exchange.ChangePositionSide(true)
 		if err != nil {
 			Logger.Error("change user position side failed", zap.Error(err))
 			// return err
@@ -60,13 +77,13 @@ func (acc *DocumentaryAccount) InitDocumentaryAccount(symbol string) error {
 
 		// 改变全仓模式
 
-		err = Acc.Binance.ChangeBinanceMarginType(s, binance.POSITION_ISOLATED) // 全仓
+		err = Acc.Exchange.ChangeMarginType(s, mod.MarginIsolated) // 全仓
 		if err != nil {
 			Logger.Error("change margin type failed", zap.Error(err))
 			//return err
 		}
 
-		ba, err := Acc.Binance.GetFutureBalance()
+		ba, err := Acc.Exchange.GetFutureBalance()
 
 		if err != nil {
 			Logger.Error("CopyAccount :", zap.Any("account", Acc.Name))
@@ -76,7 +93,7 @@ func (acc *DocumentaryAccount) InitDocumentaryAccount(symbol string) error {
 
 		for _, v := range ba {
 
-			if util.ACCOUNTASSET[symbol] == v.Asset {
+			if marginAsset(Acc.Exchange, s) == v.Asset {
 
 				Acc.Asset = v.Asset
 				Acc.Balance = v.Balance                       // 总余额，包括已持仓的和当前盈利
@@ -97,3 +114,81 @@ func (acc *DocumentaryAccount) InitDocumentaryAccount(symbol string) error {
 	return nil
 
 }
+
+// 监听主账户的挂单事件,按跟单账户配置的数量比例同步下单和撤单
+func (acc *DocumentaryAccount) CopyLoop(symbol string) error {
+	master, err := NewExchange(util.Futures)
+	if err != nil {
+		return err
+	}
+	master.InitExchange(ApiKey(util.Futures))
+
+	if err := acc.InitDocumentaryAccount(symbol); err != nil {
+		return err
+	}
+	acc.orders = make(map[string][]*copyOrder)
+
+	accWs, _ := master.GetAccountWs()
+	Logger.Info("开启跟单")
+	for ev := range accWs {
+		if ev.EventName != util.ORDER_TRADE_UPDATE {
+			continue
+		}
+		order := ev.Order
+		if order.Symbol != symbol {
+			continue
+		}
+		switch order.Event {
+		case mod.EventNew:
+			acc.copyNewOrder(order)
+		case mod.EventCanceled, mod.EventExpired:
+			acc.copyCancelOrder(order.ClientOrderID)
+		case mod.EventTrade:
+			if order.Status == mod.StatusFilled {
+				delete(acc.orders, order.ClientOrderID)
+			}
+		}
+	}
+	return nil
+}
+
+func (acc *DocumentaryAccount) copyNewOrder(order *mod.OrderUpdate) {
+	for _, a := range acc.Account {
+		quantity := symbolInfo(a.Exchange, a.Symbol).FloorQty(order.OrigQty * a.Quantity / util.Quantity)
+		if quantity <= 0 && !order.ClosePosition {
+			continue
+		}
+		res, err := a.Exchange.NewFutureOrder(&mod.OrderRequest{
+			Symbol:        a.Symbol,
+			Side:          order.Side,
+			PositionSide:  order.PositionSide,
+			Type:          order.Type,
+			TimeInForce:   order.TimeInForce,
+			Quantity:      quantity,
+			Price:         order.Price,
+			StopPrice:     order.StopPrice,
+			ClientOrderID: order.ClientOrderID,
+
+			ClosePosition:   order.ClosePosition,
+			ActivationPrice: order.ActivatePrice,
+			CallbackRate:    order.PriceRate,
+			WorkingType:     order.WorkingType,
+			PriceProtect:    order.PriceProtect,
+		})
+		if err != nil {
+			Logger.Error("copy order failed", zap.String("account", a.Name), zap.Error(err))
+			continue
+		}
+		Logger.Sugar().Infof("跟单 %s 下单成功 价格 : %v 数量 : %v", a.Name, order.Price, quantity)
+		acc.orders[order.ClientOrderID] = append(acc.orders[order.ClientOrderID], &copyOrder{acc: a, orderID: res.OrderID})
+	}
+}
+
+func (acc *DocumentaryAccount) copyCancelOrder(clientOrderID string) {
+	for _, o := range acc.orders[clientOrderID] {
+		if _, err := o.acc.Exchange.CancelFutureOrder(o.acc.Symbol, o.orderID); err != nil {
+			Logger.Error("copy cancel order failed", zap.String("account", o.acc.Name), zap.Error(err))
+		}
+	}
+	delete(acc.orders, clientOrderID)
+}
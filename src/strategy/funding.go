@@ -0,0 +1,208 @@
+package strategy
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	. "tinyquant/src/logger"
+	"tinyquant/src/mod"
+	"tinyquant/src/quant"
+	"tinyquant/src/util"
+
+	"go.uber.org/zap"
+)
+
+// 第一次查询资金费用流水时往前查的时间
+const fundingHistory = 7 * 24 * time.Hour
+
+// 交易对的资金费率和资金费用流水
+// 标记价格和费率由推送更新,结算后查询收益流水,累计收取和支付的资金费用
+type Funding struct {
+	Exchange quant.Exchange
+	Symbol   string
+	Rate     *mod.FundingRate // 最新的标记价格和资金费率,没有数据时为 nil
+	Incomes  []*mod.Income    // 资金费用流水,按时间升序
+	Received float64          // 累计收取的资金费用
+	Paid     float64          // 累计支付的资金费用,正数
+	sync.RWMutex
+
+	since   time.Time      // 下次查询流水的开始时间
+	passed  time.Time      // 最近一次已经过去的结算时间
+	settled time.Time      // 已经查询过流水的结算时间
+	seen    map[int64]bool // 已记录的流水
+}
+
+func NewFunding(ex quant.Exchange, symbol string) *Funding {
+	return &Funding{
+		Exchange: ex,
+		Symbol:   symbol,
+		since:    time.Now().Add(-fundingHistory),
+		seen:     make(map[int64]bool),
+	}
+}
+
+// 用 REST 查询一次资金费率,推送断开或刚启动时使用
+func (f *Funding) Refresh() error {
+	r, err := f.Exchange.GetFundingRate(f.Symbol)
+	if err != nil {
+		return err
+	}
+	f.Update(r)
+	return nil
+}
+
+// 推送的标记价格和资金费率
+func (f *Funding) Update(r *mod.FundingRate) {
+	f.Lock()
+	defer f.Unlock()
+	if f.Rate != nil {
+		if r.Time.Before(f.Rate.Time) {
+			return
+		}
+		// 下次结算时间后移,上一次结算已经完成
+		if last := f.Rate.NextFundingTime; !last.IsZero() && r.NextFundingTime.After(last) && last.After(f.passed) {
+			f.passed = last
+		}
+	}
+	f.Rate = r
+}
+
+func (f *Funding) Current() (mod.FundingRate, bool) {
+	f.RLock()
+	defer f.RUnlock()
+	if f.Rate == nil {
+		return mod.FundingRate{}, false
+	}
+	return *f.Rate, true
+}
+
+// 查询上次之后的资金费用流水,返回新增的,单次最多1000条,满了继续往后查
+func (f *Funding) SyncIncomes() ([]*mod.Income, error) {
+	var added []*mod.Income
+	for {
+		f.RLock()
+		since := f.since
+		f.RUnlock()
+		list, err := f.Exchange.GetIncomes(f.Symbol, mod.IncomeFundingFee, since)
+		if err != nil {
+			return added, err
+		}
+		added = append(added, f.record(list)...)
+		if len(list) < 1000 {
+			return added, nil
+		}
+		f.RLock()
+		stuck := !f.since.After(since)
+		f.RUnlock()
+		if stuck {
+			return added, nil
+		}
+	}
+}
+
+// 开始时间包含在查询范围内,同一条流水可能返回两次,按 TranID 去重
+func (f *Funding) record(list []*mod.Income) []*mod.Income {
+	f.Lock()
+	defer f.Unlock()
+	var added []*mod.Income
+	for _, v := range list {
+		if v.Type != mod.IncomeFundingFee || f.seen[v.TranID] {
+			continue
+		}
+		f.seen[v.TranID] = true
+		f.Incomes = append(f.Incomes, v)
+		if v.Amount > 0 {
+			f.Received += v.Amount
+		} else {
+			f.Paid -= v.Amount
+		}
+		if v.Time.After(f.since) {
+			f.since = v.Time
+		}
+		added = append(added, v)
+	}
+	return added
+}
+
+// 累计资金费用,正数为净收入
+func (f *Funding) Net() float64 {
+	f.RLock()
+	defer f.RUnlock()
+	return f.Received - f.Paid
+}
+
+// 结算已经过去一分钟但还没有查询流水时返回 true,同一次结算只返回一次
+// 交易所记录流水有延迟,推送断开时下次结算时间不会更新,按时间判断
+func (f *Funding) Settled(now time.Time) bool {
+	f.Lock()
+	defer f.Unlock()
+	due := f.passed
+	if f.Rate != nil && !now.Before(f.Rate.NextFundingTime) && f.Rate.NextFundingTime.After(due) {
+		due = f.Rate.NextFundingTime
+	}
+	if due.IsZero() || !due.After(f.settled) || now.Sub(due) < time.Minute {
+		return false
+	}
+	f.settled = due
+	return true
+}
+
+// 临近结算时开仓方向需要支付的费率超过 MaxRate 时返回放弃的原因
+func (f *Funding) Veto(side mod.PositionSide, now time.Time, p util.FundingParam) string {
+	r, ok := f.Current()
+	if !ok || r.NextFundingTime.IsZero() {
+		return ""
+	}
+	left := r.NextFundingTime.Sub(now)
+	if left < 0 || left > time.Duration(p.Window)*time.Second {
+		return ""
+	}
+	if cost := r.Cost(side, 1); cost > p.MaxRate {
+		return fmt.Sprintf("%v 后结算,%v 方向支付资金费率 %v", left.Truncate(time.Second), side, cost)
+	}
+	return ""
+}
+
+// 每分钟调用,推送没有更新时用 REST 查询费率,结算后查询流水
+func (f *Funding) OnTimer(now time.Time) {
+	if r, ok := f.Current(); !ok || now.Sub(r.Time) > time.Minute {
+		if err := f.Refresh(); err != nil {
+			Logger.Warn("refresh funding rate failed", zap.String("symbol", f.Symbol), zap.Error(err))
+		}
+	}
+	if f.Settled(now) {
+		f.settle()
+	}
+}
+
+// 查询流水并记录日志,查询失败时下一分钟再试
+func (f *Funding) settle() {
+	added, err := f.SyncIncomes()
+	if err != nil {
+		Logger.Error("sync funding income failed", zap.String("symbol", f.Symbol), zap.Error(err))
+		f.Lock()
+		f.settled = time.Time{}
+		f.Unlock()
+		return
+	}
+	for _, v := range added {
+		Logger.Sugar().Infof("资金费用 %v %v %v 时间 : %v 累计净收入 : %v", v.Symbol, v.Amount, v.Asset, v.Time, f.Net())
+	}
+}
+
+// 查询资金费率和历史流水,订阅标记价格推送
+func (s *Strategy) InitFunding() {
+	if s.Funding != nil || s.Exchange == nil {
+		return
+	}
+	s.Funding = NewFunding(s.Exchange, s.Symbol)
+	if err := s.Funding.Refresh(); err != nil {
+		Logger.Warn("get funding rate failed", zap.String("symbol", s.Symbol), zap.Error(err))
+	}
+	if _, err := s.Funding.SyncIncomes(); err != nil {
+		Logger.Warn("get funding income failed", zap.String("symbol", s.Symbol), zap.Error(err))
+	}
+	Logger.Sugar().Infof("%v 资金费用 收取 : %v 支付 : %v", s.Symbol, s.Funding.Received, s.Funding.Paid)
+	s.MarkPriceWs, _ = s.Exchange.GetMarkPriceWs(s.Symbol)
+}
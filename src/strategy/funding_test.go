@@ -0,0 +1,121 @@
+package strategy_test
+
+import (
+	"testing"
+	"time"
+	"tinyquant/src/mod"
+	"tinyquant/src/quant"
+	"tinyquant/src/strategy"
+	"tinyquant/src/util"
+)
+
+// 只实现资金费率相关接口的交易所
+type fundingExchange struct {
+	quant.Exchange
+	rate    *mod.FundingRate
+	incomes []*mod.Income
+	calls   int
+}
+
+func (e *fundingExchange) GetFundingRate(symbol string) (*mod.FundingRate, error) {
+	return e.rate, nil
+}
+
+// 和交易所一样,开始时间包含在范围内
+func (e *fundingExchange) GetIncomes(symbol string, incomeType mod.IncomeType, start time.Time) ([]*mod.Income, error) {
+	e.calls++
+	var res []*mod.Income
+	for _, v := range e.incomes {
+		if !v.Time.Before(start) && v.Type == incomeType {
+			res = append(res, v)
+		}
+	}
+	return res, nil
+}
+
+func Test_FundingIncomes(t *testing.T) {
+	settle := time.Now().Truncate(8 * time.Hour)
+	ex := &fundingExchange{incomes: []*mod.Income{
+		{Symbol: "ETHUSDT", Type: mod.IncomeFundingFee, Amount: -0.5, TranID: 1, Time: settle.Add(-16 * time.Hour)},
+		{Symbol: "ETHUSDT", Type: mod.IncomeCommission, Amount: -0.1, TranID: 2, Time: settle.Add(-10 * time.Hour)},
+		{Symbol: "ETHUSDT", Type: mod.IncomeFundingFee, Amount: 0.2, TranID: 3, Time: settle.Add(-8 * time.Hour)},
+	}}
+	f := strategy.NewFunding(ex, "ETHUSDT")
+	if added, err := f.SyncIncomes(); err != nil || len(added) != 2 {
+		t.Fatalf("sync %v %v", added, err)
+	}
+
+	// 最后一条会再次返回,不能重复记录
+	ex.incomes = append(ex.incomes, &mod.Income{Symbol: "ETHUSDT", Type: mod.IncomeFundingFee, Amount: -0.3, TranID: 4, Time: settle})
+	if added, _ := f.SyncIncomes(); len(added) != 1 || added[0].TranID != 4 {
+		t.Fatalf("second sync %v", added)
+	}
+	if f.Received != 0.2 || f.Paid != 0.8 || len(f.Incomes) != 3 {
+		t.Errorf("received %v paid %v incomes %v", f.Received, f.Paid, len(f.Incomes))
+	}
+	if net := f.Net(); net > -0.6+1e-9 || net < -0.6-1e-9 {
+		t.Errorf("net %v", net)
+	}
+}
+
+func Test_FundingSettled(t *testing.T) {
+	next := time.Date(2021, 5, 1, 8, 0, 0, 0, time.UTC)
+	f := strategy.NewFunding(&fundingExchange{}, "ETHUSDT")
+	f.Update(&mod.FundingRate{Rate: 0.0001, NextFundingTime: next, Time: next.Add(-time.Minute)})
+	if f.Settled(next.Add(-time.Second)) {
+		t.Fatal("settled before funding time")
+	}
+
+	// 结算后推送的下次结算时间后移,一分钟后查询流水,只查一次
+	f.Update(&mod.FundingRate{Rate: 0.0002, NextFundingTime: next.Add(8 * time.Hour), Time: next.Add(time.Second)})
+	if f.Settled(next.Add(30 * time.Second)) {
+		t.Error("settled before income recorded")
+	}
+	if !f.Settled(next.Add(time.Minute)) || f.Settled(next.Add(2*time.Minute)) {
+		t.Error("settlement not reported once")
+	}
+
+	// 推送断开,下次结算时间没有更新时按时间判断
+	if !f.Settled(next.Add(8*time.Hour + 5*time.Minute)) {
+		t.Error("settlement with stale rate")
+	}
+
+	// 旧的推送不覆盖新的
+	f.Update(&mod.FundingRate{Rate: 0.0003, NextFundingTime: next, Time: next})
+	if r, _ := f.Current(); r.Rate != 0.0002 {
+		t.Errorf("rate %v", r.Rate)
+	}
+}
+
+func Test_FundingVeto(t *testing.T) {
+	now := time.Date(2021, 5, 1, 7, 55, 0, 0, time.UTC)
+	param := util.FundingParam{Enable: true, Window: 600, MaxRate: 0.0001}
+	ex := &fundingExchange{rate: &mod.FundingRate{Rate: 0.0005, NextFundingTime: now.Add(5 * time.Minute), Time: now}}
+	f := strategy.NewFunding(ex, "ETHUSDT")
+	if f.Veto(mod.LONG, now, param) != "" {
+		t.Fatal("veto without rate")
+	}
+	if err := f.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 费率为正时多头支付,空头收取
+	if f.Veto(mod.LONG, now, param) == "" {
+		t.Error("long not vetoed")
+	}
+	if f.Veto(mod.SHORT, now, param) != "" {
+		t.Error("short vetoed")
+	}
+	if f.Veto(mod.LONG, now.Add(-10*time.Minute), param) != "" {
+		t.Error("vetoed outside window")
+	}
+
+	f.Update(&mod.FundingRate{Rate: -0.0002, NextFundingTime: now.Add(5 * time.Minute), Time: now.Add(time.Second)})
+	if f.Veto(mod.SHORT, now, param) == "" || f.Veto(mod.LONG, now, param) != "" {
+		t.Error("negative rate")
+	}
+	param.MaxRate = 0.0003
+	if f.Veto(mod.SHORT, now, param) != "" {
+		t.Error("vetoed under max rate")
+	}
+}
@@ -0,0 +1,156 @@
+package strategy
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	. "tinyquant/src/logger"
+	"tinyquant/src/mod"
+	quant "tinyquant/src/quant"
+	"tinyquant/src/util"
+
+	"go.uber.org/zap"
+)
+
+// 同一进程内运行多个交易对的策略
+// 每个交易对有自己的客户端和K线推送,账户推送只订阅一次,按交易对分发给各个策略
+type Manager struct {
+	Strategies map[string]*Strategy
+	Exchange   quant.Exchange // 订阅账户推送的客户端
+	Dropped    map[string]int // 策略处理不过来被丢弃的账户推送数量,按交易对
+}
+
+func (m *Manager) Init(symbols []string) error {
+	if len(symbols) == 0 {
+		return errors.New("no symbol")
+	}
+	m.Strategies = make(map[string]*Strategy)
+
+	//模拟盘所有交易对共用一个本地撮合的账户
+	var shared quant.Exchange
+	client := func() (quant.Exchange, error) {
+		if shared != nil {
+			return shared, nil
+		}
+		b, err := NewClient(util.Futures)
+		if err == nil && util.Paper {
+			shared = b
+		}
+		return b, err
+	}
+
+	Logger.Sugar().Infof("tinyquant %s 启动 交易对 : %v", util.Futures, symbols)
+	for _, symbol := range symbols {
+		if _, ok := m.Strategies[symbol]; ok {
+			return fmt.Errorf("duplicate symbol : %v", symbol)
+		}
+		b, err := client()
+		if err != nil {
+			return err
+		}
+		s := &Strategy{RWMutex: &sync.RWMutex{}}
+		if err := s.InitStrategy(b, symbol); err != nil {
+			return fmt.Errorf("init %v failed : %v", symbol, err)
+		}
+		m.Strategies[symbol] = s
+	}
+
+	b, err := client()
+	if err != nil {
+		return err
+	}
+	m.Exchange = b
+	return nil
+}
+
+// 启动所有策略并分发账户推送,推送断开时返回
+func (m *Manager) Run() error {
+	accWs, _ := m.Exchange.GetAccountWs()
+	for _, ex := range m.exchanges() {
+		go m.dispatchStreamEvents(ex)
+	}
+	for _, s := range m.Strategies {
+		go s.StrategyLoop(false)
+	}
+	for ev := range accWs {
+		m.Dispatch(ev)
+	}
+	return errors.New("account ws closed")
+}
+
+// 账户客户端和各个策略的客户端,模拟盘共用同一个
+func (m *Manager) exchanges() []quant.Exchange {
+	res := []quant.Exchange{m.Exchange}
+	for _, s := range m.Strategies {
+		dup := false
+		for _, ex := range res {
+			dup = dup || ex == s.Exchange
+		}
+		if !dup {
+			res = append(res, s.Exchange)
+		}
+	}
+	return res
+}
+
+// 账户客户端的连接状态发给所有策略,行情连接状态只发给使用这个客户端的策略
+func (m *Manager) dispatchStreamEvents(ex quant.Exchange) {
+	ch := ex.GetStreamEvents()
+	if ch == nil {
+		return
+	}
+	for ev := range ch {
+		for _, s := range m.Strategies {
+			if ex != m.Exchange && ex != s.Exchange {
+				continue
+			}
+			select {
+			case s.StreamWs <- ev:
+			default:
+				Logger.Warn("stream event dropped", zap.String("symbol", s.Symbol), zap.String("stream", ev.Stream))
+			}
+		}
+	}
+}
+
+// 订单推送只发给对应的交易对,仓位按交易对拆分,余额每个策略都需要
+func (m *Manager) Dispatch(ev *mod.AccountEvent) {
+	switch ev.EventName {
+	case util.ORDER_TRADE_UPDATE:
+		s, ok := m.Strategies[ev.Order.Symbol]
+		if !ok {
+			Logger.Debug("skip order of unknown symbol", zap.String("symbol", ev.Order.Symbol))
+			return
+		}
+		m.send(s, ev)
+	case util.ACCOUNT_UPDATE:
+		for symbol, s := range m.Strategies {
+			update := &mod.AccountUpdate{
+				Reason:   ev.Account.Reason,
+				Balances: ev.Account.Balances,
+			}
+			for _, p := range ev.Account.Positions {
+				if p.Symbol == symbol {
+					update.Positions = append(update.Positions, p)
+				}
+			}
+			m.send(s, &mod.AccountEvent{EventName: ev.EventName, Time: ev.Time, Account: update})
+		}
+	default:
+		Logger.Sugar().Infof("account event : %v", ev.EventName)
+	}
+}
+
+// 不阻塞分发,一个策略卡住时其他交易对照常收到推送,丢弃的挂单和仓位由定时对账恢复
+func (m *Manager) send(s *Strategy, ev *mod.AccountEvent) {
+	select {
+	case s.AccWs <- ev:
+	default:
+		if m.Dropped == nil {
+			m.Dropped = make(map[string]int)
+		}
+		m.Dropped[s.Symbol]++
+		Logger.Warn("account event dropped", zap.String("symbol", s.Symbol), zap.String("event", ev.EventName),
+			zap.Int("dropped", m.Dropped[s.Symbol]))
+	}
+}
@@ -0,0 +1,68 @@
+package strategy_test
+
+import (
+	"sync"
+	"testing"
+	"tinyquant/src/mod"
+	"tinyquant/src/quant"
+	"tinyquant/src/strategy"
+	"tinyquant/src/util"
+)
+
+func Test_ManagerDispatch(t *testing.T) {
+	eth := &strategy.Strategy{Symbol: "ETHUSDT", AccWs: make(chan *mod.AccountEvent, 1)}
+	sol := &strategy.Strategy{Symbol: "SOLUSDT", AccWs: make(chan *mod.AccountEvent, 1)}
+	m := &strategy.Manager{Strategies: map[string]*strategy.Strategy{eth.Symbol: eth, sol.Symbol: sol}}
+	order := func(symbol string) *mod.AccountEvent {
+		return &mod.AccountEvent{EventName: util.ORDER_TRADE_UPDATE, Order: &mod.OrderUpdate{FutureOrder: mod.FutureOrder{Symbol: symbol}}}
+	}
+
+	// ETHUSDT 没有读取,第二条丢弃,不影响 SOLUSDT
+	m.Dispatch(order("ETHUSDT"))
+	m.Dispatch(order("ETHUSDT"))
+	m.Dispatch(order("SOLUSDT"))
+	if len(sol.AccWs) != 1 || len(eth.AccWs) != 1 {
+		t.Fatalf("queued eth %d sol %d", len(eth.AccWs), len(sol.AccWs))
+	}
+	if m.Dropped["ETHUSDT"] != 1 || m.Dropped["SOLUSDT"] != 0 {
+		t.Errorf("dropped %v", m.Dropped)
+	}
+
+	<-sol.AccWs
+	m.Dispatch(&mod.AccountEvent{EventName: util.ACCOUNT_UPDATE, Account: &mod.AccountUpdate{}})
+	if ev := <-sol.AccWs; ev.EventName != util.ACCOUNT_UPDATE || m.Dropped["ETHUSDT"] != 2 {
+		t.Errorf("account update %+v dropped %v", ev, m.Dropped)
+	}
+}
+
+// 只实现余额和下单规则的交易所
+type assetExchange struct {
+	quant.Exchange
+	margin string
+}
+
+func (e *assetExchange) GetSymbolInfo(symbol string) (*mod.SymbolInfo, error) {
+	return &mod.SymbolInfo{Symbol: symbol, MarginAsset: e.margin, TickSize: 0.01, StepSize: 0.001}, nil
+}
+
+func (e *assetExchange) GetFutureBalance() ([]*mod.Balance, error) {
+	return []*mod.Balance{{Asset: "USDT", Balance: 100}, {Asset: "USDC", Balance: 200}, {Asset: "ETH", Balance: 3}}, nil
+}
+
+func Test_LoadAccountAsset(t *testing.T) {
+	for _, c := range []struct {
+		symbol, margin string
+		want           float64
+	}{
+		{"SOLUSDC", "USDC", 200},
+		{"SOLUSD_PERP", "SOL", 0},
+		// 交易所没有返回保证金币种时按配置
+		{util.COIN_ETHUSD, "", 3},
+		{"SOLUSDT", "", 100},
+	} {
+		acc := &strategy.BinanceFutureAsset{RWMutex: &sync.RWMutex{}, Symbol: c.symbol, Exchange: &assetExchange{margin: c.margin}}
+		if err := acc.LoadAccount(); err != nil || acc.Balance != c.want {
+			t.Errorf("%v balance %v want %v %v", c.symbol, acc.Balance, c.want, err)
+		}
+	}
+}
@@ -5,21 +5,63 @@ import (
 	"strconv"
 	"sync"
 	"time"
+	"tinyquant/src/indicator"
 	. "tinyquant/src/logger"
+	"tinyquant/src/mod"
+	"tinyquant/src/quant"
+	"tinyquant/src/series"
+	"tinyquant/src/util"
 
-	"github.com/rootpd/binance"
 	"go.uber.org/zap"
 )
 
+// 多周期本地K线,周期和根数来自配置
+// 启动时用 REST 加载一次,之后由推送更新,K线不连续或推送重连后在后台重新加载对应周期
 type Market struct {
-	Last24HHigh float64
-	Las24HLow   float64
+	Exchange quant.Exchange
+	Symbol   string
 
-	MinuteKlineList        *MyKlineQueue // 近60条k线, 分钟线
-	FifteenMinuteKlineList *MyKlineQueue
-	OneHourKlineList       *MyKlineQueue
-	FourHourKlineList      *MyKlineQueue
-	DayKlineList           *MyKlineQueue
+	MinuteKlineList *MyKlineQueue // 分钟线,一定存在,当前价格从这里取
+
+	intervals   []mod.Interval
+	queues      map[mod.Interval]*MyKlineQueue
+	mutex       sync.Mutex
+	backfilling map[mod.Interval]bool
+}
+
+// 推送的K线和所属周期
+type KlineEvent struct {
+	Interval mod.Interval
+	Kline    *mod.Kline
+}
+
+// 没有配置根数时的默认值
+const defaultKlineLength = 60
+
+// 按配置创建本地K线,重复和不认识的周期忽略,没有配置分钟线时自动加上
+func NewMarket(ex quant.Exchange, symbol string, params []util.KlineParam) *Market {
+	m := &Market{
+		Exchange:    ex,
+		Symbol:      symbol,
+		queues:      make(map[mod.Interval]*MyKlineQueue),
+		backfilling: make(map[mod.Interval]bool),
+	}
+	add := func(interval mod.Interval, length int) {
+		if _, ok := m.queues[interval]; ok || interval.Duration() == 0 {
+			return
+		}
+		if length <= 0 {
+			length = defaultKlineLength
+		}
+		m.intervals = append(m.intervals, interval)
+		m.queues[interval] = NewQueue(length)
+	}
+	for _, p := range params {
+		add(mod.Interval(p.Interval), p.Length)
+	}
+	add(mod.Minute, defaultKlineLength)
+	m.MinuteKlineList = m.queues[mod.Minute]
+	return m
 }
 
 type Kline struct {
@@ -37,312 +79,337 @@ type UpDownLink struct {
 	DownLink           float64
 	MaxHigh            float64
 	MinLow             float64
-	MaxHighIndex       int
+	MaxHighIndex       int // 按时间顺序的下标,0 是最旧的一根
 	MinLowIndex        int
 	AvgVolume          float64
 	MaxVolume          float64
 	AvgPrice           float64
-	HalfSampleAvgPrice float64
+	HalfSampleAvgPrice float64 // 最新一半K线的平均成交量
 }
 
-func (m *Market) InitMarket(symbol string) error {
-	m.MinuteKlineList.Clear()
-	m.FifteenMinuteKlineList.Clear()
-	// m.OneHourKlineList.Clear()
-	m.FourHourKlineList.Clear()
-	// m.DayKlineList.Clear()
-
-	//	Logger.Info("初始化 k 线 ")
-	// 获取 近5天日线
-	// daylist, err := Binance.GetFutureKlines(symbol, 30, binance.Day)
-	// if err != nil {
-	// 	Logger.Error("Get day future kline failed", zap.Error(err))
-	// 	return err
-	// }
-
-	// m.Las24HLow = daylist[29].Low
-	// m.Last24HHigh = daylist[29].High
-
-	FourHourlist, err := Binance.GetFutureKlines(symbol, m.FourHourKlineList.Capacity, binance.FourHours) // 30天
-	if err != nil {
-		Logger.Error("Get 4/6*30 hour future kline failed", zap.Error(err))
-		return err
-	}
+// 周期对应的本地K线,没有维护的周期返回 nil
+func (m *Market) Queue(interval mod.Interval) *MyKlineQueue {
+	return m.queues[interval]
+}
 
-	// OneHourList, err := Binance.GetFutureKlines(symbol, m.OneHourKlineList.Capacity, binance.Hour) //24小时
-	// if err != nil {
-	// 	Logger.Error("Get 1/24 hour future kline failed", zap.Error(err))
-	// 	return err
-	// }
+// 维护的周期,按配置顺序
+func (m *Market) Intervals() []mod.Interval {
+	return append([]mod.Interval{}, m.intervals...)
+}
 
-	FifteenMinuteKlineList, err := Binance.GetFutureKlines(symbol, m.FifteenMinuteKlineList.Capacity, binance.FifteenMinutes) // 15分钟 4小时
-	if err != nil {
-		Logger.Error("Get 15/4*4 minute future kline failed", zap.Error(err))
-		return err
+// 用 REST 加载所有周期
+func (m *Market) InitMarket(symbol string) error {
+	m.Symbol = symbol
+	for _, interval := range m.intervals {
+		if err := m.load(interval); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	Minutelist, err := Binance.GetFutureKlines(symbol, m.MinuteKlineList.Capacity, binance.Minute) //分钟 一小时
+func (m *Market) load(interval mod.Interval) error {
+	queue := m.queues[interval]
+	list, err := m.Exchange.GetFutureKlines(m.Symbol, queue.Capacity, interval)
 	if err != nil {
-		Logger.Error("Get 1/60 minute future kline failed", zap.Error(err))
+		Logger.Error("Get future kline failed", zap.String("interval", string(interval)), zap.Error(err))
 		return err
 	}
-
-	// for _, v := range daylist {
-	// 	m.DayKlineList.EnQqueu(&Kline{
-	// 		Open:      v.Open,
-	// 		Close:     v.Close,
-	// 		High:      v.High,
-	// 		Low:       v.Low,
-	// 		Volume:    v.Volume,
-	// 		BuyVolume: v.TakerBuyBaseAssetVolume,
-	// 	})
-	// }
-
-	for _, v := range FourHourlist {
-		m.FourHourKlineList.EnQqueu(&Kline{
-			Open:      v.Open,
-			Close:     v.Close,
-			High:      v.High,
-			Low:       v.Low,
-			Volume:    v.Volume,
-			BuyVolume: v.TakerBuyBaseAssetVolume,
-			CloseTime: v.CloseTime,
-		})
-	}
-
-	// for _, v := range OneHourList {
-	// 	m.OneHourKlineList.EnQqueu(&Kline{
-	// 		Open:      v.Open,
-	// 		Close:     v.Close,
-	// 		High:      v.High,
-	// 		Low:       v.Low,
-	// 		Volume:    v.Volume,
-	// 		BuyVolume: v.TakerBuyBaseAssetVolume,
-	// 	})
-	// }
-
-	for _, v := range FifteenMinuteKlineList {
-		m.FifteenMinuteKlineList.EnQqueu(&Kline{
+	klines := make([]*Kline, 0, len(list))
+	for _, v := range list {
+		klines = append(klines, &Kline{
 			Open:      v.Open,
 			Close:     v.Close,
 			High:      v.High,
 			Low:       v.Low,
 			Volume:    v.Volume,
-			BuyVolume: v.TakerBuyBaseAssetVolume,
+			BuyVolume: v.BuyVolume,
 			CloseTime: v.CloseTime,
 		})
-		Logger.Sugar().Debugf("%+v", v)
 	}
-	for _, v := range Minutelist {
-		m.MinuteKlineList.EnQqueu(&Kline{
-			Open:      v.Open,
-			Close:     v.Close,
-			High:      v.High,
-			Low:       v.Low,
-			Volume:    v.Volume,
-			BuyVolume: v.TakerBuyBaseAssetVolume,
-			CloseTime: v.CloseTime,
-		})
-		Logger.Sugar().Debugf("%+v", v)
-	}
-	m.MinuteKlineList.UpdateUpDownLink(true)
-	m.FifteenMinuteKlineList.UpdateUpDownLink(true)
-	// m.OneHourKlineList.UpdateUpDownLink(true)
-	m.FourHourKlineList.UpdateUpDownLink(true)
-	// m.DayKlineList.UpdateUpDownLink(true)
-
+	queue.Reload(klines)
+	queue.UpdateUpDownLink(true)
 	return nil
 }
 
-func (m *Market) UpdateKlineListTicker(symbol string) {
-
-	ticker := time.NewTicker(1 * time.Minute)
-
-	defer ticker.Stop()
+// 订阅所有周期的K线推送,合并到一个通道
+func (m *Market) Subscribe() chan *KlineEvent {
+	out := make(chan *KlineEvent)
+	for _, interval := range m.intervals {
+		go func(interval mod.Interval, in chan *mod.Kline) {
+			for k := range in {
+				out <- &KlineEvent{Interval: interval, Kline: k}
+			}
+		}(interval, m.Exchange.GetKlineWs(m.Symbol, interval))
+	}
+	return out
+}
 
-	for {
-		<-ticker.C
-		//Logger.Info("开始更新 K 线")
+// 推送的K线和本地最后一根之间缺了K线
+func (m *Market) Gap(interval mod.Interval, k *mod.Kline) bool {
+	queue := m.Queue(interval)
+	if queue == nil {
+		return false
+	}
+	last, ok := queue.lastCloseTime()
+	if !ok {
+		return false
+	}
+	// 月线长度不固定,超过1.5个周期才算缺失
+	return k.CloseTime.Sub(last) > interval.Duration()*3/2
+}
 
-		m.InitMarket(symbol)
+// 在后台重新加载一个周期,同一周期同时只加载一次
+func (m *Market) Backfill(interval mod.Interval) {
+	if m.Queue(interval) == nil {
+		return
+	}
+	m.mutex.Lock()
+	if m.backfilling[interval] {
+		m.mutex.Unlock()
+		return
+	}
+	m.backfilling[interval] = true
+	m.mutex.Unlock()
+
+	go func() {
+		defer func() {
+			m.mutex.Lock()
+			m.backfilling[interval] = false
+			m.mutex.Unlock()
+		}()
+		if err := m.load(interval); err == nil {
+			Logger.Info("kline backfilled", zap.String("symbol", m.Symbol), zap.String("interval", string(interval)))
+		}
+	}()
+}
 
+// 推送重连后重新加载所有周期
+func (m *Market) BackfillAll() {
+	for _, interval := range m.intervals {
+		m.Backfill(interval)
 	}
 }
 
+// 按时间排列的K线,满了以后覆盖最旧的一根
 type MyKlineQueue struct {
-	Data     []*Kline
+	ring     *series.Ring
 	Capacity int
-	Head     int //队头
-	Full     bool
 	*sync.RWMutex
 	*UpDownLink
+	indicators []indicator.Indicator //收盘的K线依次更新,读取时需要持有读锁
 }
 
 func NewQueue(len int) *MyKlineQueue {
-	return &MyKlineQueue{Capacity: len, Head: -1, Data: make([]*Kline, len), UpDownLink: &UpDownLink{}, RWMutex: &sync.RWMutex{}}
+	ring := series.NewRing(len)
+	return &MyKlineQueue{Capacity: ring.Cap(), ring: ring, UpDownLink: &UpDownLink{}, RWMutex: &sync.RWMutex{}}
 }
 
-// 插入
+// 插入,收盘时间相同的替换最新一根,比最新一根旧的忽略
 func (queue *MyKlineQueue) EnQqueu(val *Kline) {
 	queue.Lock()
 	defer queue.Unlock()
-	if queue.IsEmpty() {
-		queue.Head = 0
-	} else if queue.Full {
-		if queue.Data[queue.Head].CloseTime.Equal(val.CloseTime) {
-			queue.Data[queue.Head] = val
-			return
-		} else if queue.Data[queue.Head].CloseTime.After(val.CloseTime) {
-			return
-		}
-		queue.Head = (queue.Head + 1) % queue.Capacity
-	} else {
-		queue.Head = queue.Head + 1
-		if queue.Head == queue.Capacity-2 {
-			queue.Full = true
+	queue.enqueue(val)
+}
+
+// 替换全部K线,指标重新计算
+func (queue *MyKlineQueue) Reload(klines []*Kline) {
+	queue.Lock()
+	defer queue.Unlock()
+	queue.ring.Clear()
+	for _, ind := range queue.indicators {
+		ind.Reset()
+	}
+	for _, k := range klines {
+		queue.enqueue(k)
+	}
+}
+
+// 挂上指标并用已有的K线预热,最新一根还没有收盘,不计算
+// 之后每当下一根K线到达时用收盘的上一根更新
+func (queue *MyKlineQueue) Attach(inds ...indicator.Indicator) {
+	queue.Lock()
+	defer queue.Unlock()
+	klines := queue.ordered()
+	for _, ind := range inds {
+		ind.Reset()
+		for i := 0; i < len(klines)-1; i++ {
+			ind.Update(klines[i].bar())
 		}
 	}
-	queue.Data[queue.Head] = val
+	queue.indicators = append(queue.indicators, inds...)
+}
+
+// 全部K线,从旧到新
+func (queue *MyKlineQueue) Klines() []*Kline {
+	queue.RLock()
+	defer queue.RUnlock()
+	return queue.ordered()
+}
+
+// 最新的 n 根K线,从旧到新,不足 n 根时返回全部
+func (queue *MyKlineQueue) Last(n int) []*Kline {
+	queue.RLock()
+	defer queue.RUnlock()
+	return toKlines(queue.ring.Last(n))
+}
+
+// 下标在 [from, to) 的K线,0 是最旧的一根
+func (queue *MyKlineQueue) Window(from, to int) []*Kline {
+	queue.RLock()
+	defer queue.RUnlock()
+	return toKlines(queue.ring.Window(from, to))
+}
+
+// 第 i 根K线,0 是最旧的一根,越界时返回 nil
+func (queue *MyKlineQueue) At(i int) *Kline {
+	queue.RLock()
+	defer queue.RUnlock()
+	k, _ := queue.ring.At(i).(*Kline)
+	return k
+}
+
+func (queue *MyKlineQueue) Len() int {
+	queue.RLock()
+	defer queue.RUnlock()
+	return queue.ring.Len()
+}
+
+// 从旧到新的K线,调用方持有锁
+func (queue *MyKlineQueue) ordered() []*Kline {
+	return toKlines(queue.ring.Slice())
+}
+
+func toKlines(vs []interface{}) []*Kline {
+	if len(vs) == 0 {
+		return nil
+	}
+	res := make([]*Kline, len(vs))
+	for i, v := range vs {
+		res[i] = v.(*Kline)
+	}
+	return res
+}
+
+func (k *Kline) bar() indicator.Bar {
+	return indicator.Bar{High: k.High, Low: k.Low, Close: k.Close, Volume: k.Volume}
+}
+
+func (queue *MyKlineQueue) newest() *Kline {
+	k, _ := queue.ring.Newest().(*Kline)
+	return k
+}
+
+func (queue *MyKlineQueue) lastCloseTime() (time.Time, bool) {
+	queue.RLock()
+	defer queue.RUnlock()
+	if last := queue.newest(); last != nil {
+		return last.CloseTime, true
+	}
+	return time.Time{}, false
+}
+
+func (queue *MyKlineQueue) enqueue(val *Kline) {
+	last := queue.newest()
+	if last == nil {
+		queue.ring.Push(val)
+		return
+	}
+	if last.CloseTime.Equal(val.CloseTime) {
+		queue.ring.SetNewest(val)
+		return
+	} else if last.CloseTime.After(val.CloseTime) {
+		return
+	}
+	// 下一根K线到达,上一根已经收盘
+	for _, ind := range queue.indicators {
+		ind.Update(last.bar())
+	}
+	queue.ring.Push(val)
 }
 
 //判满
 func (queue *MyKlineQueue) IsFull() bool {
-	return queue.Head+1 == queue.Capacity
+	queue.RLock()
+	defer queue.RUnlock()
+	return queue.ring.Full()
 }
 
 //判断空
 func (queue *MyKlineQueue) IsEmpty() bool {
-	return queue.Head == -1
+	queue.RLock()
+	defer queue.RUnlock()
+	return queue.ring.Len() == 0
 }
 
 func (queue *MyKlineQueue) Clear() {
-	queue.Head = -1
+	queue.Lock()
+	defer queue.Unlock()
+	queue.ring.Clear()
 }
 
 func (queue *MyKlineQueue) GetUpDownLink() *UpDownLink {
 	queue.RLock()
 	defer queue.RUnlock()
-	return &UpDownLink{
-		UpLink:             queue.DownLink,
-		DownLink:           queue.DownLink,
-		MaxHigh:            queue.MaxHigh,
-		MinLow:             queue.MinLow,
-		MaxHighIndex:       queue.MaxHighIndex,
-		MinLowIndex:        queue.MinLowIndex,
-		AvgVolume:          queue.AvgVolume,
-		MaxVolume:          queue.MaxVolume,
-		AvgPrice:           queue.AvgPrice,
-		HalfSampleAvgPrice: queue.HalfSampleAvgPrice,
-	}
+	upl := *queue.UpDownLink
+	return &upl
 }
 
+// 按时间顺序统计上下行线和均量,lastValid 为 false 时最新一根还没有收盘,不参与统计
+// 半采样取最新的一半K线
 func (queue *MyKlineQueue) UpdateUpDownLink(lastValid bool) {
-	if !queue.Full {
+	queue.Lock()
+	defer queue.Unlock()
+	if !queue.ring.Full() {
 		return
 	}
+	klines := queue.ordered()
+	if !lastValid {
+		klines = klines[:len(klines)-1]
+	}
+	if len(klines) == 0 {
+		return
+	}
+
 	High := 0.0
 	Low := 0.0
-	max_High := 0.0
+	max_High := klines[0].High
 	max_High_index := 0
-	min_Low := 0.0
+	min_Low := klines[0].Low
 	min_Low_index := 0
-	avg_High := 0.0
-	avg_Low := 0.0
-
 	Volume := 0.0
 	HalfSampleVolume := 0.0
 	MaxVolume := 0.0
-
 	avgPrice := 0.0
 
-	queue.Lock()
-	if lastValid {
-		j := queue.Capacity / 2
-		for k := 0; k < queue.Capacity; k++ {
-			kl := queue.Data[k]
-			if kl.Volume > MaxVolume {
-				MaxVolume = kl.Volume
-			}
-			High += kl.High
-			Low += kl.Low
-			avgPrice += kl.Close
-
-			if kl.High > max_High {
-				max_High = kl.High
-				max_High_index = k
-			}
-			if kl.Low < min_Low {
-				min_Low = kl.Low
-				min_Low_index = k
-			}
-			Volume += kl.Volume
-			if k >= j {
-				HalfSampleVolume += kl.Volume
-			}
+	half := len(klines) - len(klines)/2
+	for k, kl := range klines {
+		if kl.Volume > MaxVolume {
+			MaxVolume = kl.Volume
 		}
-	} else {
-		i := 0
-		j := queue.Capacity / 2
-		for k := queue.Head + 1; k < queue.Capacity; k++ {
-			i++
-			kl := queue.Data[k]
-			if kl.Volume > MaxVolume {
-				MaxVolume = kl.Volume
-			}
-			High += kl.High
-			Low += kl.Low
-			avgPrice += kl.Close
-			if kl.High > max_High {
-				max_High = kl.High
-				max_High_index = k
-			}
-			if kl.Low < min_Low {
-				min_Low = kl.Low
-				min_Low_index = k
-			}
-			Volume += kl.Volume
-			if i >= j {
-				HalfSampleVolume += kl.Volume
-			}
+		High += kl.High
+		Low += kl.Low
+		avgPrice += kl.Close
+		if kl.High > max_High {
+			max_High = kl.High
+			max_High_index = k
 		}
-		for k := 0; k < queue.Head; k++ {
-			i++
-			kl := queue.Data[k]
-			if kl.Volume > MaxVolume {
-				MaxVolume = kl.Volume
-			}
-			High += kl.High
-			Low += kl.Low
-			avgPrice += kl.Close
-			if kl.High > max_High {
-				max_High = kl.High
-				max_High_index = k
-			}
-			if kl.Low < min_Low {
-				min_Low = kl.Low
-				min_Low_index = k
-			}
-			Volume += kl.Volume
-			if i >= j {
-				HalfSampleVolume += kl.Volume
-			}
+		if kl.Low < min_Low {
+			min_Low = kl.Low
+			min_Low_index = k
+		}
+		Volume += kl.Volume
+		if k >= half {
+			HalfSampleVolume += kl.Volume
 		}
 	}
 
-	var avgVolume float64
+	n := float64(len(klines))
+	avg_High := High / n
+	avg_Low := Low / n
 	var halfSampleAvgVolume float64
-	if lastValid {
-		avg_High = High / float64(queue.Capacity)
-		avg_Low = Low / float64(queue.Capacity)
-		avgPrice = avgPrice / float64(queue.Capacity)
-		avgVolume = Volume / float64(queue.Capacity)
-		halfSampleAvgVolume = HalfSampleVolume / float64(queue.Capacity/2)
-	} else {
-		avg_High = High / float64(queue.Capacity-1)
-		avg_Low = Low / float64(queue.Capacity-1)
-		avgPrice = avgPrice / float64(queue.Capacity-1)
-		avgVolume = Volume / float64(queue.Capacity-1)
-		halfSampleAvgVolume = HalfSampleVolume / float64((queue.Capacity/2)-1)
+	if m := len(klines) / 2; m > 0 {
+		halfSampleAvgVolume = HalfSampleVolume / float64(m)
 	}
 
 	queue.UpLink = (avg_High + max_High) / 2 // 上行线
@@ -354,18 +421,18 @@ func (queue *MyKlineQueue) UpdateUpDownLink(lastValid bool) {
 	queue.MinLow = min_Low
 	queue.MaxHighIndex = max_High_index
 	queue.MinLowIndex = min_Low_index
-	queue.AvgVolume = avgVolume
+	queue.AvgVolume = Volume / n
 	queue.MaxVolume = MaxVolume
-	queue.AvgPrice = avgPrice
+	queue.AvgPrice = avgPrice / n
 	queue.HalfSampleAvgPrice = halfSampleAvgVolume
-	queue.Unlock()
 }
 
+// 最新价格,没有K线时返回 -1
 func (queue *MyKlineQueue) GetNewPrice() float64 {
-	if !queue.Full {
-		return -1
-	}
 	queue.RLock()
 	defer queue.RUnlock()
-	return queue.Data[queue.Head].Close
+	if last := queue.newest(); last != nil {
+		return last.Close
+	}
+	return -1
 }
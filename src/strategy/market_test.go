@@ -0,0 +1,151 @@
+package strategy_test
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+	"tinyquant/src/indicator"
+	"tinyquant/src/mod"
+	"tinyquant/src/strategy"
+	"tinyquant/src/util"
+)
+
+func Test_MarketIntervals(t *testing.T) {
+	m := strategy.NewMarket(nil, "ETHUSDT", []util.KlineParam{
+		{Interval: "15m", Length: 16},
+		{Interval: "15m", Length: 30},
+		{Interval: "7x"},
+		{Interval: "4h"},
+	})
+	if got := m.Intervals(); len(got) != 3 || got[0] != mod.FifteenMinutes || got[2] != mod.Minute {
+		t.Fatalf("intervals %v", got)
+	}
+	if m.Queue(mod.FifteenMinutes).Capacity != 16 || m.Queue(mod.FourHours).Capacity != 60 || m.MinuteKlineList == nil {
+		t.Error("queue length")
+	}
+	if m.Queue(mod.Day) != nil {
+		t.Error("queue for unconfigured interval")
+	}
+
+	start := time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC)
+	closeAt := func(i int) time.Time { return start.Add(time.Duration(i)*15*time.Minute - time.Millisecond) }
+	m.Queue(mod.FifteenMinutes).Reload([]*strategy.Kline{{CloseTime: closeAt(1)}, {CloseTime: closeAt(2)}})
+
+	if m.Gap(mod.FifteenMinutes, &mod.Kline{CloseTime: closeAt(2)}) || m.Gap(mod.FifteenMinutes, &mod.Kline{CloseTime: closeAt(3)}) {
+		t.Error("continuous kline reported as gap")
+	}
+	if !m.Gap(mod.FifteenMinutes, &mod.Kline{CloseTime: closeAt(4)}) {
+		t.Error("gap not detected")
+	}
+	if m.Gap(mod.FourHours, &mod.Kline{CloseTime: closeAt(4)}) {
+		t.Error("gap on empty queue")
+	}
+}
+
+func Test_QueueIndicators(t *testing.T) {
+	queue := strategy.NewQueue(10)
+	start := time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC)
+	bar := func(i int, c float64) *strategy.Kline {
+		return &strategy.Kline{High: c, Low: c, Close: c, CloseTime: start.Add(time.Duration(i) * time.Minute)}
+	}
+	queue.Reload([]*strategy.Kline{bar(1, 1), bar(2, 2), bar(3, 100)})
+
+	// 已有K线预热,最新一根没有收盘
+	sma := indicator.NewSMA(2)
+	queue.Attach(sma)
+	if sma.Value() != 1.5 {
+		t.Fatalf("warmup sma %v", sma.Value())
+	}
+	// 同一根K线的推送不更新指标,下一根到达时用上一根的最终值更新
+	queue.EnQqueu(bar(3, 3))
+	if sma.Value() != 1.5 {
+		t.Errorf("sma updated by unfinished kline %v", sma.Value())
+	}
+	queue.EnQqueu(bar(4, 4))
+	if sma.Value() != 2.5 {
+		t.Errorf("sma %v", sma.Value())
+	}
+
+	queue.Reload([]*strategy.Kline{bar(5, 10), bar(6, 20), bar(7, 30)})
+	if !sma.Ready() || sma.Value() != 15 {
+		t.Errorf("sma after reload %v", sma.Value())
+	}
+}
+
+// 随机推送K线,和按收盘时间去重的切片比较
+func Test_QueueProperties(t *testing.T) {
+	start := time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC)
+	for seed := int64(0); seed < 30; seed++ {
+		r := rand.New(rand.NewSource(seed))
+		capacity := 2 + r.Intn(8)
+		queue := strategy.NewQueue(capacity)
+		var ref []*strategy.Kline
+		minute := 0
+		for step := 0; step < 200; step++ {
+			// 新的一根、同一根的更新或者旧的推送
+			switch op := r.Intn(4); {
+			case op == 0 && minute > 1:
+				queue.EnQqueu(&strategy.Kline{Close: -1, CloseTime: start.Add(time.Duration(minute-2) * time.Minute)})
+			case op == 1 && len(ref) > 0:
+				k := &strategy.Kline{Close: r.Float64(), CloseTime: start.Add(time.Duration(minute) * time.Minute)}
+				queue.EnQqueu(k)
+				ref[len(ref)-1] = k
+			default:
+				minute++
+				k := &strategy.Kline{Close: r.Float64(), CloseTime: start.Add(time.Duration(minute) * time.Minute)}
+				queue.EnQqueu(k)
+				if ref = append(ref, k); len(ref) > capacity {
+					ref = ref[1:]
+				}
+			}
+
+			if got := queue.Klines(); !reflect.DeepEqual(got, ref) && len(ref) > 0 {
+				t.Fatalf("seed %d step %d klines %v want %v", seed, step, got, ref)
+			}
+			if queue.Len() != len(ref) || queue.IsFull() != (len(ref) == capacity) {
+				t.Fatalf("seed %d step %d len %d want %d", seed, step, queue.Len(), len(ref))
+			}
+			if len(ref) > 0 && queue.GetNewPrice() != ref[len(ref)-1].Close {
+				t.Fatalf("seed %d step %d new price %v", seed, step, queue.GetNewPrice())
+			}
+			n := r.Intn(capacity + 1)
+			want := ref
+			if n < len(ref) {
+				want = ref[len(ref)-n:]
+			}
+			if got := queue.Last(n); len(got) != len(want) || len(want) > 0 && !reflect.DeepEqual(got, want) {
+				t.Fatalf("seed %d step %d last(%d) %v want %v", seed, step, n, got, want)
+			}
+			if from := r.Intn(capacity); from < len(ref) && queue.At(from) != ref[from] {
+				t.Fatalf("seed %d step %d at(%d)", seed, step, from)
+			}
+		}
+	}
+}
+
+func Test_UpDownLink(t *testing.T) {
+	queue := strategy.NewQueue(4)
+	start := time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC)
+	// 写满后再覆盖一根,存储顺序和时间顺序不同
+	for i, v := range []float64{50, 10, 20, 30, 40} {
+		queue.EnQqueu(&strategy.Kline{High: v + 1, Low: v - 1, Close: v, Volume: v, CloseTime: start.Add(time.Duration(i) * time.Minute)})
+	}
+	queue.UpdateUpDownLink(true)
+	upl := queue.GetUpDownLink()
+	if upl.AvgPrice != 25 || upl.AvgVolume != 25 || upl.HalfSampleAvgPrice != 35 {
+		t.Errorf("avg price %v volume %v half %v", upl.AvgPrice, upl.AvgVolume, upl.HalfSampleAvgPrice)
+	}
+	if upl.MinLow != 9 || upl.MinLowIndex != 0 || upl.MaxHigh != 41 || upl.MaxHighIndex != 3 {
+		t.Errorf("min %v@%v max %v@%v", upl.MinLow, upl.MinLowIndex, upl.MaxHigh, upl.MaxHighIndex)
+	}
+	if upl.UpLink != 33.5 || upl.DownLink != 16.5 {
+		t.Errorf("uplink %v downlink %v", upl.UpLink, upl.DownLink)
+	}
+
+	// 最新一根不参与统计
+	queue.UpdateUpDownLink(false)
+	if upl := queue.GetUpDownLink(); upl.AvgPrice != 20 || upl.HalfSampleAvgPrice != 30 || upl.MaxHighIndex != 2 {
+		t.Errorf("without last %+v", upl)
+	}
+}
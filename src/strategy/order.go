@@ -8,22 +8,31 @@ import (
 	"sync"
 	"time"
 	. "tinyquant/src/logger"
+	"tinyquant/src/mod"
 	"tinyquant/src/util"
 
-	"github.com/rootpd/binance"
 	"go.uber.org/zap"
 )
 
 type OriginOrder struct {
 	Symbol       string
-	Side         binance.OrderSide    // 买卖方向 SELL, BUY
-	PositionSide binance.PositionSide // 持仓方向，单向持仓模式下非必填，默认且仅可填BOTH;在双向持仓模式下必填,且仅可选择 LONG 或 SHORT
+	Side         mod.OrderSide    // 买卖方向 SELL, BUY
+	PositionSide mod.PositionSide // 持仓方向，单向持仓模式下非必填，默认且仅可填BOTH;在双向持仓模式下必填,且仅可选择 LONG 或 SHORT
 	Quantity     float64
 	Price        float64
-	ClosePrice   float64
+	ClosePrice   float64 // 条件单触发价格
 	OrderStatus  util.ORIGIN_ORDER_STATUS
 	OrderFlag    util.ORIGIN_ORDER_FLAG //仓位标志 0:手动 1:加仓单 2:减仓单
 	IsTest       bool
+
+	Type            mod.OrderType   // 为空时按 ClosePrice 判断 LIMIT 或 STOP
+	TimeInForce     mod.TimeInForce // 为空时 GTC;GTX 被拒绝时按盘口重新定价
+	ReduceOnly      bool            // 只减仓,双向持仓下不能使用
+	ClosePosition   bool            // 触发后全部平仓,不需要数量
+	ActivationPrice float64         // 跟踪止损激活价格
+	CallbackRate    float64         // 跟踪止损回调比例,1 代表 1%
+	WorkingType     string          // 条件单触发价格类型
+	PriceProtect    bool            // 条件单触发保护
 }
 type PlaceOrderManager struct {
 	*sync.RWMutex
@@ -49,27 +58,120 @@ type PlaceOrderManager struct {
 	ShortContinuePlaceCount int32 //空单连续下单的次数
 
 	OrderType     map[string]*MyFutureOrder
-	Account       *BinanceFutureAsset // 账户信息
+	Account       *BinanceFutureAsset // 账户信息,下单使用账户的客户端
 	TerracedPrice []float64           //连续开仓T度
+	Param         *util.QuantParam    //交易对的策略参数
 
 	positionInfo PositionInfo
+	placeLimit   time.Time        //上一次发送超过压力位支撑位提醒的时间
+	Clock        func() time.Time //当前时间,回测时使用K线时间
+	lastOrderID  int64            //上一次的 clientOrderID
 }
 
 type PositionInfo interface {
 	GetLongBetweenAllCloseFutureOrderAndPositionD_Value() (float64, float64, float64)
 	GetShortBetweenAllCloseFutureOrderAndPositionD_Value() (float64, float64, float64)
-	CancelAllCloseFutureOrder(binance.PositionSide)
+	CancelAllCloseFutureOrder(mod.PositionSide)
 	GetLongShortPinCloseFutureOrder() (bool, bool)
 }
 
-var placeLimit time.Time = time.Now()
+func (p *PlaceOrderManager) now() time.Time {
+	if p.Clock != nil {
+		return p.Clock()
+	}
+	return time.Now()
+}
+
+func (p *PlaceOrderManager) price(v float64) float64 {
+	return symbolInfo(p.Account.Exchange, p.Symbol).RoundPrice(v)
+}
+
+func (p *PlaceOrderManager) qty(v float64) float64 {
+	return symbolInfo(p.Account.Exchange, p.Symbol).RoundQty(v)
+}
 
-func (p *PlaceOrderManager) MakePlaceOrder(order *OriginOrder) (*binance.FutureProcessedOrder, error) {
+// 按交易对规则把价格取整到 tickSize,数量向下取整到 stepSize,不满足最小名义价值等规则的订单不发送
+func (p *PlaceOrderManager) checkOrder(order *OriginOrder) error {
+	info, err := p.Account.Exchange.GetSymbolInfo(order.Symbol)
+	if err != nil {
+		return err
+	}
+	if !order.Type.HasPrice() {
+		order.Price = 0
+	}
+	order.Price = info.RoundPrice(order.Price)
+	order.ClosePrice = info.RoundPrice(order.ClosePrice)
+	order.ActivationPrice = info.RoundPrice(order.ActivationPrice)
+	order.Quantity = info.FloorQty(order.Quantity)
+	if info.MaxNumOrders != 0 && len(p.OrderType) >= info.MaxNumOrders {
+		return fmt.Errorf("%s open orders reach max %v", order.Symbol, info.MaxNumOrders)
+	}
+	if order.TimeInForce == mod.GTX && order.Type != "" && order.Type != mod.TypeLimit {
+		return fmt.Errorf("%s post only is not supported by %v", order.Symbol, order.Type)
+	}
+	if order.Type == mod.TypeTrailingStopMarket && (order.CallbackRate < 0.1 || order.CallbackRate > 5) {
+		return fmt.Errorf("%s callback rate %v out of [0.1, 5]", order.Symbol, order.CallbackRate)
+	}
+	if order.ClosePosition {
+		if order.Type != mod.TypeStopMarket && order.Type != mod.TypeTakeProfitMarket {
+			return fmt.Errorf("%s close position is not supported by %v", order.Symbol, order.Type)
+		}
+		order.Quantity = 0
+		return nil
+	}
+	//市价类订单用触发价估算名义价值
+	price := order.Price
+	if price == 0 {
+		price = order.ClosePrice
+	}
+	if price == 0 {
+		price = order.ActivationPrice
+	}
+	return info.Check(price, order.Quantity)
+}
+
+func (p *PlaceOrderManager) MakePlaceOrder(order *OriginOrder) (*mod.FutureOrder, error) {
 	p.Lock()
 	defer p.Unlock()
+	req, err := p.prepareOrder(order)
+	if req == nil {
+		return nil, err
+	}
+	resOrder, err := p.Account.Exchange.NewFutureOrder(req)
+	return p.placed(order, req, resOrder, err)
+}
 
-	if order.Quantity == 0.0 {
-		order.Quantity = util.Round(p.Quantity, 3)
+// 批量下单,阶梯挂单时一次请求发出多个订单,结果和 orders 一一对应
+func (p *PlaceOrderManager) MakePlaceOrders(orders []*OriginOrder) ([]*mod.FutureOrder, []error) {
+	p.Lock()
+	defer p.Unlock()
+	res := make([]*mod.FutureOrder, len(orders))
+	errs := make([]error, len(orders))
+	var reqs []*mod.OrderRequest
+	var index []int
+	for i, order := range orders {
+		req, err := p.prepareOrder(order)
+		if req == nil {
+			errs[i] = err
+			continue
+		}
+		reqs = append(reqs, req)
+		index = append(index, i)
+	}
+	if len(reqs) == 0 {
+		return res, errs
+	}
+	sent, sentErrs := p.Account.Exchange.NewFutureOrders(reqs)
+	for j, i := range index {
+		res[i], errs[i] = p.placed(orders[i], reqs[j], sent[j], sentErrs[j])
+	}
+	return res, errs
+}
+
+// 检查订单并生成下单请求,不需要发送时返回 nil
+func (p *PlaceOrderManager) prepareOrder(order *OriginOrder) (*mod.OrderRequest, error) {
+	if order.Quantity == 0.0 && !order.ClosePosition {
+		order.Quantity = p.qty(p.Quantity)
 	}
 
 	if order.OrderFlag == util.ADDPOSITION { // 如果是加仓单
@@ -81,9 +183,9 @@ func (p *PlaceOrderManager) MakePlaceOrder(order *OriginOrder) (*binance.FutureP
 		case util.PIN:
 			{
 				switch order.PositionSide {
-				case binance.LONG:
+				case mod.LONG:
 					{
-						if time.Now().Unix()-p.LongLastPinPlaceOrderTime > util.ContinuousOrderValidityTime*60 { //距离上一次多单时间过去5min
+						if p.now().Unix()-p.LongLastPinPlaceOrderTime > p.Param.ContinuousOrderValidityTime*60 { //距离上一次多单时间过去5min
 							p.LongContinuePlaceCount = 0 //重置连续下单的次数为0
 						}
 						index := int(p.LongContinuePlaceCount)
@@ -96,43 +198,43 @@ func (p *PlaceOrderManager) MakePlaceOrder(order *OriginOrder) (*binance.FutureP
 								Logger.Sugar().Infof("和上次下单价格相差小于 %v order : %+v", price, order)
 								return nil, errors.New("price limit")
 							} else {
-								order.Quantity = util.Round(order.Quantity+util.Quantity*float64(index-1), 3)
-								order.Price = util.Round(order.Price-order.Price*util.SpringPrice*float64(index), 2)
+								order.Quantity = p.qty(order.Quantity + p.Param.Quantity*float64(index-1))
+								order.Price = p.price(order.Price - order.Price*p.Param.SpringPrice*float64(index))
 							}
 						}
 
 						positionAmt, _, entryPrice := p.positionInfo.GetLongBetweenAllCloseFutureOrderAndPositionD_Value()
-						// if positionAmt >= util.Quantity*util.DoubleCreatOrderLevel { //当前仓位过大
+						// if positionAmt >= p.Param.Quantity*p.Param.DoubleCreatOrderLevel { //当前仓位过大
 						// 	if p.LongLastDonePrice != 0 {
-						// 		limitPrice := math.Ceil(positionAmt / util.Quantity / util.DoubleCreatOrderLevel)
-						// 		if p.LongLastDonePrice-order.Price < order.Price*util.Profits*limitPrice {
-						// 			Logger.Sugar().Infof("取消加仓 多单总仓位  : %v 多单均价 : %v 加仓价格 : %v 加仓数量  : %v 上次加仓价格 : %v limit : %v %v", positionAmt, entryPrice, order.Price, order.Quantity, p.LongLastDonePrice, limitPrice, order.Price*util.Profits*limitPrice)
+						// 		limitPrice := math.Ceil(positionAmt / p.Param.Quantity / p.Param.DoubleCreatOrderLevel)
+						// 		if p.LongLastDonePrice-order.Price < order.Price*p.Param.Profits*limitPrice {
+						// 			Logger.Sugar().Infof("取消加仓 多单总仓位  : %v 多单均价 : %v 加仓价格 : %v 加仓数量  : %v 上次加仓价格 : %v limit : %v %v", positionAmt, entryPrice, order.Price, order.Quantity, p.LongLastDonePrice, limitPrice, order.Price*p.Param.Profits*limitPrice)
 						// 			p.LongPinOrderCancel = true
 						// 			return nil, errors.New("quantity limit")
 						// 		}
 						// 	}
 						// }
 						// if p.LongPinOrderCancel {
-						// 	order.Quantity = util.Round(order.Quantity+util.Quantity, 3)
+						// 	order.Quantity = p.qty(order.Quantity+p.Param.Quantity)
 						// }
-						if positionAmt != 0 && math.Abs(order.Price-entryPrice) > order.Price*util.IncreaseQuantityLevel {
+						if positionAmt != 0 && math.Abs(order.Price-entryPrice) > order.Price*p.Param.IncreaseQuantityLevel {
 							Logger.Sugar().Warnf("增加加仓 多单总仓位  : %v 多单均价 : %v 加仓价格 : %v 加仓数量  : %v index : %v", positionAmt, entryPrice, order.Price, order.Quantity, index)
-							order.Quantity = util.Round(order.Quantity+util.Quantity, 3)
+							order.Quantity = p.qty(order.Quantity + p.Param.Quantity)
 						}
 						// turnPositionAmt, _, turnEntryPrice := p.positionInfo.GetShortBetweenAllCloseFutureOrderAndPositionD_Value()
-						// if turnPositionAmt >= util.Quantity*6 && turnPositionAmt > positionAmt*2 && order.Quantity == util.Profits {
+						// if turnPositionAmt >= p.Param.Quantity*6 && turnPositionAmt > positionAmt*2 && order.Quantity == p.Param.Profits {
 						// 	Logger.Sugar().Warnf("增加加仓 多单总仓位  : %v 多单均价 : %v 加仓价格 : %v 加仓数量  : %v 空单总仓位  : %v", positionAmt, entryPrice, order.Price, order.Quantity, turnPositionAmt)
-						// 	order.Quantity = order.Quantity + util.Quantity
+						// 	order.Quantity = order.Quantity + p.Param.Quantity
 						// }
 
 						p.LongContinuePlaceCount++
-						p.LongLastPinPlaceOrderTime = time.Now().Unix()
+						p.LongLastPinPlaceOrderTime = p.now().Unix()
 						p.LongLastPinPrice = order.Price
 
 					}
-				case binance.SHORT:
+				case mod.SHORT:
 					{
-						if time.Now().Unix()-p.ShortLastPinPlaceOrderTime > util.ContinuousOrderValidityTime*60 { //距离上一次多单时间过去5min
+						if p.now().Unix()-p.ShortLastPinPlaceOrderTime > p.Param.ContinuousOrderValidityTime*60 { //距离上一次多单时间过去5min
 							p.ShortContinuePlaceCount = 0 //重置连续下单的次数为0
 						}
 						index := int(p.ShortContinuePlaceCount)
@@ -145,46 +247,46 @@ func (p *PlaceOrderManager) MakePlaceOrder(order *OriginOrder) (*binance.FutureP
 								Logger.Sugar().Infof("和上次下单价格相差小于 %v order : %+v", price, order)
 								return nil, errors.New("price limit")
 							} else {
-								order.Quantity = util.Round(order.Quantity+util.Quantity*float64(index-1), 3)
-								order.Price = util.Round(order.Price+order.Price*util.SpringPrice*float64(index), 2)
+								order.Quantity = p.qty(order.Quantity + p.Param.Quantity*float64(index-1))
+								order.Price = p.price(order.Price + order.Price*p.Param.SpringPrice*float64(index))
 							}
 						}
 
 						positionAmt, _, entryPrice := p.positionInfo.GetShortBetweenAllCloseFutureOrderAndPositionD_Value()
-						// if positionAmt >= util.Quantity*util.DoubleCreatOrderLevel { //当前仓位过大
+						// if positionAmt >= p.Param.Quantity*p.Param.DoubleCreatOrderLevel { //当前仓位过大
 						// 	if p.ShortLastDonePrice != 0 {
-						// 		limitPrice := math.Ceil(positionAmt / util.Quantity / util.DoubleCreatOrderLevel)
-						// 		if order.Price-p.ShortLastDonePrice < order.Price*util.Profits*limitPrice {
-						// 			Logger.Sugar().Infof("取消加仓 空单总仓位  : %v 空单均价 : %v 加仓价格 : %v 加仓数量  : %v 上次加仓价格 : %v limit : %v %v", positionAmt, entryPrice, order.Price, order.Quantity, p.ShortLastDonePrice, limitPrice, order.Price*util.Profits*limitPrice)
+						// 		limitPrice := math.Ceil(positionAmt / p.Param.Quantity / p.Param.DoubleCreatOrderLevel)
+						// 		if order.Price-p.ShortLastDonePrice < order.Price*p.Param.Profits*limitPrice {
+						// 			Logger.Sugar().Infof("取消加仓 空单总仓位  : %v 空单均价 : %v 加仓价格 : %v 加仓数量  : %v 上次加仓价格 : %v limit : %v %v", positionAmt, entryPrice, order.Price, order.Quantity, p.ShortLastDonePrice, limitPrice, order.Price*p.Param.Profits*limitPrice)
 						// 			p.ShortPinOrderCancel = true
 						// 			return nil, errors.New("quantity limit")
 						// 		}
 						// 	}
 						// }
 						// if p.ShortPinOrderCancel {
-						// 	order.Quantity = util.Round(order.Quantity+util.Quantity, 3)
+						// 	order.Quantity = p.qty(order.Quantity+p.Param.Quantity)
 						// }
-						if positionAmt != 0 && math.Abs(order.Price-entryPrice) > order.Price*util.IncreaseQuantityLevel {
+						if positionAmt != 0 && math.Abs(order.Price-entryPrice) > order.Price*p.Param.IncreaseQuantityLevel {
 							Logger.Sugar().Warnf("增加加仓 空单总仓位  : %v 空单均价 : %v 加仓价格 : %v 加仓数量  : %v", positionAmt, entryPrice, order.Price, order.Quantity)
-							order.Quantity = util.Round(order.Quantity+util.Quantity, 3)
+							order.Quantity = p.qty(order.Quantity + p.Param.Quantity)
 						}
 						// turnPositionAmt, _, turnEntryPrice := p.positionInfo.GetLongBetweenAllCloseFutureOrderAndPositionD_Value()
-						// if turnPositionAmt >= util.Quantity*6 && turnPositionAmt > positionAmt*2 && order.Quantity == util.Profits {
+						// if turnPositionAmt >= p.Param.Quantity*6 && turnPositionAmt > positionAmt*2 && order.Quantity == p.Param.Profits {
 						// 	Logger.Sugar().Warnf("增加加仓 空单总仓位  : %v 空单均价 : %v 加仓价格 : %v 加仓数量  : %v 多单总仓位  : %v", positionAmt, entryPrice, order.Price, order.Quantity, turnPositionAmt)
-						// 	order.Quantity = order.Quantity + util.Quantity
+						// 	order.Quantity = order.Quantity + p.Param.Quantity
 						// }
 
 						p.ShortContinuePlaceCount++
-						p.ShortLastPinPlaceOrderTime = time.Now().Unix()
+						p.ShortLastPinPlaceOrderTime = p.now().Unix()
 						p.ShortLastPinPrice = order.Price
 
 					}
 				}
-				if (order.Price > util.PressureLevel || order.Price < util.SupportLevel) && order.OrderStatus != util.FLOW {
-					Logger.Sugar().Warn("开仓价格超多压力位或者支撑位,curprice : %v PressureLevel : %v,SupportLevel : %v", order.Price, util.PressureLevel, util.SupportLevel)
-					if time.Since(placeLimit) > 15*time.Minute {
-						placeLimit = time.Now()
-						util.SendOrderMsg(fmt.Sprintf("开仓价格超多压力位或者支撑位,请介入处理\norder price : %v \nPressureLevel : %v\n,SupportLevel : %v", order.Price, util.PressureLevel, util.SupportLevel))
+				if (order.Price > p.Param.PressureLevel || order.Price < p.Param.SupportLevel) && order.OrderStatus != util.FLOW {
+					Logger.Sugar().Warn("开仓价格超多压力位或者支撑位,curprice : %v PressureLevel : %v,SupportLevel : %v", order.Price, p.Param.PressureLevel, p.Param.SupportLevel)
+					if p.now().Sub(p.placeLimit) > 15*time.Minute {
+						p.placeLimit = p.now()
+						util.SendOrderMsg(fmt.Sprintf("开仓价格超多压力位或者支撑位,请介入处理\norder price : %v \nPressureLevel : %v\n,SupportLevel : %v", order.Price, p.Param.PressureLevel, p.Param.SupportLevel))
 					}
 					return nil, nil
 				}
@@ -199,12 +301,12 @@ func (p *PlaceOrderManager) MakePlaceOrder(order *OriginOrder) (*binance.FutureP
 		case util.PINCLOSECOMMON:
 			{
 				switch order.PositionSide {
-				case binance.LONG:
+				case mod.LONG:
 					ok, _ := p.positionInfo.GetLongShortPinCloseFutureOrder()
 					if ok {
 						return nil, errors.New("has Long pin close future order")
 					}
-				case binance.SHORT:
+				case mod.SHORT:
 					_, ok := p.positionInfo.GetLongShortPinCloseFutureOrder()
 					if ok {
 						return nil, errors.New("has Short pin close future order")
@@ -218,34 +320,99 @@ func (p *PlaceOrderManager) MakePlaceOrder(order *OriginOrder) (*binance.FutureP
 		}
 	}
 
+	if err := p.checkOrder(order); err != nil {
+		Logger.Warn("order rejected", zap.Error(err), zap.Any("order", order))
+		return nil, err
+	}
+
 	if order.IsTest {
 		Logger.Info("test下单", zap.Any(order.Symbol, order))
 		return nil, nil
 	}
 
-	customOrderId := strconv.FormatInt(time.Now().UnixNano(), 10)
+	//连续下单时纳秒时间戳可能重复
+	id := time.Now().UnixNano()
+	if id <= p.lastOrderID {
+		id = p.lastOrderID + 1
+	}
+	p.lastOrderID = id
+	customOrderId := strconv.FormatInt(id, 10)
 	p.OrderType[customOrderId] = &MyFutureOrder{
-		ExecutedFutureOrder: &binance.ExecutedFutureOrder{},
-		OrdeType:            order.OrderStatus,
-		OrderFlag:           order.OrderFlag,
+		FutureOrder: &mod.FutureOrder{},
+		OrdeType:    order.OrderStatus,
+		OrderFlag:   order.OrderFlag,
 	}
 	// if order.Quantity >= 20 {
 	// 	Logger.Error("下单拦截", zap.Any(order.Symbol, order))
 	// 	return nil, nil
 	// }
-	resOrder, err := Binance.NewBinanceFutureOrder(order.Symbol, order.Quantity, order.Price, order.ClosePrice, order.Side, order.PositionSide, customOrderId)
+	req := &mod.OrderRequest{
+		Symbol:        order.Symbol,
+		Side:          order.Side,
+		PositionSide:  order.PositionSide,
+		Quantity:      order.Quantity,
+		Price:         order.Price,
+		StopPrice:     order.ClosePrice,
+		ClientOrderID: customOrderId,
+		TimeInForce:   order.TimeInForce,
+
+		Type:            order.Type,
+		ReduceOnly:      order.ReduceOnly,
+		ClosePosition:   order.ClosePosition,
+		ActivationPrice: order.ActivationPrice,
+		CallbackRate:    order.CallbackRate,
+		WorkingType:     order.WorkingType,
+		PriceProtect:    order.PriceProtect,
+	}
+	return req, nil
+}
+
+// 处理下单结果,GTX 被拒绝时重新定价后单独重试
+func (p *PlaceOrderManager) placed(order *OriginOrder, req *mod.OrderRequest, resOrder *mod.FutureOrder, err error) (*mod.FutureOrder, error) {
+	for i := 0; i < postOnlyRetry && err == mod.ErrPostOnlyRejected; i++ {
+		if !p.repricePostOnly(req) {
+			break
+		}
+		Logger.Sugar().Infof("GTX 订单会立即成交,按盘口重新定价 %v -> %v", order.Price, req.Price)
+		order.Price = req.Price
+		resOrder, err = p.Account.Exchange.NewFutureOrder(req)
+	}
+	customOrderId := req.ClientOrderID
 	if err != nil {
 		delete(p.OrderType, customOrderId)
 		Logger.Error("new future order failed ", zap.Error(err), zap.Any("order", order))
 		return nil, err
 	}
-	p.OrderType[customOrderId].ActivetePrice = resOrder.ActivatePrice
+	p.OrderType[customOrderId].ActivatePrice = resOrder.ActivatePrice
 	p.OrderType[customOrderId].PriceRate = resOrder.PriceRate
 	p.OrderType[customOrderId].WorkingType = resOrder.WorkingType
 	p.OrderType[customOrderId].PriceProtect = resOrder.PriceProtect
 	Logger.Info("开始下单", zap.Any(order.Symbol, order))
 	return resOrder, nil
+}
 
+// GTX 订单被拒绝后重新定价的次数
+const postOnlyRetry = 3
+
+// 买单挂到买一,卖单挂到卖一,价格没有变化时不再重试
+func (p *PlaceOrderManager) repricePostOnly(req *mod.OrderRequest) bool {
+	depth, err := p.Account.Exchange.GetDepth(req.Symbol, 5)
+	if err != nil {
+		Logger.Error("get depth failed", zap.Error(err))
+		return false
+	}
+	var price float64
+	if req.Side == mod.SideBuy && len(depth.Bids) > 0 {
+		price = depth.Bids[0].Price
+	} else if req.Side == mod.SideSell && len(depth.Asks) > 0 {
+		price = depth.Asks[0].Price
+	}
+	price = p.price(price)
+	if price == 0 || price == req.Price {
+		return false
+	}
+	req.Price = price
+	return true
 }
 
 func (p *PlaceOrderManager) GetOrderInfo(customId string) *MyFutureOrder {
@@ -259,7 +426,7 @@ func (p *PlaceOrderManager) AddOrderInfo(customId string, order *MyFutureOrder)
 	defer p.Unlock()
 	p.OrderType[customId] = order
 	if order.OrdeType == util.PIN {
-		if order.PositionSide == string(binance.LONG) {
+		if order.PositionSide == mod.LONG {
 			p.LongPinCount++
 		} else {
 			p.ShortPinCount++
@@ -272,15 +439,15 @@ func (p *PlaceOrderManager) DelOrderInfo(customId string) {
 	defer p.Unlock()
 	if order, ok := p.OrderType[customId]; ok {
 		if order.OrdeType == util.PIN {
-			if order.PositionSide == string(binance.LONG) {
+			if order.PositionSide == mod.LONG {
 				p.LongPinCount--
-				if order.Status == binance.StatusFilled {
+				if order.Status == mod.StatusFilled {
 					p.LongLastDonePrice = order.Price
 					p.LongPinOrderCancel = false
 				}
 			} else {
 				p.ShortPinCount--
-				if order.Status == binance.StatusFilled {
+				if order.Status == mod.StatusFilled {
 					p.ShortLastDonePrice = order.Price
 					p.ShortPinOrderCancel = false
 				}
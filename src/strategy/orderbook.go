@@ -1,9 +1,14 @@
 package strategy
 
 import (
+	"fmt"
+	"sort"
 	"sync"
 	"time"
 	. "tinyquant/src/logger"
+	"tinyquant/src/mod"
+	"tinyquant/src/quant"
+	"tinyquant/src/util"
 
 	"go.uber.org/zap"
 )
@@ -23,123 +28,292 @@ type Order struct {
 	Quantity float64
 }
 
+// 本地订单簿
+// 先缓存增量,拿到快照后丢弃快照之前的增量,之后每条增量的 pu 必须等于上一条的 u,不连续时重新获取快照
+// 火币这样每次推送全量深度的交易所 Snapshot 为 true,每条推送直接替换订单簿
 type OrderBookMap struct {
-	LastUpdateID   int
+	Exchange       quant.Exchange
+	Symbol         string
+	Limit          int // 快照档数
+	LastUpdateID   int // 快照的 lastUpdateId,同步后是最后一条增量的 u
 	MessageTime    time.Time
 	ChangeInfoTime time.Time
 	Bids           map[float64]float64
 	Asks           map[float64]float64
+	Resyncs        int // 因为增量不连续重新同步的次数
 	mutex          sync.RWMutex
+
+	buffer    []*mod.Depth // 同步前缓存的增量
+	snapshot  bool         // 已经加载快照
+	synced    bool         // 已经应用第一条覆盖快照的增量
+	fetching  bool
+	lastFetch time.Time
+	lastWalls []Wall // 上次 TrackWalls 时的大单墙
+	vanished  []Wall
 }
 
-func (o *OrderBookMap) InitOrderBook(symbol string, limit int) error {
+// 同步前最多缓存的增量,100ms 一条
+const depthBufferSize = 1000
 
-	ob, err := Binance.GetDepth(symbol, limit)
+func NewOrderBookMap(exchange quant.Exchange, symbol string) *OrderBookMap {
+	return &OrderBookMap{
+		Exchange: exchange,
+		Symbol:   symbol,
+		Limit:    1000,
+		Bids:     map[float64]float64{},
+		Asks:     map[float64]float64{},
+	}
+}
+
+// 获取快照并应用缓存的增量
+func (o *OrderBookMap) InitOrderBook(symbol string, limit int) error {
+	ob, err := o.Exchange.GetDepth(symbol, limit)
 	if err != nil {
 		Logger.Error("Get Depth failed ", zap.Error(err))
 		return err
 	}
+	return o.Reset(ob)
+}
 
-	o.LastUpdateID = ob.LastUpdateID
-	o.MessageTime = ob.MessageTime
-
+// 用快照重建订单簿,快照比缓存的增量旧时返回错误,需要重新获取
+func (o *OrderBookMap) Reset(ob *mod.Depth) error {
 	o.mutex.Lock()
 	defer o.mutex.Unlock()
 
-	for _, v := range ob.Asks {
-		o.Asks[v.Price] = v.Quantity
-	}
+	o.load(ob)
+	o.snapshot, o.synced = true, false
 
-	for _, v := range ob.Bids {
-		o.Bids[v.Price] = v.Quantity
+	if err := o.replay(); err != nil {
+		o.snapshot = false
+		return err
 	}
-
 	return nil
-	/*obTmp := &OrderBook{}
-
-	obTmp.LastUpdateID = ob.LastUpdateID
-	obTmp.MessageTime = ob.MessageTime
-
-	for _, v := range ob.Asks {
-		obTmp.Asks = append(obTmp.Asks, Order{
-			Price:    v.Price,
-			Quantity: v.Quantity,
-		})
-	}
+}
 
+func (o *OrderBookMap) load(ob *mod.Depth) {
+	o.Bids = make(map[float64]float64, len(ob.Bids))
+	o.Asks = make(map[float64]float64, len(ob.Asks))
 	for _, v := range ob.Bids {
-		obTmp.Bids = append(obTmp.Bids, Order{
-			Price:    v.Price,
-			Quantity: v.Quantity,
-		})
+		if v.Quantity > 0 {
+			o.Bids[v.Price] = v.Quantity
+		}
 	}
-
-	sort.Sort(OrderSlice(obTmp.Asks))
-	sort.Sort(OrderSlice(obTmp.Bids))
-
-	OB = obTmp*/
-}
-
-func DepthMaptoSlice() {
-
+	for _, v := range ob.Asks {
+		if v.Quantity > 0 {
+			o.Asks[v.Price] = v.Quantity
+		}
+	}
+	o.LastUpdateID = ob.LastUpdateID
+	o.MessageTime = ob.MessageTime
 }
 
-func (o *OrderBookMap) DepthUpdate(symbol string) {
+// 处理一条增量,返回是否需要获取快照
+func (o *OrderBookMap) Apply(d *mod.Depth) bool {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
 
-	depth_chan, depth_done := Binance.GetFutureDepthWs(symbol)
+	// 全量推送直接替换,不需要快照
+	if d.Snapshot {
+		o.load(d)
+		o.buffer = o.buffer[:0]
+		o.snapshot, o.synced = true, true
+		return false
+	}
+	if o.synced && !d.Gap && d.BeforeUID == o.LastUpdateID {
+		o.apply(d)
+		return false
+	}
+	// 增量不连续或者推送读取太慢被丢弃过,缓存的增量也不完整
+	if o.synced || d.Gap {
+		Logger.Warn("depth update gap, resync order book", zap.String("symbol", o.Symbol),
+			zap.Int("pu", d.BeforeUID), zap.Int("last u", o.LastUpdateID), zap.Bool("dropped", d.Gap))
+		o.Resyncs++
+		o.snapshot, o.synced = false, false
+		o.buffer = o.buffer[:0]
+	}
 
-	o.Asks = map[float64]float64{}
-	o.Bids = map[float64]float64{}
+	if len(o.buffer) >= depthBufferSize {
+		copy(o.buffer, o.buffer[1:])
+		o.buffer = o.buffer[:len(o.buffer)-1]
+	}
+	o.buffer = append(o.buffer, d)
+	if !o.snapshot {
+		return true
+	}
+	if err := o.replay(); err != nil {
+		Logger.Warn("order book snapshot out of date", zap.String("symbol", o.Symbol), zap.Error(err))
+		o.snapshot = false
+		return true
+	}
+	return false
+}
 
-	update_flag := false
+// 处理一条深度推送,需要快照时在后台获取,失败后至少间隔1秒再次获取
+func (o *OrderBookMap) Feed(d *mod.Depth) {
+	if !o.Apply(d) {
+		return
+	}
+	o.mutex.Lock()
+	if o.fetching || time.Since(o.lastFetch) < time.Second {
+		o.mutex.Unlock()
+		return
+	}
+	o.fetching, o.lastFetch = true, time.Now()
+	o.mutex.Unlock()
 
 	go func() {
-		time.Sleep(1000 * time.Millisecond)
-		o.InitOrderBook(symbol, 1000)
+		err := o.InitOrderBook(o.Symbol, o.Limit)
+		o.mutex.Lock()
+		o.fetching = false
+		o.mutex.Unlock()
+		if err != nil {
+			Logger.Warn("init order book failed", zap.String("symbol", o.Symbol), zap.Error(err))
+		}
 	}()
+}
 
+// 订阅深度推送并维护订单簿,推送关闭或取消订阅后返回
+func (o *OrderBookMap) DepthUpdate(symbol string) {
+	o.Symbol = symbol
+	depth_chan, depth_done := o.Exchange.GetDepthWs(symbol)
 	for {
 		select {
+		case depth, ok := <-depth_chan:
+			if !ok {
+				return
+			}
+			o.Feed(depth)
+		case <-depth_done:
+			return
+		}
+	}
+}
 
-		case depth := <-depth_chan:
-
-			if update_flag {
-
-				o.mutex.Lock()
-				for _, v := range depth.Asks {
-					if v.Quantity == 0.0 {
-						delete(o.Asks, v.Price)
-					}
+// 应用缓存的增量,丢弃 u 小于快照 lastUpdateId 的,第一条应用的增量需要满足 U <= lastUpdateId <= u
+func (o *OrderBookMap) replay() error {
+	defer func() { o.buffer = o.buffer[:0] }()
+	for _, d := range o.buffer {
+		if o.synced {
+			if d.BeforeUID != o.LastUpdateID {
+				return fmt.Errorf("depth update gap, pu %v last u %v", d.BeforeUID, o.LastUpdateID)
+			}
+			o.apply(d)
+			continue
+		}
+		if d.UpdateID < o.LastUpdateID {
+			continue
+		}
+		if d.LastUpdateID > o.LastUpdateID {
+			return fmt.Errorf("snapshot %v older than depth update %v", o.LastUpdateID, d.LastUpdateID)
+		}
+		o.apply(d)
+		o.synced = true
+	}
+	return nil
+}
 
-					o.Asks[v.Price] = v.Quantity
+func (o *OrderBookMap) apply(d *mod.Depth) {
+	for _, v := range d.Bids {
+		if v.Quantity == 0 {
+			delete(o.Bids, v.Price)
+		} else {
+			o.Bids[v.Price] = v.Quantity
+		}
+	}
+	for _, v := range d.Asks {
+		if v.Quantity == 0 {
+			delete(o.Asks, v.Price)
+		} else {
+			o.Asks[v.Price] = v.Quantity
+		}
+	}
+	o.LastUpdateID = d.UpdateID
+	o.MessageTime = d.MessageTime
+}
 
-				}
+// 是否已经同步,未同步时查询结果为空
+func (o *OrderBookMap) Synced() bool {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+	return o.synced
+}
 
-				for _, v := range depth.Bids {
-					if v.Quantity == 0.0 {
-						delete(o.Bids, v.Price)
-					}
-					o.Bids[v.Price] = v.Quantity
+// 买一
+func (o *OrderBookMap) BestBid() (Order, bool) {
+	bids, _ := o.Top(1)
+	if len(bids) == 0 {
+		return Order{}, false
+	}
+	return bids[0], true
+}
 
-				}
-				o.mutex.Unlock()
+// 卖一
+func (o *OrderBookMap) BestAsk() (Order, bool) {
+	_, asks := o.Top(1)
+	if len(asks) == 0 {
+		return Order{}, false
+	}
+	return asks[0], true
+}
 
-				continue
-			}
-			if depth.LastUpdateID == 0 || depth.UpdateID == 0 || o.LastUpdateID == 0 {
-				continue
-			}
-			if depth.LastUpdateID <= o.LastUpdateID && depth.UpdateID >= o.LastUpdateID {
-				update_flag = true
-			}
+// 前 n 档,买方价格从高到低,卖方从低到高,n <= 0 时返回全部
+func (o *OrderBookMap) Top(n int) (bids, asks OrderSlice) {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+	if !o.synced {
+		return nil, nil
+	}
+	bids = levels(o.Bids, n, true)
+	asks = levels(o.Asks, n, false)
+	return bids, asks
+}
 
-		case <-depth_done:
+// 买方价格不低于 price 的累计数量
+func (o *OrderBookMap) BidDepth(price float64) float64 {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+	if !o.synced {
+		return 0
+	}
+	var sum float64
+	for p, q := range o.Bids {
+		if p >= price {
+			sum += q
+		}
+	}
+	return sum
+}
 
-		default:
-			break
+// 卖方价格不高于 price 的累计数量
+func (o *OrderBookMap) AskDepth(price float64) float64 {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+	if !o.synced {
+		return 0
+	}
+	var sum float64
+	for p, q := range o.Asks {
+		if p <= price {
+			sum += q
 		}
 	}
+	return sum
+}
 
+func levels(m map[float64]float64, n int, desc bool) OrderSlice {
+	res := make(OrderSlice, 0, len(m))
+	for p, q := range m {
+		res = append(res, Order{Price: p, Quantity: q})
+	}
+	if desc {
+		sort.Sort(sort.Reverse(res))
+	} else {
+		sort.Sort(res)
+	}
+	if n > 0 && len(res) > n {
+		res = res[:n]
+	}
+	return res
 }
 
 func (o *OrderBookMap) GetOrderBookRate() float64 {
@@ -172,3 +346,275 @@ func (a OrderSlice) Swap(i, j int) {
 func (a OrderSlice) Less(i, j int) bool {
 	return a[i].Price < a[j].Price
 }
+
+// 大单墙
+type Wall struct {
+	Side     mod.OrderSide // BUY 买方支撑 SELL 卖方压力
+	Price    float64
+	Quantity float64
+	Pulled   bool      // 价格没有到达就消失了,可能是撤单
+	Time     time.Time // 消失的时间
+}
+
+// 消失的大单墙保留的时间
+const wallHistory = 10 * time.Minute
+
+// 中间价
+func (o *OrderBookMap) Mid() (float64, bool) {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+	return o.mid()
+}
+
+func (o *OrderBookMap) mid() (float64, bool) {
+	bid, ask, ok := o.best()
+	if !ok {
+		return 0, false
+	}
+	return (bid.Price + ask.Price) / 2, true
+}
+
+func (o *OrderBookMap) best() (bid, ask Order, ok bool) {
+	if !o.synced || len(o.Bids) == 0 || len(o.Asks) == 0 {
+		return bid, ask, false
+	}
+	for p, q := range o.Bids {
+		if p > bid.Price {
+			bid = Order{Price: p, Quantity: q}
+		}
+	}
+	for p, q := range o.Asks {
+		if ask.Price == 0 || p < ask.Price {
+			ask = Order{Price: p, Quantity: q}
+		}
+	}
+	return bid, ask, true
+}
+
+// 买一卖一按对方数量加权的中间价,买方挂单多时偏向卖一
+func (o *OrderBookMap) WeightedMid() (float64, bool) {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+	bid, ask, ok := o.best()
+	if !ok || bid.Quantity+ask.Quantity == 0 {
+		return 0, false
+	}
+	return (bid.Price*ask.Quantity + ask.Price*bid.Quantity) / (bid.Quantity + ask.Quantity), true
+}
+
+// 买卖价差,bps 为相对中间价的基点
+func (o *OrderBookMap) Spread() (spread, bps float64, ok bool) {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+	bid, ask, ok := o.best()
+	if !ok {
+		return 0, 0, false
+	}
+	spread = ask.Price - bid.Price
+	return spread, spread / ((bid.Price + ask.Price) / 2) * 1e4, true
+}
+
+// 中间价上下 bps 个基点内的买卖失衡,(买量-卖量)/(买量+卖量),1 全是买单 -1 全是卖单
+func (o *OrderBookMap) Imbalance(bps float64) (float64, bool) {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+	mid, ok := o.mid()
+	if !ok {
+		return 0, false
+	}
+	low, high := mid*(1-bps/1e4), mid*(1+bps/1e4)
+	var bid, ask float64
+	for p, q := range o.Bids {
+		if p >= low {
+			bid += q
+		}
+	}
+	for p, q := range o.Asks {
+		if p <= high {
+			ask += q
+		}
+	}
+	if bid+ask == 0 {
+		return 0, false
+	}
+	return (bid - ask) / (bid + ask), true
+}
+
+// 中间价上下 bps 个基点内数量超过平均每档 multiple 倍的挂单
+func (o *OrderBookMap) Walls(bps, multiple float64) []Wall {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+	return o.walls(bps, multiple)
+}
+
+func (o *OrderBookMap) walls(bps, multiple float64) []Wall {
+	mid, ok := o.mid()
+	if !ok {
+		return nil
+	}
+	low, high := mid*(1-bps/1e4), mid*(1+bps/1e4)
+	find := func(side mod.OrderSide, m map[float64]float64, in func(p float64) bool) []Wall {
+		var sum float64
+		var n int
+		for p, q := range m {
+			if in(p) {
+				sum += q
+				n++
+			}
+		}
+		// 档位太少时平均值没有意义
+		if n < 3 {
+			return nil
+		}
+		var res []Wall
+		for p, q := range m {
+			if in(p) && q >= sum/float64(n)*multiple {
+				res = append(res, Wall{Side: side, Price: p, Quantity: q})
+			}
+		}
+		return res
+	}
+	res := find(mod.SideBuy, o.Bids, func(p float64) bool { return p >= low })
+	return append(res, find(mod.SideSell, o.Asks, func(p float64) bool { return p <= high })...)
+}
+
+// 和上次比较找出消失的大单墙,价格没有到达墙的位置时认为是撤单
+// 需要定时调用,消失的墙保留10分钟供 VanishedWalls 查询
+func (o *OrderBookMap) TrackWalls(bps, multiple float64) []Wall {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	bid, ask, ok := o.best()
+	if !ok {
+		return nil
+	}
+	now := time.Now()
+	current := o.walls(bps, multiple)
+	exists := make(map[Wall]bool, len(current))
+	for _, w := range current {
+		exists[Wall{Side: w.Side, Price: w.Price}] = true
+	}
+
+	var vanished []Wall
+	for _, w := range o.lastWalls {
+		if exists[Wall{Side: w.Side, Price: w.Price}] {
+			continue
+		}
+		if w.Side == mod.SideBuy {
+			w.Pulled = bid.Price > w.Price
+		} else {
+			w.Pulled = ask.Price < w.Price
+		}
+		w.Time = now
+		vanished = append(vanished, w)
+	}
+	o.lastWalls = current
+
+	keep := o.vanished[:0]
+	for _, w := range o.vanished {
+		if now.Sub(w.Time) < wallHistory {
+			keep = append(keep, w)
+		}
+	}
+	o.vanished = append(keep, vanished...)
+	return vanished
+}
+
+// since 之后被撤掉的大单墙
+func (o *OrderBookMap) PulledWalls(side mod.OrderSide, since time.Time) []Wall {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+	var res []Wall
+	for _, w := range o.vanished {
+		if w.Side == side && w.Pulled && w.Time.After(since) {
+			res = append(res, w)
+		}
+	}
+	return res
+}
+
+// 按当前订单簿吃掉 qty 的成交均价和相对中间价的滑点(基点),深度不够时 ok 为 false
+func (o *OrderBookMap) Slippage(side mod.OrderSide, qty float64) (avgPrice, bps float64, ok bool) {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+	mid, ok := o.mid()
+	if !ok || qty <= 0 {
+		return 0, 0, false
+	}
+	book := levels(o.Asks, 0, false)
+	if side == mod.SideSell {
+		book = levels(o.Bids, 0, true)
+	}
+	var filled, notional float64
+	for _, l := range book {
+		q := l.Quantity
+		if filled+q > qty {
+			q = qty - filled
+		}
+		filled += q
+		notional += q * l.Price
+		if filled >= qty {
+			break
+		}
+	}
+	if filled < qty {
+		return 0, 0, false
+	}
+	avgPrice = notional / qty
+	if side == mod.SideSell {
+		return avgPrice, (mid - avgPrice) / mid * 1e4, true
+	}
+	return avgPrice, (avgPrice - mid) / mid * 1e4, true
+}
+
+// 开仓前的订单簿检查结果
+type BookCheck struct {
+	Synced      bool
+	Imbalance   float64 // 开仓方向的失衡,正数表示开仓方向的挂单多
+	SpreadBps   float64
+	SlippageBps float64
+	Support     int    // 开仓方向的大单墙数量
+	Veto        string // 放弃开仓的原因,为空时可以开仓
+}
+
+// 按参数检查开仓方向的深度,订单簿未同步时不放弃开仓
+func (o *OrderBookMap) Check(side mod.OrderSide, qty float64, p util.OrderBookParam) *BookCheck {
+	c := &BookCheck{Synced: o.Synced()}
+	if !c.Synced {
+		return c
+	}
+	_, c.SpreadBps, _ = o.Spread()
+	if imb, ok := o.Imbalance(p.Bps); ok {
+		c.Imbalance = imb
+		if side == mod.SideSell {
+			c.Imbalance = -imb
+		}
+	}
+	for _, w := range o.Walls(p.Bps, p.WallMultiple) {
+		if w.Side == side {
+			c.Support++
+		}
+	}
+
+	if p.MaxImbalance > 0 && c.Imbalance < -p.MaxImbalance {
+		c.Veto = fmt.Sprintf("imbalance %.2f against %v", c.Imbalance, side)
+		return c
+	}
+	if p.MaxSlippage > 0 {
+		_, bps, ok := o.Slippage(side, qty)
+		if !ok {
+			c.Veto = "depth not enough"
+			return c
+		}
+		c.SlippageBps = bps
+		if bps > p.MaxSlippage {
+			c.Veto = fmt.Sprintf("slippage %.2f bps", bps)
+			return c
+		}
+	}
+	if p.WallPulled > 0 {
+		if pulled := o.PulledWalls(side, time.Now().Add(-time.Duration(p.WallPulled)*time.Second)); len(pulled) > 0 {
+			c.Veto = fmt.Sprintf("%v wall at %v pulled", side, pulled[len(pulled)-1].Price)
+		}
+	}
+	return c
+}
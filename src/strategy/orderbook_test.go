@@ -0,0 +1,169 @@
+package strategy_test
+
+import (
+	"math"
+	"testing"
+	"tinyquant/src/logger"
+	"tinyquant/src/mod"
+	"tinyquant/src/strategy"
+	"tinyquant/src/util"
+
+	"go.uber.org/zap"
+)
+
+// 单元测试不读取配置,加 integration 标签时 strategy_test.go 会重新初始化日志
+func init() {
+	logger.Logger = zap.NewNop()
+}
+
+func depthDiff(U, u, pu int, bids, asks []*mod.Order) *mod.Depth {
+	return &mod.Depth{LastUpdateID: U, UpdateID: u, BeforeUID: pu, Bids: bids, Asks: asks}
+}
+
+func Test_OrderBookSync(t *testing.T) {
+	ob := strategy.NewOrderBookMap(nil, "ETHUSDT")
+
+	// 快照之前的增量先缓存
+	if !ob.Apply(depthDiff(90, 95, 89, []*mod.Order{{Price: 99, Quantity: 5}}, nil)) {
+		t.Fatal("need snapshot before sync")
+	}
+	ob.Apply(depthDiff(96, 105, 95, []*mod.Order{{Price: 100, Quantity: 0}}, []*mod.Order{{Price: 102, Quantity: 3}}))
+	if ob.Synced() {
+		t.Fatal("synced without snapshot")
+	}
+
+	err := ob.Reset(&mod.Depth{
+		LastUpdateID: 100,
+		Bids:         []*mod.Order{{Price: 100, Quantity: 1}, {Price: 98, Quantity: 2}},
+		Asks:         []*mod.Order{{Price: 101, Quantity: 1}, {Price: 103, Quantity: 4}},
+	})
+	if err != nil || !ob.Synced() {
+		t.Fatalf("reset failed : %v", err)
+	}
+
+	// u=95 的增量在快照之前被丢弃,u=105 的删除了 100 档
+	if bid, _ := ob.BestBid(); bid.Price != 98 {
+		t.Errorf("best bid %v", bid)
+	}
+	if ask, _ := ob.BestAsk(); ask.Price != 101 {
+		t.Errorf("best ask %v", ask)
+	}
+	if _, asks := ob.Top(2); len(asks) != 2 || asks[1].Price != 102 {
+		t.Errorf("top asks %v", asks)
+	}
+	if d := ob.AskDepth(102.5); d != 4 {
+		t.Errorf("ask depth %v", d)
+	}
+
+	if ob.Apply(depthDiff(106, 110, 105, []*mod.Order{{Price: 99.5, Quantity: 1}}, nil)) {
+		t.Fatal("continuous update need snapshot")
+	}
+	if d := ob.BidDepth(99); d != 1 {
+		t.Errorf("bid depth %v", d)
+	}
+
+	// pu 不连续时重新同步
+	if !ob.Apply(depthDiff(115, 120, 112, nil, nil)) || ob.Synced() || ob.Resyncs != 1 {
+		t.Fatal("gap not detected")
+	}
+	if _, ok := ob.BestBid(); ok {
+		t.Error("query before resync")
+	}
+
+	// 快照比缓存的增量旧
+	if err := ob.Reset(&mod.Depth{LastUpdateID: 110}); err == nil {
+		t.Error("stale snapshot accepted")
+	}
+	ob.Apply(depthDiff(121, 125, 120, nil, nil))
+	if err := ob.Reset(&mod.Depth{LastUpdateID: 123, Bids: []*mod.Order{{Price: 97, Quantity: 1}}}); err != nil || !ob.Synced() {
+		t.Fatalf("resync failed : %v", err)
+	}
+
+	// 读取太慢丢弃过推送,pu 连续也要重新同步
+	gap := depthDiff(126, 130, 125, nil, nil)
+	gap.Gap = true
+	if !ob.Apply(gap) || ob.Synced() || ob.Resyncs != 2 {
+		t.Fatal("dropped update not resynced")
+	}
+}
+
+// 火币每次推送全量深度,Version 不连续,每条直接替换订单簿
+func Test_OrderBookSnapshot(t *testing.T) {
+	ob := strategy.NewOrderBookMap(nil, "ethusdt")
+	push := func(version int, bids, asks []*mod.Order) *mod.Depth {
+		return &mod.Depth{LastUpdateID: version, UpdateID: version, Snapshot: true, Bids: bids, Asks: asks}
+	}
+	if ob.Apply(push(100, []*mod.Order{{Price: 99, Quantity: 1}}, []*mod.Order{{Price: 101, Quantity: 1}})) || !ob.Synced() {
+		t.Fatal("snapshot push not synced")
+	}
+	if ob.Apply(push(105, []*mod.Order{{Price: 98, Quantity: 2}}, []*mod.Order{{Price: 100, Quantity: 3}})) || !ob.Synced() {
+		t.Fatal("snapshot push need resync")
+	}
+	if bid, _ := ob.BestBid(); bid.Price != 98 || ob.BidDepth(0) != 2 {
+		t.Errorf("best bid %v, old levels kept", bid)
+	}
+	if ask, _ := ob.BestAsk(); ask.Price != 100 {
+		t.Errorf("best ask %v", ask)
+	}
+	if ob.Resyncs != 0 || ob.LastUpdateID != 105 {
+		t.Errorf("resyncs %v last %v", ob.Resyncs, ob.LastUpdateID)
+	}
+}
+
+func Test_OrderBookAnalytics(t *testing.T) {
+	ob := strategy.NewOrderBookMap(nil, "ETHUSDT")
+	ob.Apply(depthDiff(10, 11, 9, nil, nil))
+	err := ob.Reset(&mod.Depth{
+		LastUpdateID: 10,
+		Bids:         []*mod.Order{{Price: 99.9, Quantity: 1}, {Price: 99.8, Quantity: 1}, {Price: 99.7, Quantity: 1}, {Price: 99.6, Quantity: 20}},
+		Asks:         []*mod.Order{{Price: 100.1, Quantity: 3}, {Price: 100.2, Quantity: 2}, {Price: 100.3, Quantity: 1}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mid, _ := ob.Mid(); mid != 100 {
+		t.Errorf("mid %v", mid)
+	}
+	// 买一数量少,加权中间价偏向买一
+	if wm, _ := ob.WeightedMid(); wm >= 100 {
+		t.Errorf("weighted mid %v", wm)
+	}
+	if _, bps, _ := ob.Spread(); bps < 19.9 || bps > 20.1 {
+		t.Errorf("spread %v bps", bps)
+	}
+	// 25个基点内买方 2 卖方 5
+	if imb, _ := ob.Imbalance(25); math.Abs(imb+3.0/7) > 1e-9 {
+		t.Errorf("imbalance %v", imb)
+	}
+	if avg, bps, ok := ob.Slippage(mod.SideBuy, 4); !ok || math.Abs(avg-100.125) > 1e-9 || bps < 12.4 || bps > 12.6 {
+		t.Errorf("slippage %v %v %v", avg, bps, ok)
+	}
+	if _, _, ok := ob.Slippage(mod.SideBuy, 7); ok {
+		t.Error("slippage beyond depth")
+	}
+
+	walls := ob.TrackWalls(50, 3)
+	if len(walls) != 0 || len(ob.Walls(50, 3)) != 1 {
+		t.Fatalf("walls %v", ob.Walls(50, 3))
+	}
+	check := ob.Check(mod.SideBuy, 1, util.OrderBookParam{Bps: 50, MaxImbalance: 0.6, MaxSlippage: 10, WallMultiple: 3, WallPulled: 30})
+	if check.Veto != "" || check.Support != 1 {
+		t.Errorf("check %+v", check)
+	}
+
+	// 价格没有到达就撤掉的支撑墙,之后放弃做多
+	ob.Apply(depthDiff(12, 12, 11, []*mod.Order{{Price: 99.6, Quantity: 0}}, nil))
+	walls = ob.TrackWalls(50, 3)
+	if len(walls) != 1 || !walls[0].Pulled || walls[0].Price != 99.6 {
+		t.Fatalf("vanished walls %v", walls)
+	}
+	check = ob.Check(mod.SideBuy, 1, util.OrderBookParam{Bps: 50, MaxImbalance: 0.6, MaxSlippage: 10, WallMultiple: 3, WallPulled: 30})
+	if check.Veto == "" {
+		t.Errorf("pulled wall not vetoed %+v", check)
+	}
+	check = ob.Check(mod.SideSell, 6, util.OrderBookParam{Bps: 50, MaxSlippage: 10})
+	if check.Veto != "depth not enough" {
+		t.Errorf("check %+v", check)
+	}
+}
@@ -5,16 +5,16 @@ import (
 	"strings"
 	"sync"
 	"time"
+	"tinyquant/src/mod"
 	"tinyquant/src/util"
 
 	. "tinyquant/src/logger"
 
-	"github.com/rootpd/binance"
 	"go.uber.org/zap"
 )
 
 type Position struct {
-	*binance.FuturePositions
+	*mod.Position
 	*sync.RWMutex
 	//存储到mysql
 	PlaceCount          int                       // 加仓次数
@@ -27,7 +27,7 @@ type Position struct {
 }
 
 type MyFutureOrder struct {
-	*binance.ExecutedFutureOrder
+	*mod.FutureOrder
 	//存储到mysql
 	OrdeType  util.ORIGIN_ORDER_STATUS //挂单类型
 	OrderFlag util.ORIGIN_ORDER_FLAG   //仓位标志 0:手动 1:加仓单 2:减仓单
@@ -48,21 +48,25 @@ func (s *Strategy) ReloadPosition() {
 
 func (s *Strategy) LoadPosition() {
 	// 加载当前持仓单
-	res := Binance.GetFutureAccount(s.Symbol)
+	res, err := s.Exchange.GetFuturePositions(s.Symbol)
+	if err != nil {
+		Logger.Error("get future positions failed", zap.Error(err))
+		return
+	}
 	for _, v := range res {
-		if v.PositionSide == string(binance.LONG) {
+		if v.PositionSide == mod.LONG {
 			s.LongPosition.Lock()
-			s.LongPosition.FuturePositions = v
-			s.LongPosition.FuturePositions.PositionAmt = util.Round(s.LongPosition.FuturePositions.PositionAmt, 3)
-			s.LongPosition.FuturePositions.EntryPrice = util.Round(s.LongPosition.FuturePositions.EntryPrice, 2)
+			s.LongPosition.Position = v
+			s.LongPosition.Position.PositionAmt = s.qty(s.LongPosition.Position.PositionAmt)
+			s.LongPosition.Position.EntryPrice = s.price(s.LongPosition.Position.EntryPrice)
 			s.LongPosition.Unlock()
 			continue
 		}
-		if v.PositionSide == string(binance.SHORT) {
+		if v.PositionSide == mod.SHORT {
 			s.ShortPosition.Lock()
-			s.ShortPosition.FuturePositions = v
-			s.ShortPosition.FuturePositions.PositionAmt = util.Round(s.ShortPosition.FuturePositions.PositionAmt, 3)
-			s.ShortPosition.FuturePositions.EntryPrice = util.Round(s.ShortPosition.FuturePositions.EntryPrice, 2)
+			s.ShortPosition.Position = v
+			s.ShortPosition.Position.PositionAmt = s.qty(s.ShortPosition.Position.PositionAmt)
+			s.ShortPosition.Position.EntryPrice = s.price(s.ShortPosition.Position.EntryPrice)
 			s.ShortPosition.Unlock()
 			continue
 		}
@@ -70,17 +74,17 @@ func (s *Strategy) LoadPosition() {
 }
 
 func (s *Strategy) LoadAllOpenOrder() {
-	ts, err := Binance.QueryBinanceAllFutureOrder(s.Symbol)
+	ts, err := s.Exchange.QueryOpenFutureOrders(s.Symbol)
 	if err != nil {
 		return
 	}
 
 	for _, order := range ts {
 		Logger.Info("当前挂单 : ", zap.Any("order", order))
-		s.FutureOrder[order.ClientOrderID] = &MyFutureOrder{ExecutedFutureOrder: order}
-		s.FutureOrder[order.ClientOrderID].Price = util.Round(s.FutureOrder[order.ClientOrderID].Price, 2)
-		s.FutureOrder[order.ClientOrderID].OrigQty = util.Round(s.FutureOrder[order.ClientOrderID].OrigQty, 3)
-		s.FutureOrder[order.ClientOrderID].ExecutedQty = util.Round(s.FutureOrder[order.ClientOrderID].ExecutedQty, 3)
+		s.FutureOrder[order.ClientOrderID] = &MyFutureOrder{FutureOrder: order}
+		s.FutureOrder[order.ClientOrderID].Price = s.price(s.FutureOrder[order.ClientOrderID].Price)
+		s.FutureOrder[order.ClientOrderID].OrigQty = s.qty(s.FutureOrder[order.ClientOrderID].OrigQty)
+		s.FutureOrder[order.ClientOrderID].ExecutedQty = s.qty(s.FutureOrder[order.ClientOrderID].ExecutedQty)
 	}
 
 }
@@ -92,8 +96,12 @@ func (s *Strategy) SaveFutureOrder(futureOrder *MyFutureOrder, clientOrderID str
 		s.Lock()
 		s.FutureOrder[clientOrderID] = futureOrder
 		s.Unlock()
+	} else if futureOrder.OrdeType == util.GRID {
+		s.Lock()
+		s.GridFutureOrder[clientOrderID] = futureOrder
+		s.Unlock()
 	} else {
-		if futureOrder.PositionSide == string(binance.LONG) { //必须为双向持仓
+		if futureOrder.PositionSide == mod.LONG { //必须为双向持仓
 			if futureOrder.OrdeType == util.PIN && futureOrder.OrderFlag == util.ADDPOSITION {
 				s.LongPosition.Lock()
 				s.LongPosition.PinFutureOrder[clientOrderID] = futureOrder
@@ -110,7 +118,7 @@ func (s *Strategy) SaveFutureOrder(futureOrder *MyFutureOrder, clientOrderID str
 				Logger.Error("", zap.Any("订单异常", futureOrder))
 			}
 
-		} else if futureOrder.PositionSide == string(binance.SHORT) {
+		} else if futureOrder.PositionSide == mod.SHORT {
 			if futureOrder.OrdeType == util.PIN && futureOrder.OrderFlag == util.ADDPOSITION {
 				s.ShortPosition.Lock()
 				s.ShortPosition.PinFutureOrder[clientOrderID] = futureOrder
@@ -139,8 +147,12 @@ func (s *Strategy) DelFutureOrder(futureOrder *MyFutureOrder, clientOrderID stri
 		s.Lock()
 		delete(s.FutureOrder, clientOrderID)
 		s.Unlock()
+	} else if futureOrder.OrdeType == util.GRID {
+		s.Lock()
+		delete(s.GridFutureOrder, clientOrderID)
+		s.Unlock()
 	} else {
-		if futureOrder.PositionSide == string(binance.LONG) { //必须为双向持仓
+		if futureOrder.PositionSide == mod.LONG { //必须为双向持仓
 			if futureOrder.OrdeType == util.PIN && futureOrder.OrderFlag == util.ADDPOSITION {
 				s.LongPosition.Lock()
 				delete(s.LongPosition.PinFutureOrder, clientOrderID)
@@ -156,7 +168,7 @@ func (s *Strategy) DelFutureOrder(futureOrder *MyFutureOrder, clientOrderID stri
 			} else {
 				Logger.Error("", zap.Any("订单异常", futureOrder))
 			}
-		} else if futureOrder.PositionSide == string(binance.SHORT) {
+		} else if futureOrder.PositionSide == mod.SHORT {
 			if futureOrder.OrdeType == util.PIN && futureOrder.OrderFlag == util.ADDPOSITION {
 				s.ShortPosition.Lock()
 				delete(s.ShortPosition.PinFutureOrder, clientOrderID)
@@ -187,82 +199,69 @@ func (s *Strategy) ClearPartiallyFilledOrder() {
 			case <-timer.C:
 				curPrice := s.KlineManager.MinuteKlineList.GetNewPrice()
 				Logger.Sugar().Debugf("curPrice : %v", curPrice)
+				ids := []int64{}
 				s.LongPosition.RLock()
 				for _, order := range s.LongPosition.PinFutureOrder {
-					Logger.Sugar().Debugf("LongPosition.PinFutureOrder : %+v OrdeType : %v OrderFlag : %v", order.ExecutedFutureOrder, order.OrdeType, order.OrderFlag)
-					if order.Price > util.PressureLevel || order.Price < util.SupportLevel {
+					Logger.Sugar().Debugf("LongPosition.PinFutureOrder : %+v OrdeType : %v OrderFlag : %v", order.FutureOrder, order.OrdeType, order.OrderFlag)
+					if order.Price > s.Param.PressureLevel || order.Price < s.Param.SupportLevel {
 						return
 					}
 					if (order.OrderFlag == util.ADDPOSITION && order.OrdeType == util.PIN) &&
-						((order.Status == binance.StatusPartiallyFilled && math.Abs(order.Price-curPrice) > 10.0) ||
-							(order.Status == binance.StatusNew && time.Since(order.UpdateTime) > 5*time.Minute)) {
-						Logger.Sugar().Warnf("取消开仓挂单 %+v OrdeType : %v OrderFlag : %v", order.ExecutedFutureOrder, order.OrdeType, order.OrderFlag)
-						_, err := Binance.CancelBinanceFutureOrder(s.Symbol, int64(order.OrderID))
-						if err != nil {
-							Logger.Error("cancel future order failed", zap.Error(err), zap.Any("order", order))
-						}
+						((order.Status == mod.StatusPartiallyFilled && math.Abs(order.Price-curPrice) > 10.0) ||
+							(order.Status == mod.StatusNew && time.Since(order.UpdateTime) > 5*time.Minute)) {
+						Logger.Sugar().Warnf("取消开仓挂单 %+v OrdeType : %v OrderFlag : %v", order.FutureOrder, order.OrdeType, order.OrderFlag)
+						ids = append(ids, int64(order.OrderID))
 					}
 				}
 				for _, order := range s.LongPosition.CloseFutureOrder {
-					Logger.Sugar().Debugf("LongPosition.CloseFutureOrder : %+v OrdeType : %v OrderFlag : %v", order.ExecutedFutureOrder, order.OrdeType, order.OrderFlag)
+					Logger.Sugar().Debugf("LongPosition.CloseFutureOrder : %+v OrdeType : %v OrderFlag : %v", order.FutureOrder, order.OrdeType, order.OrderFlag)
 					if order.OrderFlag == util.DELPOSITION && order.OrdeType == util.PINCLOSECOMMON && time.Since(order.UpdateTime) > 15*time.Minute {
-						Logger.Sugar().Warnf("取消插针平仓挂单 %+v OrdeType : %v OrderFlag : %v", order.ExecutedFutureOrder, order.OrdeType, order.OrderFlag)
-						_, err := Binance.CancelBinanceFutureOrder(s.Symbol, int64(order.OrderID))
-						if err != nil {
-							Logger.Error("cancel future order failed", zap.Error(err), zap.Any("order", order))
-						}
+						Logger.Sugar().Warnf("取消插针平仓挂单 %+v OrdeType : %v OrderFlag : %v", order.FutureOrder, order.OrdeType, order.OrderFlag)
+						ids = append(ids, int64(order.OrderID))
 					}
 				}
 				s.LongPosition.RUnlock()
 
 				s.ShortPosition.RLock()
 				for _, order := range s.ShortPosition.PinFutureOrder {
-					Logger.Sugar().Debugf("ShortPosition.PinFutureOrder : %+v OrdeType : %v OrderFlag : %v", order.ExecutedFutureOrder, order.OrdeType, order.OrderFlag)
-					if order.Price > util.PressureLevel || order.Price < util.SupportLevel {
+					Logger.Sugar().Debugf("ShortPosition.PinFutureOrder : %+v OrdeType : %v OrderFlag : %v", order.FutureOrder, order.OrdeType, order.OrderFlag)
+					if order.Price > s.Param.PressureLevel || order.Price < s.Param.SupportLevel {
 						return
 					}
 					if (order.OrderFlag == util.ADDPOSITION && order.OrdeType == util.PIN) &&
-						((order.Status == binance.StatusPartiallyFilled && math.Abs(order.Price-curPrice) > 10.0) ||
-							(order.Status == binance.StatusNew && time.Since(order.UpdateTime) > 5*time.Minute)) {
-						Logger.Sugar().Warnf("取消开仓挂单 %+v OrdeType : %v OrderFlag : %v", order.ExecutedFutureOrder, order.OrdeType, order.OrderFlag)
-						_, err := Binance.CancelBinanceFutureOrder(s.Symbol, int64(order.OrderID))
-						if err != nil {
-							Logger.Error("cancel future order failed", zap.Error(err), zap.Any("order", order))
-						}
+						((order.Status == mod.StatusPartiallyFilled && math.Abs(order.Price-curPrice) > 10.0) ||
+							(order.Status == mod.StatusNew && time.Since(order.UpdateTime) > 5*time.Minute)) {
+						Logger.Sugar().Warnf("取消开仓挂单 %+v OrdeType : %v OrderFlag : %v", order.FutureOrder, order.OrdeType, order.OrderFlag)
+						ids = append(ids, int64(order.OrderID))
 					}
 				}
 				for _, order := range s.ShortPosition.CloseFutureOrder {
-					Logger.Sugar().Debugf("ShortPosition.CloseFutureOrder : %+v OrdeType : %v OrderFlag : %v", order.ExecutedFutureOrder, order.OrdeType, order.OrderFlag)
+					Logger.Sugar().Debugf("ShortPosition.CloseFutureOrder : %+v OrdeType : %v OrderFlag : %v", order.FutureOrder, order.OrdeType, order.OrderFlag)
 					if order.OrderFlag == util.DELPOSITION && order.OrdeType == util.PINCLOSECOMMON && time.Since(order.UpdateTime) > 15*time.Minute {
-						Logger.Sugar().Warnf("取消插针平仓挂单 %+v OrdeType : %v OrderFlag : %v", order.ExecutedFutureOrder, order.OrdeType, order.OrderFlag)
-						_, err := Binance.CancelBinanceFutureOrder(s.Symbol, int64(order.OrderID))
-						if err != nil {
-							Logger.Error("cancel future order failed", zap.Error(err), zap.Any("order", order))
-						}
+						Logger.Sugar().Warnf("取消插针平仓挂单 %+v OrdeType : %v OrderFlag : %v", order.FutureOrder, order.OrdeType, order.OrderFlag)
+						ids = append(ids, int64(order.OrderID))
 					}
 				}
 				s.ShortPosition.RUnlock()
 
 				s.RLock()
 				for _, order := range s.FutureOrder {
-					Logger.Sugar().Debugf("s.FutureOrder : %+v OrdeType : %v OrderFlag : %v", order.ExecutedFutureOrder, order.OrdeType, order.OrderFlag)
-					if (order.PositionSide == string(binance.LONG) && order.Side == binance.SideBuy) ||
-						(order.PositionSide == string(binance.SHORT) && order.Side == binance.SideSell) {
-						if order.Price > util.PressureLevel || order.Price < util.SupportLevel {
+					Logger.Sugar().Debugf("s.FutureOrder : %+v OrdeType : %v OrderFlag : %v", order.FutureOrder, order.OrdeType, order.OrderFlag)
+					if (order.PositionSide == mod.LONG && order.Side == mod.SideBuy) ||
+						(order.PositionSide == mod.SHORT && order.Side == mod.SideSell) {
+						if order.Price > s.Param.PressureLevel || order.Price < s.Param.SupportLevel {
 							return
 						}
-						if (order.Status == binance.StatusPartiallyFilled && math.Abs(order.Price-curPrice) > 10.0) ||
-							(order.Status == binance.StatusNew && time.Since(order.UpdateTime) > 5*time.Minute) {
-							Logger.Sugar().Warnf("取消开仓挂单 %+v OrdeType : %v OrderFlag : %v", order.ExecutedFutureOrder, order.OrdeType, order.OrderFlag)
-							_, err := Binance.CancelBinanceFutureOrder(s.Symbol, int64(order.OrderID))
-							if err != nil {
-								Logger.Error("cancel future order failed", zap.Error(err), zap.Any("order", order))
-							}
+						if (order.Status == mod.StatusPartiallyFilled && math.Abs(order.Price-curPrice) > 10.0) ||
+							(order.Status == mod.StatusNew && time.Since(order.UpdateTime) > 5*time.Minute) {
+							Logger.Sugar().Warnf("取消开仓挂单 %+v OrdeType : %v OrderFlag : %v", order.FutureOrder, order.OrdeType, order.OrderFlag)
+							ids = append(ids, int64(order.OrderID))
 						}
 					}
 				}
 				s.RUnlock()
 
+				s.CancelOrders(ids)
 				timer.Reset(1 * time.Minute)
 			}
 		}
@@ -278,48 +277,41 @@ func (s *Strategy) ScanCloseFutureOrder() {
 			case <-timer.C:
 				curPrice := s.KlineManager.MinuteKlineList.GetNewPrice()
 				Logger.Sugar().Debugf("curPrice : %v", curPrice)
+				ids := []int64{}
 				s.LongPosition.RLock()
 				for _, order := range s.LongPosition.CloseFutureOrder {
-					Logger.Sugar().Debugf("LongPosition.CloseFutureOrder : %+v OrdeType : %v OrderFlag : %v", order.ExecutedFutureOrder, order.OrdeType, order.OrderFlag)
-					if math.Abs(order.Price-curPrice) > curPrice*util.CancelCloseOrderLevel {
-						Logger.Sugar().Warnf("取消平仓挂单 %+v OrdeType : %v OrderFlag : %v", order.ExecutedFutureOrder, order.OrdeType, order.OrderFlag)
-						_, err := Binance.CancelBinanceFutureOrder(s.Symbol, int64(order.OrderID))
-						if err != nil {
-							Logger.Error("cancel future order failed", zap.Error(err), zap.Any("order", order))
-						}
+					Logger.Sugar().Debugf("LongPosition.CloseFutureOrder : %+v OrdeType : %v OrderFlag : %v", order.FutureOrder, order.OrdeType, order.OrderFlag)
+					if math.Abs(order.Price-curPrice) > curPrice*s.Param.CancelCloseOrderLevel {
+						Logger.Sugar().Warnf("取消平仓挂单 %+v OrdeType : %v OrderFlag : %v", order.FutureOrder, order.OrdeType, order.OrderFlag)
+						ids = append(ids, int64(order.OrderID))
 					}
 				}
 				s.LongPosition.RUnlock()
 
 				s.ShortPosition.RLock()
 				for _, order := range s.ShortPosition.CloseFutureOrder {
-					Logger.Sugar().Debugf("ShortPosition.CloseFutureOrder : %+v OrdeType : %v OrderFlag : %v", order.ExecutedFutureOrder, order.OrdeType, order.OrderFlag)
-					if math.Abs(order.Price-curPrice) > curPrice*util.CancelCloseOrderLevel {
-						Logger.Sugar().Warnf("取消平仓挂单 %+v OrdeType : %v OrderFlag : %v", order.ExecutedFutureOrder, order.OrdeType, order.OrderFlag)
-						_, err := Binance.CancelBinanceFutureOrder(s.Symbol, int64(order.OrderID))
-						if err != nil {
-							Logger.Error("cancel future order failed", zap.Error(err), zap.Any("order", order))
-						}
+					Logger.Sugar().Debugf("ShortPosition.CloseFutureOrder : %+v OrdeType : %v OrderFlag : %v", order.FutureOrder, order.OrdeType, order.OrderFlag)
+					if math.Abs(order.Price-curPrice) > curPrice*s.Param.CancelCloseOrderLevel {
+						Logger.Sugar().Warnf("取消平仓挂单 %+v OrdeType : %v OrderFlag : %v", order.FutureOrder, order.OrdeType, order.OrderFlag)
+						ids = append(ids, int64(order.OrderID))
 					}
 				}
 				s.ShortPosition.RUnlock()
 
 				s.RLock()
 				for _, order := range s.FutureOrder {
-					Logger.Sugar().Debugf("s.FutureOrder : %+v OrdeType : %v OrderFlag : %v", order.ExecutedFutureOrder, order.OrdeType, order.OrderFlag)
-					if (order.Type != binance.TypeSTOP) &&
-						((order.PositionSide == string(binance.LONG) && order.Side == binance.SideSell) ||
-							(order.PositionSide == string(binance.SHORT) && order.Side == binance.SideBuy)) {
-						if math.Abs(order.Price-curPrice) > curPrice*util.CancelCloseOrderLevel {
-							Logger.Sugar().Warnf("取消平仓挂单 %+v OrdeType : %v OrderFlag : %v", order.ExecutedFutureOrder, order.OrdeType, order.OrderFlag)
-							_, err := Binance.CancelBinanceFutureOrder(s.Symbol, int64(order.OrderID))
-							if err != nil {
-								Logger.Error("cancel future order failed", zap.Error(err), zap.Any("order", order))
-							}
+					Logger.Sugar().Debugf("s.FutureOrder : %+v OrdeType : %v OrderFlag : %v", order.FutureOrder, order.OrdeType, order.OrderFlag)
+					if !order.Type.IsConditional() &&
+						((order.PositionSide == mod.LONG && order.Side == mod.SideSell) ||
+							(order.PositionSide == mod.SHORT && order.Side == mod.SideBuy)) {
+						if math.Abs(order.Price-curPrice) > curPrice*s.Param.CancelCloseOrderLevel {
+							Logger.Sugar().Warnf("取消平仓挂单 %+v OrdeType : %v OrderFlag : %v", order.FutureOrder, order.OrdeType, order.OrderFlag)
+							ids = append(ids, int64(order.OrderID))
 						}
 					}
 				}
 				s.RUnlock()
+				s.CancelOrders(ids)
 				timer.Reset(1 * time.Minute)
 			}
 		}
@@ -327,9 +319,9 @@ func (s *Strategy) ScanCloseFutureOrder() {
 }
 
 //取消所有平仓单
-func (s *Strategy) CancelAllCloseFutureOrder(positionSide binance.PositionSide) {
+func (s *Strategy) CancelAllCloseFutureOrder(positionSide mod.PositionSide) {
 	sli := []int64{}
-	if positionSide == binance.LONG {
+	if positionSide == mod.LONG {
 		s.LongPosition.RLock()
 		for _, order := range s.LongPosition.CloseFutureOrder {
 			sli = append(sli, int64(order.OrderID))
@@ -338,7 +330,7 @@ func (s *Strategy) CancelAllCloseFutureOrder(positionSide binance.PositionSide)
 
 		s.RLock()
 		for _, order := range s.FutureOrder {
-			if order.PositionSide == string(binance.LONG) && order.Side == binance.SideSell {
+			if order.PositionSide == mod.LONG && order.Side == mod.SideSell {
 				sli = append(sli, int64(order.OrderID))
 			}
 		}
@@ -352,17 +344,25 @@ func (s *Strategy) CancelAllCloseFutureOrder(positionSide binance.PositionSide)
 
 		s.RLock()
 		for _, order := range s.FutureOrder {
-			if order.PositionSide == string(binance.SHORT) && order.Side == binance.SideBuy {
+			if order.PositionSide == mod.SHORT && order.Side == mod.SideBuy {
 				sli = append(sli, int64(order.OrderID))
 			}
 		}
 		s.RUnlock()
 	}
 
-	for _, v := range sli {
-		_, err := Binance.CancelBinanceFutureOrder(s.Symbol, v)
+	s.CancelOrders(sli)
+}
+
+// 批量撤单,失败的单独打日志
+func (s *Strategy) CancelOrders(ids []int64) {
+	if len(ids) == 0 {
+		return
+	}
+	_, errs := s.Exchange.CancelFutureOrders(s.Symbol, ids)
+	for i, err := range errs {
 		if err != nil {
-			Logger.Error("cancel future order failed", zap.Error(err), zap.Any("orderId", v))
+			Logger.Error("cancel future order failed", zap.Error(err), zap.Any("orderId", ids[i]))
 		}
 	}
 }
@@ -376,28 +376,29 @@ func (s *Strategy) GetLongBetweenAllCloseFutureOrderAndPositionD_Value() (float6
 	var quantity2 float64 = 0
 	var quantity3 float64 = 0
 	for _, order := range s.LongPosition.CloseFutureOrder {
-		quantity += util.Round(order.OrigQty, 3)
+		quantity += s.qty(order.OrigQty)
 		quantity1 += order.OrigQty
 	}
 	// Logger.Sugar().Infof("平仓挂单的仓位1 : %v", quantity)
 	for _, order := range s.LongPosition.PinFutureOrder {
-		if order.Status == binance.StatusPartiallyFilled {
-			quantity += util.Round(order.ExecutedQty, 3)
-			quantity2 += util.Round(order.ExecutedQty, 3)
+		if order.Status == mod.StatusPartiallyFilled {
+			quantity += s.qty(order.ExecutedQty)
+			quantity2 += s.qty(order.ExecutedQty)
 		}
 	}
 	// Logger.Sugar().Infof("平仓挂单的仓位2 : %v", quantity)
 	s.RLock()
 	defer s.RUnlock()
 	for _, order := range s.FutureOrder {
-		if order.PositionSide == string(binance.LONG) && order.Side == binance.SideSell && order.Type != binance.TypeSTOP {
-			quantity += util.Round(order.OrigQty, 3)
-			quantity3 += util.Round(order.OrigQty, 3)
+		if order.PositionSide == mod.LONG && order.Side == mod.SideSell && !order.Type.IsConditional() {
+			quantity += s.qty(order.OrigQty)
+			quantity3 += s.qty(order.OrigQty)
 		}
 	}
 	// Logger.Sugar().Infof("平仓挂单的仓位3 : %v", quantity)
-	Logger.Sugar().Infof("[%v %v %v]多单仓位 : %v 价格 : %v", util.Round(quantity1, 3), util.Round(quantity2, 3), util.Round(quantity3, 3), util.Round(s.LongPosition.PositionAmt, 3), util.Round(s.LongPosition.EntryPrice, 2))
-	return util.Round(s.LongPosition.PositionAmt, 3), util.Round(quantity, 3), util.Round(s.LongPosition.EntryPrice, 2)
+	positionAmt := s.qty(math.Max(s.LongPosition.PositionAmt-s.gridPositionAmt(mod.LONG), 0))
+	Logger.Sugar().Infof("[%v %v %v]多单仓位 : %v 价格 : %v", s.qty(quantity1), s.qty(quantity2), s.qty(quantity3), positionAmt, s.price(s.LongPosition.EntryPrice))
+	return positionAmt, s.qty(quantity), s.price(s.LongPosition.EntryPrice)
 }
 
 //获取空单仓位 所有平仓挂单的仓位 当前持仓价格
@@ -409,37 +410,55 @@ func (s *Strategy) GetShortBetweenAllCloseFutureOrderAndPositionD_Value() (float
 	var quantity2 float64 = 0
 	var quantity3 float64 = 0
 	for _, order := range s.ShortPosition.CloseFutureOrder {
-		quantity += util.Round(math.Abs(order.OrigQty), 3)
-		quantity1 += util.Round(math.Abs(order.OrigQty), 3)
+		quantity += s.qty(math.Abs(order.OrigQty))
+		quantity1 += s.qty(math.Abs(order.OrigQty))
 	}
 	// Logger.Sugar().Infof("平仓挂单的仓位1 : %v", quantity)
 	for _, order := range s.ShortPosition.PinFutureOrder {
-		if order.Status == binance.StatusPartiallyFilled {
-			quantity += util.Round(math.Abs(order.ExecutedQty), 3)
-			quantity2 += util.Round(math.Abs(order.ExecutedQty), 3)
+		if order.Status == mod.StatusPartiallyFilled {
+			quantity += s.qty(math.Abs(order.ExecutedQty))
+			quantity2 += s.qty(math.Abs(order.ExecutedQty))
 		}
 	}
 	// Logger.Sugar().Infof("平仓挂单的仓位2 : %v", quantity)
 	s.RLock()
 	defer s.RUnlock()
 	for _, order := range s.FutureOrder {
-		if order.PositionSide == string(binance.SHORT) && order.Side == binance.SideBuy && order.Type != binance.TypeSTOP {
-			quantity += util.Round(math.Abs(order.OrigQty), 3)
-			quantity3 += util.Round(math.Abs(order.OrigQty), 3)
+		if order.PositionSide == mod.SHORT && order.Side == mod.SideBuy && !order.Type.IsConditional() {
+			quantity += s.qty(math.Abs(order.OrigQty))
+			quantity3 += s.qty(math.Abs(order.OrigQty))
 		}
 	}
 	// Logger.Sugar().Infof("平仓挂单的仓位3 : %v", quantity)
-	Logger.Sugar().Infof("[%v %v %v]空单仓位 : %v 价格 : %v", util.Round(quantity1, 3), util.Round(quantity2, 3), util.Round(quantity3, 3), util.Round(math.Abs(s.ShortPosition.PositionAmt), 3), util.Round(s.ShortPosition.EntryPrice, 2))
-	return util.Round(math.Abs(s.ShortPosition.PositionAmt), 3), util.Round(quantity, 3), util.Round(s.ShortPosition.EntryPrice, 2)
+	positionAmt := s.qty(math.Max(math.Abs(s.ShortPosition.PositionAmt)-s.gridPositionAmt(mod.SHORT), 0))
+	Logger.Sugar().Infof("[%v %v %v]空单仓位 : %v 价格 : %v", s.qty(quantity1), s.qty(quantity2), s.qty(quantity3), positionAmt, s.price(s.ShortPosition.EntryPrice))
+	return positionAmt, s.qty(quantity), s.price(s.ShortPosition.EntryPrice)
+}
+
+// 网格持有的仓位,由网格自己的平仓单平掉,插针的平仓单和止损单不包含这部分
+// 未成交的网格平仓单数量加上部分成交的网格开仓单数量,调用方需要持有 s 的读锁
+func (s *Strategy) gridPositionAmt(positionSide mod.PositionSide) float64 {
+	var quantity float64
+	for _, order := range s.GridFutureOrder {
+		if order.PositionSide != positionSide {
+			continue
+		}
+		if order.OrderFlag == util.DELPOSITION {
+			quantity += math.Abs(order.OrigQty) - math.Abs(order.ExecutedQty)
+		} else {
+			quantity += math.Abs(order.ExecutedQty)
+		}
+	}
+	return quantity
 }
 
 func (s *Strategy) GetLongShortPinCloseFutureOrder() (bool, bool) {
 	long, short := false, false
 	s.LongPosition.RLock()
 	for _, order := range s.LongPosition.CloseFutureOrder {
-		Logger.Sugar().Debugf("LongPosition.CloseFutureOrder : %+v OrdeType : %v OrderFlag : %v", order.ExecutedFutureOrder, order.OrdeType, order.OrderFlag)
+		Logger.Sugar().Debugf("LongPosition.CloseFutureOrder : %+v OrdeType : %v OrderFlag : %v", order.FutureOrder, order.OrdeType, order.OrderFlag)
 		if order.OrderFlag == util.DELPOSITION && order.OrdeType == util.PINCLOSECOMMON {
-			Logger.Sugar().Warnf("获取到多单插针平仓挂单 %+v OrdeType : %v OrderFlag : %v", order.ExecutedFutureOrder, order.OrdeType, order.OrderFlag)
+			Logger.Sugar().Warnf("获取到多单插针平仓挂单 %+v OrdeType : %v OrderFlag : %v", order.FutureOrder, order.OrdeType, order.OrderFlag)
 			long = true
 			break
 		}
@@ -447,9 +466,9 @@ func (s *Strategy) GetLongShortPinCloseFutureOrder() (bool, bool) {
 	s.LongPosition.RUnlock()
 	s.ShortPosition.RLock()
 	for _, order := range s.ShortPosition.CloseFutureOrder {
-		Logger.Sugar().Debugf("ShortPosition.CloseFutureOrder : %+v OrdeType : %v OrderFlag : %v", order.ExecutedFutureOrder, order.OrdeType, order.OrderFlag)
+		Logger.Sugar().Debugf("ShortPosition.CloseFutureOrder : %+v OrdeType : %v OrderFlag : %v", order.FutureOrder, order.OrdeType, order.OrderFlag)
 		if order.OrderFlag == util.DELPOSITION && order.OrdeType == util.PINCLOSECOMMON {
-			Logger.Sugar().Warnf("获取到空单插针平仓挂单 %+v OrdeType : %v OrderFlag : %v", order.ExecutedFutureOrder, order.OrdeType, order.OrderFlag)
+			Logger.Sugar().Warnf("获取到空单插针平仓挂单 %+v OrdeType : %v OrderFlag : %v", order.FutureOrder, order.OrdeType, order.OrderFlag)
 			short = true
 			break
 		}
@@ -468,31 +487,31 @@ func (s *Strategy) ScanPositionAndCreatCloseFutureOrder() {
 			case <-timer.C:
 				curPrice := s.KlineManager.MinuteKlineList.GetNewPrice()
 				long_positionAmt, long_closePosition, long_entryPrice := s.GetLongBetweenAllCloseFutureOrderAndPositionD_Value()
-				if long_positionAmt-long_closePosition >= util.Quantity && math.Abs(curPrice-long_entryPrice) < curPrice*util.CreatCloseOrderLevel {
+				if long_positionAmt-long_closePosition >= s.Param.Quantity && math.Abs(curPrice-long_entryPrice) < curPrice*s.Param.CreatCloseOrderLevel {
 					newOrder := &OriginOrder{
 						Symbol:       s.Symbol,
 						OrderStatus:  util.CLOSECOMMON,
-						Side:         binance.SideSell,
-						PositionSide: binance.LONG,
+						Side:         mod.SideSell,
+						PositionSide: mod.LONG,
 						IsTest:       util.PlaceTest,
 						OrderFlag:    util.DELPOSITION,
 					}
-					newOrder.Quantity = util.Round(long_positionAmt-long_closePosition, 3)
-					newOrder.Price = util.Round(long_entryPrice+long_entryPrice*util.Profits, 2)
+					newOrder.Quantity = s.qty(long_positionAmt - long_closePosition)
+					newOrder.Price = s.price(long_entryPrice + long_entryPrice*s.Param.Profits)
 					s.PlaceOrderManager.MakePlaceOrder(newOrder)
 				}
 				short_positionAmt, short_closePosition, short_entryPrice := s.GetShortBetweenAllCloseFutureOrderAndPositionD_Value()
-				if short_positionAmt-short_closePosition >= util.Quantity && math.Abs(curPrice-short_closePosition) < curPrice*util.CreatCloseOrderLevel {
+				if short_positionAmt-short_closePosition >= s.Param.Quantity && math.Abs(curPrice-short_closePosition) < curPrice*s.Param.CreatCloseOrderLevel {
 					newOrder := &OriginOrder{
 						Symbol:       s.Symbol,
 						OrderStatus:  util.CLOSECOMMON,
-						Side:         binance.SideBuy,
-						PositionSide: binance.SHORT,
+						Side:         mod.SideBuy,
+						PositionSide: mod.SHORT,
 						IsTest:       util.PlaceTest,
 						OrderFlag:    util.DELPOSITION,
 					}
-					newOrder.Quantity = util.Round(short_positionAmt-short_closePosition, 3)
-					newOrder.Price = util.Round(short_entryPrice-short_entryPrice*util.Profits, 2)
+					newOrder.Quantity = s.qty(short_positionAmt - short_closePosition)
+					newOrder.Price = s.price(short_entryPrice - short_entryPrice*s.Param.Profits)
 					s.PlaceOrderManager.MakePlaceOrder(newOrder)
 				}
 				//检查止损单
@@ -500,24 +519,24 @@ func (s *Strategy) ScanPositionAndCreatCloseFutureOrder() {
 				long_close, short_close := []int64{}, []int64{}
 				s.LongPosition.RLock()
 				for _, order := range s.LongPosition.CloseAllFutureOrder {
-					Logger.Sugar().Debugf("LongPosition.CloseAllFutureOrder : %+v OrdeType : %v OrderFlag : %v", order.ExecutedFutureOrder, order.OrdeType, order.OrderFlag)
+					Logger.Sugar().Debugf("LongPosition.CloseAllFutureOrder : %+v OrdeType : %v OrderFlag : %v", order.FutureOrder, order.OrdeType, order.OrderFlag)
 					long += order.OrigQty
 					long_close = append(long_close, int64(order.OrderID))
 				}
 				s.LongPosition.RUnlock()
 				s.ShortPosition.RLock()
 				for _, order := range s.ShortPosition.CloseAllFutureOrder {
-					Logger.Sugar().Debugf("ShortPosition.CloseAllFutureOrder : %+v OrdeType : %v OrderFlag : %v", order.ExecutedFutureOrder, order.OrdeType, order.OrderFlag)
+					Logger.Sugar().Debugf("ShortPosition.CloseAllFutureOrder : %+v OrdeType : %v OrderFlag : %v", order.FutureOrder, order.OrdeType, order.OrderFlag)
 					short += order.OrigQty
 					short_close = append(short_close, int64(order.OrderID))
 				}
 				s.ShortPosition.RUnlock()
 				s.RLock()
 				for _, order := range s.FutureOrder {
-					if order.Type == binance.TypeSTOP {
-						if order.PositionSide == string(binance.LONG) && order.Side == binance.SideSell {
+					if order.Type.IsStop() {
+						if order.PositionSide == mod.LONG && order.Side == mod.SideSell {
 							long += order.OrigQty
-						} else if order.PositionSide == string(binance.SHORT) && order.Side == binance.SideBuy {
+						} else if order.PositionSide == mod.SHORT && order.Side == mod.SideBuy {
 							short += order.OrigQty
 						}
 					}
@@ -528,19 +547,14 @@ func (s *Strategy) ScanPositionAndCreatCloseFutureOrder() {
 					//先取消止损单
 					sli = long_close
 					Logger.Sugar().Warnf("多单止损单不足")
-					s.MakeCloseOrder(&MyFutureOrder{ExecutedFutureOrder: &binance.ExecutedFutureOrder{PositionSide: string(binance.LONG), Side: binance.SideBuy}})
+					s.MakeCloseOrder(&MyFutureOrder{FutureOrder: &mod.FutureOrder{PositionSide: mod.LONG, Side: mod.SideBuy}})
 				}
 				if short < short_positionAmt {
 					sli = short_close
 					Logger.Sugar().Warnf("空止损单不足")
-					s.MakeCloseOrder(&MyFutureOrder{ExecutedFutureOrder: &binance.ExecutedFutureOrder{PositionSide: string(binance.SHORT), Side: binance.SideSell}})
-				}
-				for _, v := range sli {
-					_, err := Binance.CancelBinanceFutureOrder(s.Symbol, v)
-					if err != nil {
-						Logger.Error("cancel future order failed", zap.Error(err), zap.Any("orderId", v))
-					}
+					s.MakeCloseOrder(&MyFutureOrder{FutureOrder: &mod.FutureOrder{PositionSide: mod.SHORT, Side: mod.SideSell}})
 				}
+				s.CancelOrders(sli)
 				timer.Reset(5 * time.Second)
 			}
 		}
@@ -561,10 +575,10 @@ func (s *Strategy) ScanFutureOrder() {
 
 				s.LongPosition.RLock()
 				for _, order := range s.LongPosition.PinFutureOrder {
-					res, err := Binance.QueryBinanceOneFutureOrder(s.Symbol, order.ClientOrderID)
+					res, err := s.Exchange.QueryFutureOrder(s.Symbol, order.ClientOrderID)
 					if err != nil {
 						if strings.Contains(err.Error(), "Order does not exist.") {
-							Logger.Sugar().Errorf("多单开仓挂单丢失 %v %+v OrdeType : %v OrderFlag : %v", err, order.ExecutedFutureOrder, order.OrdeType, order.OrderFlag)
+							Logger.Sugar().Errorf("多单开仓挂单丢失 %v %+v OrdeType : %v OrderFlag : %v", err, order.FutureOrder, order.OrdeType, order.OrderFlag)
 							sli = append(sli, order)
 						} else {
 							Logger.Sugar().Errorf("%v", err)
@@ -575,10 +589,10 @@ func (s *Strategy) ScanFutureOrder() {
 				}
 
 				for _, order := range s.LongPosition.CloseFutureOrder {
-					res, err := Binance.QueryBinanceOneFutureOrder(s.Symbol, order.ClientOrderID)
+					res, err := s.Exchange.QueryFutureOrder(s.Symbol, order.ClientOrderID)
 					if err != nil {
 						if strings.Contains(err.Error(), "Order does not exist.") {
-							Logger.Sugar().Errorf("多单平仓挂单丢失 %v %+v OrdeType : %v OrderFlag : %v", err, order.ExecutedFutureOrder, order.OrdeType, order.OrderFlag)
+							Logger.Sugar().Errorf("多单平仓挂单丢失 %v %+v OrdeType : %v OrderFlag : %v", err, order.FutureOrder, order.OrdeType, order.OrderFlag)
 							sli = append(sli, order)
 						} else {
 							Logger.Sugar().Errorf("%v", err)
@@ -591,10 +605,10 @@ func (s *Strategy) ScanFutureOrder() {
 
 				s.ShortPosition.RLock()
 				for _, order := range s.ShortPosition.PinFutureOrder {
-					res, err := Binance.QueryBinanceOneFutureOrder(s.Symbol, order.ClientOrderID)
+					res, err := s.Exchange.QueryFutureOrder(s.Symbol, order.ClientOrderID)
 					if err != nil {
 						if strings.Contains(err.Error(), "Order does not exist.") {
-							Logger.Sugar().Errorf("空单开仓挂单丢失 %v %+v OrdeType : %v OrderFlag : %v", err, order.ExecutedFutureOrder, order.OrdeType, order.OrderFlag)
+							Logger.Sugar().Errorf("空单开仓挂单丢失 %v %+v OrdeType : %v OrderFlag : %v", err, order.FutureOrder, order.OrdeType, order.OrderFlag)
 							sli = append(sli, order)
 						} else {
 							Logger.Sugar().Errorf("%v", err)
@@ -605,10 +619,10 @@ func (s *Strategy) ScanFutureOrder() {
 				}
 
 				for _, order := range s.ShortPosition.CloseFutureOrder {
-					res, err := Binance.QueryBinanceOneFutureOrder(s.Symbol, order.ClientOrderID)
+					res, err := s.Exchange.QueryFutureOrder(s.Symbol, order.ClientOrderID)
 					if err != nil {
 						if strings.Contains(err.Error(), "Order does not exist.") {
-							Logger.Sugar().Errorf("空单平仓挂单丢失 %v %+v OrdeType : %v OrderFlag : %v", err, order.ExecutedFutureOrder, order.OrdeType, order.OrderFlag)
+							Logger.Sugar().Errorf("空单平仓挂单丢失 %v %+v OrdeType : %v OrderFlag : %v", err, order.FutureOrder, order.OrdeType, order.OrderFlag)
 							sli = append(sli, order)
 						} else {
 							Logger.Sugar().Errorf("%v", err)
@@ -621,10 +635,10 @@ func (s *Strategy) ScanFutureOrder() {
 
 				s.RLock()
 				for _, order := range s.FutureOrder {
-					res, err := Binance.QueryBinanceOneFutureOrder(s.Symbol, order.ClientOrderID)
+					res, err := s.Exchange.QueryFutureOrder(s.Symbol, order.ClientOrderID)
 					if err != nil {
 						if strings.Contains(err.Error(), "Order does not exist.") {
-							Logger.Sugar().Errorf("err : %v \n手动挂单丢失 %+v OrdeType : %v OrderFlag : %v", err, order.ExecutedFutureOrder, order.OrdeType, order.OrderFlag)
+							Logger.Sugar().Errorf("err : %v \n手动挂单丢失 %+v OrdeType : %v OrderFlag : %v", err, order.FutureOrder, order.OrdeType, order.OrderFlag)
 							sli = append(sli, order)
 						} else {
 							Logger.Sugar().Errorf("%v", err)
@@ -0,0 +1,89 @@
+package strategy_test
+
+import (
+	"sync"
+	"testing"
+	"tinyquant/src/mod"
+	"tinyquant/src/quant"
+	"tinyquant/src/strategy"
+	"tinyquant/src/util"
+)
+
+// 记录下单请求,持仓固定的交易所
+type positionExchange struct {
+	quant.Exchange
+	positions []*mod.Position
+	orders    []*mod.OrderRequest
+}
+
+func (e *positionExchange) GetSymbolInfo(symbol string) (*mod.SymbolInfo, error) {
+	return &mod.SymbolInfo{Symbol: symbol, TickSize: 0.01, StepSize: 0.001}, nil
+}
+
+func (e *positionExchange) GetFuturePositions(symbol string) ([]*mod.Position, error) {
+	return e.positions, nil
+}
+
+func (e *positionExchange) NewFutureOrder(req *mod.OrderRequest) (*mod.FutureOrder, error) {
+	e.orders = append(e.orders, req)
+	return &mod.FutureOrder{Symbol: req.Symbol, ClientOrderID: req.ClientOrderID, Side: req.Side, PositionSide: req.PositionSide,
+		OrigQty: req.Quantity, Price: req.Price, StopPrice: req.StopPrice}, nil
+}
+
+// 实盘路径: 插针和网格同一个交易对,插针的止损单不包含网格的仓位
+func Test_PinStopExcludesGrid(t *testing.T) {
+	ex := &positionExchange{positions: []*mod.Position{
+		{Symbol: util.ETHUSDT, PositionSide: mod.LONG, PositionAmt: 0.03, EntryPrice: 1500},
+		{Symbol: util.ETHUSDT, PositionSide: mod.SHORT, PositionAmt: -0.01, EntryPrice: 1520},
+	}}
+	s := &strategy.Strategy{RWMutex: &sync.RWMutex{}, Exchange: ex, Param: &util.QuantParam{Quantity: 0.01, SupportLevel: 1000, PressureLevel: 2000}}
+	s.InitState(util.ETHUSDT)
+	s.PlaceOrderManager.Account = &strategy.BinanceFutureAsset{RWMutex: &sync.RWMutex{}, Symbol: util.ETHUSDT, Exchange: ex}
+	s.LoadPosition()
+
+	// 多单 0.01 等网格平仓单平掉,另有 0.005 是部分成交的网格开仓单,空单全部是网格的
+	grid := func(id string, positionSide mod.PositionSide, side mod.OrderSide, flag util.ORIGIN_ORDER_FLAG, orig, executed float64) *strategy.MyFutureOrder {
+		o := &strategy.MyFutureOrder{
+			FutureOrder: &mod.FutureOrder{ClientOrderID: id, PositionSide: positionSide, Side: side, OrigQty: orig, ExecutedQty: executed},
+			OrdeType:    util.GRID,
+			OrderFlag:   flag,
+		}
+		s.SaveFutureOrder(o, id)
+		return o
+	}
+	gridClose := grid("g1", mod.LONG, mod.SideSell, util.DELPOSITION, 0.01, 0)
+	grid("g2", mod.LONG, mod.SideBuy, util.ADDPOSITION, 0.01, 0.005)
+	grid("g3", mod.SHORT, mod.SideBuy, util.DELPOSITION, 0.01, 0)
+
+	if amt, _, _ := s.GetLongBetweenAllCloseFutureOrderAndPositionD_Value(); amt != 0.015 {
+		t.Errorf("long position without grid %v, want 0.015", amt)
+	}
+	if amt, _, _ := s.GetShortBetweenAllCloseFutureOrderAndPositionD_Value(); amt != 0 {
+		t.Errorf("short position without grid %v, want 0", amt)
+	}
+
+	pin := &strategy.PinSignal{BaseSignal: strategy.BaseSignal{S: s}}
+	filled := func(o *strategy.MyFutureOrder) *mod.OrderUpdate {
+		return &mod.OrderUpdate{FutureOrder: mod.FutureOrder{ClientOrderID: o.ClientOrderID, Status: mod.StatusFilled}, Event: mod.EventTrade}
+	}
+
+	// 网格单成交不挂止损
+	pin.OnOrderUpdate(filled(gridClose), gridClose)
+	if len(ex.orders) != 0 {
+		t.Fatalf("grid fill placed %+v", ex.orders[0])
+	}
+
+	// 手动开仓成交后按插针自己的仓位挂止损
+	common := &strategy.MyFutureOrder{
+		FutureOrder: &mod.FutureOrder{ClientOrderID: "c1", PositionSide: mod.LONG, Side: mod.SideBuy, OrigQty: 0.015, Price: 1500},
+		OrdeType:    util.COMMON,
+		OrderFlag:   util.ADDPOSITION,
+	}
+	pin.OnOrderUpdate(filled(common), common)
+	if len(ex.orders) != 1 {
+		t.Fatalf("common fill placed %d orders, want 1 stop", len(ex.orders))
+	}
+	if stop := ex.orders[0]; stop.Side != mod.SideSell || stop.Quantity != 0.015 || stop.StopPrice != 1000 {
+		t.Errorf("stop %+v", stop)
+	}
+}
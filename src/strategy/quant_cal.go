@@ -1,6 +1,6 @@
 package strategy
 
-import "github.com/rootpd/binance"
+import "tinyquant/src/mod"
 
 func CalProfit(start_price, stop_price, quote float64, rate float64) float64 {
 
@@ -17,6 +17,6 @@ func CalProfit(start_price, stop_price, quote float64, rate float64) float64 {
 	5. 日线或者月线的影线 20%
 */
 
-func CalSide() binance.OrderSide {
-	return binance.SideBuy
+func CalSide() mod.OrderSide {
+	return mod.SideBuy
 }
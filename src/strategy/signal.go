@@ -0,0 +1,187 @@
+package strategy
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	. "tinyquant/src/logger"
+	"tinyquant/src/mod"
+
+	"go.uber.org/zap"
+)
+
+// 策略插件,引擎负责维护K线、仓位和挂单,在对应事件发生时依次调用各个插件
+// 插件在 Init 时拿到引擎,通过 s.PlaceOrderManager 下单
+type Signal interface {
+	Name() string
+	Init(s *Strategy) error
+	OnKline(interval mod.Interval, k *mod.Kline) //本地K线已经更新
+	OnDepth(d *mod.Depth)
+	OnBar(bar *mod.Kline)                                             //成交合成的K线收盘,本地 BarQueue 已经更新
+	OnOrderUpdate(order *mod.OrderUpdate, futureOrder *MyFutureOrder) //本地挂单已经更新
+	OnPositionUpdate(p *mod.Position)                                 //本地仓位已经更新
+	OnTimer(now time.Time)
+	OnStreamEvent(ev *mod.StreamEvent) //推送断开或重连,重连后仓位和余额已经重新加载
+}
+
+// 空实现,插件嵌入后只需要实现关心的事件
+type BaseSignal struct {
+	S *Strategy
+}
+
+func (b *BaseSignal) Init(s *Strategy) error {
+	b.S = s
+	return nil
+}
+
+func (b *BaseSignal) OnKline(interval mod.Interval, k *mod.Kline)                      {}
+func (b *BaseSignal) OnDepth(d *mod.Depth)                                             {}
+func (b *BaseSignal) OnBar(bar *mod.Kline)                                             {}
+func (b *BaseSignal) OnOrderUpdate(order *mod.OrderUpdate, futureOrder *MyFutureOrder) {}
+func (b *BaseSignal) OnPositionUpdate(p *mod.Position)                                 {}
+func (b *BaseSignal) OnTimer(now time.Time)                                            {}
+func (b *BaseSignal) OnStreamEvent(ev *mod.StreamEvent)                                {}
+
+var (
+	signalLock sync.RWMutex
+	signals    = make(map[string]func() Signal)
+)
+
+// 注册策略插件,一般在插件所在文件的 init 中调用
+func RegisterSignal(name string, f func() Signal) {
+	signalLock.Lock()
+	defer signalLock.Unlock()
+	if _, ok := signals[name]; ok {
+		panic("duplicate signal : " + name)
+	}
+	signals[name] = f
+}
+
+func NewSignal(name string) (Signal, error) {
+	signalLock.RLock()
+	f, ok := signals[name]
+	signalLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown signal : %v", name)
+	}
+	return f(), nil
+}
+
+// 已注册的插件名
+func SignalNames() []string {
+	signalLock.RLock()
+	defer signalLock.RUnlock()
+	names := make([]string, 0, len(signals))
+	for name := range signals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 按配置创建并初始化插件,没有配置时运行插针策略
+func (s *Strategy) InitSignals() error {
+	names := s.Param.Signals
+	if len(names) == 0 {
+		names = []string{PinSignalName}
+	}
+	s.Signals = s.Signals[:0]
+	for _, name := range names {
+		sig, err := NewSignal(name)
+		if err != nil {
+			return err
+		}
+		if err := sig.Init(s); err != nil {
+			return fmt.Errorf("init signal %v failed : %v", name, err)
+		}
+		s.Signals = append(s.Signals, sig)
+	}
+	return nil
+}
+
+// 插件需要深度推送时在 Init 中调用
+func (s *Strategy) SubscribeDepth() {
+	if s.DepthWs != nil || s.Exchange == nil {
+		return
+	}
+	s.DepthWs, _ = s.Exchange.GetDepthWs(s.Symbol)
+}
+
+// 插件需要成交合成K线时在 Init 中调用,参数不对时返回错误
+func (s *Strategy) SubscribeTrades() error {
+	if s.TradeWs != nil || s.Exchange == nil {
+		return nil
+	}
+	bars, err := NewBarBuilder(BarType(s.Param.TradeBar.Type), s.Param.TradeBar.Size)
+	if err != nil {
+		return err
+	}
+	s.Bars = bars
+	s.BarQueue = NewQueue(60)
+	s.TradeWs, _ = s.Exchange.GetTradeWs(s.Symbol)
+	return nil
+}
+
+// 合成的K线收盘后更新本地K线并交给插件
+func (s *Strategy) HandleTrade(t *mod.AggTrade) {
+	bar := s.Bars.Add(t)
+	if bar == nil {
+		return
+	}
+	s.BarQueue.EnQqueu(&Kline{
+		Open:      bar.Open,
+		Close:     bar.Close,
+		High:      bar.High,
+		Low:       bar.Low,
+		Volume:    bar.Volume,
+		CloseTime: bar.CloseTime,
+		BuyVolume: bar.BuyVolume,
+	})
+	s.BarQueue.UpdateUpDownLink(true)
+	for _, sig := range s.Signals {
+		sig.OnBar(bar)
+	}
+}
+
+// 更新本地K线后交给插件
+func (s *Strategy) HandleKline(interval mod.Interval, ke *mod.Kline) {
+	if !s.Sync && s.KlineManager.Gap(interval, ke) {
+		Logger.Warn("kline gap, backfill", zap.String("symbol", s.Symbol), zap.String("interval", string(interval)))
+		s.KlineManager.Backfill(interval)
+	}
+	if kqueue := s.KlineManager.Queue(interval); kqueue != nil {
+		kqueue.EnQqueu(&Kline{
+			Open:      ke.Open,
+			Close:     ke.Close,
+			High:      ke.High,
+			Low:       ke.Low,
+			Volume:    ke.Volume,
+			CloseTime: ke.CloseTime,
+			BuyVolume: ke.BuyVolume,
+		})
+		if ke.Final {
+			//k线结束更新均值
+			kqueue.UpdateUpDownLink(true)
+		}
+	}
+	for _, sig := range s.Signals {
+		sig.OnKline(interval, ke)
+	}
+}
+
+func (s *Strategy) HandleDepth(d *mod.Depth) {
+	for _, sig := range s.Signals {
+		sig.OnDepth(d)
+	}
+}
+
+func (s *Strategy) HandleTimer(now time.Time) {
+	if s.Funding != nil {
+		go s.Funding.OnTimer(now)
+	}
+	for _, sig := range s.Signals {
+		sig.OnTimer(now)
+	}
+}
@@ -0,0 +1,213 @@
+package strategy
+
+import (
+	"errors"
+	"math"
+	"sync"
+
+	. "tinyquant/src/logger"
+	"tinyquant/src/mod"
+	"tinyquant/src/util"
+
+	"go.uber.org/zap"
+)
+
+const GridSignalName = "grid"
+
+func init() {
+	RegisterSignal(GridSignalName, func() Signal { return &GridSignal{} })
+}
+
+// 网格策略: 在 Lower 和 Upper 之间分 Count 格挂单
+// 首根K线时当前价以下挂多单开仓,以上挂空单开仓,开仓成交后在相邻一档挂平仓单,平仓成交后在原价重新挂开仓单
+// 和插针同时启用时,插针的平仓单和止损单不包含网格持有的仓位
+type GridSignal struct {
+	BaseSignal
+	sync.Mutex
+	Prices   []float64    //每档价格,从低到高
+	Levels   []*GridLevel //Levels[i] 是 Prices[i] 到 Prices[i+1] 这一格
+	quantity float64
+	orders   map[string]*gridOrder //网格挂单,key 为 clientOrderID
+	started  bool
+}
+
+// 一格的收益统计
+type GridLevel struct {
+	Low    float64
+	High   float64
+	Profit float64 //已实现收益,不含手续费
+	Trades int     //平仓次数
+}
+
+type gridOrder struct {
+	level        int
+	positionSide mod.PositionSide
+	open         bool    //开仓单
+	openPrice    float64 //平仓单对应的开仓成交价
+}
+
+func (g *GridSignal) Name() string {
+	return GridSignalName
+}
+
+func (g *GridSignal) Init(s *Strategy) error {
+	g.S = s
+	prices, err := GridPrices(s.Param.Grid)
+	if err != nil {
+		return err
+	}
+	g.Prices = prices
+	g.Levels = make([]*GridLevel, len(prices)-1)
+	for i := range g.Levels {
+		g.Levels[i] = &GridLevel{Low: prices[i], High: prices[i+1]}
+	}
+	g.quantity = s.Param.Grid.Quantity
+	if g.quantity == 0 {
+		g.quantity = s.Param.Quantity
+	}
+	g.orders = make(map[string]*gridOrder)
+	return nil
+}
+
+// 计算每档价格,等差或等比
+func GridPrices(p util.GridParam) ([]float64, error) {
+	if p.Count < 1 || p.Lower <= 0 || p.Upper <= p.Lower {
+		return nil, errors.New("grid needs 0 < Lower < Upper and Count >= 1")
+	}
+	prices := make([]float64, p.Count+1)
+	for i := range prices {
+		if p.Geometric {
+			prices[i] = p.Lower * math.Pow(p.Upper/p.Lower, float64(i)/float64(p.Count))
+		} else {
+			prices[i] = p.Lower + (p.Upper-p.Lower)*float64(i)/float64(p.Count)
+		}
+	}
+	return prices, nil
+}
+
+// 所有格的已实现收益
+func (g *GridSignal) Profit() float64 {
+	g.Lock()
+	defer g.Unlock()
+	var sum float64
+	for _, l := range g.Levels {
+		sum += l.Profit
+	}
+	return sum
+}
+
+// 第一根K线按当前价铺单
+func (g *GridSignal) OnKline(interval mod.Interval, k *mod.Kline) {
+	g.Lock()
+	defer g.Unlock()
+	if g.started {
+		return
+	}
+	g.started = true
+	Logger.Sugar().Infof("网格铺单 当前价格 : %v 价格 : %v", k.Close, g.Prices)
+	var orders []*OriginOrder
+	var metas []*gridOrder
+	for i, price := range g.Prices {
+		if price < k.Close && i < len(g.Levels) {
+			metas = append(metas, &gridOrder{level: i, positionSide: mod.LONG, open: true})
+		} else if price > k.Close && i > 0 {
+			metas = append(metas, &gridOrder{level: i - 1, positionSide: mod.SHORT, open: true})
+		}
+	}
+	for _, m := range metas {
+		orders = append(orders, g.order(m, g.quantity))
+	}
+	//一次批量请求铺完整个网格
+	res, _ := g.S.PlaceOrderManager.MakePlaceOrders(orders)
+	for i, o := range res {
+		if o != nil {
+			g.orders[o.ClientOrderID] = metas[i]
+		}
+	}
+}
+
+func (g *GridSignal) OnOrderUpdate(order *mod.OrderUpdate, futureOrder *MyFutureOrder) {
+	g.Lock()
+	defer g.Unlock()
+	o, ok := g.orders[order.ClientOrderID]
+	if !ok {
+		return
+	}
+	switch order.Event {
+	case mod.EventCanceled, mod.EventExpired:
+	case mod.EventTrade:
+		if order.Status != mod.StatusFilled {
+			return
+		}
+	default:
+		return
+	}
+	delete(g.orders, order.ClientOrderID)
+
+	qty := order.ExecutedQty
+	if qty == 0 {
+		if order.Event != mod.EventTrade {
+			return
+		}
+		qty = order.OrigQty
+	}
+	price := order.AvgPrice
+	if price == 0 {
+		price = order.Price
+	}
+
+	if o.open {
+		//开仓成交,挂相邻一档的平仓单
+		g.place(o.level, o.positionSide, false, qty, price)
+		return
+	}
+
+	//平仓成交,记录这一格的收益并在原价重新开仓
+	profit := (price - o.openPrice) * qty
+	if o.positionSide == mod.SHORT {
+		profit = -profit
+	}
+	level := g.Levels[o.level]
+	level.Profit += profit
+	level.Trades++
+	Logger.Info("网格平仓", zap.String("symbol", g.S.Symbol), zap.Float64("low", level.Low), zap.Float64("high", level.High),
+		zap.Float64("profit", profit), zap.Float64("levelProfit", level.Profit))
+	g.place(o.level, o.positionSide, true, g.quantity, 0)
+}
+
+func (g *GridSignal) place(level int, positionSide mod.PositionSide, open bool, qty, openPrice float64) {
+	o := &gridOrder{level: level, positionSide: positionSide, open: open, openPrice: openPrice}
+	res, err := g.S.PlaceOrderManager.MakePlaceOrder(g.order(o, qty))
+	if err != nil || res == nil {
+		return
+	}
+	g.orders[res.ClientOrderID] = o
+}
+
+// 多单在格子下沿开仓、上沿平仓,空单相反
+func (g *GridSignal) order(o *gridOrder, qty float64) *OriginOrder {
+	s := g.S
+	order := &OriginOrder{
+		Symbol:       s.Symbol,
+		PositionSide: o.positionSide,
+		Quantity:     qty,
+		OrderStatus:  util.GRID,
+		OrderFlag:    util.ADDPOSITION,
+		IsTest:       util.PlaceTest,
+	}
+	l := g.Levels[o.level]
+	switch {
+	case o.positionSide == mod.LONG && o.open:
+		order.Side, order.Price = mod.SideBuy, l.Low
+	case o.positionSide == mod.LONG:
+		order.Side, order.Price = mod.SideSell, l.High
+	case o.open:
+		order.Side, order.Price = mod.SideSell, l.High
+	default:
+		order.Side, order.Price = mod.SideBuy, l.Low
+	}
+	if !o.open {
+		order.OrderFlag = util.DELPOSITION
+	}
+	return order
+}
@@ -0,0 +1,256 @@
+package strategy
+
+import (
+	"strings"
+	"time"
+
+	"tinyquant/src/indicator"
+	. "tinyquant/src/logger"
+	"tinyquant/src/mod"
+	"tinyquant/src/util"
+)
+
+const PinSignalName = "pin"
+
+func init() {
+	RegisterSignal(PinSignalName, func() Signal { return &PinSignal{} })
+}
+
+// 插针策略: 放量且偏离均价时反向挂开仓单,成交后挂止盈单和止损单
+type PinSignal struct {
+	BaseSignal
+	lastTrack time.Time
+	atr       *indicator.ATR // 配置了 AtrSpring 时挂在分钟线上
+}
+
+func (p *PinSignal) Name() string {
+	return PinSignalName
+}
+
+func (p *PinSignal) Init(s *Strategy) error {
+	p.S = s
+	if !s.Sync {
+		//清理开仓挂单,调整平仓单和止损单
+		s.ClearPartiallyFilledOrder()
+		s.ScanCloseFutureOrder()
+		s.ScanPositionAndCreatCloseFutureOrder()
+	}
+	if s.Param.AtrSpring > 0 {
+		period := s.Param.AtrPeriod
+		if period <= 0 {
+			period = 14
+		}
+		p.atr = indicator.NewATR(period)
+		s.KlineManager.MinuteKlineList.Attach(p.atr)
+	}
+	if s.Param.OrderBook.Enable && !s.Sync && s.Exchange != nil {
+		s.OBM = NewOrderBookMap(s.Exchange, s.Symbol)
+		s.SubscribeDepth()
+	}
+	if s.Param.TradeBar.Type != "" && !s.Sync {
+		return s.SubscribeTrades()
+	}
+	return nil
+}
+
+// 成交合成的K线收盘时判断插针,不用等分钟K线推送
+func (p *PinSignal) OnBar(bar *mod.Kline) {
+	go p.assert(bar, p.S.BarQueue)
+}
+
+// 维护订单簿,每秒记录一次大单墙的变化
+func (p *PinSignal) OnDepth(d *mod.Depth) {
+	if p.S.OBM == nil {
+		return
+	}
+	p.S.OBM.Feed(d)
+	if now := time.Now(); now.Sub(p.lastTrack) >= time.Second {
+		p.lastTrack = now
+		ob := p.S.Param.OrderBook
+		for _, w := range p.S.OBM.TrackWalls(ob.Bps, ob.WallMultiple) {
+			if w.Pulled {
+				Logger.Sugar().Infof("大单墙撤单 %v 价格 : %v 数量 : %v", w.Side, w.Price, w.Quantity)
+			}
+		}
+	}
+}
+
+// 资金费率和订单簿确认开仓,没有启用时直接开仓
+func (p *PinSignal) confirm(order *OriginOrder) bool {
+	if p.S.Funding != nil {
+		if veto := p.S.Funding.Veto(order.PositionSide, time.Now(), p.S.Param.Funding); veto != "" {
+			Logger.Sugar().Infof("资金费率放弃开仓 %v 原因 : %v", order.PositionSide, veto)
+			return false
+		}
+	}
+	if p.S.OBM == nil {
+		return true
+	}
+	c := p.S.OBM.Check(order.Side, order.Quantity, p.S.Param.OrderBook)
+	if c.Veto != "" {
+		Logger.Sugar().Infof("订单簿放弃开仓 %v 失衡 : %.2f 价差 : %.2f 大单墙 : %v 原因 : %v",
+			order.Side, c.Imbalance, c.SpreadBps, c.Support, c.Veto)
+		return false
+	}
+	Logger.Sugar().Debugf("订单簿确认开仓 %+v", c)
+	return true
+}
+
+// 插针只看分钟线,其他周期的K线只更新本地K线
+func (p *PinSignal) OnKline(interval mod.Interval, ke *mod.Kline) {
+	if interval != mod.Minute {
+		return
+	}
+	kqueue := p.S.KlineManager.Queue(interval)
+	if kqueue == nil {
+		return
+	}
+	if p.S.Sync {
+		p.assert(ke, kqueue)
+	} else {
+		go p.assert(ke, kqueue)
+	}
+}
+
+// 插针偏离均价的距离,分钟线 ATR 还没有足够数据时按 SpringPrice 比例计算
+func (p *PinSignal) spring(ke *mod.Kline, kqueue *MyKlineQueue) float64 {
+	if p.atr != nil && kqueue == p.S.KlineManager.MinuteKlineList {
+		kqueue.RLock()
+		atr, ready := p.atr.Value(), p.atr.Ready()
+		kqueue.RUnlock()
+		if ready {
+			return atr * p.S.Param.AtrSpring
+		}
+	}
+	return ke.Close * p.S.Param.SpringPrice
+}
+
+// 插针下单判断
+func (p *PinSignal) assert(ke *mod.Kline, kqueue *MyKlineQueue) {
+	s := p.S
+	upl := kqueue.GetUpDownLink()
+	spring := p.spring(ke, kqueue)
+	if time.Now().Unix()%5 == 0 {
+		Logger.Sugar().Debugf("平均成交量 * %v : %v half 采样点 : %v K线当前成交量  : %v k线当前价格 : %v 均价 : %v",
+			s.Param.VolumeIncrease, upl.AvgVolume*s.Param.VolumeIncrease, upl.HalfSampleAvgPrice*s.Param.VolumeIncrease, ke.Volume, ke.Close, upl.AvgPrice)
+	}
+	if ke.Volume > upl.AvgVolume*s.Param.VolumeIncreaseForClose && ke.Volume > upl.HalfSampleAvgPrice*s.Param.VolumeIncreaseForClose {
+		if s.Sync {
+			kqueue.UpdateUpDownLink(true)
+		} else {
+			go kqueue.UpdateUpDownLink(true) //先更新
+		}
+
+		if ke.Open > ke.Close && ke.Close < upl.AvgPrice-spring { //向下插针
+			turnPositionAmt, _, turnEntryPrice := s.GetShortBetweenAllCloseFutureOrderAndPositionD_Value()
+			if turnPositionAmt != 0 && turnEntryPrice > ke.Close {
+				newOrder := &OriginOrder{
+					Symbol:       s.Symbol,
+					OrderStatus:  util.PINCLOSECOMMON,
+					Side:         mod.SideBuy,
+					PositionSide: mod.SHORT,
+					IsTest:       util.PlaceTest,
+					OrderFlag:    util.DELPOSITION,
+					Quantity:     s.qty(turnPositionAmt),
+					Price:        s.price(ke.Close + spring/2),
+				}
+				pinCloseType(newOrder, s.Param.PinCloseType)
+				Logger.Sugar().Debugf("插针取消平仓单,创建新的平仓单 %+v", newOrder)
+				// p.positionInfo.CancelAllCloseFutureOrder(newOrder.PositionSide)
+				s.PlaceOrderManager.MakePlaceOrder(newOrder)
+			} else {
+				Logger.Sugar().Debugf("turnEntryPrice : %v", turnEntryPrice)
+			}
+		} else if ke.Open < ke.Close && ke.Close > upl.AvgPrice+spring { //向上插针
+			turnPositionAmt, _, turnEntryPrice := s.GetLongBetweenAllCloseFutureOrderAndPositionD_Value()
+			if turnPositionAmt != 0 && turnEntryPrice < ke.Close {
+				newOrder := &OriginOrder{
+					Symbol:       s.Symbol,
+					OrderStatus:  util.PINCLOSECOMMON,
+					Side:         mod.SideSell,
+					PositionSide: mod.LONG,
+					IsTest:       util.PlaceTest,
+					OrderFlag:    util.DELPOSITION,
+					Quantity:     s.qty(turnPositionAmt),
+					Price:        s.price(ke.Close - spring/2),
+				}
+				pinCloseType(newOrder, s.Param.PinCloseType)
+				Logger.Sugar().Debugf("插针取消平仓单,创建新的平仓单 %+v", newOrder)
+				// p.positionInfo.CancelAllCloseFutureOrder(newOrder.PositionSide)
+				s.PlaceOrderManager.MakePlaceOrder(newOrder)
+			} else {
+				Logger.Sugar().Debugf("turnEntryPrice : %v", turnEntryPrice)
+			}
+		}
+
+		if ke.Volume > upl.AvgVolume*s.Param.VolumeIncrease && ke.Volume > upl.HalfSampleAvgPrice*s.Param.VolumeIncrease {
+			if ke.Open > ke.Close && ke.Close < upl.AvgPrice-spring { //向下插针
+				order := &OriginOrder{
+					Symbol:       s.Symbol,
+					OrderStatus:  util.PIN,
+					Side:         mod.SideBuy,
+					PositionSide: mod.LONG,
+					OrderFlag:    util.ADDPOSITION,
+					IsTest:       util.PlaceTest,
+				}
+				order.Price = s.price(ke.Close - spring)
+				order.Quantity = s.qty(s.Param.Quantity)
+				Logger.Sugar().Infof("向下插针 分钟平均成交量 * %v : %v K线当前成交量 : %v k线当前价格 : %v 创建开仓单价格 : %v",
+					s.Param.VolumeIncrease, upl.AvgVolume*s.Param.VolumeIncrease, ke.Volume, ke.Close, order.Price)
+				if p.confirm(order) {
+					s.PlaceOrderManager.MakePlaceOrder(order)
+				}
+			} else if ke.Open < ke.Close && ke.Close > upl.AvgPrice+spring { //向上插针
+				order := &OriginOrder{
+					Symbol:       s.Symbol,
+					OrderStatus:  util.PIN,
+					Side:         mod.SideSell,
+					PositionSide: mod.SHORT,
+					OrderFlag:    util.ADDPOSITION,
+					IsTest:       util.PlaceTest,
+				}
+				order.Price = s.price(ke.Close + spring)
+				order.Quantity = s.qty(s.Param.Quantity)
+				Logger.Sugar().Infof("向上插针 分钟平均成交量 * %v : %v K线当前成交量 : %v k线当前价格 : %v 创建开仓单价格 : %v",
+					s.Param.VolumeIncrease, upl.AvgVolume*s.Param.VolumeIncrease, ke.Volume, ke.Close, order.Price)
+				if p.confirm(order) {
+					s.PlaceOrderManager.MakePlaceOrder(order)
+				}
+			}
+		}
+	}
+}
+
+// 加仓单成交或部分成交后取消/过期,创建对应的平仓单和止损单
+// 网格挂单由网格自己平仓,不挂止损
+func (p *PinSignal) OnOrderUpdate(order *mod.OrderUpdate, futureOrder *MyFutureOrder) {
+	if futureOrder.OrdeType == util.GRID {
+		return
+	}
+	switch order.Event {
+	case mod.EventCanceled, mod.EventExpired:
+		if futureOrder.ExecutedQty == 0 ||
+			!((futureOrder.PositionSide == mod.LONG && futureOrder.Side == mod.SideBuy) ||
+				(futureOrder.PositionSide == mod.SHORT && futureOrder.Side == mod.SideSell)) {
+			return
+		}
+	case mod.EventTrade:
+		if order.Status != mod.StatusFilled {
+			return
+		}
+	default:
+		return
+	}
+	p.S.MakePlaceOrder(futureOrder)
+	p.S.MakeCloseOrder(futureOrder)
+}
+
+// 按配置设置插针平仓单的下单方式,价格跑得快时用市价或 IOC 避免挂单不成交
+func pinCloseType(order *OriginOrder, t string) {
+	switch strings.ToUpper(t) {
+	case string(mod.TypeMarket):
+		order.Type = mod.TypeMarket
+	case string(mod.IOC), string(mod.FOK), string(mod.GTX):
+		order.TimeInForce = mod.TimeInForce(strings.ToUpper(t))
+	}
+}
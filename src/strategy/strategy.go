@@ -2,7 +2,6 @@ package strategy
 
 import (
 	"fmt"
-	"strconv"
 	"sync"
 	"time"
 
@@ -12,401 +11,368 @@ import (
 
 	quant "tinyquant/src/quant"
 
-	"github.com/rootpd/binance"
 	"go.uber.org/zap"
 )
 
-var Binance quant.Binance
-
 type Strategy struct {
 	*sync.RWMutex
 	Symbol            string
-	LongPosition      Position                         //多单持仓信息
-	ShortPosition     Position                         //空单持仓信息
-	FutureOrder       map[string]*MyFutureOrder        //所有手动的挂单
-	KlineWs           chan *mod.Kline                  //K线事件
-	Ch15Kline         chan *mod.Kline                  //K线事件
-	Ch4hKline         chan *mod.Kline                  //K线事件
-	AccWs             chan *binance.FutureAccountEvent //账户变动事件
-	KlineManager      *Market                          //K线
-	OBM               *OrderBookMap                    //深度
-	PlaceOrderManager *PlaceOrderManager               //开单管理
+	Exchange          quant.Exchange            //交易客户端
+	Param             *util.QuantParam          //策略参数,为空时按交易对读取配置
+	LongPosition      Position                  //多单持仓信息
+	ShortPosition     Position                  //空单持仓信息
+	FutureOrder       map[string]*MyFutureOrder //所有手动的挂单
+	GridFutureOrder   map[string]*MyFutureOrder //网格挂单
+	KlineWs           chan *KlineEvent          //所有周期的K线事件
+	AccWs             chan *mod.AccountEvent    //账户变动事件,由 Manager 按交易对分发
+	DepthWs           chan *mod.Depth           //深度事件,有插件订阅时才有值
+	TradeWs           chan *mod.AggTrade        //归集成交,有插件订阅时才有值
+	Bars              *BarBuilder               //成交合成K线
+	BarQueue          *MyKlineQueue             //成交合成的本地K线
+	StreamWs          chan *mod.StreamEvent     //推送连接状态,由 Manager 分发
+	KlineManager      *Market                   //K线
+	OBM               *OrderBookMap             //深度
+	Funding           *Funding                  //资金费率和资金费用,启用时才有值
+	MarkPriceWs       chan *mod.FundingRate     //标记价格和资金费率推送
+	PlaceOrderManager *PlaceOrderManager        //开单管理
+	Sync              bool                      //同步执行插针判断,回测时使用
+	Signals           []Signal                  //策略插件
 }
 
-func (s *Strategy) placeAssert(ke *mod.Kline, kqueue *MyKlineQueue) {
-	//更新本地K线
-	kqueue.EnQqueu(&Kline{
-		Open:      ke.Open,
-		Close:     ke.Close,
-		High:      ke.High,
-		Low:       ke.Low,
-		Volume:    ke.Volume,
-		CloseTime: ke.CloseTime,
-		BuyVolume: ke.BuyVolume,
-	})
-	if ke.Final {
-		//k线结束更新均值
-		kqueue.UpdateUpDownLink(true)
-	}
-	go func() {
-		//插针下单判断
-		upl := kqueue.GetUpDownLink()
-		if time.Now().Unix()%5 == 0 {
-			Logger.Sugar().Debugf("平均成交量 * %v : %v half 采样点 : %v K线当前成交量  : %v k线当前价格 : %v 均价 : %v",
-				util.VolumeIncrease, upl.AvgVolume*util.VolumeIncrease, upl.HalfSampleAvgPrice*util.VolumeIncrease, ke.Volume, ke.Close, upl.AvgPrice)
+// 交易对的下单规则,获取不到时价格保留两位,数量保留三位
+func symbolInfo(ex quant.Exchange, symbol string) *mod.SymbolInfo {
+	if ex != nil {
+		if info, err := ex.GetSymbolInfo(symbol); err == nil {
+			return info
 		}
-		if ke.Volume > upl.AvgVolume*util.VolumeIncreaseForClose && ke.Volume > upl.HalfSampleAvgPrice*util.VolumeIncreaseForClose {
-			go kqueue.UpdateUpDownLink(true) //先更新
-
-			if ke.Open > ke.Close && ke.Close < upl.AvgPrice-ke.Close*util.SpringPrice { //向下插针
-				turnPositionAmt, _, turnEntryPrice := s.GetShortBetweenAllCloseFutureOrderAndPositionD_Value()
-				if turnPositionAmt != 0 && turnEntryPrice > ke.Close {
-					newOrder := &OriginOrder{
-						Symbol:       s.Symbol,
-						OrderStatus:  util.PINCLOSECOMMON,
-						Side:         binance.SideBuy,
-						PositionSide: binance.PositionSide(binance.SHORT),
-						IsTest:       util.PlaceTest,
-						OrderFlag:    util.DELPOSITION,
-						Quantity:     util.Round(turnPositionAmt, 3),
-						Price:        util.Round(ke.Close+ke.Close*util.SpringPrice/2, 2),
-					}
-					Logger.Sugar().Debugf("插针取消平仓单,创建新的平仓单 %+v", newOrder)
-					// p.positionInfo.CancelAllCloseFutureOrder(newOrder.PositionSide)
-					s.PlaceOrderManager.MakePlaceOrder(newOrder)
-				} else {
-					Logger.Sugar().Debugf("turnEntryPrice : %v", turnEntryPrice)
-				}
-			} else if ke.Open < ke.Close && ke.Close > upl.AvgPrice+ke.Close*util.SpringPrice { //向上插针
-				turnPositionAmt, _, turnEntryPrice := s.GetLongBetweenAllCloseFutureOrderAndPositionD_Value()
-				if turnPositionAmt != 0 && turnEntryPrice < ke.Close {
-					newOrder := &OriginOrder{
-						Symbol:       s.Symbol,
-						OrderStatus:  util.PINCLOSECOMMON,
-						Side:         binance.SideSell,
-						PositionSide: binance.PositionSide(binance.LONG),
-						IsTest:       util.PlaceTest,
-						OrderFlag:    util.DELPOSITION,
-						Quantity:     util.Round(turnPositionAmt, 3),
-						Price:        util.Round(ke.Close-ke.Close*util.SpringPrice/2, 2),
-					}
-					Logger.Sugar().Debugf("插针取消平仓单,创建新的平仓单 %+v", newOrder)
-					// p.positionInfo.CancelAllCloseFutureOrder(newOrder.PositionSide)
-					s.PlaceOrderManager.MakePlaceOrder(newOrder)
-				} else {
-					Logger.Sugar().Debugf("turnEntryPrice : %v", turnEntryPrice)
-				}
-			}
+	}
+	return &mod.SymbolInfo{Symbol: symbol, TickSize: 0.01, StepSize: 0.001}
+}
 
-			if ke.Volume > upl.AvgVolume*util.VolumeIncrease && ke.Volume > upl.HalfSampleAvgPrice*util.VolumeIncrease {
-				if ke.Open > ke.Close && ke.Close < upl.AvgPrice-ke.Close*util.SpringPrice { //向下插针
-					order := &OriginOrder{
-						Symbol:       s.Symbol,
-						OrderStatus:  util.PIN,
-						Side:         binance.SideBuy,
-						PositionSide: binance.LONG,
-						OrderFlag:    util.ADDPOSITION,
-						IsTest:       util.PlaceTest,
-					}
-					order.Price = util.Round(ke.Close-ke.Close*util.SpringPrice, 2)
-					order.Quantity = util.Round(util.Quantity, 3)
-					Logger.Sugar().Infof("向下插针 分钟平均成交量 * %v : %v K线当前成交量 : %v k线当前价格 : %v 创建开仓单价格 : %v",
-						util.VolumeIncrease, upl.AvgVolume*util.VolumeIncrease, ke.Volume, ke.Close, order.Price)
-					s.PlaceOrderManager.MakePlaceOrder(order)
-				} else if ke.Open < ke.Close && ke.Close > upl.AvgPrice+ke.Close*util.SpringPrice { //向上插针
-					order := &OriginOrder{
-						Symbol:       s.Symbol,
-						OrderStatus:  util.PIN,
-						Side:         binance.SideSell,
-						PositionSide: binance.SHORT,
-						OrderFlag:    util.ADDPOSITION,
-						IsTest:       util.PlaceTest,
-					}
-					order.Price = util.Round(ke.Close+ke.Close*util.SpringPrice, 2)
-					order.Quantity = util.Round(util.Quantity, 3)
-					Logger.Sugar().Infof("向上插针 分钟平均成交量 * %v : %v K线当前成交量 : %v k线当前价格 : %v 创建开仓单价格 : %v",
-						util.VolumeIncrease, upl.AvgVolume*util.VolumeIncrease, ke.Volume, ke.Close, order.Price)
-					s.PlaceOrderManager.MakePlaceOrder(order)
-				}
-			}
-		}
-	}()
+// 交易对的保证金币种,交易所没有返回时按配置,都没有时为 USDT
+func marginAsset(ex quant.Exchange, symbol string) string {
+	if a := symbolInfo(ex, symbol).MarginAsset; a != "" {
+		return a
+	}
+	if a, ok := util.ACCOUNTASSET[symbol]; ok {
+		return a
+	}
+	return "USDT"
+}
+
+func (s *Strategy) price(v float64) float64 {
+	return symbolInfo(s.Exchange, s.Symbol).RoundPrice(v)
+}
+
+func (s *Strategy) qty(v float64) float64 {
+	return symbolInfo(s.Exchange, s.Symbol).RoundQty(v)
+}
+
+// 推送一根分钟K线
+func (s *Strategy) OnKline(ke *mod.Kline) {
+	s.HandleKline(mod.Minute, ke)
 }
 
 func (s *Strategy) StrategyLoop(ct bool) error {
-	Logger.Info("开启策略")
+	Logger.Info("开启策略", zap.String("symbol", s.Symbol))
 
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
 	for {
 		select {
 		case ke := <-s.KlineWs:
-			s.placeAssert(ke, s.KlineManager.MinuteKlineList)
-		case ke := <-s.Ch15Kline:
-			s.placeAssert(ke, s.KlineManager.FifteenMinuteKlineList)
-		case ke := <-s.Ch4hKline:
-			s.placeAssert(ke, s.KlineManager.FourHourKlineList)
+			s.HandleKline(ke.Interval, ke.Kline)
+		case d := <-s.DepthWs:
+			s.HandleDepth(d)
+		case t := <-s.TradeWs:
+			s.HandleTrade(t)
+		case r := <-s.MarkPriceWs:
+			s.Funding.Update(r)
 		case acc := <-s.AccWs:
-			switch acc.EventName {
-			case util.ACCOUNT_UPDATE: //TODO 需要定时去更新最新可下单余额
-				Logger.Debug("ACCOUNT_UPDATE")
-				for _, v := range acc.AE.Acc.Balance {
-					if v.Symbol != util.ACCOUNTASSET[s.Symbol] {
-						continue
-					}
-					Logger.Sugar().Debugf("%+v", v)
-					s.PlaceOrderManager.Account.Lock()
-					s.PlaceOrderManager.Account.AvailableBalance = v.CurBalance
-					s.PlaceOrderManager.Account.Unlock()
-				}
+			s.HandleAccountEvent(acc)
+		case ev := <-s.StreamWs:
+			s.HandleStreamEvent(ev)
+		case now := <-ticker.C:
+			s.HandleTimer(now)
+		}
+	}
+}
 
-				for _, v := range acc.AE.Acc.Property {
-					if v.Symbol != s.Symbol {
-						continue
+// 推送重连后断开期间的事件已经丢失,重新加载仓位和余额,挂单由 ScanFutureOrder 定时对账
+func (s *Strategy) HandleStreamEvent(ev *mod.StreamEvent) {
+	if !ev.Connected {
+		Logger.Warn("stream disconnected", zap.String("symbol", s.Symbol), zap.String("stream", ev.Stream), zap.Error(ev.Err))
+	} else if ev.Reconnect {
+		Logger.Info("stream reconnected", zap.String("symbol", s.Symbol), zap.String("stream", ev.Stream))
+		if ev.Stream == mod.UserDataStream {
+			s.LoadPosition()
+			if s.PlaceOrderManager.Account != nil {
+				s.PlaceOrderManager.Account.LoadAccount()
+			}
+		} else {
+			s.KlineManager.BackfillAll()
+		}
+	}
+	for _, sig := range s.Signals {
+		sig.OnStreamEvent(ev)
+	}
+}
+
+// 处理账户推送事件
+func (s *Strategy) HandleAccountEvent(acc *mod.AccountEvent) {
+	switch acc.EventName {
+	case util.ACCOUNT_UPDATE: //TODO 需要定时去更新最新可下单余额
+		Logger.Debug("ACCOUNT_UPDATE")
+		asset := marginAsset(s.Exchange, s.Symbol)
+		for _, v := range acc.Account.Balances {
+			if v.Asset != asset {
+				continue
+			}
+			Logger.Sugar().Debugf("%+v", v)
+			s.PlaceOrderManager.Account.Lock()
+			s.PlaceOrderManager.Account.AvailableBalance = v.CrossWalletBalance
+			s.PlaceOrderManager.Account.Unlock()
+		}
+
+		for _, v := range acc.Account.Positions {
+			if v.Symbol != s.Symbol {
+				continue
+			}
+			Logger.Sugar().Debugf("%+v", v)
+			switch v.PositionSide {
+			case mod.LONG:
+				//仓位没了
+				s.LongPosition.Lock()
+				Logger.Sugar().Infof("做多方向仓位变动,原始持仓数量 : %v 价格 : %v 未实现盈亏 : %v 变动后 持仓数量 : %v 价格 : %v 未实现盈亏 : %v",
+					s.LongPosition.PositionAmt, s.LongPosition.EntryPrice, s.LongPosition.UnrealizedProfit, v.PositionAmt, v.EntryPrice, v.UnrealizedProfit)
+				if v.PositionAmt == 0 {
+					//删除本地
+					ids := []int64{}
+					for _, v := range s.LongPosition.CloseFutureOrder {
+						Logger.Sugar().Errorf("取消平仓单 %+v", v.FutureOrder)
+						ids = append(ids, int64(v.OrderID))
+						delete(s.LongPosition.CloseFutureOrder, v.ClientOrderID)
 					}
-					Logger.Sugar().Debugf("%+v", v)
-					switch v.PS {
-					case string(binance.LONG):
-						//仓位没了
-						s.LongPosition.Lock()
-						Logger.Sugar().Infof("做多方向仓位变动,原始持仓数量 : %v 价格 : %v 未实现盈亏 : %v 变动后 持仓数量 : %v 价格 : %v 未实现盈亏 : %v",
-							s.LongPosition.PositionAmt, s.LongPosition.EntryPrice, s.LongPosition.UnrealizedProfit, v.Pa, v.EP, v.UP)
-						if v.Pa == 0 {
-							//删除本地
-							for _, v := range s.LongPosition.CloseFutureOrder {
-								Logger.Sugar().Errorf("取消平仓单 %+v", v.ExecutedFutureOrder)
-								_, err := Binance.CancelBinanceFutureOrder(s.Symbol, int64(v.OrderID))
-								if err != nil {
-									Logger.Error("cancel future order failed", zap.Error(err))
-								}
-								delete(s.LongPosition.CloseFutureOrder, v.ClientOrderID)
-							}
-						}
-						s.LongPosition.UnrealizedProfit = v.UP
-						s.LongPosition.EntryPrice = util.Round(v.EP, 2)
-						s.LongPosition.PositionAmt = util.Round(v.Pa, 3)
-						s.LongPosition.UpdateTime = time.Now()
-						s.LongPosition.Unlock()
-
-					case string(binance.SHORT):
-						//仓位没了
-						//有时候是自动平的有时候是手动平的
-						s.ShortPosition.Lock()
-						Logger.Sugar().Infof("做空方向仓位变动,原始持仓数量 : %v 价格 : %v 未实现盈亏 : %v 变动后 持仓数量 : %v 价格 : %v 未实现盈亏 : %v",
-							s.ShortPosition.PositionAmt, s.ShortPosition.EntryPrice, s.ShortPosition.UnrealizedProfit, v.Pa, v.EP, v.UP)
-						if v.Pa == 0 {
-							//删除本地
-							for _, v := range s.ShortPosition.CloseFutureOrder {
-								Logger.Sugar().Errorf("取消平仓单 %+v", v.ExecutedFutureOrder)
-								_, err := Binance.CancelBinanceFutureOrder(s.Symbol, int64(v.OrderID))
-								if err != nil {
-									Logger.Error("cancel future order failed", zap.Error(err))
-								}
-								delete(s.ShortPosition.CloseFutureOrder, v.ClientOrderID)
-							}
-						}
-						s.ShortPosition.UnrealizedProfit = v.UP
-						s.ShortPosition.EntryPrice = util.Round(v.EP, 2)
-						s.ShortPosition.PositionAmt = util.Round(v.Pa, 3)
-						s.ShortPosition.UpdateTime = time.Now()
-						s.ShortPosition.Unlock()
+					//止损单(跟踪止损)也一起撤掉
+					for _, v := range s.LongPosition.CloseAllFutureOrder {
+						ids = append(ids, int64(v.OrderID))
+						delete(s.LongPosition.CloseAllFutureOrder, v.ClientOrderID)
 					}
+					s.CancelOrders(ids)
 				}
-			case util.ORDER_TRADE_UPDATE:
-				order := acc.OE.Order
-				if order.Symbol != s.Symbol {
-					continue
+				s.LongPosition.UnrealizedProfit = v.UnrealizedProfit
+				s.LongPosition.EntryPrice = s.price(v.EntryPrice)
+				s.LongPosition.PositionAmt = s.qty(v.PositionAmt)
+				s.LongPosition.UpdateTime = time.Now()
+				s.LongPosition.Unlock()
+				for _, sig := range s.Signals {
+					sig.OnPositionUpdate(v)
 				}
-				Logger.Info("ORDER_TRADE_UPDATE")
-				Logger.Sugar().Debugf("%+v", order)
 
-				positionSide := "多单"
-				if order.PositionSide == string(binance.SHORT) {
-					positionSide = "空单"
+			case mod.SHORT:
+				//仓位没了
+				//有时候是自动平的有时候是手动平的
+				s.ShortPosition.Lock()
+				Logger.Sugar().Infof("做空方向仓位变动,原始持仓数量 : %v 价格 : %v 未实现盈亏 : %v 变动后 持仓数量 : %v 价格 : %v 未实现盈亏 : %v",
+					s.ShortPosition.PositionAmt, s.ShortPosition.EntryPrice, s.ShortPosition.UnrealizedProfit, v.PositionAmt, v.EntryPrice, v.UnrealizedProfit)
+				if v.PositionAmt == 0 {
+					//删除本地
+					ids := []int64{}
+					for _, v := range s.ShortPosition.CloseFutureOrder {
+						Logger.Sugar().Errorf("取消平仓单 %+v", v.FutureOrder)
+						ids = append(ids, int64(v.OrderID))
+						delete(s.ShortPosition.CloseFutureOrder, v.ClientOrderID)
+					}
+					//止损单(跟踪止损)也一起撤掉
+					for _, v := range s.ShortPosition.CloseAllFutureOrder {
+						ids = append(ids, int64(v.OrderID))
+						delete(s.ShortPosition.CloseAllFutureOrder, v.ClientOrderID)
+					}
+					s.CancelOrders(ids)
 				}
-				side := "买"
-				if order.Side == string(binance.SideSell) {
-					side = "卖"
+				s.ShortPosition.UnrealizedProfit = v.UnrealizedProfit
+				s.ShortPosition.EntryPrice = s.price(v.EntryPrice)
+				s.ShortPosition.PositionAmt = s.qty(v.PositionAmt)
+				s.ShortPosition.UpdateTime = time.Now()
+				s.ShortPosition.Unlock()
+				for _, sig := range s.Signals {
+					sig.OnPositionUpdate(v)
 				}
+			}
+		}
+	case util.ORDER_TRADE_UPDATE:
+		order := acc.Order
+		if order.Symbol != s.Symbol {
+			return
+		}
+		Logger.Info("ORDER_TRADE_UPDATE")
+		Logger.Sugar().Debugf("%+v", order)
 
-				var futureOrder *MyFutureOrder = nil
-				if futureOrder = s.PlaceOrderManager.GetOrderInfo(order.ClientOrderID); futureOrder == nil {
-					futureOrder = &MyFutureOrder{ExecutedFutureOrder: &binance.ExecutedFutureOrder{}}
-				}
+		positionSide := "多单"
+		if order.PositionSide == mod.SHORT {
+			positionSide = "空单"
+		}
+		side := "买"
+		if order.Side == mod.SideSell {
+			side = "卖"
+		}
 
-				orderFlag := ""
-				if futureOrder.OrderFlag == util.ADDPOSITION && futureOrder.OrdeType == util.PIN {
-					orderFlag = "自动加仓单"
-				} else if futureOrder.OrderFlag == util.DELPOSITION && (futureOrder.OrdeType == util.CLOSECOMMON || futureOrder.OrdeType == util.PINCLOSECOMMON) {
-					orderFlag = "自动减仓单"
-				} else if futureOrder.OrdeType == util.COMMON && futureOrder.OrderFlag == util.UNKNNOW {
-					if (order.PositionSide == string(binance.LONG) && order.Side == string(binance.SideBuy)) ||
-						(order.PositionSide == string(binance.SHORT) && order.Side == string(binance.SideSell)) {
-						Logger.Info("手动加仓")
-					} else if (order.PositionSide == string(binance.LONG) && order.Side == string(binance.SideSell)) ||
-						(order.PositionSide == string(binance.SHORT) && order.Side == string(binance.SideBuy)) {
-						Logger.Info("手动减仓")
-					}
-				} else {
-					Logger.Error("异常")
-				}
+		var futureOrder *MyFutureOrder = nil
+		if futureOrder = s.PlaceOrderManager.GetOrderInfo(order.ClientOrderID); futureOrder == nil {
+			futureOrder = &MyFutureOrder{FutureOrder: &mod.FutureOrder{}}
+		}
 
-				futureOrder.Symbol = order.Symbol
-				futureOrder.OrderID = order.ID
-				futureOrder.ClientOrderID = order.ClientOrderID
-				futureOrder.Price = util.Round(order.Price, 2)
-				futureOrder.OrigQty = util.Round(order.OrigQty, 3)
-				futureOrder.AvgPrice = strconv.FormatFloat(order.AvgPrice, 'f', 10, 64)
-				futureOrder.ExecutedQty = util.Round(order.ExecutedQty, 3)
-				futureOrder.Status = order.OrderStatus
-				futureOrder.TimeInForce = binance.TimeInForce(order.TimeInForce)
-				futureOrder.Type = binance.OrderType(order.OrderType)
-				futureOrder.OrigType = string(order.OrigType)
-				futureOrder.Side = binance.OrderSide(order.Side)
-				futureOrder.ClosePosition = order.IsClose
-				futureOrder.StopPrice = order.StopPrice
-				futureOrder.ReduceOnly = order.IsReduce
-				futureOrder.PositionSide = order.PositionSide
-				futureOrder.Time = order.Time       //新订单是否有值？
-				futureOrder.UpdateTime = order.Time //新订单是否有值？
-				Logger.Debug("", zap.Any(s.Symbol, futureOrder))
-				Logger.Sugar().Infof("价格 : %v 数量 : %v 买卖方向 : %v 持仓方向 : %v 类型 : %v", order.Price, order.OrigQty, side, positionSide, orderFlag)
-				switch order.NewEvent {
-				case binance.EventNew: //新挂单
-					{
-						Logger.Info("新挂单", zap.Any(s.Symbol, order))
-						s.SaveFutureOrder(futureOrder, order.ClientOrderID)
-					}
+		orderFlag := ""
+		if futureOrder.OrderFlag == util.ADDPOSITION && futureOrder.OrdeType == util.PIN {
+			orderFlag = "自动加仓单"
+		} else if futureOrder.OrderFlag == util.DELPOSITION && (futureOrder.OrdeType == util.CLOSECOMMON || futureOrder.OrdeType == util.PINCLOSECOMMON) {
+			orderFlag = "自动减仓单"
+		} else if futureOrder.OrdeType == util.GRID {
+			orderFlag = "网格单"
+		} else if futureOrder.OrdeType == util.COMMON && futureOrder.OrderFlag == util.UNKNNOW {
+			if (order.PositionSide == mod.LONG && order.Side == mod.SideBuy) ||
+				(order.PositionSide == mod.SHORT && order.Side == mod.SideSell) {
+				Logger.Info("手动加仓")
+			} else if (order.PositionSide == mod.LONG && order.Side == mod.SideSell) ||
+				(order.PositionSide == mod.SHORT && order.Side == mod.SideBuy) {
+				Logger.Info("手动减仓")
+			}
+		} else {
+			Logger.Error("异常")
+		}
 
-				case binance.EventCanceled: //挂单取消
+		futureOrder.Symbol = order.Symbol
+		futureOrder.OrderID = order.OrderID
+		futureOrder.ClientOrderID = order.ClientOrderID
+		futureOrder.Price = s.price(order.Price)
+		futureOrder.OrigQty = s.qty(order.OrigQty)
+		futureOrder.AvgPrice = order.AvgPrice
+		futureOrder.ExecutedQty = s.qty(order.ExecutedQty)
+		futureOrder.Status = order.Status
+		futureOrder.TimeInForce = order.TimeInForce
+		futureOrder.Type = order.Type
+		futureOrder.OrigType = order.OrigType
+		futureOrder.Side = order.Side
+		futureOrder.ClosePosition = order.ClosePosition
+		futureOrder.StopPrice = order.StopPrice
+		futureOrder.ReduceOnly = order.ReduceOnly
+		futureOrder.PositionSide = order.PositionSide
+		futureOrder.Time = order.Time       //新订单是否有值？
+		futureOrder.UpdateTime = order.Time //新订单是否有值？
+		Logger.Debug("", zap.Any(s.Symbol, futureOrder))
+		Logger.Sugar().Infof("价格 : %v 数量 : %v 买卖方向 : %v 持仓方向 : %v 类型 : %v", order.Price, order.OrigQty, side, positionSide, orderFlag)
+		switch order.Event {
+		case mod.EventNew: //新挂单
+			{
+				Logger.Info("新挂单", zap.Any(s.Symbol, order))
+				s.SaveFutureOrder(futureOrder, order.ClientOrderID)
+				s.notifyOrderUpdate(order, futureOrder)
+			}
+
+		case mod.EventCanceled: //挂单取消
+			{
+				Logger.Info("挂单取消", zap.Any(s.Symbol, order))
+				s.DelFutureOrder(futureOrder, order.ClientOrderID)
+				s.notifyOrderUpdate(order, futureOrder)
+			}
+		case mod.EventCalculated: //挂单计算？
+			{
+				Logger.Info("挂单计算？", zap.Any(s.Symbol, order))
+			}
+		case mod.EventTrade: //挂单成交
+			{
+				Logger.Info("挂单成交", zap.Any(s.Symbol, order))
+				switch order.Status {
+				case mod.StatusNew:
 					{
-						Logger.Info("挂单取消", zap.Any(s.Symbol, order))
-						s.DelFutureOrder(futureOrder, order.ClientOrderID)
-						if futureOrder.ExecutedQty != 0 &&
-							((futureOrder.PositionSide == string(binance.LONG) && futureOrder.Side == binance.SideBuy) ||
-								(futureOrder.PositionSide == string(binance.SHORT) && futureOrder.Side == binance.SideSell)) {
-							//部分成交的加仓挂单创建对应平仓单
-							s.MakePlaceOrder(futureOrder)
-							s.MakeCloseOrder(futureOrder)
-						}
+
 					}
-				case binance.EventCalCulated: //挂单计算？
+				case mod.StatusPartiallyFilled:
 					{
-						Logger.Info("挂单计算？", zap.Any(s.Symbol, order))
+						s.SaveFutureOrder(futureOrder, order.ClientOrderID)
+						s.notifyOrderUpdate(order, futureOrder)
+						// util.SendOrderMsg("")
 					}
-				case binance.EventTrade: //挂单成交
+				case mod.StatusFilled:
 					{
-						Logger.Info("挂单成交", zap.Any(s.Symbol, order))
-						switch order.OrderStatus {
-						case binance.StatusNew:
-							{
 
+						s.DelFutureOrder(futureOrder, order.ClientOrderID)
+						s.notifyOrderUpdate(order, futureOrder)
+						fx := "开仓"
+						var f1, f2, f3 float64
+						if futureOrder.PositionSide == mod.LONG {
+							if futureOrder.Side == mod.SideSell {
+								fx = "平仓"
 							}
-						case binance.StatusPartiallyFilled:
-							{
-								s.SaveFutureOrder(futureOrder, order.ClientOrderID)
-								// util.SendOrderMsg("")
-							}
-						case binance.StatusFilled:
-							{
-
-								s.DelFutureOrder(futureOrder, order.ClientOrderID)
-								//创建平仓单
-								s.MakePlaceOrder(futureOrder)
-								s.MakeCloseOrder(futureOrder)
-								fx := "开仓"
-								var f1, f2, f3 float64
-								if futureOrder.PositionSide == string(binance.LONG) {
-									if futureOrder.Side == binance.SideSell {
-										fx = "平仓"
-									}
-									f1, f2, f3 = s.GetLongBetweenAllCloseFutureOrderAndPositionD_Value()
-								}
-								if futureOrder.PositionSide == string(binance.SHORT) {
-									if futureOrder.Side == binance.SideBuy {
-										fx = "平仓"
-									}
-									f1, f2, f3 = s.GetShortBetweenAllCloseFutureOrderAndPositionD_Value()
-								}
-								msg := fmt.Sprintf("订单类型 : %s \n订单品种 : %s  \n订单方向 : %s  \n成交价格 : %f  \n成交数量 :  %f \n盈利 : %f \n仓位 : %f \n所有平仓挂单的仓位 : %f \n当前持仓价格 : %f \n",
-									fx, "ETHUSDT", futureOrder.PositionSide, futureOrder.Price, futureOrder.OrigQty, order.Profit, f1, f2, f3)
-								util.SendOrderMsg(msg)
-							}
-						case binance.StatusCancelled:
-							{
-								s.DelFutureOrder(futureOrder, order.ClientOrderID)
-							}
-						case binance.StatusExpired:
-							{
-								s.DelFutureOrder(futureOrder, order.ClientOrderID)
-							}
-						case binance.StatusInsurance:
-							{
-								s.DelFutureOrder(futureOrder, order.ClientOrderID)
-							}
-						case binance.StatusADL:
-							{
-								s.DelFutureOrder(futureOrder, order.ClientOrderID)
-							}
-						default:
-							{
-								Logger.Sugar().Errorf("未知订单状态 : %v", order.OrderStatus)
+							f1, f2, f3 = s.GetLongBetweenAllCloseFutureOrderAndPositionD_Value()
+						}
+						if futureOrder.PositionSide == mod.SHORT {
+							if futureOrder.Side == mod.SideBuy {
+								fx = "平仓"
 							}
+							f1, f2, f3 = s.GetShortBetweenAllCloseFutureOrderAndPositionD_Value()
 						}
+						msg := fmt.Sprintf("订单类型 : %s \n订单品种 : %s  \n订单方向 : %s  \n成交价格 : %f  \n成交数量 :  %f \n盈利 : %f \n仓位 : %f \n所有平仓挂单的仓位 : %f \n当前持仓价格 : %f \n",
+							fx, s.Symbol, futureOrder.PositionSide, futureOrder.Price, futureOrder.OrigQty, order.RealizedProfit, f1, f2, f3)
+						util.SendOrderMsg(msg)
 					}
-				case binance.EventExpired: //挂单过期
+				case mod.StatusCanceled:
 					{
-						Logger.Info("挂单过期", zap.Any(s.Symbol, order))
 						s.DelFutureOrder(futureOrder, order.ClientOrderID)
-						if futureOrder.ExecutedQty != 0 &&
-							((futureOrder.PositionSide == string(binance.LONG) && futureOrder.Side == binance.SideBuy) ||
-								(futureOrder.PositionSide == string(binance.SHORT) && futureOrder.Side == binance.SideSell)) {
-							//部分成交的加仓挂单创建对应平仓单
-							s.MakePlaceOrder(futureOrder)
-							s.MakeCloseOrder(futureOrder)
-						}
+						s.notifyOrderUpdate(order, futureOrder)
+					}
+				case mod.StatusExpired:
+					{
+						s.DelFutureOrder(futureOrder, order.ClientOrderID)
+						s.notifyOrderUpdate(order, futureOrder)
+					}
+				case mod.StatusInsurance:
+					{
+						s.DelFutureOrder(futureOrder, order.ClientOrderID)
+						s.notifyOrderUpdate(order, futureOrder)
+					}
+				case mod.StatusADL:
+					{
+						s.DelFutureOrder(futureOrder, order.ClientOrderID)
+						s.notifyOrderUpdate(order, futureOrder)
 					}
 				default:
 					{
-						Logger.Info("挂单未知事件类型", zap.Any(s.Symbol, order))
-						Logger.Sugar().Errorf("未知事件类型 : %v", order.NewEvent)
+						Logger.Sugar().Errorf("未知订单状态 : %v", order.Status)
 					}
 				}
 			}
+		case mod.EventExpired: //挂单过期
+			{
+				Logger.Info("挂单过期", zap.Any(s.Symbol, order))
+				s.DelFutureOrder(futureOrder, order.ClientOrderID)
+				s.notifyOrderUpdate(order, futureOrder)
+			}
+		default:
+			{
+				Logger.Info("挂单未知事件类型", zap.Any(s.Symbol, order))
+				Logger.Sugar().Errorf("未知事件类型 : %v", order.Event)
+			}
 		}
 	}
 }
 
-func (s *Strategy) InitStrategy(symbol string) error {
-	//初始化结构
-	{
-		s.Symbol = symbol
-		s.FutureOrder = make(map[string]*MyFutureOrder)
-		s.LongPosition.RWMutex = &sync.RWMutex{}
-		s.ShortPosition.RWMutex = &sync.RWMutex{}
-		s.LongPosition.PinFutureOrder = make(map[string]*MyFutureOrder)
-		s.ShortPosition.PinFutureOrder = make(map[string]*MyFutureOrder)
-		s.LongPosition.CloseAllFutureOrder = make(map[string]*MyFutureOrder)
-		s.ShortPosition.CloseAllFutureOrder = make(map[string]*MyFutureOrder)
-		s.LongPosition.CloseFutureOrder = make(map[string]*MyFutureOrder)
-		s.ShortPosition.CloseFutureOrder = make(map[string]*MyFutureOrder)
-		s.PlaceOrderManager = &PlaceOrderManager{
-			RWMutex:       &sync.RWMutex{},
-			Symbol:        symbol,
-			Quantity:      util.Quantity,
-			OrderType:     make(map[string]*MyFutureOrder),
-			TerracedPrice: util.TerracedPrice,
-			positionInfo:  s,
-		}
-		s.KlineManager = &Market{
-			MinuteKlineList:        NewQueue(60),     //一小时
-			FifteenMinuteKlineList: NewQueue(16),     //4小时
-			OneHourKlineList:       NewQueue(24),     //一天
-			FourHourKlineList:      NewQueue(6 * 10), //10天
-			DayKlineList:           NewQueue(30),     //30天
-		}
+// 本地挂单更新后通知插件
+func (s *Strategy) notifyOrderUpdate(order *mod.OrderUpdate, futureOrder *MyFutureOrder) {
+	for _, sig := range s.Signals {
+		sig.OnOrderUpdate(order, futureOrder)
 	}
+}
+
+func (s *Strategy) InitStrategy(ex quant.Exchange, symbol string) error {
+	s.Exchange = ex
+	s.InitState(symbol)
+
 	//初始化账户
-	acc := &BinanceFutureAsset{RWMutex: &sync.RWMutex{}}
-	acc.InitAccount(symbol)
+	acc := &BinanceFutureAsset{RWMutex: &sync.RWMutex{}, Exchange: ex}
+	if err := acc.InitAccount(symbol); err != nil {
+		return err
+	}
 	s.PlaceOrderManager.Account = acc
 
 	//加载当前持仓
@@ -416,21 +382,52 @@ func (s *Strategy) InitStrategy(symbol string) error {
 	//加载当前挂单
 	s.LoadAllOpenOrder()
 
-	//启动所有定时任务
-	s.ClearPartiallyFilledOrder()
-	s.ScanCloseFutureOrder()
-	s.ScanPositionAndCreatCloseFutureOrder()
+	//启动挂单对账任务,其他定时任务由插件启动
 	s.ScanFutureOrder()
 
-	//初始化K线事件
-	s.KlineWs = Binance.GetKlineWs(util.ETHUSDT, binance.Minute)
-	// s.Ch15Kline = Binance.GetKlineWs(util.ETHUSDT, binance.FiveMinutes)
-
-	//初始化账户事件
-	s.AccWs, _ = Binance.GetAccountWs()
+	//初始化K线事件,先订阅再加载,中间缺的K线由推送补上
+	s.KlineWs = s.KlineManager.Subscribe()
 
 	//初始化K线
-	s.KlineManager.InitMarket(symbol)
+	if err := s.KlineManager.InitMarket(symbol); err != nil {
+		return err
+	}
 
-	return nil
+	//资金费率只用于过滤开仓,失败时不影响启动
+	if s.Param.Funding.Enable {
+		s.InitFunding()
+	}
+
+	//初始化策略插件
+	return s.InitSignals()
+}
+
+// 初始化本地状态,不访问交易所
+func (s *Strategy) InitState(symbol string) {
+	s.Symbol = symbol
+	if s.Param == nil {
+		s.Param = util.LoadQuantParam(symbol)
+	}
+	s.AccWs = make(chan *mod.AccountEvent, 100)
+	s.StreamWs = make(chan *mod.StreamEvent, 16)
+	s.FutureOrder = make(map[string]*MyFutureOrder)
+	s.GridFutureOrder = make(map[string]*MyFutureOrder)
+	s.LongPosition.RWMutex = &sync.RWMutex{}
+	s.ShortPosition.RWMutex = &sync.RWMutex{}
+	s.LongPosition.PinFutureOrder = make(map[string]*MyFutureOrder)
+	s.ShortPosition.PinFutureOrder = make(map[string]*MyFutureOrder)
+	s.LongPosition.CloseAllFutureOrder = make(map[string]*MyFutureOrder)
+	s.ShortPosition.CloseAllFutureOrder = make(map[string]*MyFutureOrder)
+	s.LongPosition.CloseFutureOrder = make(map[string]*MyFutureOrder)
+	s.ShortPosition.CloseFutureOrder = make(map[string]*MyFutureOrder)
+	s.PlaceOrderManager = &PlaceOrderManager{
+		RWMutex:       &sync.RWMutex{},
+		Symbol:        symbol,
+		Quantity:      s.Param.Quantity,
+		OrderType:     make(map[string]*MyFutureOrder),
+		TerracedPrice: s.Param.TerracedPrice,
+		Param:         s.Param,
+		positionInfo:  s,
+	}
+	s.KlineManager = NewMarket(s.Exchange, symbol, s.Param.Klines)
 }
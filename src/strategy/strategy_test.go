@@ -1,3 +1,7 @@
+//go:build integration
+// +build integration
+
+// 需要配置文件、MySQL 和币安接口,go test -tags integration 时才编译
 package strategy_test
 
 import (
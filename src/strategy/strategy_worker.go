@@ -2,11 +2,10 @@ package strategy
 
 import (
 	"math"
+	"tinyquant/src/mod"
 	"tinyquant/src/util"
 
 	. "tinyquant/src/logger"
-
-	"github.com/rootpd/binance"
 )
 
 //创建平仓单
@@ -15,7 +14,7 @@ func (s *Strategy) MakePlaceOrder(futureOrder *MyFutureOrder) {
 	if futureOrder.OrderFlag == util.ADDPOSITION && futureOrder.OrdeType == util.PIN {
 		s.LoadPosition() //实时更新下仓位
 		var positionAmt, closePosition, entryPrice float64 = 0.0, 0.0, 0.0
-		if futureOrder.PositionSide == string(binance.LONG) {
+		if futureOrder.PositionSide == mod.LONG {
 			positionAmt, closePosition, entryPrice = s.GetLongBetweenAllCloseFutureOrderAndPositionD_Value()
 		} else {
 			positionAmt, closePosition, entryPrice = s.GetShortBetweenAllCloseFutureOrderAndPositionD_Value()
@@ -24,22 +23,22 @@ func (s *Strategy) MakePlaceOrder(futureOrder *MyFutureOrder) {
 			Symbol:       s.Symbol,
 			OrderStatus:  util.CLOSECOMMON,
 			Side:         futureOrder.Side,
-			PositionSide: binance.PositionSide(futureOrder.PositionSide),
+			PositionSide: futureOrder.PositionSide,
 			IsTest:       util.PlaceTest,
 			OrderFlag:    util.DELPOSITION,
 		}
-		if futureOrder.Side == binance.SideBuy {
-			newOrder.Side = binance.SideSell
+		if futureOrder.Side == mod.SideBuy {
+			newOrder.Side = mod.SideSell
 		} else {
-			newOrder.Side = binance.SideBuy
+			newOrder.Side = mod.SideBuy
 		}
 
-		if futureOrder.Status == binance.StatusCancelled || futureOrder.Status == binance.StatusExpired {
-			newOrder.Quantity = util.Round(futureOrder.ExecutedQty, 3)
-			if futureOrder.Side == binance.SideBuy {
-				newOrder.Price = util.Round(futureOrder.Price+futureOrder.Price*util.Profits, 2)
+		if futureOrder.Status == mod.StatusCanceled || futureOrder.Status == mod.StatusExpired {
+			newOrder.Quantity = s.qty(futureOrder.ExecutedQty)
+			if futureOrder.Side == mod.SideBuy {
+				newOrder.Price = s.price(futureOrder.Price + futureOrder.Price*s.Param.Profits)
 			} else {
-				newOrder.Price = util.Round(futureOrder.Price-futureOrder.Price*util.Profits, 2)
+				newOrder.Price = s.price(futureOrder.Price - futureOrder.Price*s.Param.Profits)
 			}
 			s.PlaceOrderManager.MakePlaceOrder(newOrder)
 			return
@@ -50,28 +49,28 @@ func (s *Strategy) MakePlaceOrder(futureOrder *MyFutureOrder) {
 			//说明有仓位没有对应挂单
 			//case1 手动取消了平仓挂单
 			//case2 由于价格相差过大自动取消了平仓挂单
-			newOrder.Quantity = util.Round(positionAmt-closePosition, 3)
-			if futureOrder.Side == binance.SideBuy {
-				newOrder.Price = util.Round(entryPrice+entryPrice*util.Profits/5.0, 2)
+			newOrder.Quantity = s.qty(positionAmt - closePosition)
+			if futureOrder.Side == mod.SideBuy {
+				newOrder.Price = s.price(entryPrice + entryPrice*s.Param.Profits/5.0)
 			} else {
-				newOrder.Price = util.Round(entryPrice-entryPrice*util.Profits/5.0, 2)
+				newOrder.Price = s.price(entryPrice - entryPrice*s.Param.Profits/5.0)
 			}
 			curPrice := s.KlineManager.MinuteKlineList.GetNewPrice()
 			//这里就是做T逻辑
-			if math.Abs(newOrder.Price-curPrice) > curPrice*util.CancelCloseOrderLevel {
-				newOrder.Quantity = util.Round(futureOrder.OrigQty, 3)
-				if futureOrder.Side == binance.SideBuy {
-					newOrder.Price = util.Round(futureOrder.Price+entryPrice*util.Profits, 2)
+			if math.Abs(newOrder.Price-curPrice) > curPrice*s.Param.CancelCloseOrderLevel {
+				newOrder.Quantity = s.qty(futureOrder.OrigQty)
+				if futureOrder.Side == mod.SideBuy {
+					newOrder.Price = s.price(futureOrder.Price + entryPrice*s.Param.Profits)
 				} else {
-					newOrder.Price = util.Round(futureOrder.Price-entryPrice*util.Profits, 2)
+					newOrder.Price = s.price(futureOrder.Price - entryPrice*s.Param.Profits)
 				}
 			}
 		} else {
-			newOrder.Quantity = util.Round(futureOrder.OrigQty, 3)
-			if futureOrder.Side == binance.SideBuy {
-				newOrder.Price = util.Round(futureOrder.Price+entryPrice*util.Profits, 2)
+			newOrder.Quantity = s.qty(futureOrder.OrigQty)
+			if futureOrder.Side == mod.SideBuy {
+				newOrder.Price = s.price(futureOrder.Price + entryPrice*s.Param.Profits)
 			} else {
-				newOrder.Price = util.Round(futureOrder.Price-entryPrice*util.Profits, 2)
+				newOrder.Price = s.price(futureOrder.Price - entryPrice*s.Param.Profits)
 			}
 		}
 
@@ -84,7 +83,7 @@ func (s *Strategy) MakeCloseOrder(futureOrder *MyFutureOrder) {
 	//更新
 	s.LoadPosition() //实时更新下仓位
 	var positionAmt, _, _ float64 = 0.0, 0.0, 0.0
-	if futureOrder.PositionSide == string(binance.LONG) {
+	if futureOrder.PositionSide == mod.LONG {
 		positionAmt, _, _ = s.GetLongBetweenAllCloseFutureOrderAndPositionD_Value()
 	} else {
 		positionAmt, _, _ = s.GetShortBetweenAllCloseFutureOrderAndPositionD_Value()
@@ -93,19 +92,25 @@ func (s *Strategy) MakeCloseOrder(futureOrder *MyFutureOrder) {
 		Symbol:       s.Symbol,
 		OrderStatus:  util.LOSSCLOSECOMMON,
 		Side:         futureOrder.Side,
-		PositionSide: binance.PositionSide(futureOrder.PositionSide),
+		PositionSide: futureOrder.PositionSide,
 		IsTest:       util.PlaceTest,
 		OrderFlag:    util.DELPOSITION,
 		Quantity:     positionAmt,
 	}
-	if futureOrder.Side == binance.SideBuy {
-		newOrder.Side = binance.SideSell
-		newOrder.Price = util.SupportLevel - 10
-		newOrder.ClosePrice = util.SupportLevel
+	if futureOrder.Side == mod.SideBuy {
+		newOrder.Side = mod.SideSell
+		newOrder.Price = s.Param.SupportLevel - 10
+		newOrder.ClosePrice = s.Param.SupportLevel
 	} else {
-		newOrder.Side = binance.SideBuy
-		newOrder.Price = util.PressureLevel + 10
-		newOrder.ClosePrice = util.PressureLevel
+		newOrder.Side = mod.SideBuy
+		newOrder.Price = s.Param.PressureLevel + 10
+		newOrder.ClosePrice = s.Param.PressureLevel
+	}
+	if s.Param.StopCallbackRate > 0 {
+		//跟踪止损,止损价跟着最高(低)价走,不再固定在支撑位压力位
+		newOrder.Type = mod.TypeTrailingStopMarket
+		newOrder.CallbackRate = s.Param.StopCallbackRate
+		newOrder.Price, newOrder.ClosePrice = 0, 0
 	}
 	s.PlaceOrderManager.MakePlaceOrder(newOrder)
 }
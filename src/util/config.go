@@ -2,6 +2,9 @@ package util
 
 import (
 	"fmt"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -9,9 +12,11 @@ import (
 func InitParam(follow bool) {
 	InitConfig(follow)
 	InitLogParam()
+	InitHttpParam()
 	// InitMysqlParams()
 	InitQuantParam()
 	// InitRedisParams()
+	InitFutures()
 	InitApiKey()
 }
 
@@ -31,12 +36,13 @@ const (
 type ORIGIN_ORDER_STATUS int
 
 const (
-	COMMON          = ORIGIN_ORDER_STATUS(0)     // 手动单
-	PIN             = ORIGIN_ORDER_STATUS(1)     // 插针单
-	CLOSECOMMON     = ORIGIN_ORDER_STATUS(10)    // 普通平仓单
-	PINCLOSECOMMON  = ORIGIN_ORDER_STATUS(100)   // 插针平仓单
-	LOSSCLOSECOMMON = ORIGIN_ORDER_STATUS(1000)  // 止损平仓单
-	FLOW            = ORIGIN_ORDER_STATUS(10000) // 顺势单
+	COMMON          = ORIGIN_ORDER_STATUS(0)      // 手动单
+	PIN             = ORIGIN_ORDER_STATUS(1)      // 插针单
+	CLOSECOMMON     = ORIGIN_ORDER_STATUS(10)     // 普通平仓单
+	PINCLOSECOMMON  = ORIGIN_ORDER_STATUS(100)    // 插针平仓单
+	LOSSCLOSECOMMON = ORIGIN_ORDER_STATUS(1000)   // 止损平仓单
+	FLOW            = ORIGIN_ORDER_STATUS(10000)  // 顺势单
+	GRID            = ORIGIN_ORDER_STATUS(100000) // 网格单
 )
 
 type ORIGIN_ORDER_FLAG int
@@ -50,14 +56,23 @@ const (
 const (
 	ETHUSDT = "ETHUSDT"
 	ETHBUSD = "ETHBUSD"
+	BTCUSDT = "BTCUSDT"
 )
 
+const COIN_ETHUSD = "ETHUSD_PERP"
+
 var ACCOUNTASSET = map[string]string{
-	ETHUSDT: "USDT",
-	ETHBUSD: "BUSD",
+	ETHUSDT:     "USDT",
+	ETHBUSD:     "BUSD",
+	BTCUSDT:     "USDT",
+	COIN_ETHUSD: "ETH",
 }
 
-const COIN_ETHUSD = "ETHUSD_PERP"
+// 币本位合约面值(美元/张),数量按张计算
+var ContractSize = map[string]float64{
+	COIN_ETHUSD:   10,
+	"BTCUSD_PERP": 100,
+}
 
 const OrderType = "order_type"
 const CopyOrderID = "copy_id"
@@ -67,12 +82,6 @@ const TrySellCount = "tsc"
 const AllTryBuyCount = "atbc"
 const AllTrySellCount = "atsc"
 
-var Order_Precision = map[string]int{
-	"FILUSDT": 8,
-	"BTCUSDT": 8,
-	"ETHUSDT": 6,
-}
-
 // 账户信息推送事件
 const (
 	ListenKeyExpired      = "listenKeyExpired"
@@ -87,10 +96,29 @@ var (
 	BINANCE_SECRET_KEY string
 )
 
+var (
+	HUOBI_ACCESS_KEY string
+	HUOBI_SECRET_KEY string
+	HUOBI_HOST       string
+	HUOBI_ACCOUNT_ID string // 现货账户 id,为空时自动查询
+)
+
 var (
 	WXROBOTURL string
 )
 
+// 交易所接口的 http 和 websocket 连接配置
+var (
+	HttpTimeout         time.Duration // 单个请求超时
+	HttpDialTimeout     time.Duration // 建立连接超时
+	HttpIdleConnTimeout time.Duration // 空闲连接保持时间
+	HttpMaxIdleConns    int           // 最大空闲连接数
+	HttpProxy           string        // 空 : 不使用代理 env : 使用环境变量 其他 : 代理地址,例如 http://127.0.0.1:7890
+	HttpInsecure        bool          // 跳过 TLS 证书校验,只用于调试
+	TimeSyncInterval    time.Duration // 同步服务器时间的间隔,为0时只在启动时同步
+	MaxClockDrift       time.Duration // 本地时钟偏差超过后告警
+)
+
 var (
 	MysqlHost   string
 	MysqlUser   string
@@ -104,7 +132,11 @@ var (
 	VolumeIncrease              float64
 	VolumeIncreaseForClose      float64
 	SpringPrice                 float64
+	AtrSpring                   float64 //插针偏离均价的距离按分钟线 ATR 的倍数计算,为0时使用 SpringPrice
+	AtrPeriod                   int
 	PlaceTest                   bool
+	Paper                       bool      //模拟盘,订单在本地撮合
+	PaperBalance                float64   //模拟盘初始资金
 	TerracedPrice               []float64 //连续开单T度
 	CancelCloseOrderLevel       float64   //取消平仓单
 	CreatCloseOrderLevel        float64   //创建平仓单
@@ -113,8 +145,82 @@ var (
 	ContinuousOrderValidityTime int64
 	SupportLevel                float64
 	PressureLevel               float64
+	StopCallbackRate            float64  //止损单回调比例,1 代表 1%,为0时在支撑位压力位挂固定止损单
+	PinCloseType                string   //插针平仓单下单方式 LIMIT MARKET IOC FOK GTX
+	Symbols                     []string //同时运行的交易对,为空时使用命令行参数
+	Signals                     []string //启用的策略插件名
+	Grid                        GridParam
+	OrderBook                   OrderBookParam
+	TradeBar                    BarParam
+	Klines                      []KlineParam //本地K线的周期和根数
+	Funding                     FundingParam
 )
 
+// 单个交易对的策略参数
+type QuantParam struct {
+	Quantity                    float64
+	Profits                     float64
+	VolumeIncrease              float64
+	VolumeIncreaseForClose      float64
+	SpringPrice                 float64
+	AtrSpring                   float64
+	AtrPeriod                   int
+	TerracedPrice               []float64 //连续开单T度
+	CancelCloseOrderLevel       float64   //取消平仓单
+	CreatCloseOrderLevel        float64   //创建平仓单
+	IncreaseQuantityLevel       float64   //增加开仓
+	DoubleCreatOrderLevel       float64   //增加开仓仓位满足Profits的倍数
+	ContinuousOrderValidityTime int64
+	SupportLevel                float64
+	PressureLevel               float64
+	StopCallbackRate            float64
+	PinCloseType                string
+	Signals                     []string //策略插件名,为空时只运行插针策略
+	Grid                        GridParam
+	OrderBook                   OrderBookParam
+	TradeBar                    BarParam
+	Klines                      []KlineParam
+	Funding                     FundingParam
+}
+
+// 网格策略参数
+type GridParam struct {
+	Upper     float64 //价格上限
+	Lower     float64 //价格下限
+	Count     int     //网格数量,挂单价格有 Count+1 档
+	Quantity  float64 //每格数量,为0时使用 Quantity
+	Geometric bool    //等比网格,默认等差
+}
+
+// 订单簿过滤参数,插针开仓前用深度确认或放弃
+type OrderBookParam struct {
+	Enable       bool    //订阅深度并过滤插针开仓单
+	Bps          float64 //统计中间价上下多少个基点内的挂单
+	MaxImbalance float64 //反方向失衡超过这个值时放弃开仓,0~1
+	MaxSlippage  float64 //开仓数量按当前深度成交的滑点上限,基点
+	WallMultiple float64 //挂单量超过范围内平均每档的倍数认为是大单墙
+	WallPulled   int64   //开仓方向的大单墙在这么多秒内被撤掉时放弃开仓
+}
+
+// 资金费率参数,临近结算时开仓方向需要支付的费率过高就不开仓
+type FundingParam struct {
+	Enable  bool    //跟踪标记价格和资金费率,记录资金费用流水
+	Window  int64   //结算前多少秒内检查费率
+	MaxRate float64 //开仓方向支付的费率超过这个值时放弃开仓,0.0001 即 0.01%
+}
+
+// 本地K线的周期和根数
+type KlineParam struct {
+	Interval string //1m 15m 4h 等
+	Length   int    //为0时保留60根
+}
+
+// 成交推送合成K线的参数,Type 为空时不订阅成交
+type BarParam struct {
+	Type string  //time tick volume dollar
+	Size float64 //秒数、成交笔数、成交量或成交额
+}
+
 var (
 	Console      bool
 	File         bool
@@ -142,10 +248,20 @@ type BinanceConfig struct {
 
 var DocumentaryApi *Api
 
+var (
+	ConfigFile string // 配置文件路径,为空时读取当前目录下的 config
+	ApiFile    string // 跟单账户配置路径,为空时读取当前目录下的 api.json
+	Futures    string // 合约类型 usdt : u本位 coin : 币本位 huobi : 火币现货
+)
+
 func InitConfig(documentary bool) {
 
-	viper.SetConfigName("config")
-	viper.AddConfigPath("./")
+	if ConfigFile != "" {
+		viper.SetConfigFile(ConfigFile)
+	} else {
+		viper.SetConfigName("config")
+		viper.AddConfigPath("./")
+	}
 	err := viper.ReadInConfig()
 	if err != nil {
 		panic(err)
@@ -154,21 +270,36 @@ func InitConfig(documentary bool) {
 
 	if documentary {
 		vjson := viper.New()
-		vjson.SetConfigName("api")
+		if ApiFile != "" {
+			vjson.SetConfigFile(ApiFile)
+		} else {
+			vjson.SetConfigName("api")
+			vjson.AddConfigPath(".")
+		}
 		vjson.SetConfigType("json")
-		vjson.AddConfigPath(".")
 
 		if err := vjson.ReadInConfig(); err != nil {
 			panic("read api config failed")
 		}
 
-		err = vjson.Unmarshal(&documentary)
+		DocumentaryApi = &Api{}
+		err = vjson.Unmarshal(DocumentaryApi)
 		if err != nil {
 			panic("Unmarshal config failed")
 		}
 	}
 }
 
+// 合约类型优先使用命令行参数,其次是环境变量 futures
+func InitFutures() {
+	if Futures != "" {
+		return
+	}
+	if f, ok := os.LookupEnv("futures"); ok {
+		Futures = f
+	}
+}
+
 func InitMysqlParams() {
 
 	MysqlHost = viper.GetString("mysql.Host")
@@ -214,10 +345,17 @@ func InitQuantParam() {
 	VolumeIncrease = viper.GetFloat64("quant.VolumeIncrease")
 	viper.SetDefault("quant.VolumeIncreaseForClose", 4.0)
 	VolumeIncreaseForClose = viper.GetFloat64("quant.VolumeIncreaseForClose")
-	viper.SetDefault("quant.VolumeIncrease", 0.0025)
+	viper.SetDefault("quant.SpringPrice", 0.0025)
 	SpringPrice = viper.GetFloat64("quant.SpringPrice")
+	AtrSpring = viper.GetFloat64("quant.AtrSpring")
+	viper.SetDefault("quant.AtrPeriod", 14)
+	AtrPeriod = viper.GetInt("quant.AtrPeriod")
 	viper.SetDefault("quant.PlaceTest", true)
 	PlaceTest = viper.GetBool("quant.PlaceTest")
+	viper.SetDefault("quant.Paper", false)
+	Paper = viper.GetBool("quant.Paper")
+	viper.SetDefault("quant.PaperBalance", 1000.0)
+	PaperBalance = viper.GetFloat64("quant.PaperBalance")
 	TerracedPrice = make([]float64, 0)
 	TerracedPrice = append(TerracedPrice, viper.GetFloat64("quant.TerracedPrice0"))
 	TerracedPrice = append(TerracedPrice, viper.GetFloat64("quant.TerracedPrice1"))
@@ -241,6 +379,155 @@ func InitQuantParam() {
 	viper.SetDefault("quant.SupportLevel", 1310)
 	SupportLevel = viper.GetFloat64("quant.SupportLevel")
 
+	viper.SetDefault("quant.StopCallbackRate", 0.0)
+	StopCallbackRate = viper.GetFloat64("quant.StopCallbackRate")
+
+	viper.SetDefault("quant.PinCloseType", "LIMIT")
+	PinCloseType = viper.GetString("quant.PinCloseType")
+
+	Symbols = viper.GetStringSlice("quant.Symbols")
+
+	viper.SetDefault("quant.Signals", []string{"pin"})
+	Signals = viper.GetStringSlice("quant.Signals")
+
+	Grid.Upper = viper.GetFloat64("quant.Grid.Upper")
+	Grid.Lower = viper.GetFloat64("quant.Grid.Lower")
+	viper.SetDefault("quant.Grid.Count", 10)
+	Grid.Count = viper.GetInt("quant.Grid.Count")
+	Grid.Quantity = viper.GetFloat64("quant.Grid.Quantity")
+	Grid.Geometric = viper.GetBool("quant.Grid.Geometric")
+
+	OrderBook.Enable = viper.GetBool("quant.OrderBook.Enable")
+	viper.SetDefault("quant.OrderBook.Bps", 10.0)
+	OrderBook.Bps = viper.GetFloat64("quant.OrderBook.Bps")
+	viper.SetDefault("quant.OrderBook.MaxImbalance", 0.6)
+	OrderBook.MaxImbalance = viper.GetFloat64("quant.OrderBook.MaxImbalance")
+	viper.SetDefault("quant.OrderBook.MaxSlippage", 10.0)
+	OrderBook.MaxSlippage = viper.GetFloat64("quant.OrderBook.MaxSlippage")
+	viper.SetDefault("quant.OrderBook.WallMultiple", 5.0)
+	OrderBook.WallMultiple = viper.GetFloat64("quant.OrderBook.WallMultiple")
+	viper.SetDefault("quant.OrderBook.WallPulled", 30)
+	OrderBook.WallPulled = viper.GetInt64("quant.OrderBook.WallPulled")
+
+	TradeBar.Type = viper.GetString("quant.TradeBar.Type")
+	TradeBar.Size = viper.GetFloat64("quant.TradeBar.Size")
+
+	Funding.Enable = viper.GetBool("quant.Funding.Enable")
+	viper.SetDefault("quant.Funding.Window", 600)
+	Funding.Window = viper.GetInt64("quant.Funding.Window")
+	viper.SetDefault("quant.Funding.MaxRate", 0.0001)
+	Funding.MaxRate = viper.GetFloat64("quant.Funding.MaxRate")
+
+	Klines = []KlineParam{{Interval: "1m", Length: 60}, {Interval: "15m", Length: 16}, {Interval: "4h", Length: 60}}
+	if viper.IsSet("quant.Klines") {
+		Klines = nil
+		if err := viper.UnmarshalKey("quant.Klines", &Klines); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// 读取交易对的策略参数,symbols.<交易对> 下没有配置的项使用 quant 下的值
+func LoadQuantParam(symbol string) *QuantParam {
+	p := &QuantParam{
+		Quantity:                    Quantity,
+		Profits:                     Profits,
+		VolumeIncrease:              VolumeIncrease,
+		VolumeIncreaseForClose:      VolumeIncreaseForClose,
+		SpringPrice:                 SpringPrice,
+		AtrSpring:                   AtrSpring,
+		AtrPeriod:                   AtrPeriod,
+		TerracedPrice:               append([]float64{}, TerracedPrice...),
+		CancelCloseOrderLevel:       CancelCloseOrderLevel,
+		CreatCloseOrderLevel:        CreatCloseOrderLevel,
+		IncreaseQuantityLevel:       IncreaseQuantityLevel,
+		DoubleCreatOrderLevel:       DoubleCreatOrderLevel,
+		ContinuousOrderValidityTime: ContinuousOrderValidityTime,
+		SupportLevel:                SupportLevel,
+		PressureLevel:               PressureLevel,
+		StopCallbackRate:            StopCallbackRate,
+		PinCloseType:                PinCloseType,
+		Signals:                     append([]string{}, Signals...),
+		Grid:                        Grid,
+		OrderBook:                   OrderBook,
+		TradeBar:                    TradeBar,
+		Klines:                      append([]KlineParam{}, Klines...),
+		Funding:                     Funding,
+	}
+	v := viper.Sub("symbols." + strings.ToLower(symbol))
+	if v == nil {
+		return p
+	}
+	float := func(key string, f *float64) {
+		if v.IsSet(key) {
+			*f = v.GetFloat64(key)
+		}
+	}
+	float("Quantity", &p.Quantity)
+	float("Profits", &p.Profits)
+	float("VolumeIncrease", &p.VolumeIncrease)
+	float("VolumeIncreaseForClose", &p.VolumeIncreaseForClose)
+	float("SpringPrice", &p.SpringPrice)
+	float("AtrSpring", &p.AtrSpring)
+	if v.IsSet("AtrPeriod") {
+		p.AtrPeriod = v.GetInt("AtrPeriod")
+	}
+	for i := range p.TerracedPrice {
+		float(fmt.Sprintf("TerracedPrice%d", i), &p.TerracedPrice[i])
+	}
+	float("CancelCloseOrderLevel", &p.CancelCloseOrderLevel)
+	float("CreatCloseOrderLevel", &p.CreatCloseOrderLevel)
+	float("IncreaseQuantityLevel", &p.IncreaseQuantityLevel)
+	float("DoubleCreatOrderLevel", &p.DoubleCreatOrderLevel)
+	float("SupportLevel", &p.SupportLevel)
+	float("PressureLevel", &p.PressureLevel)
+	float("StopCallbackRate", &p.StopCallbackRate)
+	if v.IsSet("ContinuousOrderValidityTime") {
+		p.ContinuousOrderValidityTime = v.GetInt64("ContinuousOrderValidityTime")
+	}
+	if v.IsSet("PinCloseType") {
+		p.PinCloseType = v.GetString("PinCloseType")
+	}
+	if v.IsSet("Signals") {
+		p.Signals = v.GetStringSlice("Signals")
+	}
+	float("Grid.Upper", &p.Grid.Upper)
+	float("Grid.Lower", &p.Grid.Lower)
+	float("Grid.Quantity", &p.Grid.Quantity)
+	if v.IsSet("Grid.Count") {
+		p.Grid.Count = v.GetInt("Grid.Count")
+	}
+	if v.IsSet("Grid.Geometric") {
+		p.Grid.Geometric = v.GetBool("Grid.Geometric")
+	}
+	if v.IsSet("OrderBook.Enable") {
+		p.OrderBook.Enable = v.GetBool("OrderBook.Enable")
+	}
+	float("OrderBook.Bps", &p.OrderBook.Bps)
+	float("OrderBook.MaxImbalance", &p.OrderBook.MaxImbalance)
+	float("OrderBook.MaxSlippage", &p.OrderBook.MaxSlippage)
+	float("OrderBook.WallMultiple", &p.OrderBook.WallMultiple)
+	if v.IsSet("OrderBook.WallPulled") {
+		p.OrderBook.WallPulled = v.GetInt64("OrderBook.WallPulled")
+	}
+	if v.IsSet("TradeBar.Type") {
+		p.TradeBar.Type = v.GetString("TradeBar.Type")
+	}
+	float("TradeBar.Size", &p.TradeBar.Size)
+	if v.IsSet("Funding.Enable") {
+		p.Funding.Enable = v.GetBool("Funding.Enable")
+	}
+	if v.IsSet("Funding.Window") {
+		p.Funding.Window = v.GetInt64("Funding.Window")
+	}
+	float("Funding.MaxRate", &p.Funding.MaxRate)
+	if v.IsSet("Klines") {
+		p.Klines = nil
+		if err := v.UnmarshalKey("Klines", &p.Klines); err != nil {
+			panic(err)
+		}
+	}
+	return p
 }
 
 func InitLogParam() {
@@ -258,15 +545,43 @@ func InitLogParam() {
 	fmt.Printf("%v %v %v %v %v\n", Console, File, Path, FileLevel, ConsoleLevel)
 }
 
+func InitHttpParam() {
+	viper.SetDefault("http.Timeout", "10s")
+	HttpTimeout = viper.GetDuration("http.Timeout")
+	viper.SetDefault("http.DialTimeout", "5s")
+	HttpDialTimeout = viper.GetDuration("http.DialTimeout")
+	viper.SetDefault("http.IdleConnTimeout", "90s")
+	HttpIdleConnTimeout = viper.GetDuration("http.IdleConnTimeout")
+	viper.SetDefault("http.MaxIdleConns", 30)
+	HttpMaxIdleConns = viper.GetInt("http.MaxIdleConns")
+	HttpProxy = viper.GetString("http.Proxy")
+	HttpInsecure = viper.GetBool("http.InsecureSkipVerify")
+	viper.SetDefault("http.TimeSyncInterval", "1m")
+	TimeSyncInterval = viper.GetDuration("http.TimeSyncInterval")
+	viper.SetDefault("http.MaxClockDrift", "1s")
+	MaxClockDrift = viper.GetDuration("http.MaxClockDrift")
+}
+
 func InitApiKey() {
 
-	BINANCE_API_KEY = viper.GetString("system.ApiKey")
-	if BINANCE_API_KEY == "" {
-		panic("Get ApiKey  failed ")
-	}
-	BINANCE_SECRET_KEY = viper.GetString("system.SecretKey")
-	if BINANCE_SECRET_KEY == "" {
-		panic("Get secretKey failed ")
+	viper.SetDefault("huobi.Host", "api.huobi.pro")
+	HUOBI_HOST = viper.GetString("huobi.Host")
+	HUOBI_ACCOUNT_ID = viper.GetString("huobi.AccountId")
+	HUOBI_ACCESS_KEY = viper.GetString("huobi.AccessKey")
+	HUOBI_SECRET_KEY = viper.GetString("huobi.SecretKey")
+	if Futures == "huobi" {
+		if HUOBI_ACCESS_KEY == "" || HUOBI_SECRET_KEY == "" {
+			panic("Get huobi key failed ")
+		}
+	} else {
+		BINANCE_API_KEY = viper.GetString("system.ApiKey")
+		if BINANCE_API_KEY == "" {
+			panic("Get ApiKey  failed ")
+		}
+		BINANCE_SECRET_KEY = viper.GetString("system.SecretKey")
+		if BINANCE_SECRET_KEY == "" {
+			panic("Get secretKey failed ")
+		}
 	}
 
 	WXROBOTURL = viper.GetString("system.robot")
@@ -0,0 +1,40 @@
+package util_test
+
+import (
+	"testing"
+	"tinyquant/src/util"
+
+	"github.com/spf13/viper"
+)
+
+func Test_LoadQuantParam(t *testing.T) {
+	util.Quantity, util.Profits = 0.01, 0.0125
+	util.TerracedPrice = []float64{0.002, 0.004}
+	viper.Set("symbols.btcusdt.Quantity", 0.001)
+	viper.Set("symbols.btcusdt.TerracedPrice1", 0.005)
+
+	p := util.LoadQuantParam(util.BTCUSDT)
+	if p.Quantity != 0.001 || p.Profits != 0.0125 {
+		t.Errorf("quantity = %v profits = %v, want 0.001 0.0125", p.Quantity, p.Profits)
+	}
+	if p.TerracedPrice[0] != 0.002 || p.TerracedPrice[1] != 0.005 || util.TerracedPrice[1] != 0.004 {
+		t.Errorf("terraced price = %v global = %v", p.TerracedPrice, util.TerracedPrice)
+	}
+
+	p = util.LoadQuantParam(util.ETHUSDT)
+	if p.Quantity != 0.01 {
+		t.Errorf("quantity = %v, want 0.01", p.Quantity)
+	}
+}
+
+// 默认在支撑位压力位挂固定止损单,配置了回调比例才用跟踪止损
+func Test_StopCallbackRateDefault(t *testing.T) {
+	util.InitQuantParam()
+	if p := util.LoadQuantParam(util.ETHUSDT); p.StopCallbackRate != 0 {
+		t.Errorf("stop callback rate = %v, want 0", p.StopCallbackRate)
+	}
+	viper.Set("symbols.ethusdt.StopCallbackRate", 1.5)
+	if p := util.LoadQuantParam(util.ETHUSDT); p.StopCallbackRate != 1.5 {
+		t.Errorf("stop callback rate = %v, want 1.5", p.StopCallbackRate)
+	}
+}
@@ -15,7 +15,12 @@ type WxMsg struct {
 	} `json:"text"`
 }
 
+var MsgEnable = true // 是否推送消息,回测时关闭
+
 func SendOrderMsg(content string) error {
+	if !MsgEnable {
+		return nil
+	}
 
 	v := url.Values{}
 	v.Add("title", "重要通知")